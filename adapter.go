@@ -0,0 +1,120 @@
+package signal
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// Adapter relays broadcasts and room emits between the server instances of a cluster.
+// Every node publishes its emits and delivers the ones received from the other nodes to its local clients.
+type Adapter interface {
+	Publish(data []byte) error
+	Subscribe(handler func(data []byte)) error
+	Close() error
+}
+
+// clusterPacket is the envelope exchanged between nodes through the adapter, always encoded as JSON
+// whatever the codec of the clients
+type clusterPacket struct {
+	Node   string `json:"node"`
+	Tenant string `json:"tenant,omitempty"`
+	Room   string `json:"room,omitempty"`
+	Topic  string `json:"topic,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	User   string `json:"user,omitempty"`
+	// Selection is set for the emits of a RoomSelection
+	Selection *selectionPacket `json:"selection,omitempty"`
+	Except    []string         `json:"except,omitempty"`
+	Message   Message          `json:"message"`
+}
+
+// NodeId returns the identifier of this server instance within the cluster
+func (socket *Server) NodeId() string {
+	return socket.nodeId
+}
+
+// publish forwards an emit to the other nodes, an empty roomId means a broadcast.
+// Room emits go through the room owner instead when RoomOwnership is enabled.
+func (socket *Server) publish(tenant, roomId string, except []string, eventName string, payload Payload) {
+	packet := clusterPacket{
+		Tenant:  tenant,
+		Room:    roomId,
+		Except:  except,
+		Message: Message{EventName: eventName, Payload: payload},
+	}
+	if roomId != "" && socket.ownsRooms() {
+		socket.publishRoom(packet)
+		return
+	}
+	socket.publishPacket(packet)
+}
+
+// publishTopic forwards a topic publication to the other nodes
+func (socket *Server) publishTopic(tenant, topic, eventName string, payload Payload) {
+	socket.publishPacket(clusterPacket{
+		Tenant:  tenant,
+		Topic:   topic,
+		Message: Message{EventName: eventName, Payload: payload},
+	})
+}
+
+func (socket *Server) publishPacket(packet clusterPacket) {
+	if socket.adapter == nil {
+		return
+	}
+	packet.Node = socket.nodeId
+	data, err := json.Marshal(packet)
+	if err != nil {
+		log.Printf("Adapter marshal error: %v", err)
+		return
+	}
+	if err := socket.adapter.Publish(data); err != nil {
+		log.Printf("Adapter publish error: %v", err)
+	}
+}
+
+// onClusterPacket delivers an emit published by another node to the local clients
+func (socket *Server) onClusterPacket(data []byte) {
+	var packet clusterPacket
+	if err := json.Unmarshal(data, &packet); err != nil {
+		log.Printf("Adapter unmarshal error: %v", err)
+		return
+	}
+	if packet.Node == socket.nodeId {
+		return
+	}
+	socket.deliverPacket(packet)
+}
+
+// deliverPacket emits a packet of another node to the local clients it addresses
+func (socket *Server) deliverPacket(packet clusterPacket) {
+	if packet.Topic != "" {
+		socket.emitAll(socket.topicClients(packet.Tenant, packet.Topic), packet.Message.EventName, packet.Message.Payload)
+		return
+	}
+	if packet.Selection != nil {
+		socket.onSelectionPacket(packet.Tenant, packet.Selection, packet.Message)
+		return
+	}
+	if packet.User != "" {
+		// remote user emits are not queued, the emitting node queued them already
+		socket.emitAll(socket.userClients(packet.User), packet.Message.EventName, packet.Message.Payload)
+		return
+	}
+	if packet.Tag != "" {
+		socket.emitAll(socket.taggedClients(packet.Tenant, packet.Tag), packet.Message.EventName, packet.Message.Payload)
+		return
+	}
+	if packet.Room == "" {
+		socket.emitAll(socket.broadcastClients(packet.Tenant), packet.Message.EventName, packet.Message.Payload)
+		return
+	}
+	room := socket.lookupRoom(packet.Tenant, packet.Room)
+	if room == nil {
+		message := packet.Message
+		message.Room = packet.Room
+		socket.notifyWatchers(roomKey(packet.Tenant, packet.Room), message)
+		return
+	}
+	room.Except(packet.Except...).emitLocal(packet.Message.EventName, packet.Message.Payload)
+}
@@ -0,0 +1,166 @@
+package signal
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ConnectionInfo describes a connection in the admin API
+type ConnectionInfo struct {
+	ConnectionId string            `json:"connectionId"`
+	Auth         string            `json:"auth"`
+	Tenant       string            `json:"tenant,omitempty"`
+	Query        map[string]string `json:"query"`
+	RemoteAddr   string            `json:"remoteAddr"`
+	IP           string            `json:"ip"`
+	State        string            `json:"state"`
+	Rooms        []string          `json:"rooms"`
+	Tags         []string          `json:"tags,omitempty"`
+	Healthy      bool              `json:"healthy"`
+}
+
+// RoomInfo describes a room in the admin API
+type RoomInfo struct {
+	Id      string `json:"id"`
+	Tenant  string `json:"tenant,omitempty"`
+	Clients int    `json:"clients"`
+}
+
+type adminEmitRequest struct {
+	EventName    string  `json:"eventName"`
+	Payload      Payload `json:"payload"`
+	Room         string  `json:"room,omitempty"`
+	ConnectionId string  `json:"connectionId,omitempty"`
+}
+
+// AdminToken returns an authorizer for AdminHandler accepting requests sent with "Authorization: Bearer <token>"
+func AdminToken(token string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		provided, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return found && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+	}
+}
+
+// AdminHandler returns the introspection API, every request must pass authorize:
+//
+//	GET    /connections       list connections with their auth, query and rooms
+//	GET    /rooms             list rooms with their member count
+//	DELETE /connections/{id}  force-disconnect a connection
+//	POST   /emit              emit {"eventName", "payload"} to a "room", a "connectionId" or everyone
+//	GET    /stats             traffic per event and top talkers, see Stats
+//
+// Mount it under a prefix with http.StripPrefix, preferably on an internal port.
+func (socket *Server) AdminHandler(authorize func(r *http.Request) bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /connections", socket.adminConnections)
+	mux.HandleFunc("GET /rooms", socket.adminRooms)
+	mux.HandleFunc("DELETE /connections/{id}", socket.adminDisconnect)
+	mux.HandleFunc("POST /emit", socket.adminEmit)
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, socket.Stats())
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorize == nil || !authorize(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// clientRooms returns the ids of the rooms the connection is a member of
+func (socket *Server) clientRooms(connectionId string) []string {
+	roomIds := make([]string, 0)
+	for _, room := range socket.roomList() {
+		if room.Has(connectionId) {
+			roomIds = append(roomIds, room.Id)
+		}
+	}
+	sort.Strings(roomIds)
+	return roomIds
+}
+
+// roomList returns a snapshot of the registered rooms
+func (socket *Server) roomList() []*Room {
+	return socket.rooms.list()
+}
+
+func (socket *Server) adminConnections(w http.ResponseWriter, r *http.Request) {
+	connections := make([]ConnectionInfo, 0)
+	for _, client := range socket.Clients() {
+		info := ConnectionInfo{
+			ConnectionId: client.ConnectionId,
+			Auth:         client.Auth,
+			Tenant:       client.Tenant(),
+			Query:        client.Query,
+			IP:           client.IP(),
+			State:        client.State().String(),
+			Rooms:        socket.clientRooms(client.ConnectionId),
+			Tags:         client.Tags(),
+			Healthy:      client.Healthy(),
+		}
+		if client.HTTPRequest != nil {
+			info.RemoteAddr = client.HTTPRequest.RemoteAddr
+		}
+		connections = append(connections, info)
+	}
+	writeJSON(w, http.StatusOK, connections)
+}
+
+func (socket *Server) adminRooms(w http.ResponseWriter, r *http.Request) {
+	rooms := make([]RoomInfo, 0)
+	for _, room := range socket.roomList() {
+		rooms = append(rooms, RoomInfo{Id: room.Id, Tenant: room.Tenant, Clients: room.Len()})
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Id < rooms[j].Id })
+	writeJSON(w, http.StatusOK, rooms)
+}
+
+func (socket *Server) adminDisconnect(w http.ResponseWriter, r *http.Request) {
+	if !socket.Disconnect(r.PathValue("id")) {
+		http.Error(w, "connection not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (socket *Server) adminEmit(w http.ResponseWriter, r *http.Request) {
+	var request adminEmitRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.EventName == "" {
+		http.Error(w, "invalid emit request", http.StatusBadRequest)
+		return
+	}
+
+	var result BroadcastResult
+	switch {
+	case request.ConnectionId != "":
+		client, exists := socket.Client(request.ConnectionId)
+		if !exists {
+			http.Error(w, "connection not found", http.StatusNotFound)
+			return
+		}
+		if err := client.Emit(request.EventName, request.Payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		result.Delivered = 1
+	case request.Room != "":
+		result = socket.EmitTo(request.Room, request.EventName, request.Payload)
+	default:
+		result = socket.Broadcast(request.EventName, request.Payload)
+	}
+	writeJSON(w, http.StatusAccepted, map[string]int{
+		"delivered": result.Delivered,
+		"failed":    result.Failed(),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,72 @@
+package signal
+
+import (
+	"net/http"
+	"time"
+)
+
+// AffinityHeader is the upgrade response header carrying the affinity token, for clients reading it
+// to send it back on their next handshake
+const AffinityHeader = "X-Signal-Affinity"
+
+// AffinityParam is the handshake query parameter clients without cookies send the affinity token in,
+// for load balancers hashing or mapping on it
+const AffinityParam = "affinity"
+
+// Affinity configures the sticky-session token returning the reconnections of a client to the node
+// holding its session outbox, offline queue and room sequences
+type Affinity struct {
+	// Cookie is the name of the cookie set at handshake, "signal_affinity" by default
+	Cookie string
+	// Node is the token identifying this node to the load balancer, the NodeId by default. Set it to a
+	// name surviving restarts, such as the pod name, which the load balancer maps to the node.
+	Node string
+	// MaxAge of the cookie, it lasts for the browser session when zero
+	MaxAge   time.Duration
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+func (affinity Affinity) withDefaults(nodeId string) Affinity {
+	if affinity.Cookie == "" {
+		affinity.Cookie = "signal_affinity"
+	}
+	if affinity.Node == "" {
+		affinity.Node = nodeId
+	}
+	if affinity.Path == "" {
+		affinity.Path = "/"
+	}
+	return affinity
+}
+
+// AffinityToken returns the token load balancers route the reconnections of clients to this node with,
+// empty without WithAffinity
+func (socket *Server) AffinityToken() string {
+	if socket.affinity == nil {
+		return ""
+	}
+	return socket.affinity.withDefaults(socket.nodeId).Node
+}
+
+// setAffinity adds the affinity cookie and header to the upgrade response
+func (socket *Server) setAffinity(header http.Header) {
+	if socket.affinity == nil {
+		return
+	}
+	affinity := socket.affinity.withDefaults(socket.nodeId)
+	cookie := &http.Cookie{
+		Name:     affinity.Cookie,
+		Value:    affinity.Node,
+		Path:     affinity.Path,
+		Domain:   affinity.Domain,
+		MaxAge:   int(affinity.MaxAge.Seconds()),
+		Secure:   affinity.Secure,
+		HttpOnly: true,
+		SameSite: affinity.SameSite,
+	}
+	header.Add("Set-Cookie", cookie.String())
+	header.Set(AffinityHeader, affinity.Node)
+}
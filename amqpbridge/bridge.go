@@ -0,0 +1,287 @@
+// Package amqpbridge connects a signal.io server to an AMQP broker such as RabbitMQ: messages consumed from
+// mapped queues are emitted to rooms, and selected client events are published to exchanges.
+//
+// The package does not depend on an AMQP client, with github.com/rabbitmq/amqp091-go:
+//
+//	type rabbit struct{ *amqp.Channel }
+//
+//	func (r rabbit) Consume(queue string) (<-chan amqpbridge.Delivery, error) {
+//		messages, err := r.Channel.Consume(queue, "", false, false, false, false, nil)
+//		if err != nil {
+//			return nil, err
+//		}
+//		deliveries := make(chan amqpbridge.Delivery)
+//		go func() {
+//			defer close(deliveries)
+//			for msg := range messages {
+//				deliveries <- amqpbridge.Delivery{
+//					Queue: queue, RoutingKey: msg.RoutingKey, ContentType: msg.ContentType, Body: msg.Body,
+//					Ack: func() error { return msg.Ack(false) }, Nack: func(requeue bool) error { return msg.Nack(false, requeue) },
+//				}
+//			}
+//		}()
+//		return deliveries, nil
+//	}
+//
+//	func (r rabbit) Publish(ctx context.Context, exchange, key string, message amqpbridge.Publishing) error {
+//		return r.Channel.PublishWithContext(ctx, exchange, key, false, false,
+//			amqp.Publishing{ContentType: message.ContentType, Headers: message.Headers, Body: message.Body})
+//	}
+package amqpbridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+	"github.com/gorilla/websocket"
+)
+
+// Delivery is a message consumed from a queue, Ack and Nack are nil when the consumer acknowledges on its own
+type Delivery struct {
+	Queue       string
+	RoutingKey  string
+	ContentType string
+	Headers     map[string]any
+	Body        []byte
+	Ack         func() error
+	Nack        func(requeue bool) error
+}
+
+// Publishing is a message published to an exchange
+type Publishing struct {
+	ContentType string
+	Headers     map[string]any
+	Body        []byte
+}
+
+// Consumer consumes the messages of a queue, the channel is closed when the consumer stops
+type Consumer interface {
+	Consume(queue string) (<-chan Delivery, error)
+}
+
+// Publisher publishes messages to exchanges
+type Publisher interface {
+	Publish(ctx context.Context, exchange, routingKey string, message Publishing) error
+}
+
+// Server is the part of the signal.io server used by the bridge
+type Server interface {
+	Broadcast(eventName string, payload signal.Payload) signal.BroadcastResult
+	EmitTo(roomId, eventName string, payload signal.Payload) signal.BroadcastResult
+	OnAny(callback signal.AnyEvent)
+}
+
+// Route tells where the messages of a queue are emitted
+type Route struct {
+	// Room receiving the messages, when empty the routing key is used as room id,
+	// and messages without a routing key are broadcast to every client
+	Room string
+	// Event name emitted to clients, defaults to the queue name
+	Event string
+}
+
+// Target tells where a client event is published
+type Target struct {
+	Exchange string
+	// RoutingKey of the published messages, defaults to the event name
+	RoutingKey string
+}
+
+// Headers set on the messages published for client events
+const (
+	HeaderConnectionId = "signal-connection-id"
+	HeaderUserId       = "signal-user-id"
+)
+
+// Bridge moves events between an AMQP broker and a signal.io server
+type Bridge struct {
+	server    Server
+	consumer  Consumer
+	publisher Publisher
+	codec     signal.Codec
+	routes    map[string]Route
+	targets   map[string]Target
+}
+
+// Option configures the bridge
+type Option func(*Bridge)
+
+// WithConsumer sets the consumer read by Run
+func WithConsumer(consumer Consumer) Option {
+	return func(bridge *Bridge) {
+		bridge.consumer = consumer
+	}
+}
+
+// WithPublisher sets the publisher used to publish client events
+func WithPublisher(publisher Publisher) Option {
+	return func(bridge *Bridge) {
+		bridge.publisher = publisher
+	}
+}
+
+// WithCodec changes how message bodies are decoded and payloads encoded, JSON by default
+func WithCodec(codec signal.Codec) Option {
+	return func(bridge *Bridge) {
+		bridge.codec = codec
+	}
+}
+
+// New creates a bridge, client events are only published once Publish has been called for them
+func New(server Server, options ...Option) *Bridge {
+	bridge := &Bridge{
+		server:  server,
+		codec:   signal.JSONCodec{},
+		routes:  make(map[string]Route),
+		targets: make(map[string]Target),
+	}
+	for _, option := range options {
+		option(bridge)
+	}
+	server.OnAny(bridge.onClientEvent)
+	return bridge
+}
+
+// Route maps the messages consumed from queue to a room event.
+// Routes must be registered before calling Run.
+func (bridge *Bridge) Route(queue string, route Route) *Bridge {
+	bridge.routes[queue] = route
+	return bridge
+}
+
+// Publish forwards the payloads of eventName sent by clients to target.
+// Targets must be registered before the server starts.
+func (bridge *Bridge) Publish(eventName string, target Target) *Bridge {
+	bridge.targets[eventName] = target
+	return bridge
+}
+
+// Run consumes the routed queues until ctx is done or a consumer stops
+func (bridge *Bridge) Run(ctx context.Context) error {
+	if bridge.consumer == nil {
+		return errors.New("amqpbridge: no consumer, see WithConsumer")
+	}
+	if len(bridge.routes) == 0 {
+		return errors.New("amqpbridge: no queue routed, see Route")
+	}
+	consumers, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var failure error
+	for queue := range bridge.routes {
+		deliveries, err := bridge.consumer.Consume(queue)
+		if err != nil {
+			cancel()
+			wg.Wait()
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// a stopped consumer stops the others, Run reports it rather than running half deaf
+			if err := bridge.consume(consumers, queue, deliveries); err != nil {
+				once.Do(func() { failure = err })
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	if failure != nil {
+		return failure
+	}
+	return ctx.Err()
+}
+
+// consume dispatches the deliveries of a queue, it returns when ctx is done or the channel is closed
+func (bridge *Bridge) consume(ctx context.Context, queue string, deliveries <-chan Delivery) error {
+	for {
+		select {
+		case delivery, ok := <-deliveries:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("amqpbridge: consumer of %s stopped", queue)
+			}
+			if delivery.Queue == "" {
+				delivery.Queue = queue
+			}
+			bridge.dispatch(delivery)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (bridge *Bridge) dispatch(delivery Delivery) {
+	route, exists := bridge.routes[delivery.Queue]
+	if !exists {
+		if delivery.Nack != nil {
+			delivery.Nack(false)
+		}
+		return
+	}
+
+	eventName := route.Event
+	if eventName == "" {
+		eventName = delivery.Queue
+	}
+
+	var payload signal.Payload
+	if err := bridge.codec.Unmarshal(delivery.Body, &payload); err != nil {
+		// not encoded with the codec, hand the raw body to clients
+		payload = string(delivery.Body)
+	}
+
+	roomId := route.Room
+	if roomId == "" {
+		roomId = delivery.RoutingKey
+	}
+	if roomId == "" {
+		bridge.server.Broadcast(eventName, payload)
+	} else {
+		bridge.server.EmitTo(roomId, eventName, payload)
+	}
+	if delivery.Ack != nil {
+		if err := delivery.Ack(); err != nil {
+			log.Printf("amqpbridge: ack error: %v", err)
+		}
+	}
+}
+
+func (bridge *Bridge) onClientEvent(eventName string, payload signal.Payload, client *signal.Client) {
+	target, exists := bridge.targets[eventName]
+	if !exists || bridge.publisher == nil {
+		return
+	}
+
+	body, err := bridge.codec.Marshal(payload)
+	if err != nil {
+		log.Printf("amqpbridge: marshal error: %v", err)
+		return
+	}
+	routingKey := target.RoutingKey
+	if routingKey == "" {
+		routingKey = eventName
+	}
+	message := Publishing{
+		ContentType: "application/json",
+		Headers:     map[string]any{HeaderConnectionId: client.ConnectionId},
+		Body:        body,
+	}
+	if signal.FrameType(bridge.codec) == websocket.BinaryMessage {
+		message.ContentType = "application/octet-stream"
+	}
+	if userId := client.UserId(); userId != "" {
+		message.Headers[HeaderUserId] = userId
+	}
+	if err := bridge.publisher.Publish(context.Background(), target.Exchange, routingKey, message); err != nil {
+		log.Printf("amqpbridge: publish error: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package signal
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// AsyncAPIInfo is the info object of the generated AsyncAPI document
+type AsyncAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// AsyncAPIDocument is an AsyncAPI 2.6 description of the event catalog.
+// Channels are named after events, "publish" operations are sent by clients and "subscribe" ones by the server.
+type AsyncAPIDocument struct {
+	AsyncAPI string                     `json:"asyncapi"`
+	Info     AsyncAPIInfo               `json:"info"`
+	Channels map[string]AsyncAPIChannel `json:"channels"`
+}
+
+// AsyncAPIChannel describes the operations of an event
+type AsyncAPIChannel struct {
+	Publish   *AsyncAPIOperation `json:"publish,omitempty"`
+	Subscribe *AsyncAPIOperation `json:"subscribe,omitempty"`
+}
+
+// AsyncAPIOperation describes the message of an event in one direction
+type AsyncAPIOperation struct {
+	Summary string          `json:"summary,omitempty"`
+	Message AsyncAPIMessage `json:"message"`
+}
+
+// AsyncAPIMessage holds the JSON Schema of an event payload
+type AsyncAPIMessage struct {
+	Name    string         `json:"name"`
+	Payload map[string]any `json:"payload"`
+}
+
+// AsyncAPI describes the event catalog, see Events
+func (socket *Server) AsyncAPI(info AsyncAPIInfo) *AsyncAPIDocument {
+	doc := &AsyncAPIDocument{
+		AsyncAPI: "2.6.0",
+		Info:     info,
+		Channels: make(map[string]AsyncAPIChannel),
+	}
+	for _, spec := range socket.Events() {
+		operation := &AsyncAPIOperation{
+			Summary: spec.Summary,
+			Message: AsyncAPIMessage{Name: spec.Name, Payload: jsonSchema(spec.PayloadType, make(map[reflect.Type]bool))},
+		}
+		channel := doc.Channels[spec.Name]
+		if spec.Direction == Outbound {
+			channel.Subscribe = operation
+		} else {
+			channel.Publish = operation
+		}
+		doc.Channels[spec.Name] = channel
+	}
+	return doc
+}
+
+// WriteFile writes the document as indented JSON
+func (doc *AsyncAPIDocument) WriteFile(path string) error {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// AsyncAPIHandler serves the AsyncAPI document of the event catalog, generated on every request
+func (socket *Server) AsyncAPIHandler(info AsyncAPIInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, socket.AsyncAPI(info))
+	})
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// jsonSchema describes how encoding/json encodes values of type t, an empty schema accepting anything when t is nil.
+// Recursive types are cut at the second occurrence.
+func jsonSchema(t reflect.Type, visiting map[reflect.Type]bool) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return map[string]any{"type": "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := make(map[string]any)
+		required := make([]string, 0)
+		for _, field := range jsonFields(t) {
+			properties[field.name] = jsonSchema(field.typ, visiting)
+			if !field.optional {
+				required = append(required, field.name)
+			}
+		}
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]any{}
+}
+
+type jsonField struct {
+	name     string
+	typ      reflect.Type
+	optional bool
+}
+
+// jsonFields lists the fields encoding/json encodes for struct type t, embedded structs flattened
+func jsonFields(t reflect.Type) []jsonField {
+	fields := make([]jsonField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				fields = append(fields, jsonFields(embedded)...)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, jsonField{
+			name:     name,
+			typ:      field.Type,
+			optional: strings.Contains(options, "omitempty") || field.Type.Kind() == reflect.Pointer,
+		})
+	}
+	return fields
+}
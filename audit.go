@@ -0,0 +1,159 @@
+package signal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Audit record types
+const (
+	AuditConnect    = "connect"
+	AuditDisconnect = "disconnect"
+	AuditRejected   = "rejected"
+	AuditJoin       = "join"
+	AuditLeave      = "leave"
+)
+
+// auditQueueSize is the number of records buffered before auditing slows the server down
+const auditQueueSize = 1024
+
+// AuditRecord is an entry of the audit log
+type AuditRecord struct {
+	Time         time.Time `json:"time"`
+	Type         string    `json:"type"`
+	ConnectionId string    `json:"connectionId"`
+	UserId       string    `json:"userId,omitempty"`
+	Tenant       string    `json:"tenant,omitempty"`
+	IP           string    `json:"ip,omitempty"`
+	Room         string    `json:"room,omitempty"`
+	// Reason explains disconnects and rejected handshakes
+	Reason string `json:"reason,omitempty"`
+}
+
+// AuditSink stores audit records, see WithAuditSink
+type AuditSink interface {
+	Record(record AuditRecord) error
+}
+
+// AuditSinkFunc adapts a function to AuditSink
+type AuditSinkFunc func(record AuditRecord) error
+
+func (record AuditSinkFunc) Record(r AuditRecord) error {
+	return record(r)
+}
+
+type writerSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewWriterAuditSink writes the records to w as JSON lines, e.g. to an append-only file
+func NewWriterAuditSink(w io.Writer) AuditSink {
+	return &writerSink{encoder: json.NewEncoder(w)}
+}
+
+func (sink *writerSink) Record(record AuditRecord) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return sink.encoder.Encode(record)
+}
+
+// NewWebhookAuditSink posts every record as JSON to url, a nil client uses http.DefaultClient
+func NewWebhookAuditSink(url string, client *http.Client) AuditSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return AuditSinkFunc(func(record AuditRecord) error {
+		body, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		response, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+		if response.StatusCode >= 300 {
+			return fmt.Errorf("audit webhook answered %s", response.Status)
+		}
+		return nil
+	})
+}
+
+// auditor hands the records to the sink from its own goroutine, in order
+type auditor struct {
+	sink    AuditSink
+	records chan AuditRecord
+	done    chan struct{}
+
+	// mu keeps close from closing records while a record is being queued
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newAuditor(sink AuditSink) *auditor {
+	auditor := &auditor{
+		sink:    sink,
+		records: make(chan AuditRecord, auditQueueSize),
+		done:    make(chan struct{}),
+	}
+	go auditor.run()
+	return auditor
+}
+
+func (auditor *auditor) run() {
+	defer close(auditor.done)
+	for record := range auditor.records {
+		if err := auditor.sink.Record(record); err != nil {
+			log.Printf("Audit sink error: %v", err)
+		}
+	}
+}
+
+// queue hands a record to the sink goroutine, records arriving after close are dropped
+func (auditor *auditor) queue(record AuditRecord) {
+	auditor.mu.RLock()
+	defer auditor.mu.RUnlock()
+	if auditor.closed {
+		log.Printf("Audit record dropped after shutdown: %s %s", record.Type, record.ConnectionId)
+		return
+	}
+	auditor.records <- record
+}
+
+// close waits until the queued records are stored
+func (auditor *auditor) close() {
+	auditor.mu.Lock()
+	if !auditor.closed {
+		auditor.closed = true
+		close(auditor.records)
+	}
+	auditor.mu.Unlock()
+	<-auditor.done
+}
+
+// audit queues a record about client, a full queue blocks rather than losing records
+func (socket *Server) audit(recordType string, client *Client, room string, reason error) {
+	if socket.auditor == nil {
+		return
+	}
+	record := AuditRecord{
+		Time:         time.Now().UTC(),
+		Type:         recordType,
+		ConnectionId: client.ConnectionId,
+		UserId:       client.UserId(),
+		Tenant:       client.Tenant(),
+		IP:           client.IP(),
+		Room:         room,
+	}
+	if reason != nil {
+		record.Reason = reason.Error()
+	}
+	socket.auditor.queue(record)
+}
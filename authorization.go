@@ -0,0 +1,93 @@
+package signal
+
+import (
+	"strings"
+	"sync"
+)
+
+// rule requires one of the roles for the events matching pattern
+type rule struct {
+	pattern string
+	roles   []string
+}
+
+type authorizer struct {
+	mu    sync.RWMutex
+	rules []rule
+}
+
+// Require restricts the events matching pattern to clients holding at least one of the roles or permissions.
+// A pattern ending with "*" matches every event starting with the rest of it, "admin:*" covers "admin:kick".
+// Events matching several patterns must satisfy all of them.
+func (socket *Server) Require(pattern string, roles ...string) {
+	socket.authorizer.mu.Lock()
+	defer socket.authorizer.mu.Unlock()
+	socket.authorizer.rules = append(socket.authorizer.rules, rule{pattern: pattern, roles: roles})
+}
+
+func matchEvent(pattern, eventName string) bool {
+	if prefix, found := strings.CutSuffix(pattern, "*"); found {
+		return strings.HasPrefix(eventName, prefix)
+	}
+	return pattern == eventName
+}
+
+// authorize reports whether the client may send eventName
+func (socket *Server) authorize(eventName string, client *Client) bool {
+	socket.authorizer.mu.RLock()
+	defer socket.authorizer.mu.RUnlock()
+
+	var granted []string
+	resolved := false
+	for _, rule := range socket.authorizer.rules {
+		if !matchEvent(rule.pattern, eventName) {
+			continue
+		}
+		if !resolved {
+			granted = socket.roles(client)
+			resolved = true
+		}
+		if !hasAnyRole(granted, rule.roles) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasAnyRole(granted, required []string) bool {
+	for _, role := range required {
+		if contains(granted, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (socket *Server) roles(client *Client) []string {
+	if socket.roleResolver != nil {
+		return socket.roleResolver(client)
+	}
+	return ClaimRoles(client)
+}
+
+// ClaimRoles is the default role resolver, it reads the "roles" and "permissions" claims,
+// as arrays or space separated strings, and the "scope" claim
+func ClaimRoles(client *Client) []string {
+	claims := client.Claims()
+	roles := make([]string, 0)
+	for _, key := range []string{"roles", "permissions", "scope"} {
+		switch value := claims[key].(type) {
+		case string:
+			roles = append(roles, strings.Fields(value)...)
+		case []string:
+			roles = append(roles, value...)
+		case []any:
+			for _, role := range value {
+				if role, ok := role.(string); ok {
+					roles = append(roles, role)
+				}
+			}
+		}
+	}
+	return roles
+}
@@ -0,0 +1,229 @@
+package signal
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// BatchEvent is the event of the frames packing several messages, its payload is the list of messages.
+// Clients unpack them with Unbatch and handle every message as if it had its own frame.
+const BatchEvent = "batch"
+
+// maxCoalesced flushes the coalesced messages of a client before the window ends once that many are pending
+const maxCoalesced = 256
+
+// Unbatch returns the messages packed in a BatchEvent frame, or the message itself for any other event
+func Unbatch(message Message) ([]Message, error) {
+	if message.EventName != BatchEvent {
+		return []Message{message}, nil
+	}
+	var messages []Message
+	if err := Bind(message.Payload, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// EmitBatch sends the messages to the client in a single frame, in order. Messages skipped by an outbound
+// transformer are left out, an empty batch is not sent. With WithCoalescing, the messages coalesced before
+// the batch are written first, and a batch emitted while another goroutine flushes them joins the pending
+// messages, its write failures being reported to the dead-letter sink.
+func (client *Client) EmitBatch(messages []Message) error {
+	batch := make([]Message, 0, len(messages))
+	for _, message := range messages {
+		transformed, err := client.transform(message)
+		if errors.Is(err, ErrSkipEmit) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		batch = append(batch, transformed)
+	}
+	if client.coalesces() && client.state != nil {
+		// messages coalesced before the batch are written first, to keep the emit order
+		return client.flush(batch)
+	}
+	err := client.writeBatch(batch, client.writeTimeout())
+	client.countWritten(batch, err)
+	return err
+}
+
+// countWritten counts the messages of a frame as sent, or failed when its write failed
+func (client *Client) countWritten(frame []Message, err error) {
+	for _, message := range frame {
+		if err == nil {
+			client.server().countSent(message.EventName, 1, 0)
+			client.server().emitPlugins(message.EventName, message.Payload, 1)
+		} else {
+			client.server().countSent(message.EventName, 0, 1)
+		}
+	}
+}
+
+// writeBatch encodes the transformed messages into one frame, a single message is sent as is
+func (client *Client) writeBatch(batch []Message, timeout time.Duration) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	frame := batch[0]
+	if len(batch) > 1 {
+		frame = Message{EventName: BatchEvent, Payload: batch}
+	}
+	buf, err := encodeMessage(client.codec(), frame)
+	if err != nil {
+		log.Printf("Marshal error: %v", err)
+		return err
+	}
+	defer putBuffer(buf)
+	return client.writeWithTimeout(buf.Bytes(), timeout)
+}
+
+// coalescer holds the messages of a client waiting for the end of the coalescing window
+type coalescer struct {
+	mu      sync.Mutex
+	pending []Message
+	// lanes holds the priority of each pending message
+	lanes []Priority
+	// conflated indexes the pending messages of conflated events by key, see Conflate
+	conflated map[string]int
+	timer     *time.Timer
+	// flushing is set while a flush writes, the messages enqueued and the batches emitted meanwhile are
+	// written by that flush after its frame, so frames stay in order without holding mu during the write
+	flushing bool
+}
+
+// coalesces reports whether the emits to the client are coalesced, see WithCoalescing
+func (client *Client) coalesces() bool {
+	server := client.server()
+	return server != nil && server.coalesceWindow > 0
+}
+
+// enqueue adds a transformed message to the pending batch, the first message of a batch starts the window
+func (client *Client) enqueue(message Message) {
+	key, conflated := client.server().conflationKey(message)
+	lane := client.server().priority(message.EventName)
+	batch := &client.state.coalesced
+	batch.mu.Lock()
+	if conflated {
+		if index, exists := batch.conflated[key]; exists {
+			batch.pending[index] = message
+			batch.mu.Unlock()
+			return
+		}
+		if batch.conflated == nil {
+			batch.conflated = make(map[string]int)
+		}
+		batch.conflated[key] = len(batch.pending)
+	}
+	batch.pending = append(batch.pending, message)
+	batch.lanes = append(batch.lanes, lane)
+	flush := len(batch.pending) >= maxCoalesced || lane == PriorityHigh
+	if len(batch.pending) == 1 && !flush {
+		batch.timer = time.AfterFunc(client.server().coalesceWindow, client.flushCoalesced)
+	}
+	batch.mu.Unlock()
+	if flush {
+		client.flushCoalesced()
+	}
+}
+
+// flushCoalesced writes the pending messages in one frame, by priority, the ones that cannot be written are dead letters.
+// The write and the dead letters happen outside batch.mu, so emits to the client never wait for them and the
+// dead-letter sink and bus listeners may emit to the client again.
+func (client *Client) flushCoalesced() {
+	client.flush(nil)
+}
+
+// flush writes the pending messages then the frame of the emitted batch, if any, and returns the error of
+// that frame. When another flush is writing, the batch joins the pending messages it writes next.
+func (client *Client) flush(emitted []Message) error {
+	if client.state == nil {
+		return nil
+	}
+	batch := &client.state.coalesced
+	batch.mu.Lock()
+	if batch.flushing {
+		for _, message := range emitted {
+			batch.pending = append(batch.pending, message)
+			batch.lanes = append(batch.lanes, client.server().priority(message.EventName))
+		}
+		batch.mu.Unlock()
+		return nil
+	}
+	batch.flushing = true
+	defer func() {
+		batch.flushing = false
+		batch.mu.Unlock()
+	}()
+
+	var emitErr error
+	for {
+		var frame []Message
+		isBatch := false
+		if len(batch.pending) > 0 {
+			frame = byPriority(batch.pending, batch.lanes)
+			batch.pending, batch.lanes = nil, nil
+			clear(batch.conflated)
+			if batch.timer != nil {
+				batch.timer.Stop()
+				batch.timer = nil
+			}
+		} else if len(emitted) > 0 {
+			// the batch is written while flushing is set, so the messages enqueued meanwhile follow it
+			frame, emitted, isBatch = emitted, nil, true
+		} else {
+			return emitErr
+		}
+		batch.mu.Unlock()
+
+		err := client.writeBatch(frame, client.writeTimeout())
+		client.countWritten(frame, err)
+		if err != nil && !isBatch {
+			for _, message := range frame {
+				client.server().deadLetter(DeadLetter{
+					Message:      message,
+					ConnectionId: client.ConnectionId,
+					UserId:       client.UserId(),
+					Reason:       err,
+				})
+			}
+		}
+
+		batch.mu.Lock()
+		if isBatch {
+			emitErr = err
+		}
+		if err != nil {
+			if len(emitted) > 0 {
+				// the batch is not written after a failed write
+				client.countWritten(emitted, err)
+				emitErr = err
+			}
+			// the messages enqueued by the failed write wait for the next window rather than failing in a loop
+			if len(batch.pending) > 0 && batch.timer == nil {
+				batch.timer = time.AfterFunc(client.server().coalesceWindow, client.flushCoalesced)
+			}
+			return emitErr
+		}
+	}
+}
+
+// emitCoalesced transforms the message for every client and adds it to their pending batch
+func (socket *Server) emitCoalesced(clients []*Client, message Message, result *BroadcastResult) {
+	for _, client := range clients {
+		transformed, err := client.transform(message)
+		if errors.Is(err, ErrSkipEmit) {
+			continue
+		}
+		if err != nil {
+			result.fail(client.ConnectionId, err)
+			continue
+		}
+		// counted as queued, the write is counted in the stats once flushed
+		client.enqueue(transformed)
+		result.Delivered++
+	}
+}
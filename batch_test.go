@@ -0,0 +1,109 @@
+package signal
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestFlushReentrantEmit emits to a client from the dead-letter sink of its own failed flush
+func TestFlushReentrantEmit(t *testing.T) {
+	var letters atomic.Int32
+	var client *Client
+	socket := IOServer("0",
+		WithCoalescing(10*time.Millisecond),
+		WithDeadLetters(DeadLetterFunc(func(letter DeadLetter) {
+			if letters.Add(1) == 1 {
+				client.Emit("alert", "write failed")
+			}
+		})),
+	)
+	socket.SetPriority("alert", PriorityHigh)
+	client = connectClients(t, socket, 1)[0]
+	socket.SetChaos(&Chaos{DisconnectRate: 1})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.Emit("alert", "first")
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the emit of the dead-letter sink deadlocked the flush")
+	}
+	if letters.Load() == 0 {
+		t.Fatal("the failed flush reported no dead letter")
+	}
+}
+
+// TestEmitBatchDuringFlush emits a batch while another goroutine writes the coalesced messages, the batch
+// must follow the messages coalesced before it
+func TestEmitBatchDuringFlush(t *testing.T) {
+	socket := IOServer("0", WithCoalescing(time.Hour))
+	socket.SetPriority("first", PriorityHigh)
+	server := httptest.NewServer(socket)
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitClients(t, socket, 1)
+	client := socket.Clients()[0]
+
+	// every write sleeps, so the flush of the first emit is in flight during the batch
+	socket.SetChaos(&Chaos{Latency: 100 * time.Millisecond, LatencyRate: 1, Random: func() float64 { return 0.5 }})
+	go client.Emit("first", nil)
+	time.Sleep(20 * time.Millisecond)
+	client.Emit("second", nil)
+	if err := client.EmitBatch([]Message{{EventName: "third"}, {EventName: "fourth"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for len(events) < 4 {
+		var frame Message
+		if err := conn.ReadJSON(&frame); err != nil {
+			t.Fatalf("after %v: %v", events, err)
+		}
+		messages, err := Unbatch(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, message := range messages {
+			events = append(events, message.EventName)
+		}
+	}
+	if strings.Join(events, ",") != "first,second,third,fourth" {
+		t.Fatalf("received %v", events)
+	}
+}
+
+// TestCoalescedStatsCountWrites checks that coalesced messages whose write fails are not counted as sent
+func TestCoalescedStatsCountWrites(t *testing.T) {
+	socket := IOServer("0", WithCoalescing(10*time.Millisecond))
+	socket.SetPriority("alert", PriorityHigh)
+	client := connectClients(t, socket, 1)[0]
+	socket.SetChaos(&Chaos{DisconnectRate: 1})
+
+	result := socket.Broadcast("alert", nil)
+	if result.Delivered != 1 {
+		t.Fatalf("queued for %d clients, want 1", result.Delivered)
+	}
+	client.Emit("alert", nil)
+	for _, event := range socket.Stats().Events {
+		if event.Event == "alert" {
+			if event.Sent != 0 || event.Failed != 2 {
+				t.Fatalf("alert sent %d and failed %d times, want 0 and 2", event.Sent, event.Failed)
+			}
+			return
+		}
+	}
+	t.Fatal("the failed writes are not counted")
+}
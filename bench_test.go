@@ -0,0 +1,58 @@
+package signal
+
+import (
+	"strconv"
+	"testing"
+)
+
+type benchPayload struct {
+	User string   `json:"user"`
+	Text string   `json:"text"`
+	Tags []string `json:"tags"`
+	Seq  int      `json:"seq"`
+}
+
+var benchMessage = Message{
+	Id:        "7f3c9a1e",
+	EventName: "chat:message",
+	Payload:   benchPayload{User: "alice", Text: "hello, how is everyone doing today?", Tags: []string{"greeting", "lobby"}, Seq: 42},
+}
+
+// BenchmarkBroadcast writes an event to every connection, encoded once for all of them
+func BenchmarkBroadcast(b *testing.B) {
+	for _, clients := range []int{1, 16, 128} {
+		b.Run(strconv.Itoa(clients), func(b *testing.B) {
+			socket := IOServer("0")
+			connectClients(b, socket, clients)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if result := socket.Broadcast(benchMessage.EventName, benchMessage.Payload); result.Delivered != clients {
+					b.Fatalf("delivered to %d of %d clients", result.Delivered, clients)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkEncodeMessage encodes a message into a pooled buffer with the built-in codecs
+func BenchmarkEncodeMessage(b *testing.B) {
+	for _, bench := range []struct {
+		name  string
+		codec Codec
+	}{
+		{"json", JSONCodec{}},
+		{"cbor", CBORCodec{}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf, err := encodeMessage(bench.codec, benchMessage)
+				if err != nil {
+					b.Fatal(err)
+				}
+				putBuffer(buf)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package signal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Bind converts a decoded payload into out, which must be a non-nil pointer, typically to a struct.
+// Payloads already holding the target type are assigned without conversion, raw JSON is decoded
+// directly, anything else goes through a single JSON round-trip.
+// Conversion failures are returned as an *Error with CodeInvalidPayload naming the offending field,
+// so handlers can pass them to client.EmitError as is.
+func Bind(payload Payload, out any) error {
+	target := reflect.ValueOf(out)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("bind: out must be a non-nil pointer, got %T", out)
+	}
+	if payload == nil {
+		return NewError(CodeInvalidPayload, "payload is missing")
+	}
+
+	value := reflect.ValueOf(payload)
+	if value.Type().AssignableTo(target.Elem().Type()) {
+		target.Elem().Set(value)
+		return nil
+	}
+
+	var data []byte
+	switch raw := payload.(type) {
+	case json.RawMessage:
+		data = raw
+	case []byte:
+		data = raw
+	default:
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return NewError(CodeInvalidPayload, err.Error())
+		}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return bindError(err)
+	}
+	return nil
+}
+
+func bindError(err error) *Error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Field != "" {
+			return NewError(CodeInvalidPayload, fmt.Sprintf("%s: expected %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value))
+		}
+		return NewError(CodeInvalidPayload, fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value))
+	}
+	return NewError(CodeInvalidPayload, err.Error())
+}
+
+// Typed adapts a handler taking its payload as T, binding every payload with Bind.
+// Payloads failing to bind are reported to the client with EmitError and the handler is not called.
+//
+//	socket.On("chat:message", signal.Typed(func(msg *chatpb.Message, client *signal.Client) {
+//		...
+//	}))
+func Typed[T any](handler func(payload T, client *Client)) Event {
+	return func(payload Payload, client *Client) {
+		var value T
+		if err := Bind(payload, &value); err != nil {
+			client.EmitError(err)
+			return
+		}
+		handler(value, client)
+	}
+}
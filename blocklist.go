@@ -0,0 +1,147 @@
+package signal
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrBlocked is returned when a handshake comes from a blocked IP or token
+var ErrBlocked = errors.New("blocked")
+
+// Kinds of blocklist entries
+const (
+	BlockKindIP   = "ip"
+	BlockKindAuth = "auth"
+)
+
+// BlocklistStore keeps the blocked IPs and tokens. Shared stores (Redis, a database...) propagate bans
+// across replicas by calling the Watch callback when another node changes an entry.
+type BlocklistStore interface {
+	Add(kind, value string) error
+	Remove(kind, value string) error
+	Contains(kind, value string) (bool, error)
+	Watch(onChange func(kind, value string, blocked bool)) error
+}
+
+// MemoryBlocklist is the default in-process BlocklistStore
+type MemoryBlocklist struct {
+	mu      sync.RWMutex
+	entries map[string]bool
+}
+
+// NewMemoryBlocklist creates an empty in-process blocklist
+func NewMemoryBlocklist() *MemoryBlocklist {
+	return &MemoryBlocklist{entries: make(map[string]bool)}
+}
+
+func (store *MemoryBlocklist) Add(kind, value string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.entries[kind+":"+value] = true
+	return nil
+}
+
+func (store *MemoryBlocklist) Remove(kind, value string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.entries, kind+":"+value)
+	return nil
+}
+
+func (store *MemoryBlocklist) Contains(kind, value string) (bool, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	return store.entries[kind+":"+value], nil
+}
+
+// Watch is a no-op, a process-local store is never changed by other nodes
+func (store *MemoryBlocklist) Watch(func(kind, value string, blocked bool)) error {
+	return nil
+}
+
+// Block refuses new connections from ip and disconnects the current ones
+func (socket *Server) Block(ip string) error {
+	if err := socket.blocklist.Add(BlockKindIP, ip); err != nil {
+		return err
+	}
+	socket.enforceBlock(BlockKindIP, ip)
+	return nil
+}
+
+// Unblock accepts connections from ip again
+func (socket *Server) Unblock(ip string) error {
+	return socket.blocklist.Remove(BlockKindIP, ip)
+}
+
+// BlockAuth refuses new connections presenting token and disconnects the current ones
+func (socket *Server) BlockAuth(token string) error {
+	if err := socket.blocklist.Add(BlockKindAuth, token); err != nil {
+		return err
+	}
+	socket.enforceBlock(BlockKindAuth, token)
+	return nil
+}
+
+// UnblockAuth accepts connections presenting token again
+func (socket *Server) UnblockAuth(token string) error {
+	return socket.blocklist.Remove(BlockKindAuth, token)
+}
+
+// onBlocklistChange applies the bans made on other nodes
+func (socket *Server) onBlocklistChange(kind, value string, blocked bool) {
+	if blocked {
+		socket.enforceBlock(kind, value)
+	}
+}
+
+// enforceBlock disconnects the local clients matching a new entry
+func (socket *Server) enforceBlock(kind, value string) {
+	for _, client := range socket.Clients() {
+		if (kind == BlockKindIP && client.IP() == value) || (kind == BlockKindAuth && contains(clientTokens(client), value)) {
+			client.closeWith(DisconnectBlocked, websocket.ClosePolicyViolation, ErrBlocked)
+		}
+	}
+}
+
+// clientTokens returns the credentials presented by the client, in the auth query param or as a bearer token
+func clientTokens(client *Client) []string {
+	tokens := make([]string, 0, 2)
+	if client.Auth != "" {
+		tokens = append(tokens, client.Auth)
+	}
+	if client.HTTPRequest != nil {
+		if bearer, found := strings.CutPrefix(client.HTTPRequest.Header.Get("Authorization"), "Bearer "); found {
+			tokens = append(tokens, bearer)
+		}
+	}
+	return tokens
+}
+
+// checkBlocklist returns ErrBlocked when the handshake comes from a blocked IP or token.
+// Store failures are logged and let the handshake through.
+func (socket *Server) checkBlocklist(client *Client) error {
+	entries := [][2]string{{BlockKindIP, client.IP()}}
+	for _, token := range clientTokens(client) {
+		entries = append(entries, [2]string{BlockKindAuth, token})
+	}
+	for _, entry := range entries {
+		blocked, err := socket.blocklist.Contains(entry[0], entry[1])
+		if err != nil {
+			log.Printf("Blocklist error: %v", err)
+			continue
+		}
+		if blocked {
+			return ErrBlocked
+		}
+	}
+	return nil
+}
+
+func rejectBlocked(w http.ResponseWriter) {
+	rejectHandshake(w, http.StatusForbidden, ErrBlocked)
+}
@@ -0,0 +1,65 @@
+package signal
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrCircuitOpen is returned by the emits to a client whose breaker tripped, no write is attempted
+var ErrCircuitOpen = errors.New("circuit open: client failed too many writes")
+
+// CircuitBreaker stops writing to clients failing consecutive writes
+type CircuitBreaker struct {
+	// Threshold is the number of consecutive failed writes tripping the breaker, 5 by default
+	Threshold int
+	// DisconnectAfter is the delay before a tripped client is disconnected, 1 second by default
+	DisconnectAfter time.Duration
+}
+
+func (breaker CircuitBreaker) withDefaults() CircuitBreaker {
+	if breaker.Threshold <= 0 {
+		breaker.Threshold = 5
+	}
+	if breaker.DisconnectAfter <= 0 {
+		breaker.DisconnectAfter = time.Second
+	}
+	return breaker
+}
+
+// Healthy reports whether writes reach the client, it is false once its circuit breaker tripped
+func (client *Client) Healthy() bool {
+	if client.state == nil {
+		return true
+	}
+	return !client.state.tripped.Load()
+}
+
+// circuitOpen reports whether writes to the client are skipped
+func (client *Client) circuitOpen() bool {
+	server := client.server()
+	return server != nil && server.breaker != nil && !client.Healthy()
+}
+
+// recordWrite counts the consecutive failed writes and trips the breaker at the threshold
+func (client *Client) recordWrite(err error) {
+	server := client.server()
+	if server == nil || server.breaker == nil {
+		return
+	}
+	if err == nil {
+		client.state.failures.Store(0)
+		return
+	}
+	if int(client.state.failures.Add(1)) < server.breaker.Threshold || !client.state.tripped.CompareAndSwap(false, true) {
+		return
+	}
+
+	log.Printf("Circuit open: %s failed %d writes", client.ConnectionId, server.breaker.Threshold)
+	client.bus().Emit(InternalEvent{Name: InternalCircuitOpen, Client: client, Err: err})
+	server.scheduler.schedule(time.Now().Add(server.breaker.DisconnectAfter), func() {
+		client.closeWith(DisconnectUnhealthy, websocket.CloseGoingAway, ErrCircuitOpen)
+	})
+}
@@ -0,0 +1,89 @@
+package signal
+
+import (
+	"sync"
+	"time"
+)
+
+// Internal events published on the server bus, see Internal
+const (
+	// InternalRoomCreated is a room registered, Room is set
+	InternalRoomCreated = "room:created"
+	// InternalRoomClosed is a room unregistered, emptied, closed or expired, Room is set
+	InternalRoomClosed = "room:closed"
+	// InternalSlowConsumer is a write to Client that timed out, Err is set
+	InternalSlowConsumer = "slow_consumer"
+	// InternalHandlerPanic is a listener that panicked, Event is set, Err holds the panic value and Data the stack
+	InternalHandlerPanic = "handler_panic"
+	// InternalHandlerTimeout is a listener that ran past its timeout, Event is set and Data holds the time.Duration
+	InternalHandlerTimeout = "handler_timeout"
+	// InternalCircuitOpen is a Client whose circuit breaker tripped, Err holds the last write error
+	InternalCircuitOpen = "circuit_open"
+	// InternalStats is the periodic traffic summary of WithStatsSummary, Data holds the Stats
+	InternalStats = "stats"
+	// InternalLeaderChanged is this node gaining or losing the cluster leadership, Data holds the new IsLeader
+	InternalLeaderChanged = "leader_changed"
+)
+
+// InternalEvent is an operational event of the server, fields not related to the event are left empty
+type InternalEvent struct {
+	Name   string
+	Time   time.Time
+	Client *Client
+	Room   *Room
+	// Event is the client event involved
+	Event string
+	Err   error
+	Data  any
+}
+
+// Bus dispatches internal events to their listeners, separately from the events sent by clients
+type Bus struct {
+	mu        sync.RWMutex
+	listeners map[string][]func(event InternalEvent)
+}
+
+// Internal returns the bus of the server internal events
+func (socket *Server) Internal() *Bus {
+	return socket.bus
+}
+
+// On registers a listener for the internal event name, "*" receives every event
+func (bus *Bus) On(name string, listener func(event InternalEvent)) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.listeners == nil {
+		bus.listeners = make(map[string][]func(event InternalEvent))
+	}
+	bus.listeners[name] = append(bus.listeners[name], listener)
+}
+
+// Emit calls the listeners of the event synchronously, Time defaults to now
+func (bus *Bus) Emit(event InternalEvent) {
+	if bus == nil {
+		return
+	}
+	bus.mu.RLock()
+	named, all := bus.listeners[event.Name], bus.listeners["*"]
+	bus.mu.RUnlock()
+	if len(named)+len(all) == 0 {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, listener := range named {
+		listener(event)
+	}
+	for _, listener := range all {
+		listener(event)
+	}
+}
+
+// bus returns the bus of the client's server, nil for clients not created by a server
+func (client *Client) bus() *Bus {
+	if server := client.server(); server != nil {
+		return server.bus
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package signal
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Direction tells which side of the connection sends an event
+type Direction string
+
+const (
+	// Inbound events are sent by clients to the server
+	Inbound Direction = "inbound"
+	// Outbound events are sent by the server to clients
+	Outbound Direction = "outbound"
+)
+
+// EventDoc documents an event of the realtime contract, see Describe
+type EventDoc struct {
+	Summary   string
+	Direction Direction
+	// Payload is a sample value of the payload type, e.g. Order{}
+	Payload any
+}
+
+// EventSpec is an event of the catalog, its PayloadType is nil when unknown
+type EventSpec struct {
+	Name        string
+	Summary     string
+	Direction   Direction
+	PayloadType reflect.Type
+}
+
+// Describe documents eventName for the event catalog. Events with a listener are listed as inbound
+// even when not described, with the payload type of their Schema validator when they have one.
+func (socket *Server) Describe(eventName string, doc EventDoc) {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	if socket.eventDocs == nil {
+		socket.eventDocs = make(map[string][]EventDoc)
+	}
+	if doc.Direction == "" {
+		doc.Direction = Inbound
+	}
+	socket.eventDocs[eventName] = append(socket.eventDocs[eventName], doc)
+}
+
+// Events returns the event catalog sorted by name, from the described events, the listeners and the validators
+func (socket *Server) Events() []EventSpec {
+	socket.mu.RLock()
+	defer socket.mu.RUnlock()
+
+	specs := make([]EventSpec, 0)
+	described := make(map[string]bool)
+	for name, docs := range socket.eventDocs {
+		for _, doc := range docs {
+			spec := EventSpec{Name: name, Summary: doc.Summary, Direction: doc.Direction}
+			if doc.Payload != nil {
+				spec.PayloadType = reflect.TypeOf(doc.Payload)
+			} else if doc.Direction == Inbound {
+				spec.PayloadType = schemaType(socket.validators[name])
+			}
+			specs = append(specs, spec)
+			described[name+"\x00"+string(doc.Direction)] = true
+		}
+	}
+	for name := range socket.listeners {
+		if isPseudoEvent(name) || described[name+"\x00"+string(Inbound)] {
+			continue
+		}
+		specs = append(specs, EventSpec{Name: name, Direction: Inbound, PayloadType: schemaType(socket.validators[name])})
+	}
+
+	sort.Slice(specs, func(i, j int) bool {
+		if specs[i].Name != specs[j].Name {
+			return specs[i].Name < specs[j].Name
+		}
+		return specs[i].Direction < specs[j].Direction
+	})
+	return specs
+}
+
+// isPseudoEvent reports whether the listener is called by the server rather than by a client event
+func isPseudoEvent(eventName string) bool {
+	switch eventName {
+	case "connect", "disconnect", "error":
+		return true
+	}
+	return false
+}
+
+func schemaType(validator Validator) reflect.Type {
+	if s, ok := validator.(schema); ok {
+		return s.typ
+	}
+	return nil
+}
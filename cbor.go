@@ -0,0 +1,789 @@
+package signal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CBORCodec encodes messages as CBOR (RFC 8949), the compact binary format most embedded and IoT
+// stacks ship with. Frames are sent as binary WebSocket messages and byte slices travel as raw bytes.
+//
+// Struct fields are named after their `cbor` tag, or their `json` tag when there is none, so the
+// same types can be used with both codecs. Decoding into an interface produces nil, bool, int64,
+// uint64 (above math.MaxInt64), float64, string, []byte, []any and map[string]any.
+type CBORCodec struct{}
+
+func (CBORCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := cborEncode(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (CBORCodec) Unmarshal(data []byte, v any) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return errors.New("cbor: Unmarshal requires a non-nil pointer")
+	}
+	decoder := cborDecoder{data: data}
+	value, err := decoder.decode(0)
+	if err != nil {
+		return err
+	}
+	if decoder.pos != len(data) {
+		return fmt.Errorf("cbor: %d trailing bytes", len(data)-decoder.pos)
+	}
+	return cborAssign(target.Elem(), value, "")
+}
+
+func (CBORCodec) Encode(w io.Writer, v any) error {
+	if buf, ok := w.(*bytes.Buffer); ok {
+		return cborEncode(buf, reflect.ValueOf(v))
+	}
+	data, err := CBORCodec{}.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Binary reports that CBOR frames are sent as binary WebSocket messages
+func (CBORCodec) Binary() bool {
+	return true
+}
+
+// CBOR major types
+const (
+	cborUnsigned byte = iota << 5
+	cborNegative
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+const (
+	cborFalse      = cborSimple | 20
+	cborTrue       = cborSimple | 21
+	cborNull       = cborSimple | 22
+	cborFloat32    = cborSimple | 26
+	cborFloat64    = cborSimple | 27
+	cborBreak      = cborSimple | 31
+	cborIndefinite = 31
+	// cborMaxDepth bounds the nesting of decoded items, so hostile frames cannot exhaust the stack
+	cborMaxDepth = 512
+)
+
+func cborHead(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		buf.WriteByte(major | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
+
+func cborInt(buf *bytes.Buffer, n int64) {
+	if n < 0 {
+		cborHead(buf, cborNegative, uint64(-(n + 1)))
+		return
+	}
+	cborHead(buf, cborUnsigned, uint64(n))
+}
+
+func cborFloat(buf *bytes.Buffer, f float64) {
+	if float64(float32(f)) == f || math.IsNaN(f) {
+		buf.WriteByte(cborFloat32)
+		buf.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(f))))
+		return
+	}
+	buf.WriteByte(cborFloat64)
+	buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+}
+
+func cborString(buf *bytes.Buffer, s string) {
+	cborHead(buf, cborText, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func cborEncode(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteByte(cborNull)
+		return nil
+	}
+	if v.Type() == timeType {
+		// tag 0, RFC 3339 date/time string
+		cborHead(buf, cborTag, 0)
+		cborString(buf, v.Interface().(time.Time).Format(time.RFC3339Nano))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteByte(cborNull)
+			return nil
+		}
+		return cborEncode(buf, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(cborTrue)
+		} else {
+			buf.WriteByte(cborFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		cborInt(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		cborHead(buf, cborUnsigned, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		cborFloat(buf, v.Float())
+	case reflect.String:
+		cborString(buf, v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			buf.WriteByte(cborNull)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			cborHead(buf, cborBytes, uint64(v.Len()))
+			buf.Write(v.Bytes())
+			return nil
+		}
+		return cborArrayOf(buf, v)
+	case reflect.Array:
+		return cborArrayOf(buf, v)
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteByte(cborNull)
+			return nil
+		}
+		return cborMapOf(buf, v)
+	case reflect.Struct:
+		return cborStruct(buf, v)
+	default:
+		return fmt.Errorf("cbor: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func cborArrayOf(buf *bytes.Buffer, v reflect.Value) error {
+	cborHead(buf, cborArray, uint64(v.Len()))
+	for i := 0; i < v.Len(); i++ {
+		if err := cborEncode(buf, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cborMapOf writes the entries sorted by encoded key, so equal maps always produce the same bytes
+func cborMapOf(buf *bytes.Buffer, v reflect.Value) error {
+	type entry struct {
+		key   []byte
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		var key bytes.Buffer
+		if err := cborEncode(&key, iter.Key()); err != nil {
+			return err
+		}
+		entries = append(entries, entry{key.Bytes(), iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	cborHead(buf, cborMap, uint64(len(entries)))
+	for _, entry := range entries {
+		buf.Write(entry.key)
+		if err := cborEncode(buf, entry.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cborStruct(buf *bytes.Buffer, v reflect.Value) error {
+	fields := cborFields(v.Type())
+	present := make([]reflect.Value, len(fields))
+	count := 0
+	for i, field := range fields {
+		value, ok := fieldByIndex(v, field.index)
+		if !ok || (field.omitEmpty && value.IsZero()) {
+			continue
+		}
+		present[i] = value
+		count++
+	}
+
+	cborHead(buf, cborMap, uint64(count))
+	for i, field := range fields {
+		if !present[i].IsValid() {
+			continue
+		}
+		cborString(buf, field.name)
+		if err := cborEncode(buf, present[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex, reporting false instead of panicking on nil embedded pointers
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+type cborField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var cborFieldCache sync.Map
+
+// cborFields lists the encoded fields of a struct type, promoting the fields of untagged embedded structs
+func cborFields(t reflect.Type) []cborField {
+	if cached, ok := cborFieldCache.Load(t); ok {
+		return cached.([]cborField)
+	}
+	var fields []cborField
+	seen := make(map[string]bool)
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag, hasTag := field.Tag.Lookup("cbor")
+			if !hasTag {
+				tag = field.Tag.Get("json")
+			}
+			if tag == "-" {
+				continue
+			}
+			name, options, _ := strings.Cut(tag, ",")
+			fieldIndex := append(append([]int(nil), index...), i)
+
+			if field.Anonymous && name == "" {
+				embedded := field.Type
+				if embedded.Kind() == reflect.Pointer {
+					embedded = embedded.Elem()
+				}
+				if embedded.Kind() == reflect.Struct {
+					walk(embedded, fieldIndex)
+					continue
+				}
+			}
+			if !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			fields = append(fields, cborField{
+				name:      name,
+				index:     fieldIndex,
+				omitEmpty: strings.Contains(options, "omitempty"),
+			})
+		}
+	}
+	walk(t, nil)
+	cborFieldCache.Store(t, fields)
+	return fields
+}
+
+// cborTagged is a tagged item decoded into an interface, only the content is kept except for date/times
+type cborTagged struct {
+	number  uint64
+	content any
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+func (decoder *cborDecoder) next(n uint64) ([]byte, error) {
+	if n > uint64(len(decoder.data)-decoder.pos) {
+		return nil, errCBORTruncated
+	}
+	start := decoder.pos
+	decoder.pos += int(n)
+	return decoder.data[start:decoder.pos], nil
+}
+
+// head reads the initial byte and argument of an item, indefinite reports the 31 additional information
+func (decoder *cborDecoder) head() (major byte, info byte, n uint64, err error) {
+	b, err := decoder.next(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b[0]&0xe0, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		b, err = decoder.next(1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, uint64(b[0]), nil
+	case info == 25:
+		b, err = decoder.next(2)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, uint64(binary.BigEndian.Uint16(b)), nil
+	case info == 26:
+		b, err = decoder.next(4)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, uint64(binary.BigEndian.Uint32(b)), nil
+	case info == 27:
+		b, err = decoder.next(8)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, binary.BigEndian.Uint64(b), nil
+	case info == cborIndefinite:
+		return major, info, 0, nil
+	default:
+		return 0, 0, 0, fmt.Errorf("cbor: reserved additional information %d", info)
+	}
+}
+
+func (decoder *cborDecoder) atBreak() bool {
+	if decoder.pos < len(decoder.data) && decoder.data[decoder.pos] == cborBreak {
+		decoder.pos++
+		return true
+	}
+	return false
+}
+
+func (decoder *cborDecoder) decode(depth int) (any, error) {
+	if depth > cborMaxDepth {
+		return nil, errors.New("cbor: maximum nesting depth exceeded")
+	}
+	major, info, n, err := decoder.head()
+	if err != nil {
+		return nil, err
+	}
+	indefinite := info == cborIndefinite
+
+	switch major {
+	case cborUnsigned:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case cborNegative:
+		if n > math.MaxInt64 {
+			return nil, errors.New("cbor: negative integer overflows int64")
+		}
+		return -1 - int64(n), nil
+	case cborBytes, cborText:
+		data, err := decoder.chunks(major, n, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(data), nil
+		}
+		return data, nil
+	case cborArray:
+		var values []any
+		if !indefinite {
+			// every item takes at least one byte
+			if n > uint64(len(decoder.data)-decoder.pos) {
+				return nil, errCBORTruncated
+			}
+			values = make([]any, 0, n)
+		}
+		for i := uint64(0); indefinite || i < n; i++ {
+			if indefinite && decoder.atBreak() {
+				break
+			}
+			value, err := decoder.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		if values == nil {
+			values = []any{}
+		}
+		return values, nil
+	case cborMap:
+		if !indefinite && n > uint64(len(decoder.data)-decoder.pos)/2 {
+			return nil, errCBORTruncated
+		}
+		values := make(map[string]any)
+		for i := uint64(0); indefinite || i < n; i++ {
+			if indefinite && decoder.atBreak() {
+				break
+			}
+			key, err := decoder.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			value, err := decoder.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := key.(string); ok {
+				values[s] = value
+			} else {
+				values[fmt.Sprint(key)] = value
+			}
+		}
+		return values, nil
+	case cborTag:
+		content, err := decoder.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return cborTagged{number: n, content: content}, nil
+	default:
+		return decoder.simple(info, n)
+	}
+}
+
+// chunks reads a byte or text string, concatenating the chunks of an indefinite length one
+func (decoder *cborDecoder) chunks(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		data, err := decoder.next(n)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.Clone(data), nil
+	}
+	var data []byte
+	for !decoder.atBreak() {
+		chunkMajor, info, size, err := decoder.head()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || info == cborIndefinite {
+			return nil, errors.New("cbor: invalid chunk in indefinite length string")
+		}
+		chunk, err := decoder.next(size)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	return data, nil
+}
+
+func (decoder *cborDecoder) simple(info byte, n uint64) (any, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return float16(uint16(n)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 27:
+		return math.Float64frombits(n), nil
+	case cborIndefinite:
+		return nil, errors.New("cbor: unexpected break")
+	default:
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", n)
+	}
+}
+
+func float16(bits uint16) float64 {
+	sign := 1.0
+	if bits&0x8000 != 0 {
+		sign = -1
+	}
+	exponent := int(bits>>10) & 0x1f
+	mantissa := float64(bits & 0x3ff)
+	switch exponent {
+	case 0:
+		return sign * math.Ldexp(mantissa, -24)
+	case 0x1f:
+		if mantissa == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	default:
+		return sign * math.Ldexp(mantissa+1024, exponent-25)
+	}
+}
+
+// cborAssign stores a decoded item into target, path names the field in errors the way Bind does
+func cborAssign(target reflect.Value, value any, path string) error {
+	if tagged, ok := value.(cborTagged); ok {
+		if target.Type() == timeType {
+			return cborAssignTime(target, tagged.content, path)
+		}
+		value = tagged.content
+	}
+	if value == nil {
+		switch target.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+			target.SetZero()
+		}
+		return nil
+	}
+	if target.Type() == timeType {
+		return cborAssignTime(target, value, path)
+	}
+
+	mismatch := func() error {
+		if path == "" {
+			return fmt.Errorf("cbor: expected %s, got %T", target.Type(), value)
+		}
+		return fmt.Errorf("cbor: %s: expected %s, got %T", path, target.Type(), value)
+	}
+	switch target.Kind() {
+	case reflect.Interface:
+		if target.NumMethod() != 0 {
+			return mismatch()
+		}
+		target.Set(reflect.ValueOf(cborPlain(value)))
+	case reflect.Pointer:
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		return cborAssign(target.Elem(), value, path)
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return mismatch()
+		}
+		target.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(int64)
+		if !ok || target.OverflowInt(n) {
+			return mismatch()
+		}
+		target.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch number := value.(type) {
+		case int64:
+			if number < 0 {
+				return mismatch()
+			}
+			n = uint64(number)
+		case uint64:
+			n = number
+		default:
+			return mismatch()
+		}
+		if target.OverflowUint(n) {
+			return mismatch()
+		}
+		target.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		switch number := value.(type) {
+		case float64:
+			target.SetFloat(number)
+		case int64:
+			target.SetFloat(float64(number))
+		case uint64:
+			target.SetFloat(float64(number))
+		default:
+			return mismatch()
+		}
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return mismatch()
+		}
+		target.SetString(s)
+	case reflect.Slice:
+		if data, ok := value.([]byte); ok && target.Type().Elem().Kind() == reflect.Uint8 {
+			target.SetBytes(data)
+			return nil
+		}
+		items, ok := value.([]any)
+		if !ok {
+			return mismatch()
+		}
+		slice := reflect.MakeSlice(target.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := cborAssign(slice.Index(i), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		target.Set(slice)
+	case reflect.Array:
+		items, ok := value.([]any)
+		if !ok || len(items) > target.Len() {
+			return mismatch()
+		}
+		for i, item := range items {
+			if err := cborAssign(target.Index(i), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		entries, ok := value.(map[string]any)
+		if !ok || target.Type().Key().Kind() != reflect.String {
+			return mismatch()
+		}
+		if target.IsNil() {
+			target.Set(reflect.MakeMapWithSize(target.Type(), len(entries)))
+		}
+		for key, item := range entries {
+			element := reflect.New(target.Type().Elem()).Elem()
+			if err := cborAssign(element, item, joinPath(path, key)); err != nil {
+				return err
+			}
+			target.SetMapIndex(reflect.ValueOf(key).Convert(target.Type().Key()), element)
+		}
+	case reflect.Struct:
+		entries, ok := value.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		fields := cborFields(target.Type())
+		for key, item := range entries {
+			field, ok := cborLookup(fields, key)
+			if !ok {
+				continue
+			}
+			destination, err := cborFieldTarget(target, field.index)
+			if err != nil {
+				return err
+			}
+			if err := cborAssign(destination, item, joinPath(path, field.name)); err != nil {
+				return err
+			}
+		}
+	default:
+		return mismatch()
+	}
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// cborLookup finds the field named key, falling back to a case-insensitive match like encoding/json
+func cborLookup(fields []cborField, key string) (cborField, bool) {
+	for _, field := range fields {
+		if field.name == key {
+			return field, true
+		}
+	}
+	for _, field := range fields {
+		if strings.EqualFold(field.name, key) {
+			return field, true
+		}
+	}
+	return cborField{}, false
+}
+
+// cborFieldTarget returns the settable field at index, allocating nil embedded pointers on the way
+func cborFieldTarget(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cbor: cannot set embedded pointer to unexported struct %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+func cborAssignTime(target reflect.Value, value any, path string) error {
+	switch content := value.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, content)
+		if err != nil {
+			return fmt.Errorf("cbor: %s: %w", path, err)
+		}
+		target.Set(reflect.ValueOf(t))
+	case int64:
+		target.Set(reflect.ValueOf(time.Unix(content, 0)))
+	case float64:
+		seconds, fraction := math.Modf(content)
+		target.Set(reflect.ValueOf(time.Unix(int64(seconds), int64(fraction*1e9))))
+	default:
+		return fmt.Errorf("cbor: %s: expected a date/time, got %T", path, value)
+	}
+	return nil
+}
+
+// cborPlain strips the tags from a decoded item before it is handed out as an interface value
+func cborPlain(value any) any {
+	switch item := value.(type) {
+	case cborTagged:
+		if item.number == 0 {
+			if s, ok := item.content.(string); ok {
+				if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+					return t
+				}
+			}
+		}
+		return cborPlain(item.content)
+	case []any:
+		for i := range item {
+			item[i] = cborPlain(item[i])
+		}
+	case map[string]any:
+		for key := range item {
+			item[key] = cborPlain(item[key])
+		}
+	}
+	return value
+}
@@ -0,0 +1,58 @@
+package signal
+
+import (
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Chaos injects faults on the writes to clients, to exercise reconnection and idempotency logic
+// against a misbehaving network. Rates are probabilities between 0 and 1, applied to every write.
+type Chaos struct {
+	// Latency is the maximum random delay added to delayed writes
+	Latency time.Duration
+	// LatencyRate is the share of writes delayed
+	LatencyRate float64
+	// DropRate is the share of writes silently dropped, the emit reports a success
+	DropRate float64
+	// DisconnectRate is the share of writes closing the connection instead
+	DisconnectRate float64
+	// Random returns a number in [0, 1), math/rand by default, set it for reproducible runs
+	Random func() float64
+}
+
+// SetChaos enables fault injection, nil disables it. Meant for staging and tests.
+func (socket *Server) SetChaos(chaos *Chaos) {
+	socket.chaos.Store(chaos)
+}
+
+// injectFault applies the chaos configuration before a write, it reports whether the write must be skipped
+func (client *Client) injectFault() (skip bool, err error) {
+	server := client.server()
+	if server == nil {
+		return false, nil
+	}
+	chaos := server.chaos.Load()
+	if chaos == nil {
+		return false, nil
+	}
+	random := chaos.Random
+	if random == nil {
+		random = rand.Float64
+	}
+
+	if chaos.DisconnectRate > 0 && random() < chaos.DisconnectRate {
+		log.Printf("Chaos: disconnecting %s", client.ConnectionId)
+		client.closeWith(DisconnectWriteError, websocket.CloseAbnormalClosure, ErrChaosDisconnect)
+		return true, ErrChaosDisconnect
+	}
+	if chaos.DropRate > 0 && random() < chaos.DropRate {
+		return true, nil
+	}
+	if chaos.Latency > 0 && chaos.LatencyRate > 0 && random() < chaos.LatencyRate {
+		time.Sleep(time.Duration(random() * float64(chaos.Latency)))
+	}
+	return false, nil
+}
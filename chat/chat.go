@@ -0,0 +1,300 @@
+// Package chat implements chat on top of signal.io rooms: messages, history, typing indicators,
+// read receipts and moderation.
+//
+//	socket := signal.IOServer("8080", signal.WithEventLog(signal.NewMemoryEventLog(500, 24*time.Hour)))
+//	rooms := chat.New(socket, chat.WithModerator(func(message *chat.Message, client *signal.Client) error {
+//		if profanity.Match(message.Text) {
+//			return signal.NewError("moderated", "message refused")
+//		}
+//		return nil
+//	}))
+//
+// Clients send chat.SendEvent with a Send payload to post in a room they joined, every member then
+// receives chat.MessageEvent with the Message. chat.HistoryEvent with a HistoryRequest answers the last
+// messages of the room, chat.TypingEvent and chat.ReadEvent share Typing and Receipt payloads with the
+// other members. History is read from the event log of the server, see signal.WithEventLog.
+package chat
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// Events exchanged with clients
+const (
+	// SendEvent posts a message, its payload is a Send
+	SendEvent = "chat:send"
+	// MessageEvent delivers a posted Message to the members of the room
+	MessageEvent = "chat:message"
+	// HistoryEvent asks for the last messages of a room with a HistoryRequest, the answer is a History
+	HistoryEvent = "chat:history"
+	// TypingEvent shares a Typing indicator with the other members of the room
+	TypingEvent = "chat:typing"
+	// ReadEvent shares a read Receipt with the other members of the room
+	ReadEvent = "chat:read"
+	// DeletedEvent tells the members of the room a message was removed by Delete, its payload is a Deleted
+	DeletedEvent = "chat:deleted"
+)
+
+// Send is the payload of SendEvent, Nonce is echoed in the Message so the sender can match it
+type Send struct {
+	Room  string `json:"room"`
+	Text  string `json:"text"`
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// Message is a chat message as delivered to the room
+type Message struct {
+	Id     string    `json:"id"`
+	Room   string    `json:"room"`
+	UserId string    `json:"userId"`
+	Text   string    `json:"text"`
+	Time   time.Time `json:"time"`
+	Nonce  string    `json:"nonce,omitempty"`
+	// Data holds application fields set by the moderator, such as a display name
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// HistoryRequest is the payload of HistoryEvent, Limit is capped by WithHistoryLimit
+type HistoryRequest struct {
+	Room  string `json:"room"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+// History answers a HistoryRequest with the messages in posting order
+type History struct {
+	Room     string    `json:"room"`
+	Messages []Message `json:"messages"`
+}
+
+// Typing is the payload of TypingEvent, UserId is set by the server
+type Typing struct {
+	Room   string `json:"room"`
+	UserId string `json:"userId,omitempty"`
+	Typing bool   `json:"typing"`
+}
+
+// Receipt is the payload of ReadEvent: UserId read the messages of the room up to MessageId
+type Receipt struct {
+	Room      string `json:"room"`
+	UserId    string `json:"userId,omitempty"`
+	MessageId string `json:"messageId"`
+}
+
+// Deleted is the payload of DeletedEvent
+type Deleted struct {
+	Room      string `json:"room"`
+	MessageId string `json:"messageId"`
+}
+
+// Moderator checks a message before it is posted, it may rewrite its Text or Data. Returning an error
+// refuses the message, the error is sent to the client.
+type Moderator func(message *Message, client *signal.Client) error
+
+// Option configures a Chat
+type Option func(*Chat)
+
+// WithModerator adds a moderation hook, hooks run in registration order
+func WithModerator(moderator Moderator) Option {
+	return func(chat *Chat) {
+		chat.moderators = append(chat.moderators, moderator)
+	}
+}
+
+// WithMaxLength refuses messages longer than length characters, 4000 by default
+func WithMaxLength(length int) Option {
+	return func(chat *Chat) {
+		chat.maxLength = length
+	}
+}
+
+// WithHistoryLimit caps the messages returned by a HistoryEvent, 50 by default
+func WithHistoryLimit(limit int) Option {
+	return func(chat *Chat) {
+		chat.historyLimit = limit
+	}
+}
+
+// Chat serves the chat events of a server
+type Chat struct {
+	socket       *signal.Server
+	moderators   []Moderator
+	maxLength    int
+	historyLimit int
+
+	mu sync.Mutex
+	// receipts holds the last message read per room and user
+	receipts map[string]map[string]string
+	deleted  map[string]struct{}
+}
+
+// New registers the chat events on socket
+func New(socket *signal.Server, options ...Option) *Chat {
+	chat := &Chat{
+		socket:       socket,
+		maxLength:    4000,
+		historyLimit: 50,
+		receipts:     make(map[string]map[string]string),
+		deleted:      make(map[string]struct{}),
+	}
+	for _, option := range options {
+		option(chat)
+	}
+	socket.On(SendEvent, chat.onSend)
+	socket.On(HistoryEvent, chat.onHistory)
+	socket.On(TypingEvent, chat.onTyping)
+	socket.On(ReadEvent, chat.onRead)
+	return chat
+}
+
+// member reports whether the client joined the room, it reports an error to the client otherwise
+func member(client *signal.Client, roomId string) bool {
+	if roomId == "" || !slices.Contains(client.Rooms(), roomId) {
+		client.EmitError(signal.NewError(signal.CodeUnauthorized, "chat: not a member of "+roomId))
+		return false
+	}
+	return true
+}
+
+func (chat *Chat) onSend(payload signal.Payload, client *signal.Client) {
+	var send Send
+	if err := signal.Bind(payload, &send); err != nil {
+		client.EmitError(err)
+		return
+	}
+	if !member(client, send.Room) {
+		return
+	}
+	message := Message{
+		Id:     signal.CreateConnectionId(),
+		Room:   send.Room,
+		UserId: client.UserId(),
+		Text:   strings.TrimSpace(send.Text),
+		Time:   time.Now().UTC(),
+		Nonce:  send.Nonce,
+	}
+	if message.Text == "" || len([]rune(message.Text)) > chat.maxLength {
+		client.EmitError(signal.NewError(signal.CodeInvalidPayload, "chat: empty or too long message"))
+		return
+	}
+	for _, moderator := range chat.moderators {
+		if err := moderator(&message, client); err != nil {
+			client.EmitError(err)
+			return
+		}
+	}
+	chat.socket.Tenant(client.Tenant()).EmitTo(message.Room, MessageEvent, message)
+}
+
+// Post sends a message from the server, such as a system notice, to a room of tenant
+func (chat *Chat) Post(tenant, roomId, userId, text string) Message {
+	message := Message{
+		Id:     signal.CreateConnectionId(),
+		Room:   roomId,
+		UserId: userId,
+		Text:   text,
+		Time:   time.Now().UTC(),
+	}
+	chat.socket.Tenant(tenant).EmitTo(roomId, MessageEvent, message)
+	return message
+}
+
+// Delete removes a message: it is left out of the history and the members are told with a DeletedEvent
+func (chat *Chat) Delete(tenant, roomId, messageId string) {
+	chat.mu.Lock()
+	chat.deleted[messageId] = struct{}{}
+	chat.mu.Unlock()
+	chat.socket.Tenant(tenant).EmitTo(roomId, DeletedEvent, Deleted{Room: roomId, MessageId: messageId})
+}
+
+// History returns the last limit messages of a room of tenant still held by the event log, oldest first
+func (chat *Chat) History(tenant, roomId string, limit int) ([]Message, error) {
+	logged, err := chat.socket.Tenant(tenant).Replay(roomId, 0)
+	if err != nil {
+		return nil, err
+	}
+	chat.mu.Lock()
+	defer chat.mu.Unlock()
+	var messages []Message
+	for i := len(logged) - 1; i >= 0 && len(messages) < limit; i-- {
+		if logged[i].EventName != MessageEvent {
+			continue
+		}
+		var message Message
+		if err := signal.Bind(logged[i].Payload, &message); err != nil {
+			continue
+		}
+		if _, deleted := chat.deleted[message.Id]; !deleted {
+			messages = append(messages, message)
+		}
+	}
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+func (chat *Chat) onHistory(payload signal.Payload, client *signal.Client) {
+	var request HistoryRequest
+	if err := signal.Bind(payload, &request); err != nil {
+		client.EmitError(err)
+		return
+	}
+	if !member(client, request.Room) {
+		return
+	}
+	limit := chat.historyLimit
+	if request.Limit > 0 && request.Limit < limit {
+		limit = request.Limit
+	}
+	messages, err := chat.History(client.Tenant(), request.Room, limit)
+	if err != nil {
+		client.EmitError(signal.NewError(signal.CodeReplayUnavailable, err.Error()))
+		return
+	}
+	client.Emit(HistoryEvent, History{Room: request.Room, Messages: messages})
+}
+
+func (chat *Chat) onTyping(payload signal.Payload, client *signal.Client) {
+	var typing Typing
+	if err := signal.Bind(payload, &typing); err != nil {
+		client.EmitError(err)
+		return
+	}
+	if !member(client, typing.Room) {
+		return
+	}
+	typing.UserId = client.UserId()
+	client.EmitTo(typing.Room, TypingEvent, typing)
+}
+
+func (chat *Chat) onRead(payload signal.Payload, client *signal.Client) {
+	var receipt Receipt
+	if err := signal.Bind(payload, &receipt); err != nil {
+		client.EmitError(err)
+		return
+	}
+	if !member(client, receipt.Room) || receipt.MessageId == "" {
+		return
+	}
+	receipt.UserId = client.UserId()
+
+	chat.mu.Lock()
+	key := client.Tenant() + "/" + receipt.Room
+	if chat.receipts[key] == nil {
+		chat.receipts[key] = make(map[string]string)
+	}
+	chat.receipts[key][receipt.UserId] = receipt.MessageId
+	chat.mu.Unlock()
+
+	client.EmitTo(receipt.Room, ReadEvent, receipt)
+}
+
+// LastRead returns the id of the last message of the room of tenant the user read on this node
+func (chat *Chat) LastRead(tenant, roomId, userId string) string {
+	chat.mu.Lock()
+	defer chat.mu.Unlock()
+	return chat.receipts[tenant+"/"+roomId][userId]
+}
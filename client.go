@@ -0,0 +1,171 @@
+package signal
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// clientState holds the per-connection data of a Client, guarded by mu
+type clientState struct {
+	server *Server
+	ip     string
+
+	// writeLock serializes writes, websocket connections support a single concurrent writer
+	writeLock chan struct{}
+
+	mu       sync.RWMutex
+	metadata map[string]any
+	tenant   string
+	userId   string
+	version  int
+	// codecName is the codec negotiated in the handshake, empty for the server codec
+	codecName string
+	codec     Codec
+	// subprotocol is the WebSocket subprotocol selected in the handshake
+	subprotocol string
+	tags        map[string]struct{}
+	// session is the key of the outbox of EmitReliable
+	session string
+	ctx     context.Context
+	// disconnect is the reason recorded when the server closes the connection
+	disconnect *DisconnectReason
+	// lifecycle holds the ConnectionState
+	lifecycle atomic.Int32
+	// failures counts the consecutive failed writes, tripped is set by the circuit breaker
+	failures atomic.Int32
+	tripped  atomic.Bool
+	// coalesced holds the emits waiting for the coalescing window
+	coalesced coalescer
+
+	counter connectionCounter
+}
+
+func newClientState(server *Server) *clientState {
+	return &clientState{
+		server:    server,
+		writeLock: make(chan struct{}, 1),
+		metadata:  make(map[string]any),
+		counter:   connectionCounter{since: time.Now()},
+	}
+}
+
+// lockWrite acquires the write lock, giving up at deadline unless it is zero
+func (client *Client) lockWrite(deadline time.Time) bool {
+	if client.state == nil {
+		return true
+	}
+	if deadline.IsZero() {
+		client.state.writeLock <- struct{}{}
+		return true
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case client.state.writeLock <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (client *Client) unlockWrite() {
+	if client.state != nil {
+		<-client.state.writeLock
+	}
+}
+
+func (client *Client) writeTimeout() time.Duration {
+	if server := client.server(); server != nil {
+		return server.writeTimeout
+	}
+	return 0
+}
+
+func (client *Client) server() *Server {
+	if client.state == nil {
+		return nil
+	}
+	return client.state.server
+}
+
+func (client *Client) codec() Codec {
+	if client.state == nil || client.state.server == nil {
+		return JSONCodec{}
+	}
+	client.state.mu.RLock()
+	defer client.state.mu.RUnlock()
+	if client.state.codec != nil {
+		return client.state.codec
+	}
+	return client.state.server.codec
+}
+
+// CodecName returns the name of the codec negotiated by the client, empty when it uses the server codec
+func (client *Client) CodecName() string {
+	if client.state == nil {
+		return ""
+	}
+	client.state.mu.RLock()
+	defer client.state.mu.RUnlock()
+	return client.state.codecName
+}
+
+// Set stores a value on the client, visible to every handler for as long as the connection lives
+func (client *Client) Set(key string, value any) {
+	if client.state == nil {
+		client.state = newClientState(nil)
+	}
+	client.state.mu.Lock()
+	defer client.state.mu.Unlock()
+	client.state.metadata[key] = value
+}
+
+// Get returns the value stored under key, and whether it was set
+func (client *Client) Get(key string) (any, bool) {
+	if client.state == nil {
+		return nil, false
+	}
+	client.state.mu.RLock()
+	defer client.state.mu.RUnlock()
+	value, ok := client.state.metadata[key]
+	return value, ok
+}
+
+// Delete removes the value stored under key
+func (client *Client) Delete(key string) {
+	if client.state == nil {
+		return
+	}
+	client.state.mu.Lock()
+	defer client.state.mu.Unlock()
+	delete(client.state.metadata, key)
+}
+
+// Rooms returns the ids of the rooms the client is a member of on this node
+func (client *Client) Rooms() []string {
+	server := client.server()
+	if server == nil {
+		return nil
+	}
+	return server.clientRooms(client.ConnectionId)
+}
+
+// EmitTo sends the event to the other members of a room on behalf of the client, subject to the CanEmit hook
+func (client *Client) EmitTo(roomId, eventName string, payload Payload) error {
+	server := client.server()
+	if server == nil {
+		return nil
+	}
+	if room := server.lookupRoom(client.Tenant(), roomId); room != nil {
+		_, err := room.EmitFrom(client, eventName, payload)
+		return err
+	}
+	// no local member, the room may still have members on other nodes
+	if err := server.checkCanEmit(roomId, client); err != nil {
+		return err
+	}
+	server.publish(client.Tenant(), roomId, []string{client.ConnectionId}, eventName, payload)
+	return nil
+}
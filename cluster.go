@@ -0,0 +1,406 @@
+package signal
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClientNotFound is returned when no node of the cluster holds the connection
+var ErrClientNotFound = errors.New("client not found")
+
+// clusterSecretHeader carries the shared secret authenticating requests between nodes
+const clusterSecretHeader = "X-Signal-Cluster-Secret"
+
+// Member is a node of the cluster, Address is the base URL its ClusterHandler is mounted on
+type Member struct {
+	Id      string `json:"id"`
+	Address string `json:"address"`
+}
+
+// Discovery lists the nodes of the cluster
+type Discovery interface {
+	Members(ctx context.Context) ([]Member, error)
+}
+
+// DiscoveryFunc adapts a function to Discovery
+type DiscoveryFunc func(ctx context.Context) ([]Member, error)
+
+func (discover DiscoveryFunc) Members(ctx context.Context) ([]Member, error) {
+	return discover(ctx)
+}
+
+// StaticMembers is a Discovery over a fixed list of node addresses, e.g. "http://10.0.0.5:8080/cluster"
+func StaticMembers(addresses ...string) Discovery {
+	return DiscoveryFunc(func(ctx context.Context) ([]Member, error) {
+		members := make([]Member, 0, len(addresses))
+		for _, address := range addresses {
+			members = append(members, Member{Id: address, Address: address})
+		}
+		return members, nil
+	})
+}
+
+// DNSMembers is a Discovery resolving host, typically a Kubernetes headless service,
+// every address becoming a node reachable at http://address:port/path
+func DNSMembers(host string, port int, path string) Discovery {
+	return DiscoveryFunc(func(ctx context.Context) ([]Member, error) {
+		addresses, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		members := make([]Member, 0, len(addresses))
+		for _, address := range addresses {
+			url := "http://" + net.JoinHostPort(address, strconv.Itoa(port)) + path
+			members = append(members, Member{Id: url, Address: url})
+		}
+		return members, nil
+	})
+}
+
+// ConnectionRegistry records the node holding each connection, so EmitToClient reaches a remote connection
+// without asking every node. Entries are hints, a stale or missing one falls back to asking the nodes.
+type ConnectionRegistry interface {
+	Register(ctx context.Context, connectionId, address string) error
+	Unregister(ctx context.Context, connectionId string) error
+	// Lookup returns the address of the node holding the connection, empty when unknown
+	Lookup(ctx context.Context, connectionId string) (string, error)
+}
+
+// ClusterConfig configures the node-to-node routing
+type ClusterConfig struct {
+	// Discovery lists the nodes of the cluster
+	Discovery Discovery
+	// Registry, optional, records the connections of this node under Self
+	Registry ConnectionRegistry
+	// Elector, optional, elects the leader of the cluster, see IsLeader
+	Elector Elector
+	// RoomOwnership assigns every room to a node by consistent hashing. Room emits go to the owner, which
+	// relays them to the nodes holding members of the room only, rather than through the Adapter to every node.
+	RoomOwnership bool
+	// Self is the address this node is known by in Discovery, so it does not call itself
+	Self string
+	// Secret shared by the nodes, requests without it are refused by ClusterHandler. It is required:
+	// with an empty Secret, ClusterHandler refuses every request.
+	Secret string
+	// RefreshInterval between two Discovery calls, 10s by default
+	RefreshInterval time.Duration
+	// Timeout of the requests between nodes, 5s by default
+	Timeout time.Duration
+}
+
+type cluster struct {
+	config ClusterConfig
+	http   *http.Client
+
+	mu      sync.RWMutex
+	members []Member
+	// owners caches the node holding each remote connection routed to so far
+	owners map[string]string
+	// registrations feeds the Registry, a single goroutine applies them so a connection is never
+	// unregistered before being registered
+	registrations chan registration
+	// leading is set while this node leads the cluster
+	leading atomic.Bool
+	// ownership holds the room ownership state, see ClusterConfig.RoomOwnership
+	ownership roomOwnership
+	// loads holds the Load of the other members by address, collected at every discovery
+	loads map[string]Load
+}
+
+type registration struct {
+	connectionId string
+	register     bool
+}
+
+type clusterEmit struct {
+	ConnectionId string  `json:"connectionId"`
+	EventName    string  `json:"eventName"`
+	Payload      Payload `json:"payload"`
+}
+
+func newCluster(config ClusterConfig) *cluster {
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = 10 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	return &cluster{
+		config:        config,
+		http:          &http.Client{Timeout: config.Timeout},
+		owners:        make(map[string]string),
+		registrations: make(chan registration, 1024),
+	}
+}
+
+// startCluster keeps the member list up to date for the lifetime of the server
+func (socket *Server) startCluster() {
+	if socket.cluster == nil {
+		return
+	}
+	if socket.cluster.config.Secret == "" {
+		log.Printf("Cluster secret is empty, the ClusterHandler of this node refuses every request")
+	}
+	socket.refreshMembers()
+	socket.Every(socket.cluster.config.RefreshInterval, func(s *Server) {
+		s.refreshMembers()
+	})
+	if socket.cluster.config.Registry != nil {
+		go socket.applyRegistrations()
+	}
+	if socket.cluster.config.Elector != nil {
+		go socket.campaign()
+	}
+}
+
+// registerConnection records a connection of this node in the Registry, without waiting for it
+func (socket *Server) registerConnection(connectionId string, register bool) {
+	if socket.cluster == nil || socket.cluster.config.Registry == nil {
+		return
+	}
+	select {
+	case socket.cluster.registrations <- registration{connectionId: connectionId, register: register}:
+	default:
+		// the registry is only a hint, EmitToClient still finds the connection by asking the nodes
+		log.Printf("Cluster registry backlog full, skipping %s", connectionId)
+	}
+}
+
+func (socket *Server) applyRegistrations() {
+	c := socket.cluster
+	for {
+		select {
+		case change := <-c.registrations:
+			ctx, cancel := context.WithTimeout(socket.ctx, c.config.Timeout)
+			var err error
+			if change.register {
+				err = c.config.Registry.Register(ctx, change.connectionId, c.config.Self)
+			} else {
+				err = c.config.Registry.Unregister(ctx, change.connectionId)
+			}
+			cancel()
+			if err != nil {
+				log.Printf("Cluster registry error: %v", err)
+			}
+		case <-socket.ctx.Done():
+			return
+		}
+	}
+}
+
+func (socket *Server) refreshMembers() {
+	ctx, cancel := context.WithTimeout(socket.ctx, socket.cluster.config.Timeout)
+	defer cancel()
+
+	members, err := socket.cluster.config.Discovery.Members(ctx)
+	if err != nil {
+		log.Printf("Cluster discovery error: %v", err)
+		return
+	}
+	socket.cluster.mu.Lock()
+	socket.cluster.members = members
+	socket.cluster.mu.Unlock()
+	if socket.ownsRooms() {
+		socket.updateRing(members)
+	}
+	if socket.cluster.config.Elector == nil {
+		socket.electByMembers(members)
+	}
+	socket.refreshLoads(members)
+}
+
+// Members returns the nodes of the cluster known from the last discovery, this node included
+func (socket *Server) Members() []Member {
+	if socket.cluster == nil {
+		return nil
+	}
+	socket.cluster.mu.RLock()
+	defer socket.cluster.mu.RUnlock()
+	members := make([]Member, len(socket.cluster.members))
+	copy(members, socket.cluster.members)
+	return members
+}
+
+// EmitToClient sends the event to a connection, wherever it lives in the cluster.
+// It returns ErrClientNotFound when no node holds the connection.
+func (socket *Server) EmitToClient(connectionId, eventName string, payload Payload) error {
+	if client, exists := socket.Client(connectionId); exists {
+		return client.Emit(eventName, payload)
+	}
+	if socket.cluster == nil {
+		return ErrClientNotFound
+	}
+
+	request := clusterEmit{ConnectionId: connectionId, EventName: eventName, Payload: payload}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	c := socket.cluster
+	// tried holds the nodes already asked, the fan-out below skips them
+	tried := map[string]bool{c.config.Self: true}
+	c.mu.RLock()
+	owner, cached := c.owners[connectionId]
+	c.mu.RUnlock()
+	if cached {
+		tried[owner] = true
+		delivered, err := c.forward(owner, body)
+		if delivered {
+			return nil
+		}
+		c.forget(connectionId)
+		if err != nil {
+			log.Printf("Cluster forward error: %v", err)
+		}
+	}
+
+	if registry := c.config.Registry; registry != nil {
+		ctx, cancel := context.WithTimeout(socket.ctx, c.config.Timeout)
+		registered, err := registry.Lookup(ctx, connectionId)
+		cancel()
+		if err != nil {
+			log.Printf("Cluster registry error: %v", err)
+		}
+		if registered != "" && !tried[registered] {
+			tried[registered] = true
+			delivered, err := c.forward(registered, body)
+			if delivered {
+				c.mu.Lock()
+				c.owners[connectionId] = registered
+				c.mu.Unlock()
+				return nil
+			}
+			if err != nil {
+				log.Printf("Cluster forward error: %v", err)
+			}
+		}
+	}
+
+	// ask every other node, the one holding the connection delivers it
+	owner = ""
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, member := range socket.Members() {
+		if tried[member.Address] {
+			continue
+		}
+		wg.Add(1)
+		go func(address string) {
+			defer wg.Done()
+			delivered, err := c.forward(address, body)
+			if err != nil {
+				log.Printf("Cluster forward error: %v", err)
+			}
+			if delivered {
+				mu.Lock()
+				owner = address
+				mu.Unlock()
+			}
+		}(member.Address)
+	}
+	wg.Wait()
+
+	if owner == "" {
+		return ErrClientNotFound
+	}
+	c.mu.Lock()
+	c.owners[connectionId] = owner
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *cluster) forget(connectionId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.owners, connectionId)
+}
+
+// forward posts an emit to a node, it reports whether the node delivered it
+func (c *cluster) forward(address string, body []byte) (bool, error) {
+	return c.post(address, "/emit", body)
+}
+
+// post sends a request to the ClusterHandler of a node, it reports whether the node handled it,
+// a node answering 404 not being concerned by the request
+func (c *cluster) post(address, path string, body []byte) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, address+path, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(clusterSecretHeader, c.config.Secret)
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("%s answered %s", address, response.Status)
+	}
+}
+
+// ClusterHandler serves the requests other nodes send to this one, mount it at the address advertised in Discovery
+func (socket *Server) ClusterHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /emit", socket.clusterEmit)
+	mux.HandleFunc("POST /room/route", socket.clusterRoomRoute)
+	mux.HandleFunc("POST /room/deliver", socket.clusterRoomDeliver)
+	mux.HandleFunc("POST /room/interest", socket.clusterRoomInterest)
+	mux.HandleFunc("POST /handoff", socket.clusterHandoff)
+	mux.HandleFunc("GET /load", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, socket.Load())
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if socket.cluster == nil || !validClusterSecret(r, socket.cluster.config.Secret) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// validClusterSecret reports whether the request carries the cluster secret, never when no secret is configured
+func validClusterSecret(r *http.Request, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(clusterSecretHeader)), []byte(secret)) == 1
+}
+
+func (socket *Server) clusterEmit(w http.ResponseWriter, r *http.Request) {
+	var request clusterEmit
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid emit request", http.StatusBadRequest)
+		return
+	}
+	client, exists := socket.Client(request.ConnectionId)
+	if !exists {
+		http.Error(w, ErrClientNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err := client.Emit(request.EventName, request.Payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
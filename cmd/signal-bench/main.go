@@ -0,0 +1,202 @@
+// Command signal-bench generates load against a signal.io server and reports throughput and latency.
+//
+// Run a reference server, then point clients at it:
+//
+//	signal-bench -serve -port 8080
+//	signal-bench -url ws://localhost:8080/ -clients 1000 -rate 2 -size 256 -rooms 50 -duration 30s
+//
+// Every client joins one of the rooms, round robin, and sends -rate messages per second to it.
+// The reference server relays each message to the other members of the room, the latency is
+// measured from the send timestamp carried in the payload. Against your own server, use -join
+// and -event to match its event names; latency is reported for the -event messages it sends back.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	signalio "github.com/Syntax0xError/signal.io-golang"
+	"github.com/gorilla/websocket"
+)
+
+type benchPayload struct {
+	Room string `json:"room"`
+	Sent int64  `json:"sent"`
+	Data string `json:"data"`
+}
+
+type stats struct {
+	sent     atomic.Int64
+	received atomic.Int64
+	errors   atomic.Int64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+}
+
+func (s *stats) observe(latency time.Duration) {
+	s.mu.Lock()
+	s.latencies = append(s.latencies, latency)
+	s.mu.Unlock()
+}
+
+func main() {
+	serve := flag.Bool("serve", false, "run the reference server instead of generating load")
+	port := flag.String("port", "8080", "port of the reference server")
+	url := flag.String("url", "ws://localhost:8080/", "websocket URL of the server under load")
+	clients := flag.Int("clients", 100, "number of connected clients")
+	rate := flag.Float64("rate", 1, "messages per second sent by each client")
+	size := flag.Int("size", 128, "payload size in bytes")
+	rooms := flag.Int("rooms", 10, "number of rooms the clients are spread over")
+	join := flag.String("join", "join", "event joining a room, its payload is the room id")
+	event := flag.String("event", "message", "event sent to the room and expected back")
+	duration := flag.Duration("duration", 10*time.Second, "how long to send messages")
+	ramp := flag.Duration("ramp", time.Second, "time over which the clients connect")
+	flag.Parse()
+
+	if *serve {
+		runServer(*port, *join, *event)
+		return
+	}
+
+	s := &stats{}
+	data := strings.Repeat("x", *size)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for i := 0; i < *clients; i++ {
+		wg.Add(1)
+		room := fmt.Sprintf("room-%d", i%*rooms)
+		go func() {
+			defer wg.Done()
+			runClient(*url, room, *join, *event, data, *rate, s, stop)
+		}()
+		time.Sleep(*ramp / time.Duration(*clients))
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	deadline := time.After(*duration)
+	var lastSent, lastReceived int64
+
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			sent, received := s.sent.Load(), s.received.Load()
+			log.Printf("sent %d/s received %d/s errors %d", sent-lastSent, received-lastReceived, s.errors.Load())
+			lastSent, lastReceived = sent, received
+		case <-deadline:
+			break loop
+		case <-interrupt:
+			break loop
+		}
+	}
+	close(stop)
+	wg.Wait()
+	report(s, *duration)
+}
+
+func runClient(url, room, join, event, data string, rate float64, s *stats, stop chan struct{}) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		s.errors.Add(1)
+		log.Printf("dial: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	go func() {
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg struct {
+				EventName string       `json:"eventName"`
+				Payload   benchPayload `json:"payload"`
+			}
+			if json.Unmarshal(message, &msg) != nil || msg.EventName != event {
+				continue
+			}
+			s.received.Add(1)
+			if msg.Payload.Sent > 0 {
+				s.observe(time.Since(time.Unix(0, msg.Payload.Sent)))
+			}
+		}
+	}()
+
+	if err := conn.WriteJSON(signalio.Message{EventName: join, Payload: room}); err != nil {
+		s.errors.Add(1)
+		return
+	}
+	if rate <= 0 {
+		<-stop
+		return
+	}
+
+	interval := time.Duration(float64(time.Second) / rate)
+	// spread the clients over the interval rather than sending in lockstep
+	time.Sleep(time.Duration(rand.Int63n(int64(interval))))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			payload := benchPayload{Room: room, Sent: time.Now().UnixNano(), Data: data}
+			if err := conn.WriteJSON(signalio.Message{EventName: event, Payload: payload}); err != nil {
+				s.errors.Add(1)
+				return
+			}
+			s.sent.Add(1)
+		}
+	}
+}
+
+func report(s *stats, duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Printf("\nsent:      %d (%.0f/s)\n", s.sent.Load(), float64(s.sent.Load())/duration.Seconds())
+	fmt.Printf("received:  %d (%.0f/s)\n", s.received.Load(), float64(s.received.Load())/duration.Seconds())
+	fmt.Printf("errors:    %d\n", s.errors.Load())
+	if len(s.latencies) == 0 {
+		return
+	}
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return s.latencies[int(p*float64(len(s.latencies)-1))]
+	}
+	fmt.Printf("latency:   p50 %s  p95 %s  p99 %s  max %s\n", percentile(0.50), percentile(0.95), percentile(0.99), s.latencies[len(s.latencies)-1])
+}
+
+// runServer serves the reference server relaying room messages to the other members
+func runServer(port, join, event string) {
+	socket := signalio.IOServer(port)
+	socket.On(join, func(payload signalio.Payload, client *signalio.Client) {
+		if room, ok := payload.(string); ok {
+			socket.JoinRoom(room, client)
+		}
+	})
+	socket.On(event, func(payload signalio.Payload, client *signalio.Client) {
+		var message benchPayload
+		if signalio.Bind(payload, &message) != nil {
+			return
+		}
+		client.EmitTo(message.Room, event, payload)
+	})
+	socket.Start()
+}
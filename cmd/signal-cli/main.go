@@ -0,0 +1,185 @@
+// Command signal-cli connects to a signal.io server to emit events from the terminal and print the ones it receives.
+//
+//	signal-cli -url ws://localhost:8080/ -auth "$TOKEN" -query locale=fr -join lobby -filter 'chat:*'
+//
+// Each input line is an event name followed by an optional payload, parsed as JSON when it is valid JSON
+// and sent as a string otherwise:
+//
+//	chat:message {"text": "hello"}
+//	ping
+//	/join lobby     emit the -join-event with the room id
+//	/quit
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+	"github.com/gorilla/websocket"
+)
+
+// listFlag collects a repeatable flag
+type listFlag []string
+
+func (list *listFlag) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *listFlag) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+func main() {
+	target := flag.String("url", "ws://localhost:8080/", "websocket URL of the server")
+	auth := flag.String("auth", "", "token sent as the auth query parameter")
+	joinEvent := flag.String("join-event", "joinRoom", "event emitted by -join and /join, its payload is the room id")
+	raw := flag.Bool("raw", false, "print the received frames as they are")
+	var query, headers, rooms, filters listFlag
+	flag.Var(&query, "query", "key=value sent in queryData, repeatable")
+	flag.Var(&headers, "header", `"Name: value" handshake header, repeatable`)
+	flag.Var(&rooms, "join", "room to join once connected, repeatable")
+	flag.Var(&filters, "filter", "only print the events matching this glob, repeatable")
+	flag.Parse()
+
+	endpoint, err := handshakeURL(*target, *auth, query)
+	if err != nil {
+		log.Fatal(err)
+	}
+	header := http.Header{}
+	for _, h := range headers {
+		name, value, found := strings.Cut(h, ":")
+		if !found {
+			log.Fatalf("invalid header %q, expected \"Name: value\"", h)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	conn, response, err := websocket.DefaultDialer.Dial(endpoint, header)
+	if err != nil {
+		if response != nil {
+			log.Fatalf("handshake refused with %s: %v", response.Status, err)
+		}
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintln(os.Stderr, "connected to", *target)
+
+	var writeMu sync.Mutex
+	emit := func(eventName string, payload signal.Payload) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(signal.Message{EventName: eventName, Payload: payload})
+	}
+
+	go func() {
+		for {
+			_, frame, err := conn.ReadMessage()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "disconnected:", err)
+				os.Exit(1)
+			}
+			printFrame(frame, filters, *raw)
+		}
+	}()
+
+	for _, room := range rooms {
+		if err := emit(*joinEvent, room); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		eventName, rest, _ := strings.Cut(line, " ")
+		switch eventName {
+		case "/quit":
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			return
+		case "/join":
+			eventName = *joinEvent
+		}
+		if err := emit(eventName, parsePayload(strings.TrimSpace(rest))); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// handshakeURL adds the auth and queryData parameters the server reads during the handshake
+func handshakeURL(target, auth string, query []string) (string, error) {
+	endpoint, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	params := endpoint.Query()
+	if auth != "" {
+		params.Set("auth", auth)
+	}
+	if len(query) > 0 {
+		// queryData carries "k1=v1&k2=v2", escaped once more as a single parameter
+		params.Set("queryData", url.QueryEscape(strings.Join(query, "&")))
+	}
+	endpoint.RawQuery = params.Encode()
+	return endpoint.String(), nil
+}
+
+// parsePayload reads the payload typed after the event name, JSON when valid, a string otherwise
+func parsePayload(text string) signal.Payload {
+	if text == "" {
+		return nil
+	}
+	var payload any
+	if err := json.Unmarshal([]byte(text), &payload); err == nil {
+		return payload
+	}
+	return text
+}
+
+func printFrame(frame []byte, filters []string, raw bool) {
+	var message signal.Message
+	if err := json.Unmarshal(frame, &message); err != nil {
+		fmt.Printf("%s < %s\n", timestamp(), frame)
+		return
+	}
+	if !matchAny(filters, message.EventName) {
+		return
+	}
+	if raw {
+		fmt.Printf("%s < %s\n", timestamp(), bytes.TrimSpace(frame))
+		return
+	}
+	payload, _ := json.MarshalIndent(message.Payload, "", "  ")
+	fmt.Printf("%s < %s %s\n", timestamp(), message.EventName, payload)
+}
+
+func matchAny(filters []string, eventName string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		if matched, _ := path.Match(filter, eventName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func timestamp() string {
+	return time.Now().Format("15:04:05.000")
+}
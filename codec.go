@@ -0,0 +1,149 @@
+package signal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Codec encodes and decodes the messages exchanged with clients.
+// jsoniter.ConfigFastest and sonic.ConfigDefault satisfy it as-is.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Encoder is optionally implemented by codecs able to write straight into a buffer,
+// which lets the emit path reuse pooled buffers instead of allocating a slice per message
+type Encoder interface {
+	Encode(w io.Writer, v any) error
+}
+
+// BinaryCodec is optionally implemented by codecs producing binary rather than UTF-8 text
+type BinaryCodec interface {
+	Binary() bool
+}
+
+// FrameType returns the WebSocket message type the frames of codec are sent as
+func FrameType(codec Codec) int {
+	if binary, ok := codec.(BinaryCodec); ok && binary.Binary() {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
+}
+
+// JSONCodec is the default codec, backed by encoding/json
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// Encode writes v as json.Marshal would, without the trailing newline of json.Encoder, through a pooled encoder
+func (JSONCodec) Encode(w io.Writer, v any) error {
+	encoder := jsonEncoderPool.Get().(*jsonEncoder)
+	defer func() {
+		if encoder.buf.Cap() <= maxPooledBufferSize {
+			encoder.buf.Reset()
+			jsonEncoderPool.Put(encoder)
+		}
+	}()
+	if err := encoder.encoder.Encode(v); err != nil {
+		return err
+	}
+	_, err := w.Write(bytes.TrimSuffix(encoder.buf.Bytes(), []byte{'\n'}))
+	return err
+}
+
+// jsonEncoder is a json.Encoder bound to its own scratch buffer, so it can be reused across frames
+type jsonEncoder struct {
+	buf     bytes.Buffer
+	encoder *json.Encoder
+}
+
+var jsonEncoderPool = sync.Pool{
+	New: func() any {
+		encoder := new(jsonEncoder)
+		encoder.encoder = json.NewEncoder(&encoder.buf)
+		return encoder
+	},
+}
+
+// ErrUnsupportedCodec is returned to handshakes asking for a codec the server does not offer
+var ErrUnsupportedCodec = errors.New("unsupported codec")
+
+// CodecParam is the handshake query parameter naming the codec of the connection, see WithCodecs
+const CodecParam = "codec"
+
+// negotiateCodec switches the client to the codec named in the handshake query or subprotocol, if any
+func (socket *Server) negotiateCodec(client *Client, r *http.Request) error {
+	name := r.URL.Query().Get(CodecParam)
+	if name == "" {
+		_, name, _ = parseSubprotocol(client.Subprotocol())
+	}
+	if name == "" {
+		return nil
+	}
+	codec, exists := socket.codecs[name]
+	if !exists && name == "json" {
+		codec, exists = JSONCodec{}, true
+	}
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrUnsupportedCodec, name)
+	}
+	client.state.mu.Lock()
+	client.state.codecName = name
+	client.state.codec = codec
+	client.state.mu.Unlock()
+	return nil
+}
+
+// buffers larger than this are left to the garbage collector rather than kept in the pool
+const maxPooledBufferSize = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// encodeMessage encodes msg into a pooled buffer, the caller must release it with putBuffer
+func encodeMessage(codec Codec, msg Message) (*bytes.Buffer, error) {
+	buf := getBuffer()
+	if encoder, ok := codec.(Encoder); ok {
+		if err := encoder.Encode(buf, msg); err != nil {
+			putBuffer(buf)
+			return nil, err
+		}
+		return buf, nil
+	}
+	data, err := codec.Marshal(msg)
+	if err != nil {
+		putBuffer(buf)
+		return nil, err
+	}
+	buf.Write(data)
+	return buf, nil
+}
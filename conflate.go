@@ -0,0 +1,37 @@
+package signal
+
+import "log"
+
+// ConflationKey tells the payloads of a conflated event apart, such as the symbol of a price update.
+// Only the latest payload of every key is kept.
+type ConflationKey func(payload Payload) string
+
+// Conflate keeps only the latest pending eventName emit per key in the outbound buffer of each client,
+// replacing the older one in place, for updates whose intermediate values are worthless such as price
+// tickers or telemetry. A nil key conflates every emit of the event. The outbound buffer is the
+// coalescing window, conflation requires WithCoalescing.
+func (socket *Server) Conflate(eventName string, key ConflationKey) {
+	if socket.coalesceWindow <= 0 {
+		log.Printf("Conflate %s: no effect without WithCoalescing", eventName)
+	}
+	if key == nil {
+		key = func(Payload) string { return "" }
+	}
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	if socket.conflated == nil {
+		socket.conflated = make(map[string]ConflationKey)
+	}
+	socket.conflated[eventName] = key
+}
+
+// conflationKey returns the key of a conflated message, false when its event is not conflated
+func (socket *Server) conflationKey(message Message) (string, bool) {
+	socket.mu.RLock()
+	key, conflated := socket.conflated[message.EventName]
+	socket.mu.RUnlock()
+	if !conflated {
+		return "", false
+	}
+	return message.EventName + "\x00" + key(message.Payload), true
+}
@@ -0,0 +1,208 @@
+// Package crdt attaches conflict-free shared documents to rooms: a last-writer-wins map and named texts
+// that clients edit concurrently, with their operations merged on the server and rebroadcast to the room.
+//
+//	docs := crdt.New(socket, "doc")
+//	shared := docs.Room(socket.Room("notes-42"))
+//	shared.Set("title", "Meeting notes")
+//	shared.InsertText("body", 0, "Agenda")
+//
+// Clients keep their own Document replica, with their connection id as replica id, and emit their
+// operations as "<event>" events with a list of Op, Room set. The server merges them and sends them to
+// the other members. Joining clients receive the signal.WelcomeEvent of the room with a Snapshot payload,
+// the operations rebuilding the document.
+package crdt
+
+import (
+	"errors"
+	"sync"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// Snapshot is the payload welcoming a client to the room of a document
+type Snapshot struct {
+	Room string `json:"room"`
+	Ops  []Op   `json:"ops"`
+}
+
+// Documents holds the shared documents of the rooms editing on one event
+type Documents struct {
+	socket *signal.Server
+	event  string
+
+	mu   sync.Mutex
+	docs map[string]*Shared
+}
+
+// New registers the operation event of the documents on socket
+func New(socket *signal.Server, event string) *Documents {
+	documents := &Documents{
+		socket: socket,
+		event:  event,
+		docs:   make(map[string]*Shared),
+	}
+	socket.On(event, documents.onOps)
+	return documents
+}
+
+// Room returns the document of the room, creating an empty one. The document sends the room welcome,
+// it replaces any other welcome of the room.
+func (documents *Documents) Room(room *signal.Room) *Shared {
+	documents.mu.Lock()
+	defer documents.mu.Unlock()
+	key := room.Tenant + "/" + room.Id
+	if shared, exists := documents.docs[key]; exists {
+		return shared
+	}
+	shared := &Shared{
+		documents: documents,
+		room:      room,
+		doc:       NewDocument(documents.socket.NodeId()),
+	}
+	room.SetWelcome(func(client *signal.Client) signal.Payload {
+		return Snapshot{Room: room.Id, Ops: shared.Ops()}
+	})
+	documents.docs[key] = shared
+	return shared
+}
+
+// Remove drops the document of the room and its welcome
+func (documents *Documents) Remove(room *signal.Room) {
+	documents.mu.Lock()
+	defer documents.mu.Unlock()
+	delete(documents.docs, room.Tenant+"/"+room.Id)
+	room.SetWelcome(nil)
+}
+
+func (documents *Documents) onOps(payload signal.Payload, client *signal.Client) {
+	var ops []Op
+	if err := signal.Bind(payload, &ops); err != nil {
+		client.EmitError(err)
+		return
+	}
+	if len(ops) == 0 {
+		return
+	}
+	roomId := ops[0].Room
+	documents.mu.Lock()
+	shared, exists := documents.docs[client.Tenant()+"/"+roomId]
+	documents.mu.Unlock()
+	if !exists || signal.IndexOf(client.ConnectionId, shared.room.Clients()) == -1 {
+		client.EmitError(signal.NewError(signal.CodeUnauthorized, "crdt: not a member of "+roomId))
+		return
+	}
+
+	applied, err := shared.merge(ops)
+	if err != nil {
+		client.EmitError(signal.NewError(signal.CodeInvalidPayload, err.Error()))
+	}
+	if len(applied) > 0 {
+		shared.room.Except(client.ConnectionId).Emit(documents.event, applied)
+	}
+}
+
+// Shared is the server replica of the document of a room, safe for concurrent use. Its changes are
+// broadcast to the room.
+type Shared struct {
+	documents *Documents
+	room      *signal.Room
+
+	mu  sync.Mutex
+	doc *Document
+}
+
+// merge applies the operations of a client and returns the ones to forward
+func (shared *Shared) merge(ops []Op) ([]Op, error) {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	var forward []Op
+	var errs []error
+	for _, op := range ops {
+		if op.Room != shared.room.Id {
+			errs = append(errs, ErrInvalidOp)
+			continue
+		}
+		if _, err := shared.doc.Apply(op); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// pending operations are forwarded too, the other replicas keep them as well
+		forward = append(forward, op)
+	}
+	return forward, errors.Join(errs...)
+}
+
+// broadcast sends operations made on the server to the room
+func (shared *Shared) broadcast(ops ...Op) {
+	if len(ops) == 0 {
+		return
+	}
+	for i := range ops {
+		ops[i].Room = shared.room.Id
+	}
+	shared.room.Emit(shared.documents.event, ops)
+}
+
+// Get returns the value of key
+func (shared *Shared) Get(key string) (any, bool) {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	return shared.doc.Get(key)
+}
+
+// Map returns a copy of the map entries
+func (shared *Shared) Map() map[string]any {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	return shared.doc.Map()
+}
+
+// Text returns the content of the named text
+func (shared *Shared) Text(name string) string {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	return shared.doc.Text(name)
+}
+
+// Ops returns the operations rebuilding the document
+func (shared *Shared) Ops() []Op {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	ops := shared.doc.Ops()
+	for i := range ops {
+		ops[i].Room = shared.room.Id
+	}
+	return ops
+}
+
+// Set sets key to value
+func (shared *Shared) Set(key string, value any) {
+	shared.mu.Lock()
+	op := shared.doc.Set(key, value)
+	shared.mu.Unlock()
+	shared.broadcast(op)
+}
+
+// Remove deletes key
+func (shared *Shared) Remove(key string) {
+	shared.mu.Lock()
+	op := shared.doc.Remove(key)
+	shared.mu.Unlock()
+	shared.broadcast(op)
+}
+
+// InsertText inserts content in the named text at position, in characters
+func (shared *Shared) InsertText(name string, position int, content string) {
+	shared.mu.Lock()
+	ops := shared.doc.InsertText(name, position, content)
+	shared.mu.Unlock()
+	shared.broadcast(ops...)
+}
+
+// DeleteText deletes count characters of the named text from position
+func (shared *Shared) DeleteText(name string, position, count int) {
+	shared.mu.Lock()
+	ops := shared.doc.DeleteText(name, position, count)
+	shared.mu.Unlock()
+	shared.broadcast(ops...)
+}
@@ -0,0 +1,324 @@
+package crdt
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxPending bounds the operations kept while waiting for the text element they refer to
+const maxPending = 1000
+
+var (
+	// ErrInvalidOp is returned for operations that are neither a map nor a text operation
+	ErrInvalidOp = errors.New("crdt: invalid operation")
+	// ErrTooManyPending is returned when too many operations refer to elements not received yet
+	ErrTooManyPending = errors.New("crdt: too many pending operations")
+)
+
+// ID identifies an operation: a Lamport timestamp and the replica that made it. IDs are totally ordered,
+// the replica breaking the ties.
+type ID struct {
+	Seq     uint64 `json:"seq"`
+	Replica string `json:"replica"`
+}
+
+func (id ID) less(other ID) bool {
+	if id.Seq != other.Seq {
+		return id.Seq < other.Seq
+	}
+	return id.Replica < other.Replica
+}
+
+// Op is an operation on a document. Map operations set Key, to Value or deleted with Delete, the last
+// writer by Id wins. Text operations name the Text and either Insert one character After an element,
+// the beginning of the text when After is nil, or Delete the Target element. Id is the inserted element.
+type Op struct {
+	Room   string `json:"room,omitempty"`
+	Id     ID     `json:"id"`
+	Key    string `json:"key,omitempty"`
+	Value  any    `json:"value,omitempty"`
+	Delete bool   `json:"delete,omitempty"`
+	Text   string `json:"text,omitempty"`
+	After  *ID    `json:"after,omitempty"`
+	Insert string `json:"insert,omitempty"`
+	Target *ID    `json:"target,omitempty"`
+}
+
+type register struct {
+	id      ID
+	value   any
+	deleted bool
+}
+
+type element struct {
+	id      ID
+	char    string
+	deleted bool
+}
+
+// Document is a replica of a shared document: a last-writer-wins map and named texts merged with the
+// RGA algorithm. Replicas applying the same operations, in any order and any number of times, converge.
+// Document is not safe for concurrent use.
+type Document struct {
+	replica string
+	clock   uint64
+	entries map[string]register
+	texts   map[string][]element
+	pending []Op
+}
+
+// NewDocument creates an empty replica, replica must be unique among the replicas of the document
+func NewDocument(replica string) *Document {
+	return &Document{
+		replica: replica,
+		entries: make(map[string]register),
+		texts:   make(map[string][]element),
+	}
+}
+
+// next returns a new operation id
+func (doc *Document) next() ID {
+	doc.clock++
+	return ID{Seq: doc.clock, Replica: doc.replica}
+}
+
+// Apply merges an operation, it reports whether the document changed. Text operations referring to an
+// element not received yet are kept and applied once it arrives.
+func (doc *Document) Apply(op Op) (bool, error) {
+	if err := validate(op); err != nil {
+		return false, err
+	}
+	changed, ready := doc.apply(op)
+	if !ready {
+		if len(doc.pending) >= maxPending {
+			return false, ErrTooManyPending
+		}
+		doc.pending = append(doc.pending, op)
+		return false, nil
+	}
+	if changed {
+		doc.retryPending()
+	}
+	return changed, nil
+}
+
+func validate(op Op) error {
+	if op.Text == "" {
+		if op.Key == "" || op.After != nil || op.Insert != "" || op.Target != nil {
+			return ErrInvalidOp
+		}
+		return nil
+	}
+	if op.Delete {
+		if op.Target == nil || op.Insert != "" {
+			return ErrInvalidOp
+		}
+		return nil
+	}
+	if utf8.RuneCountInString(op.Insert) != 1 {
+		return ErrInvalidOp
+	}
+	return nil
+}
+
+// apply merges a valid operation, ready is false when it refers to a missing text element
+func (doc *Document) apply(op Op) (changed, ready bool) {
+	doc.clock = max(doc.clock, op.Id.Seq)
+	if op.Text == "" {
+		current, exists := doc.entries[op.Key]
+		if exists && !current.id.less(op.Id) {
+			return false, true
+		}
+		doc.entries[op.Key] = register{id: op.Id, value: op.Value, deleted: op.Delete}
+		return true, true
+	}
+
+	text := doc.texts[op.Text]
+	if op.Delete {
+		index := indexOf(text, *op.Target)
+		if index == -1 {
+			return false, false
+		}
+		if text[index].deleted {
+			return false, true
+		}
+		text[index].deleted = true
+		return true, true
+	}
+
+	if indexOf(text, op.Id) != -1 {
+		return false, true
+	}
+	position := 0
+	if op.After != nil {
+		after := indexOf(text, *op.After)
+		if after == -1 {
+			return false, false
+		}
+		position = after + 1
+	}
+	// concurrent inserts at the same place are ordered by id, the greatest first
+	for position < len(text) && op.Id.less(text[position].id) {
+		position++
+	}
+	doc.texts[op.Text] = slices.Insert(text, position, element{id: op.Id, char: op.Insert})
+	return true, true
+}
+
+// retryPending applies the pending operations whose element arrived, until none is left ready
+func (doc *Document) retryPending() {
+	for progress := true; progress; {
+		progress = false
+		pending := doc.pending[:0]
+		for _, op := range doc.pending {
+			if _, ready := doc.apply(op); ready {
+				progress = true
+			} else {
+				pending = append(pending, op)
+			}
+		}
+		doc.pending = pending
+	}
+}
+
+func indexOf(text []element, id ID) int {
+	return slices.IndexFunc(text, func(element element) bool {
+		return element.id == id
+	})
+}
+
+// Get returns the value of key
+func (doc *Document) Get(key string) (any, bool) {
+	entry, exists := doc.entries[key]
+	if !exists || entry.deleted {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Map returns a copy of the map entries
+func (doc *Document) Map() map[string]any {
+	entries := make(map[string]any, len(doc.entries))
+	for key, entry := range doc.entries {
+		if !entry.deleted {
+			entries[key] = entry.value
+		}
+	}
+	return entries
+}
+
+// Set sets key to value and returns the operation to send to the other replicas
+func (doc *Document) Set(key string, value any) Op {
+	op := Op{Id: doc.next(), Key: key, Value: value}
+	doc.apply(op)
+	return op
+}
+
+// Remove deletes key and returns the operation to send to the other replicas
+func (doc *Document) Remove(key string) Op {
+	op := Op{Id: doc.next(), Key: key, Delete: true}
+	doc.apply(op)
+	return op
+}
+
+// Text returns the content of the named text
+func (doc *Document) Text(name string) string {
+	var content strings.Builder
+	for _, element := range doc.texts[name] {
+		if !element.deleted {
+			content.WriteString(element.char)
+		}
+	}
+	return content.String()
+}
+
+// visible returns the index in text of the element at position, counted in visible characters
+func visible(text []element, position int) int {
+	for index, element := range text {
+		if element.deleted {
+			continue
+		}
+		if position == 0 {
+			return index
+		}
+		position--
+	}
+	return -1
+}
+
+// InsertText inserts content at position, in characters, and returns the operations to send
+func (doc *Document) InsertText(name string, position int, content string) []Op {
+	var after *ID
+	if position > 0 {
+		if index := visible(doc.texts[name], position-1); index != -1 {
+			id := doc.texts[name][index].id
+			after = &id
+		} else if text := doc.texts[name]; len(text) > 0 {
+			// past the end, the text is appended
+			id := text[len(text)-1].id
+			after = &id
+		}
+	}
+	var ops []Op
+	for _, char := range content {
+		op := Op{Id: doc.next(), Text: name, After: after, Insert: string(char)}
+		doc.apply(op)
+		ops = append(ops, op)
+		id := op.Id
+		after = &id
+	}
+	return ops
+}
+
+// DeleteText deletes count characters from position and returns the operations to send
+func (doc *Document) DeleteText(name string, position, count int) []Op {
+	var ops []Op
+	for ; count > 0; count-- {
+		index := visible(doc.texts[name], position)
+		if index == -1 {
+			break
+		}
+		target := doc.texts[name][index].id
+		op := Op{Id: doc.next(), Text: name, Delete: true, Target: &target}
+		doc.apply(op)
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// Ops returns operations rebuilding the document on an empty replica, deleted entries and text
+// elements included so later operations referring to them still apply
+func (doc *Document) Ops() []Op {
+	var ops []Op
+	keys := make([]string, 0, len(doc.entries))
+	for key := range doc.entries {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		entry := doc.entries[key]
+		ops = append(ops, Op{Id: entry.id, Key: key, Value: entry.value, Delete: entry.deleted})
+	}
+
+	names := make([]string, 0, len(doc.texts))
+	for name := range doc.texts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		var after *ID
+		var deleted []Op
+		for _, element := range doc.texts[name] {
+			ops = append(ops, Op{Id: element.id, Text: name, After: after, Insert: element.char})
+			id := element.id
+			after = &id
+			if element.deleted {
+				deleted = append(deleted, Op{Id: element.id, Text: name, Delete: true, Target: &id})
+			}
+		}
+		ops = append(ops, deleted...)
+	}
+	return ops
+}
@@ -0,0 +1,58 @@
+package signal
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CredentialExtractor reads the credentials of a handshake, returning an empty string when the request carries none
+type CredentialExtractor func(r *http.Request) string
+
+// FromQuery reads credentials from a query parameter, the default being FromQuery("auth")
+func FromQuery(name string) CredentialExtractor {
+	return func(r *http.Request) string {
+		return r.URL.Query().Get(name)
+	}
+}
+
+// FromHeader reads credentials from a request header
+func FromHeader(name string) CredentialExtractor {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// FromBearer reads the token of an "Authorization: Bearer <token>" header
+func FromBearer() CredentialExtractor {
+	return func(r *http.Request) string {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			return ""
+		}
+		return token
+	}
+}
+
+// FromCookie reads credentials from a cookie, which browsers send with the handshake on their own
+func FromCookie(name string) CredentialExtractor {
+	return func(r *http.Request) string {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+// extractCredentials returns the first credentials found by the configured extractors
+func (socket *Server) extractCredentials(r *http.Request) string {
+	if socket.credentialExtractors == nil {
+		return r.URL.Query().Get("auth")
+	}
+	for _, extract := range socket.credentialExtractors {
+		if credentials := extract(r); credentials != "" {
+			return credentials
+		}
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package signal
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	// ErrOutboxFull is the reason of reliable messages dropped to make room in a full session outbox
+	ErrOutboxFull = errors.New("outbox full")
+	// ErrSessionExpired is the reason of reliable messages left unacknowledged when their session expired
+	ErrSessionExpired = errors.New("session expired")
+	// ErrOfflineQueueFull is the reason of messages dropped to make room in a full offline queue
+	ErrOfflineQueueFull = errors.New("offline queue full")
+	// ErrOfflineExpired is the reason of queued messages their user did not come back for in time
+	ErrOfflineExpired = errors.New("offline message expired")
+)
+
+// DeadLetter is a message that could not be delivered, with its original target and the failure reason
+type DeadLetter struct {
+	Message Message
+	// ConnectionId is the connection the message was written to, empty when it never reached one
+	ConnectionId string
+	UserId       string
+	// Session is set for the messages of EmitReliable
+	Session string
+	Reason  error
+	Time    time.Time
+}
+
+// DeadLetterSink receives the undeliverable messages, so applications can fall back to email or push
+// notifications. It is called synchronously from the emit path and should hand slow work off.
+type DeadLetterSink interface {
+	DeadLetter(letter DeadLetter)
+}
+
+// DeadLetterFunc adapts a function to a DeadLetterSink
+type DeadLetterFunc func(letter DeadLetter)
+
+func (fn DeadLetterFunc) DeadLetter(letter DeadLetter) {
+	fn(letter)
+}
+
+// deadLetter hands an undeliverable message to the sink, if one is configured
+func (socket *Server) deadLetter(letter DeadLetter) {
+	if socket == nil || socket.deadLetters == nil {
+		return
+	}
+	if letter.Time.IsZero() {
+		letter.Time = time.Now()
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("Dead letter sink panic: %v", recovered)
+		}
+	}()
+	socket.deadLetters.DeadLetter(letter)
+}
+
+// deadLetterAll hands several undeliverable messages to the sink
+func (socket *Server) deadLetterAll(letters []DeadLetter) {
+	for _, letter := range letters {
+		socket.deadLetter(letter)
+	}
+}
+
+// deadLetterFailures reports the clients an emit to several clients failed for
+func (socket *Server) deadLetterFailures(clients []*Client, message Message, result BroadcastResult) {
+	if socket.deadLetters == nil || len(result.Errors) == 0 {
+		return
+	}
+	for _, client := range clients {
+		if err, failed := result.Errors[client.ConnectionId]; failed {
+			socket.deadLetter(DeadLetter{
+				Message:      message,
+				ConnectionId: client.ConnectionId,
+				UserId:       client.UserId(),
+				Reason:       err,
+			})
+		}
+	}
+}
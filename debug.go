@@ -0,0 +1,25 @@
+package signal
+
+import (
+	"errors"
+	"log"
+	"net/http"
+)
+
+// startDebugServer serves the debug endpoints on their own address, see WithDebugEndpoints
+func (socket *Server) startDebugServer() {
+	if socket.debugAddr == "" || socket.debugHandler == nil {
+		return
+	}
+	debugServer := &http.Server{Addr: socket.debugAddr, Handler: socket.debugHandler(socket)}
+	socket.mu.Lock()
+	socket.debugServer = debugServer
+	socket.mu.Unlock()
+
+	go func() {
+		log.Println("SignalIO debug endpoints listening on", socket.debugAddr)
+		if err := debugServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Debug endpoints: %v", err)
+		}
+	}()
+}
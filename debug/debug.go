@@ -0,0 +1,48 @@
+// Package debug serves net/http/pprof and expvar counters for a signal.io server. It is a separate package
+// because importing net/http/pprof and expvar registers their handlers on http.DefaultServeMux, which Start
+// serves on the WebSocket port.
+//
+//	socket := signal.IOServer("8080", signal.WithDebugEndpoints("127.0.0.1:6060", debug.Handler))
+package debug
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// Handler serves net/http/pprof under /debug/pprof/ and the expvar counters of socket under /debug/vars
+func Handler(socket *signal.Server) http.Handler {
+	publishVars(socket)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// publishVars publishes the server counters as the "signal" expvar, "signal:<node>" when several servers
+// run in the same process
+func publishVars(socket *signal.Server) {
+	name := "signal"
+	if expvar.Get(name) != nil {
+		name = "signal:" + socket.Load().Node
+		if expvar.Get(name) != nil {
+			return
+		}
+	}
+	vars := new(expvar.Map).Init()
+	vars.Set("connections", expvar.Func(func() any { return socket.GetTotalConnections() }))
+	vars.Set("rooms", expvar.Func(func() any { return socket.Load().Rooms }))
+	vars.Set("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+	vars.Set("writes_in_flight", expvar.Func(func() any { return socket.Load().WritesInFlight }))
+	vars.Set("draining", expvar.Func(func() any { return socket.Draining() }))
+	expvar.Publish(name, vars)
+}
@@ -0,0 +1,50 @@
+package signal
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	// Start registers on http.DefaultServeMux, it runs once per test binary
+	startOnce   sync.Once
+	startedPort string
+	startErr    error
+)
+
+// TestStartServesNoDebugEndpoints checks that the mux Start serves exposes no profiling
+func TestStartServesNoDebugEndpoints(t *testing.T) {
+	startOnce.Do(func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			startErr = err
+			return
+		}
+		_, startedPort, _ = net.SplitHostPort(listener.Addr().String())
+		listener.Close()
+
+		socket := IOServer(startedPort)
+		go socket.Start()
+		deadline := time.Now().Add(5 * time.Second)
+		for !socket.listening.Load() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	})
+	if startErr != nil {
+		t.Fatal(startErr)
+	}
+
+	for _, path := range []string{"/debug/pprof/", "/debug/vars"} {
+		response, err := http.Get("http://127.0.0.1:" + startedPort + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s: %d, want %d", path, response.StatusCode, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package signal
+
+import (
+	"sync"
+	"time"
+)
+
+// deduplicator remembers the ids of the inbound messages seen within the window
+type deduplicator struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+	// order lists the keys by arrival, so expired entries are evicted from the front
+	order []dedupEntry
+}
+
+type dedupEntry struct {
+	key string
+	at  time.Time
+}
+
+func newDeduplicator(window time.Duration) *deduplicator {
+	return &deduplicator{window: window, seen: make(map[string]time.Time)}
+}
+
+// duplicate records the key and reports whether it was already seen within the window
+func (dedup *deduplicator) duplicate(key string) bool {
+	now := time.Now()
+	dedup.mu.Lock()
+	defer dedup.mu.Unlock()
+
+	expired := 0
+	for expired < len(dedup.order) && now.Sub(dedup.order[expired].at) > dedup.window {
+		delete(dedup.seen, dedup.order[expired].key)
+		expired++
+	}
+	dedup.order = dedup.order[expired:]
+
+	if _, exists := dedup.seen[key]; exists {
+		return true
+	}
+	dedup.seen[key] = now
+	dedup.order = append(dedup.order, dedupEntry{key: key, at: now})
+	return false
+}
+
+// duplicateMessage reports whether the message was already received from the client session within
+// the deduplication window, messages without an id are never duplicates
+func (socket *Server) duplicateMessage(message Message, client *Client) bool {
+	if socket.dedup == nil || message.Id == "" {
+		return false
+	}
+	return socket.dedup.duplicate(sessionKey(client) + "\x00" + message.Id)
+}
@@ -0,0 +1,270 @@
+package signal
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// AckEvent is the event clients send to acknowledge reliable messages, its payload is the id of a
+// message or a list of ids
+const AckEvent = "ack"
+
+// SessionParam is the handshake query parameter identifying a client session across reconnections
+const SessionParam = "session"
+
+// AtLeastOnce configures the reliable delivery of EmitReliable
+type AtLeastOnce struct {
+	// RedeliverAfter resends unacknowledged messages on the live connection after this delay,
+	// zero only redelivers them when the session reconnects
+	RedeliverAfter time.Duration
+	// OutboxSize bounds the unacknowledged messages kept per session, the oldest are dropped first, 1000 by default
+	OutboxSize int
+	// SessionTTL is how long the outbox of a disconnected session is kept, 5 minutes by default
+	SessionTTL time.Duration
+}
+
+func (policy AtLeastOnce) withDefaults() AtLeastOnce {
+	if policy.OutboxSize <= 0 {
+		policy.OutboxSize = 1000
+	}
+	if policy.SessionTTL <= 0 {
+		policy.SessionTTL = 5 * time.Minute
+	}
+	return policy
+}
+
+// outbox holds the unacknowledged messages of a session, in emit order
+type outbox struct {
+	pending []Message
+	client  *Client
+	// expires is set while the session is disconnected
+	expires time.Time
+}
+
+// deliveries tracks the outboxes of every session
+type deliveries struct {
+	policy AtLeastOnce
+
+	mu       sync.Mutex
+	sessions map[string]*outbox
+	// expiries lists the disconnected sessions by expiry, the session TTL being the same for every session,
+	// so expired outboxes are evicted from the front
+	expiries []outboxExpiry
+}
+
+type outboxExpiry struct {
+	key     string
+	box     *outbox
+	expires time.Time
+}
+
+func newDeliveries(policy AtLeastOnce) *deliveries {
+	return &deliveries{
+		policy:   policy.withDefaults(),
+		sessions: make(map[string]*outbox),
+	}
+}
+
+// sessionKey identifies the session of the client, the session a client resumes is bound to its user
+// so another user cannot claim its messages. Anonymous clients cannot resume a session, as any of them
+// could claim the messages of another by sending its session name.
+func sessionKey(client *Client) string {
+	session := ""
+	if client.HTTPRequest != nil {
+		session = client.HTTPRequest.URL.Query().Get(SessionParam)
+	}
+	userId := client.UserId()
+	if session == "" || userId == "" {
+		return client.ConnectionId
+	}
+	return userId + "/" + session
+}
+
+// SessionId returns the session the client resumes, its connection id when it did not send one or is anonymous
+func (client *Client) SessionId() string {
+	if client.state == nil {
+		return client.ConnectionId
+	}
+	client.state.mu.RLock()
+	defer client.state.mu.RUnlock()
+	if client.state.session == "" {
+		return client.ConnectionId
+	}
+	return client.state.session
+}
+
+// attachSession binds the client to its session outbox and redelivers what the session did not acknowledge
+func (socket *Server) attachSession(client *Client) {
+	if socket.delivery == nil {
+		return
+	}
+	key := sessionKey(client)
+	client.state.mu.Lock()
+	client.state.session = key
+	client.state.mu.Unlock()
+
+	delivery := socket.delivery
+	delivery.mu.Lock()
+	expired := delivery.expire()
+	box, exists := delivery.sessions[key]
+	if !exists {
+		box = &outbox{}
+		delivery.sessions[key] = box
+	}
+	box.client = client
+	box.expires = time.Time{}
+	pending := append([]Message(nil), box.pending...)
+	delivery.mu.Unlock()
+	socket.deadLetterAll(expired)
+
+	for _, message := range pending {
+		client.emitMessage(message, client.writeTimeout())
+	}
+}
+
+// detachSession keeps the outbox of a disconnected client for the session TTL
+func (socket *Server) detachSession(client *Client) {
+	if socket.delivery == nil {
+		return
+	}
+	delivery := socket.delivery
+	delivery.mu.Lock()
+	defer delivery.mu.Unlock()
+	key := client.SessionId()
+	if box, exists := delivery.sessions[key]; exists && box.client == client {
+		box.client = nil
+		box.expires = time.Now().Add(delivery.policy.SessionTTL)
+		delivery.expiries = append(delivery.expiries, outboxExpiry{key: key, box: box, expires: box.expires})
+	}
+}
+
+// expire drops the outboxes of sessions that did not come back in time and returns their unacknowledged
+// messages, the caller holds delivery.mu. Sessions that reconnected meanwhile are skipped.
+func (delivery *deliveries) expire() []DeadLetter {
+	now := time.Now()
+	var expired []DeadLetter
+	evicted := 0
+	for ; evicted < len(delivery.expiries) && now.After(delivery.expiries[evicted].expires); evicted++ {
+		entry := delivery.expiries[evicted]
+		box, exists := delivery.sessions[entry.key]
+		if !exists || box != entry.box || !box.expires.Equal(entry.expires) {
+			continue
+		}
+		delete(delivery.sessions, entry.key)
+		for _, message := range box.pending {
+			expired = append(expired, DeadLetter{Message: message, Session: entry.key, Reason: ErrSessionExpired, Time: now})
+		}
+	}
+	clear(delivery.expiries[:evicted])
+	delivery.expiries = delivery.expiries[evicted:]
+	return expired
+}
+
+// EmitReliable emits the event with a message id and keeps it in the session outbox until the client
+// acknowledges it with an AckEvent, redelivering it after a reconnection of the session or once
+// AtLeastOnce.RedeliverAfter elapsed. Clients may see a message more than once and should deduplicate
+// by id. Without WithAtLeastOnce it is Emit.
+func (client *Client) EmitReliable(eventName string, payload Payload) error {
+	server := client.server()
+	if server == nil || server.delivery == nil {
+		return client.Emit(eventName, payload)
+	}
+	delivery := server.delivery
+	message := Message{Id: CreateConnectionId(), EventName: eventName, Payload: payload}
+
+	delivery.mu.Lock()
+	box, exists := delivery.sessions[client.SessionId()]
+	if !exists {
+		box = &outbox{client: client}
+		delivery.sessions[client.SessionId()] = box
+	}
+	var dropped []DeadLetter
+	if len(box.pending) >= delivery.policy.OutboxSize {
+		log.Printf("Outbox full: session=%s dropping message %s", client.SessionId(), box.pending[0].Id)
+		dropped = append(dropped, DeadLetter{
+			Message: box.pending[0],
+			UserId:  client.UserId(),
+			Session: client.SessionId(),
+			Reason:  ErrOutboxFull,
+		})
+		box.pending = box.pending[1:]
+	}
+	box.pending = append(box.pending, message)
+	delivery.mu.Unlock()
+	server.deadLetterAll(dropped)
+
+	if delivery.policy.RedeliverAfter > 0 {
+		server.scheduleRedelivery(client.SessionId(), message.Id)
+	}
+	// a failed write is not an error for the caller, the message stays in the outbox
+	client.emitMessage(message, client.writeTimeout())
+	return nil
+}
+
+// scheduleRedelivery resends the message to the session connection until it is acknowledged or dropped
+func (socket *Server) scheduleRedelivery(session, messageId string) {
+	delivery := socket.delivery
+	socket.scheduler.schedule(time.Now().Add(delivery.policy.RedeliverAfter), func() {
+		delivery.mu.Lock()
+		expired := delivery.expire()
+		box, exists := delivery.sessions[session]
+		if !exists {
+			delivery.mu.Unlock()
+			socket.deadLetterAll(expired)
+			return
+		}
+		var message Message
+		found := false
+		for _, pending := range box.pending {
+			if pending.Id == messageId {
+				message, found = pending, true
+				break
+			}
+		}
+		client := box.client
+		delivery.mu.Unlock()
+		socket.deadLetterAll(expired)
+		if !found {
+			return
+		}
+
+		socket.scheduleRedelivery(session, messageId)
+		if client != nil {
+			client.emitMessage(message, client.writeTimeout())
+		}
+	})
+}
+
+// acknowledge removes the acknowledged messages from the session outbox
+func (socket *Server) acknowledge(client *Client, payload Payload) {
+	var ids []string
+	switch value := payload.(type) {
+	case string:
+		ids = []string{value}
+	case []any:
+		for _, id := range value {
+			if id, ok := id.(string); ok {
+				ids = append(ids, id)
+			}
+		}
+	default:
+		client.EmitError(NewError(CodeInvalidPayload, "ack: expected a message id or a list of ids"))
+		return
+	}
+
+	delivery := socket.delivery
+	delivery.mu.Lock()
+	defer delivery.mu.Unlock()
+	box, exists := delivery.sessions[client.SessionId()]
+	if !exists {
+		return
+	}
+	pending := box.pending[:0:0]
+	for _, message := range box.pending {
+		if !contains(ids, message.Id) {
+			pending = append(pending, message)
+		}
+	}
+	box.pending = pending
+}
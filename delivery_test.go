@@ -0,0 +1,35 @@
+package signal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeliveriesExpire(t *testing.T) {
+	delivery := newDeliveries(AtLeastOnce{})
+	past := time.Now().Add(-time.Second)
+	expired := &outbox{pending: []Message{{Id: "lost"}}, expires: past}
+	resumed := &outbox{pending: []Message{{Id: "kept"}}}
+	waiting := &outbox{expires: time.Now().Add(time.Minute)}
+	delivery.sessions = map[string]*outbox{"expired": expired, "resumed": resumed, "waiting": waiting}
+	delivery.expiries = []outboxExpiry{
+		{key: "expired", box: expired, expires: past},
+		// the session reconnected after it was queued
+		{key: "resumed", box: resumed, expires: past},
+		{key: "waiting", box: waiting, expires: waiting.expires},
+	}
+
+	letters := delivery.expire()
+	if len(letters) != 1 || letters[0].Message.Id != "lost" || letters[0].Session != "expired" {
+		t.Fatalf("dead letters %+v", letters)
+	}
+	if _, exists := delivery.sessions["expired"]; exists {
+		t.Error("the expired outbox is kept")
+	}
+	if delivery.sessions["resumed"] != resumed || delivery.sessions["waiting"] != waiting {
+		t.Error("an outbox that did not expire is dropped")
+	}
+	if len(delivery.expiries) != 1 || delivery.expiries[0].key != "waiting" {
+		t.Errorf("expiries %+v", delivery.expiries)
+	}
+}
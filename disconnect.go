@@ -0,0 +1,97 @@
+package signal
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Disconnect reason kinds, see DisconnectReason
+const (
+	// DisconnectClientClose is a close frame sent by the client, its code and text are in the reason
+	DisconnectClientClose = "client_close"
+	// DisconnectReadTimeout is a read deadline expiring
+	DisconnectReadTimeout = "read_timeout"
+	// DisconnectKicked is a Disconnect call from the application or the admin API
+	DisconnectKicked = "kicked"
+	// DisconnectBlocked is a connection closed by Block or BlockAuth
+	DisconnectBlocked = "blocked"
+	// DisconnectWriteError is a failed write, the connection is closed since it cannot be used anymore
+	DisconnectWriteError = "write_error"
+	// DisconnectDrain is a connection closed by Drain
+	DisconnectDrain = "drain"
+	// DisconnectShutdown is a connection closed by Shutdown
+	DisconnectShutdown = "shutdown"
+	// DisconnectConnectionError is any other end of the connection, such as a dropped TCP connection
+	DisconnectConnectionError = "connection_error"
+	// DisconnectUnhealthy is a client disconnected after its circuit breaker tripped, see WithCircuitBreaker
+	DisconnectUnhealthy = "unhealthy"
+)
+
+// DisconnectReason is the payload of the disconnect listener
+type DisconnectReason struct {
+	Kind string `json:"kind"`
+	// Code and Text are the close frame ones, zero when the connection ended without a close frame
+	Code int    `json:"code,omitempty"`
+	Text string `json:"text,omitempty"`
+	// Err is the error that ended the connection, if any
+	Err error `json:"-"`
+}
+
+func (reason *DisconnectReason) Error() string {
+	message := reason.Kind
+	if reason.Code != 0 {
+		message += fmt.Sprintf(" (%d %s)", reason.Code, reason.Text)
+	}
+	if reason.Err != nil {
+		message += ": " + reason.Err.Error()
+	}
+	return message
+}
+
+func (reason *DisconnectReason) Unwrap() error {
+	return reason.Err
+}
+
+// closeWith records why the server closes the connection then sends a close frame and closes it.
+// The first recorded reason wins, the disconnect listener is called once the read loop stops.
+func (client *Client) closeWith(kind string, code int, cause error) error {
+	if client.state != nil {
+		client.state.mu.Lock()
+		if client.state.disconnect == nil {
+			client.state.disconnect = &DisconnectReason{Kind: kind, Code: code, Err: cause}
+		}
+		client.state.mu.Unlock()
+	}
+	client.setState(StateClosing)
+	if client.Socket == nil {
+		return nil
+	}
+	client.Socket.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(time.Second))
+	return client.Socket.Close()
+}
+
+// disconnectReason tells why the read loop stopped with err
+func (client *Client) disconnectReason(err error) *DisconnectReason {
+	if client.state != nil {
+		client.state.mu.RLock()
+		reason := client.state.disconnect
+		client.state.mu.RUnlock()
+		if reason != nil {
+			return reason
+		}
+	}
+
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return &DisconnectReason{Kind: DisconnectClientClose, Code: closeErr.Code, Text: closeErr.Text, Err: err}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &DisconnectReason{Kind: DisconnectReadTimeout, Err: err}
+	}
+	return &DisconnectReason{Kind: DisconnectConnectionError, Err: err}
+}
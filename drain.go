@@ -0,0 +1,99 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrDraining is returned to handshakes reaching a draining node
+var ErrDraining = errors.New("server is draining")
+
+// DrainPolicy configures Drain
+type DrainPolicy struct {
+	// Event sent to every client when draining starts, "reconnect" by default
+	Event string
+	// Payload of the hint event, clients can use it to pick their next node
+	Payload Payload
+	// Window over which connections are closed, 30s by default
+	Window time.Duration
+	// Batches the connections are split into, closed one after the other across the window, 10 by default
+	Batches int
+	// Handoff, with WithCluster, moves the rooms, metadata and unacknowledged messages of every client to
+	// the peer it picks, such as HandoffByHash, right before closing it. The client receives a HandoffHint
+	// rather than Payload and resumes its session by reconnecting to the peer with the HandoffParam token.
+	Handoff HandoffFunc
+}
+
+func (policy DrainPolicy) withDefaults() DrainPolicy {
+	if policy.Event == "" {
+		policy.Event = "reconnect"
+	}
+	if policy.Window <= 0 {
+		policy.Window = 30 * time.Second
+	}
+	if policy.Batches <= 0 {
+		policy.Batches = 10
+	}
+	return policy
+}
+
+// Draining reports whether Drain was called
+func (socket *Server) Draining() bool {
+	return socket.draining.Load()
+}
+
+// Drain prepares the node for a restart without a thundering herd on the other replicas: new handshakes
+// are refused with 503, readiness turns unavailable, the clients receive the reconnect hint and the
+// connections are closed in batches spread over the drain window.
+// When ctx is done first, the remaining connections are closed at once and ctx.Err() is returned.
+// With DrainPolicy.Handoff, every batch is handed off to the peers before being closed.
+func (socket *Server) Drain(ctx context.Context) error {
+	socket.draining.Store(true)
+	policy := socket.drainPolicy.withDefaults()
+
+	clients := socket.Clients()
+	handoff := policy.Handoff != nil && socket.cluster != nil
+	if !handoff {
+		socket.emitAll(clients, policy.Event, policy.Payload)
+	}
+	for _, client := range clients {
+		client.setState(StateDraining)
+	}
+	closeAll := func(clients []*Client) {
+		if handoff {
+			// handed off last, so the session state moved is as recent as possible
+			socket.handOff(clients, policy)
+		}
+		for _, client := range clients {
+			client.closeWith(DisconnectDrain, websocket.CloseGoingAway, ErrDraining)
+		}
+	}
+
+	batchSize := (len(clients) + policy.Batches - 1) / policy.Batches
+	if batchSize == 0 {
+		return nil
+	}
+	interval := policy.Window / time.Duration(policy.Batches)
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for start := 0; start < len(clients); start += batchSize {
+		end := min(start+batchSize, len(clients))
+		closeAll(clients[start:end])
+		if end == len(clients) {
+			break
+		}
+
+		select {
+		case <-timer.C:
+			timer.Reset(interval)
+		case <-ctx.Done():
+			closeAll(clients[end:])
+			return ctx.Err()
+		}
+	}
+	return nil
+}
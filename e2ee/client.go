@@ -0,0 +1,185 @@
+package e2ee
+
+import (
+	"log"
+	"sync"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// Client runs the key exchange of a Go client. Pass it every event received with Handle:
+//
+//	client := e2ee.NewClient(identity, conn.Emit)
+//	client.Join("vault")
+//	for message := range messages {
+//		if client.Handle(message.EventName, message.Payload) {
+//			continue
+//		}
+//		var note Note
+//		client.Open("vault", message.Payload, &note)
+//	}
+type Client struct {
+	identity *Identity
+	keyring  *Keyring
+	emit     func(eventName string, payload signal.Payload) error
+	onKey    func(roomId string, key RoomKey)
+
+	mu sync.Mutex
+	// members holds the public keys of the other members of every room
+	members map[string]map[string][]byte
+}
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// OnKey sets the function called whenever a room key is created or received, for example to start
+// emitting in the room or to re-seal a draft
+func OnKey(onKey func(roomId string, key RoomKey)) ClientOption {
+	return func(client *Client) {
+		client.onKey = onKey
+	}
+}
+
+// NewClient creates a client announcing identity, emit sends an event to the server
+func NewClient(identity *Identity, emit func(eventName string, payload signal.Payload) error, options ...ClientOption) *Client {
+	client := &Client{
+		identity: identity,
+		keyring:  NewKeyring(),
+		emit:     emit,
+		members:  make(map[string]map[string][]byte),
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return client
+}
+
+// Keyring returns the keys of the rooms the client joined
+func (client *Client) Keyring() *Keyring {
+	return client.keyring
+}
+
+// Join enters an encrypted room, its key arrives with a later event
+func (client *Client) Join(roomId string) error {
+	return client.emit(JoinEvent, Join{Room: roomId, PublicKey: client.identity.PublicKey()})
+}
+
+// Leave exits an encrypted room and forgets its keys
+func (client *Client) Leave(roomId string) error {
+	client.mu.Lock()
+	delete(client.members, roomId)
+	client.mu.Unlock()
+	client.keyring.Forget(roomId)
+	return client.emit(LeaveEvent, roomId)
+}
+
+// Seal encrypts payload with the current key of the room
+func (client *Client) Seal(roomId string, payload any) (Sealed, error) {
+	return client.keyring.Seal(roomId, payload)
+}
+
+// Open decrypts a payload received in the room into v
+func (client *Client) Open(roomId string, payload signal.Payload, v any) error {
+	return client.keyring.Open(roomId, payload, v)
+}
+
+// Handle processes a key exchange event, it reports false for the other events
+func (client *Client) Handle(eventName string, payload signal.Payload) bool {
+	switch eventName {
+	case MembersEvent, MemberJoinedEvent, MemberLeftEvent, KeyRequestEvent:
+		var members Members
+		if err := signal.Bind(payload, &members); err != nil {
+			log.Printf("e2ee: %s: %v", eventName, err)
+			return true
+		}
+		if eventName == MemberLeftEvent {
+			client.forget(members)
+			return true
+		}
+		client.remember(members)
+		if eventName == KeyRequestEvent {
+			if key, ok := client.keyring.Current(members.Room); ok {
+				client.send(members.Room, key, members.Members)
+			}
+		}
+	case RotateEvent:
+		var rotate Rotate
+		if err := signal.Bind(payload, &rotate); err != nil {
+			log.Printf("e2ee: %s: %v", eventName, err)
+			return true
+		}
+		key, err := NewRoomKey()
+		if err != nil {
+			log.Printf("e2ee: rotate %s: %v", rotate.Room, err)
+			return true
+		}
+		// the members receive the key before anything is sealed with it
+		client.send(rotate.Room, key, client.others(rotate.Room))
+		client.install(rotate.Room, key)
+	case KeyEvent:
+		var wrapped Key
+		if err := signal.Bind(payload, &wrapped); err != nil {
+			log.Printf("e2ee: %s: %v", eventName, err)
+			return true
+		}
+		key, err := client.identity.Unwrap(wrapped.Room, wrapped.KeyId, wrapped.Wrapped, wrapped.SenderKey)
+		if err != nil {
+			log.Printf("e2ee: key of %s from %s: %v", wrapped.Room, wrapped.From, err)
+			return true
+		}
+		client.install(wrapped.Room, key)
+	default:
+		return false
+	}
+	return true
+}
+
+func (client *Client) install(roomId string, key RoomKey) {
+	client.keyring.Add(roomId, key)
+	if client.onKey != nil {
+		client.onKey(roomId, key)
+	}
+}
+
+// send wraps the key for every member and emits it
+func (client *Client) send(roomId string, key RoomKey, members []Member) {
+	for _, member := range members {
+		wrapped, err := client.identity.Wrap(roomId, key, member.PublicKey)
+		if err != nil {
+			log.Printf("e2ee: wrap for %s: %v", member.ConnectionId, err)
+			continue
+		}
+		client.emit(KeyEvent, Key{Room: roomId, To: member.ConnectionId, KeyId: key.Id, Wrapped: wrapped})
+	}
+}
+
+func (client *Client) remember(members Members) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	known := client.members[members.Room]
+	if known == nil {
+		known = make(map[string][]byte)
+		client.members[members.Room] = known
+	}
+	for _, member := range members.Members {
+		known[member.ConnectionId] = member.PublicKey
+	}
+}
+
+func (client *Client) forget(members Members) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	for _, member := range members.Members {
+		delete(client.members[members.Room], member.ConnectionId)
+	}
+}
+
+func (client *Client) others(roomId string) []Member {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	members := make([]Member, 0, len(client.members[roomId]))
+	for connectionId, publicKey := range client.members[roomId] {
+		members = append(members, Member{ConnectionId: connectionId, PublicKey: publicKey})
+	}
+	return members
+}
@@ -0,0 +1,254 @@
+package e2ee
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// wrapInfo binds the keys derived to wrap room keys to this protocol
+const wrapInfo = "signal.io e2ee"
+
+// keptKeys is the number of keys a Keyring keeps per room, so messages sealed before a rotation still open
+const keptKeys = 3
+
+var (
+	// ErrNoKey is returned when sealing for a room without a key, or opening a payload sealed with an unknown key
+	ErrNoKey = errors.New("e2ee: no key")
+	// ErrInvalidKey is returned for a malformed public key or a wrapped key that does not unwrap
+	ErrInvalidKey = errors.New("e2ee: invalid key")
+)
+
+// Identity is the ECDH P-256 key pair of a client, its public key is announced when joining a room and
+// room keys are wrapped for it
+type Identity struct {
+	private *ecdh.PrivateKey
+}
+
+// NewIdentity generates a key pair
+func NewIdentity() (*Identity, error) {
+	private, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{private: private}, nil
+}
+
+// PublicKey returns the uncompressed public key, as exported raw by WebCrypto
+func (identity *Identity) PublicKey() []byte {
+	return identity.private.PublicKey().Bytes()
+}
+
+// RoomKey is the AES-256-GCM key sealing the payloads of a room
+type RoomKey struct {
+	Id     string
+	Secret []byte
+}
+
+// NewRoomKey generates a room key
+func NewRoomKey() (RoomKey, error) {
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return RoomKey{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return RoomKey{}, err
+	}
+	return RoomKey{Id: hex.EncodeToString(id), Secret: secret}, nil
+}
+
+// Wrap encrypts the room key for the member owning recipient, with a key derived from both key pairs
+func (identity *Identity) Wrap(roomId string, key RoomKey, recipient []byte) ([]byte, error) {
+	wrapping, err := identity.wrapping(recipient)
+	if err != nil {
+		return nil, err
+	}
+	return seal(wrapping, key.Secret, additionalData(roomId, key.Id))
+}
+
+// Unwrap decrypts a room key wrapped by the member owning sender
+func (identity *Identity) Unwrap(roomId, keyId string, wrapped, sender []byte) (RoomKey, error) {
+	wrapping, err := identity.wrapping(sender)
+	if err != nil {
+		return RoomKey{}, err
+	}
+	secret, err := open(wrapping, wrapped, additionalData(roomId, keyId))
+	if err != nil || len(secret) != 32 {
+		return RoomKey{}, ErrInvalidKey
+	}
+	return RoomKey{Id: keyId, Secret: secret}, nil
+}
+
+// wrapping derives the key shared with a peer, HKDF-SHA256 of the ECDH secret without salt
+func (identity *Identity) wrapping(peer []byte) ([]byte, error) {
+	public, err := ecdh.P256().NewPublicKey(peer)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	shared, err := identity.private.ECDH(public)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	extract := hmac.New(sha256.New, make([]byte, sha256.Size))
+	extract.Write(shared)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte(wrapInfo))
+	expand.Write([]byte{1})
+	return expand.Sum(nil), nil
+}
+
+// Sealed is an encrypted payload, all intermediaries see of it is the id of the key
+type Sealed struct {
+	KeyId string `json:"kid"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// Seal encrypts the JSON encoding of payload with the room key
+func Seal(roomId string, key RoomKey, payload any) (Sealed, error) {
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return Sealed{}, err
+	}
+	data, err := seal(key.Secret, plaintext, additionalData(roomId, key.Id))
+	if err != nil {
+		return Sealed{}, err
+	}
+	return Sealed{KeyId: key.Id, Nonce: data[:12], Data: data[12:]}, nil
+}
+
+// Open decrypts a sealed payload into v
+func Open(roomId string, key RoomKey, sealed Sealed, v any) error {
+	plaintext, err := open(key.Secret, append(append([]byte(nil), sealed.Nonce...), sealed.Data...), additionalData(roomId, sealed.KeyId))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plaintext, v)
+}
+
+// additionalData binds a ciphertext to its room and key, so it cannot be replayed in another room
+func additionalData(roomId, keyId string) []byte {
+	return []byte(roomId + "\x00" + keyId)
+}
+
+// seal encrypts with AES-GCM, the random nonce prefixing the ciphertext
+func seal(key, plaintext, additional []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(key, ciphertext, additional []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrInvalidKey
+	}
+	plaintext, err := aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], additional)
+	if err != nil {
+		return nil, errors.New("e2ee: payload does not open")
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Keyring holds the keys of the rooms of a client, the latest one sealing and the previous ones still
+// opening the payloads sealed before a rotation
+type Keyring struct {
+	mu    sync.RWMutex
+	rooms map[string][]RoomKey
+}
+
+// NewKeyring creates an empty keyring
+func NewKeyring() *Keyring {
+	return &Keyring{rooms: make(map[string][]RoomKey)}
+}
+
+// Add makes key the current key of the room, unless it already knows it
+func (keyring *Keyring) Add(roomId string, key RoomKey) {
+	keyring.mu.Lock()
+	defer keyring.mu.Unlock()
+	for _, known := range keyring.rooms[roomId] {
+		if known.Id == key.Id {
+			return
+		}
+	}
+	keys := append(keyring.rooms[roomId], key)
+	if len(keys) > keptKeys {
+		keys = keys[len(keys)-keptKeys:]
+	}
+	keyring.rooms[roomId] = keys
+}
+
+// Current returns the key sealing the payloads of the room
+func (keyring *Keyring) Current(roomId string) (RoomKey, bool) {
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+	keys := keyring.rooms[roomId]
+	if len(keys) == 0 {
+		return RoomKey{}, false
+	}
+	return keys[len(keys)-1], true
+}
+
+// Forget drops the keys of a room the client left
+func (keyring *Keyring) Forget(roomId string) {
+	keyring.mu.Lock()
+	defer keyring.mu.Unlock()
+	delete(keyring.rooms, roomId)
+}
+
+// Seal encrypts payload with the current key of the room
+func (keyring *Keyring) Seal(roomId string, payload any) (Sealed, error) {
+	key, ok := keyring.Current(roomId)
+	if !ok {
+		return Sealed{}, ErrNoKey
+	}
+	return Seal(roomId, key, payload)
+}
+
+// Open decrypts a payload received in the room into v, payload being a Sealed or its decoded JSON
+func (keyring *Keyring) Open(roomId string, payload signal.Payload, v any) error {
+	var sealed Sealed
+	if err := signal.Bind(payload, &sealed); err != nil {
+		return err
+	}
+	keyring.mu.RLock()
+	var key RoomKey
+	found := false
+	for _, candidate := range keyring.rooms[roomId] {
+		if candidate.Id == sealed.KeyId {
+			key, found = candidate, true
+		}
+	}
+	keyring.mu.RUnlock()
+	if !found {
+		return ErrNoKey
+	}
+	return Open(roomId, key, sealed, v)
+}
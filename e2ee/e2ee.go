@@ -0,0 +1,284 @@
+// Package e2ee encrypts room payloads end to end: every room has a symmetric key known to its members only,
+// so the server, the adapters and the bridges relay payloads they cannot read.
+//
+//	relay := e2ee.New(socket, e2ee.WithRotation(func(tenant, roomId, reason string) bool {
+//		return reason != e2ee.ReasonMemberLeft || !strings.HasPrefix(roomId, "public:")
+//	}))
+//	socket.Every(24*time.Hour, func(s *signal.Server) { relay.Rotate("", "vault") })
+//
+// Clients emit JoinEvent with their public key to enter a room, and receive MembersEvent with the public
+// keys of the members already there. The first member is asked to create the room key with RotateEvent,
+// the later ones receive it from the oldest member, asked with KeyRequestEvent to wrap it for them in a
+// KeyEvent. When a member leaves, the oldest remaining member is asked to rotate the key, so the leaver
+// cannot read what follows.
+//
+// Client implements the flow for Go clients and TypeScript for browsers. Payloads sealed with the room key
+// are emitted like any other payload. The server vouches for the public keys it relays: clients needing
+// protection against a compromised server compare key fingerprints out of band.
+//
+// The members and their keys are tracked by each node, the members of an encrypted room must connect to
+// the same node, such as its RoomOwner.
+package e2ee
+
+import (
+	"crypto/ecdh"
+	"slices"
+	"sync"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// Events exchanged with clients
+const (
+	JoinEvent         = "e2ee:join"
+	LeaveEvent        = "e2ee:leave"
+	MembersEvent      = "e2ee:members"
+	MemberJoinedEvent = "e2ee:member-joined"
+	MemberLeftEvent   = "e2ee:member-left"
+	KeyRequestEvent   = "e2ee:key-request"
+	KeyEvent          = "e2ee:key"
+	RotateEvent       = "e2ee:rotate"
+)
+
+// Reasons of a RotateEvent
+const (
+	ReasonCreated    = "created"
+	ReasonMemberLeft = "member-left"
+	ReasonManual     = "manual"
+)
+
+// Join is the payload of JoinEvent
+type Join struct {
+	Room      string `json:"room"`
+	PublicKey []byte `json:"publicKey"`
+}
+
+// Member is a member of an encrypted room
+type Member struct {
+	ConnectionId string `json:"connectionId"`
+	PublicKey    []byte `json:"publicKey,omitempty"`
+}
+
+// Members is the payload of MembersEvent, MemberJoinedEvent, MemberLeftEvent and KeyRequestEvent
+type Members struct {
+	Room    string   `json:"room"`
+	Members []Member `json:"members"`
+}
+
+// Key is the payload of KeyEvent, a room key wrapped for the member To
+type Key struct {
+	Room string `json:"room"`
+	To   string `json:"to"`
+	// From and SenderKey identify the member who wrapped the key, set by the server
+	From      string `json:"from,omitempty"`
+	SenderKey []byte `json:"senderKey,omitempty"`
+	KeyId     string `json:"keyId"`
+	Wrapped   []byte `json:"wrapped"`
+}
+
+// Rotate is the payload of RotateEvent, asking a member to create a key and send it to the others
+type Rotate struct {
+	Room   string `json:"room"`
+	Reason string `json:"reason"`
+}
+
+// RotationFunc decides whether the key of a room is rotated for a reason, tenant being empty for the
+// default tenant
+type RotationFunc func(tenant, roomId, reason string) bool
+
+// Option configures the relay
+type Option func(*Relay)
+
+// WithRotation sets the hook deciding the rotations, the key is rotated whenever a member leaves by default.
+// Rooms created and Rotate calls always rotate.
+func WithRotation(rotation RotationFunc) Option {
+	return func(relay *Relay) {
+		relay.rotation = rotation
+	}
+}
+
+// Relay runs the key exchange of the encrypted rooms of a server
+type Relay struct {
+	socket   *signal.Server
+	rotation RotationFunc
+
+	mu sync.Mutex
+	// rooms holds the members of every encrypted room, oldest first
+	rooms map[roomKey][]member
+	// joined holds the encrypted rooms of every connection
+	joined map[string][]roomKey
+}
+
+type roomKey struct {
+	tenant string
+	room   string
+}
+
+type member struct {
+	client    *signal.Client
+	publicKey []byte
+}
+
+// New registers the key exchange events on socket
+func New(socket *signal.Server, options ...Option) *Relay {
+	relay := &Relay{
+		socket: socket,
+		rooms:  make(map[roomKey][]member),
+		joined: make(map[string][]roomKey),
+	}
+	for _, option := range options {
+		option(relay)
+	}
+	socket.On(JoinEvent, relay.onJoin)
+	socket.On(LeaveEvent, relay.onLeave)
+	socket.On(KeyEvent, relay.onKey)
+	socket.OnStateChange(func(client *signal.Client, from, to signal.ConnectionState) {
+		if to != signal.StateClosed {
+			return
+		}
+		relay.mu.Lock()
+		rooms := relay.joined[client.ConnectionId]
+		delete(relay.joined, client.ConnectionId)
+		relay.mu.Unlock()
+		for _, key := range rooms {
+			relay.left(client, key)
+		}
+	})
+	return relay
+}
+
+// Rotate asks the oldest member of the room to rotate its key, false when nobody holds it on this node
+func (relay *Relay) Rotate(tenant, roomId string) bool {
+	relay.mu.Lock()
+	members := relay.rooms[roomKey{tenant: tenant, room: roomId}]
+	relay.mu.Unlock()
+	if len(members) == 0 {
+		return false
+	}
+	members[0].client.Emit(RotateEvent, Rotate{Room: roomId, Reason: ReasonManual})
+	return true
+}
+
+func (relay *Relay) onJoin(payload signal.Payload, client *signal.Client) {
+	var join Join
+	if err := signal.Bind(payload, &join); err != nil {
+		client.EmitError(err)
+		return
+	}
+	if join.Room == "" {
+		client.EmitError(signal.NewError(signal.CodeInvalidPayload, "e2ee: expected a room id"))
+		return
+	}
+	if _, err := ecdh.P256().NewPublicKey(join.PublicKey); err != nil {
+		client.EmitError(signal.NewError(signal.CodeInvalidPayload, "e2ee: invalid public key"))
+		return
+	}
+	if err := relay.socket.Tenant(client.Tenant()).JoinRoom(join.Room, client); err != nil {
+		client.EmitError(err)
+		return
+	}
+
+	key := roomKey{tenant: client.Tenant(), room: join.Room}
+	relay.mu.Lock()
+	// joining again announces a new public key, the member is told apart from the others as a newcomer
+	members := slices.DeleteFunc(slices.Clone(relay.rooms[key]), func(m member) bool {
+		return m.client.ConnectionId == client.ConnectionId
+	})
+	others := members
+	relay.rooms[key] = append(members, member{client: client, publicKey: join.PublicKey})
+	if !slices.Contains(relay.joined[client.ConnectionId], key) {
+		relay.joined[client.ConnectionId] = append(relay.joined[client.ConnectionId], key)
+	}
+	relay.mu.Unlock()
+
+	known := make([]Member, 0, len(others))
+	for _, other := range others {
+		known = append(known, Member{ConnectionId: other.client.ConnectionId, PublicKey: other.publicKey})
+	}
+	client.Emit(MembersEvent, Members{Room: join.Room, Members: known})
+	if len(others) == 0 {
+		client.Emit(RotateEvent, Rotate{Room: join.Room, Reason: ReasonCreated})
+		return
+	}
+	newcomer := Members{Room: join.Room, Members: []Member{{ConnectionId: client.ConnectionId, PublicKey: join.PublicKey}}}
+	for _, other := range others {
+		other.client.Emit(MemberJoinedEvent, newcomer)
+	}
+	others[0].client.Emit(KeyRequestEvent, newcomer)
+}
+
+func (relay *Relay) onLeave(payload signal.Payload, client *signal.Client) {
+	roomId, ok := payload.(string)
+	if !ok {
+		client.EmitError(signal.NewError(signal.CodeInvalidPayload, "e2ee: expected a room id"))
+		return
+	}
+	key := roomKey{tenant: client.Tenant(), room: roomId}
+	relay.mu.Lock()
+	rooms := relay.joined[client.ConnectionId]
+	joined := slices.Contains(rooms, key)
+	relay.joined[client.ConnectionId] = slices.DeleteFunc(rooms, func(room roomKey) bool { return room == key })
+	relay.mu.Unlock()
+	if !joined {
+		return
+	}
+	relay.socket.Tenant(client.Tenant()).LeaveRoom(roomId, client)
+	relay.left(client, key)
+}
+
+// left removes the client from the members, tells the others and asks the oldest one to rotate the key
+func (relay *Relay) left(client *signal.Client, key roomKey) {
+	relay.mu.Lock()
+	members := slices.DeleteFunc(slices.Clone(relay.rooms[key]), func(m member) bool {
+		return m.client.ConnectionId == client.ConnectionId
+	})
+	if len(members) == 0 {
+		delete(relay.rooms, key)
+	} else {
+		relay.rooms[key] = members
+	}
+	relay.mu.Unlock()
+	if len(members) == 0 {
+		return
+	}
+
+	leaver := Members{Room: key.room, Members: []Member{{ConnectionId: client.ConnectionId}}}
+	for _, other := range members {
+		other.client.Emit(MemberLeftEvent, leaver)
+	}
+	if relay.rotation == nil || relay.rotation(key.tenant, key.room, ReasonMemberLeft) {
+		members[0].client.Emit(RotateEvent, Rotate{Room: key.room, Reason: ReasonMemberLeft})
+	}
+}
+
+// onKey relays a wrapped key to its target, both being members of the room
+func (relay *Relay) onKey(payload signal.Payload, client *signal.Client) {
+	var key Key
+	if err := signal.Bind(payload, &key); err != nil {
+		client.EmitError(err)
+		return
+	}
+	relay.mu.Lock()
+	members := relay.rooms[roomKey{tenant: client.Tenant(), room: key.Room}]
+	relay.mu.Unlock()
+
+	var sender, target *member
+	for i := range members {
+		switch members[i].client.ConnectionId {
+		case client.ConnectionId:
+			sender = &members[i]
+		case key.To:
+			target = &members[i]
+		}
+	}
+	if sender == nil {
+		client.EmitError(signal.NewError(signal.CodeUnauthorized, "e2ee: not a member of "+key.Room))
+		return
+	}
+	if target == nil {
+		// the target left meanwhile, the rotation following its departure covers it
+		return
+	}
+	key.From, key.SenderKey = client.ConnectionId, sender.publicKey
+	target.client.Emit(KeyEvent, key)
+}
@@ -0,0 +1,181 @@
+package e2ee
+
+// TypeScript is the browser side of the key exchange, built on WebCrypto. Save it next to the SignalClient
+// generated by Server.TypeScript, or wrap any client with emit and on methods:
+//
+//	const e2ee = new E2EE(client);
+//	e2ee.onKey = (room) => console.log("room key ready", room);
+//	await e2ee.join("vault");
+//	client.emit("note", await e2ee.seal("vault", { text: "hello" }));
+//	client.on("note", async (sealed) => render(await e2ee.open("vault", sealed)));
+const TypeScript = `type Emitter = {
+  emit(eventName: string, payload: any): void;
+  on(eventName: string, handler: (payload: any) => void): () => void;
+};
+
+type RoomKey = { id: string; raw: Uint8Array; key: Promise<CryptoKey> };
+
+type Member = { connectionId: string; publicKey?: string };
+
+export type Sealed = { kid: string; nonce: string; data: string };
+
+const encoder = new TextEncoder();
+const decoder = new TextDecoder();
+const keptKeys = 3;
+
+function toBase64(bytes: Uint8Array): string {
+  let binary = "";
+  bytes.forEach((byte) => (binary += String.fromCharCode(byte)));
+  return btoa(binary);
+}
+
+function fromBase64(text: string): Uint8Array {
+  return Uint8Array.from(atob(text), (char) => char.charCodeAt(0));
+}
+
+function additionalData(room: string, keyId: string): Uint8Array {
+  return encoder.encode(room + "\0" + keyId);
+}
+
+function concat(nonce: Uint8Array, data: ArrayBuffer): Uint8Array {
+  const joined = new Uint8Array(nonce.length + data.byteLength);
+  joined.set(nonce);
+  joined.set(new Uint8Array(data), nonce.length);
+  return joined;
+}
+
+function importRoomKey(id: string, raw: Uint8Array): RoomKey {
+  return { id, raw, key: crypto.subtle.importKey("raw", raw, "AES-GCM", false, ["encrypt", "decrypt"]) };
+}
+
+export class E2EE {
+  onKey?: (room: string, keyId: string) => void;
+
+  private client: Emitter;
+  private identity: Promise<CryptoKeyPair>;
+  private keys = new Map<string, RoomKey[]>();
+  private members = new Map<string, Map<string, string>>();
+
+  constructor(client: Emitter) {
+    this.client = client;
+    this.identity = crypto.subtle.generateKey({ name: "ECDH", namedCurve: "P-256" }, false, ["deriveBits"]) as Promise<CryptoKeyPair>;
+    client.on("e2ee:members", (payload) => this.remember(payload.room, payload.members));
+    client.on("e2ee:member-joined", (payload) => this.remember(payload.room, payload.members));
+    client.on("e2ee:member-left", (payload) => {
+      payload.members.forEach((member: Member) => this.members.get(payload.room)?.delete(member.connectionId));
+    });
+    client.on("e2ee:key-request", (payload) => {
+      this.remember(payload.room, payload.members);
+      const current = this.current(payload.room);
+      if (current) {
+        this.send(payload.room, current, payload.members);
+      }
+    });
+    client.on("e2ee:rotate", async (payload) => {
+      const id = Array.from(crypto.getRandomValues(new Uint8Array(8)), (byte) => byte.toString(16).padStart(2, "0")).join("");
+      const key = importRoomKey(id, crypto.getRandomValues(new Uint8Array(32)));
+      const others = Array.from(this.members.get(payload.room) ?? [], ([connectionId, publicKey]) => ({ connectionId, publicKey }));
+      // the members receive the key before anything is sealed with it
+      await this.send(payload.room, key, others);
+      this.install(payload.room, key);
+    });
+    client.on("e2ee:key", async (payload) => {
+      const wrapping = await this.wrapping(payload.senderKey);
+      const wrapped = fromBase64(payload.wrapped);
+      const raw = await crypto.subtle.decrypt(
+        { name: "AES-GCM", iv: wrapped.slice(0, 12), additionalData: additionalData(payload.room, payload.keyId) },
+        wrapping,
+        wrapped.slice(12),
+      );
+      this.install(payload.room, importRoomKey(payload.keyId, new Uint8Array(raw)));
+    });
+  }
+
+  async join(room: string): Promise<void> {
+    const { publicKey } = await this.identity;
+    const raw = new Uint8Array(await crypto.subtle.exportKey("raw", publicKey));
+    this.client.emit("e2ee:join", { room, publicKey: toBase64(raw) });
+  }
+
+  leave(room: string): void {
+    this.keys.delete(room);
+    this.members.delete(room);
+    this.client.emit("e2ee:leave", room);
+  }
+
+  async seal(room: string, payload: unknown): Promise<Sealed> {
+    const current = this.current(room);
+    if (!current) {
+      throw new Error("e2ee: no key for " + room);
+    }
+    const nonce = crypto.getRandomValues(new Uint8Array(12));
+    const data = await crypto.subtle.encrypt(
+      { name: "AES-GCM", iv: nonce, additionalData: additionalData(room, current.id) },
+      await current.key,
+      encoder.encode(JSON.stringify(payload)),
+    );
+    return { kid: current.id, nonce: toBase64(nonce), data: toBase64(new Uint8Array(data)) };
+  }
+
+  async open(room: string, sealed: Sealed): Promise<any> {
+    const key = this.keys.get(room)?.find((candidate) => candidate.id === sealed.kid);
+    if (!key) {
+      throw new Error("e2ee: no key " + sealed.kid + " for " + room);
+    }
+    const plaintext = await crypto.subtle.decrypt(
+      { name: "AES-GCM", iv: fromBase64(sealed.nonce), additionalData: additionalData(room, sealed.kid) },
+      await key.key,
+      fromBase64(sealed.data),
+    );
+    return JSON.parse(decoder.decode(plaintext));
+  }
+
+  private current(room: string): RoomKey | undefined {
+    const keys = this.keys.get(room);
+    return keys?.[keys.length - 1];
+  }
+
+  private install(room: string, key: RoomKey): void {
+    const keys = this.keys.get(room) ?? [];
+    if (keys.some((known) => known.id === key.id)) {
+      return;
+    }
+    keys.push(key);
+    this.keys.set(room, keys.slice(-keptKeys));
+    this.onKey?.(room, key.id);
+  }
+
+  private remember(room: string, members: Member[]): void {
+    const known = this.members.get(room) ?? new Map<string, string>();
+    members.forEach((member) => known.set(member.connectionId, member.publicKey ?? ""));
+    this.members.set(room, known);
+  }
+
+  private async send(room: string, key: RoomKey, members: Member[]): Promise<void> {
+    for (const member of members) {
+      const nonce = crypto.getRandomValues(new Uint8Array(12));
+      const wrapped = await crypto.subtle.encrypt(
+        { name: "AES-GCM", iv: nonce, additionalData: additionalData(room, key.id) },
+        await this.wrapping(member.publicKey ?? ""),
+        key.raw,
+      );
+      this.client.emit("e2ee:key", { room, to: member.connectionId, keyId: key.id, wrapped: toBase64(concat(nonce, wrapped)) });
+    }
+  }
+
+  // wrapping derives the key shared with a member, HKDF-SHA256 of the ECDH secret without salt
+  private async wrapping(publicKey: string): Promise<CryptoKey> {
+    const { privateKey } = await this.identity;
+    const peer = await crypto.subtle.importKey("raw", fromBase64(publicKey), { name: "ECDH", namedCurve: "P-256" }, false, []);
+    const shared = await crypto.subtle.deriveBits({ name: "ECDH", public: peer }, privateKey, 256);
+    const base = await crypto.subtle.importKey("raw", shared, "HKDF", false, ["deriveKey"]);
+    return crypto.subtle.deriveKey(
+      { name: "HKDF", hash: "SHA-256", salt: new Uint8Array(), info: encoder.encode("signal.io e2ee") },
+      base,
+      { name: "AES-GCM", length: 256 },
+      false,
+      ["encrypt", "decrypt"],
+    );
+  }
+}
+`
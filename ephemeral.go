@@ -0,0 +1,86 @@
+package signal
+
+import "time"
+
+// EphemeralState is the payload of ephemeral events: the indicator Key with its Payload while it is
+// active, then Expired once its TTL elapsed or it was cleared
+type EphemeralState struct {
+	Key     string  `json:"key"`
+	Payload Payload `json:"payload,omitempty"`
+	// ExpiresIn is the remaining lifetime of the indicator, in milliseconds
+	ExpiresIn int64 `json:"expiresIn,omitempty"`
+	Expired   bool  `json:"expired,omitempty"`
+}
+
+// Ephemeral is a short-lived indicator of a room, such as a typing or recording indicator or a cursor
+type Ephemeral struct {
+	room      *Room
+	eventName string
+	key       string
+	ttl       time.Duration
+	// timer is guarded by room.mu
+	timer *Timer
+}
+
+// Ephemeral emits short-lived state to the room as eventName events with an EphemeralState payload,
+// and emits its expiry once ttl elapses without a Refresh
+func (room *Room) Ephemeral(eventName string, payload Payload, ttl time.Duration) *Ephemeral {
+	return room.EphemeralKey(eventName, CreateConnectionId(), payload, ttl)
+}
+
+// EphemeralKey is Ephemeral for an indicator identified by key, such as the id of the typing user:
+// calling it again for the same event and key refreshes the indicator instead of starting another one
+func (room *Room) EphemeralKey(eventName, key string, payload Payload, ttl time.Duration) *Ephemeral {
+	room.mu.Lock()
+	if room.ephemerals == nil {
+		room.ephemerals = make(map[string]*Ephemeral)
+	}
+	indicator, exists := room.ephemerals[eventName+"\x00"+key]
+	if !exists {
+		indicator = &Ephemeral{room: room, eventName: eventName, key: key}
+		room.ephemerals[eventName+"\x00"+key] = indicator
+	}
+	room.mu.Unlock()
+
+	indicator.Refresh(payload, ttl)
+	return indicator
+}
+
+// Key returns the key the clients receive with the indicator
+func (indicator *Ephemeral) Key() string {
+	return indicator.key
+}
+
+// Refresh emits the new payload and restarts the lifetime of the indicator with ttl
+func (indicator *Ephemeral) Refresh(payload Payload, ttl time.Duration) {
+	room := indicator.room
+	room.mu.Lock()
+	if indicator.timer != nil {
+		indicator.timer.Cancel()
+	}
+	indicator.ttl = ttl
+	indicator.timer = room.server.scheduler.schedule(time.Now().Add(ttl), indicator.Clear)
+	room.ephemerals[indicator.eventName+"\x00"+indicator.key] = indicator
+	room.mu.Unlock()
+
+	room.Emit(indicator.eventName, EphemeralState{Key: indicator.key, Payload: payload, ExpiresIn: ttl.Milliseconds()})
+}
+
+// Clear ends the indicator before its TTL, the members receive its expiry
+func (indicator *Ephemeral) Clear() {
+	room := indicator.room
+	room.mu.Lock()
+	current, active := room.ephemerals[indicator.eventName+"\x00"+indicator.key]
+	if !active || current != indicator {
+		room.mu.Unlock()
+		return
+	}
+	delete(room.ephemerals, indicator.eventName+"\x00"+indicator.key)
+	if indicator.timer != nil {
+		indicator.timer.Cancel()
+		indicator.timer = nil
+	}
+	room.mu.Unlock()
+
+	room.Emit(indicator.eventName, EphemeralState{Key: indicator.key, Expired: true})
+}
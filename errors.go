@@ -0,0 +1,63 @@
+package signal
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrWriteTimeout is returned when a message could not be written to a client in time
+var ErrWriteTimeout = errors.New("write timeout")
+
+// ErrChaosDisconnect is returned by writes on a connection closed by the fault injection, see SetChaos
+var ErrChaosDisconnect = errors.New("connection closed by chaos injection")
+
+// ErrorEvent is the event name used to report errors to clients
+const ErrorEvent = "error"
+
+// Error codes sent to clients in the Error envelope
+const (
+	CodeInvalidPayload = "invalid_payload"
+	CodeUnauthorized   = "unauthorized"
+	CodeRateLimited    = "rate_limited"
+	CodeInternal       = "internal"
+	CodeTimeout        = "timeout"
+	// CodeInvalidSignature rejects an inbound message failing verification, see WithSigning
+	CodeInvalidSignature = "invalid_signature"
+)
+
+// Error is the envelope emitted to clients as the payload of an ErrorEvent.
+// Event and Id correlate the error with the inbound message that caused it.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Event   string `json:"event,omitempty"`
+	Id      string `json:"id,omitempty"`
+}
+
+// NewError creates an error envelope, handlers and hooks can return it to choose the code sent to the client
+func NewError(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s: %s", err.Code, err.Message)
+}
+
+// EmitError reports err to the client as an ErrorEvent, errors other than *Error are sent with CodeInternal
+func (client *Client) EmitError(err error) error {
+	var envelope *Error
+	if !errors.As(err, &envelope) {
+		envelope = NewError(CodeInternal, err.Error())
+	}
+	return client.Emit(ErrorEvent, envelope)
+}
+
+// emitErrorFor reports an error caused by message, correlated with its event name and id
+func (client *Client) emitErrorFor(message Message, code, text string) error {
+	return client.Emit(ErrorEvent, &Error{
+		Code:    code,
+		Message: text,
+		Event:   message.EventName,
+		Id:      message.Id,
+	})
+}
@@ -0,0 +1,220 @@
+package signal
+
+import (
+	"container/list"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ResumeEvent is the event clients send to replay the emits of a room they missed, with a Resume payload
+const ResumeEvent = "resume"
+
+// CodeReplayUnavailable is the error code sent when the log no longer holds the requested emits
+const CodeReplayUnavailable = "replay_unavailable"
+
+// ErrReplayUnavailable is returned when emits following the requested sequence number were trimmed from the log
+var ErrReplayUnavailable = errors.New("replay unavailable: the log no longer holds these emits")
+
+// ResumeRequest is the payload of ResumeEvent, Seq being the last sequence number the client received
+type ResumeRequest struct {
+	Room string `json:"room"`
+	Seq  uint64 `json:"seq"`
+}
+
+// EventLog stores the emits of every room, keyed by the room key, in sequence order.
+// Implementations bound their size and must be safe for concurrent use.
+type EventLog interface {
+	Append(room string, message Message) error
+	// Since returns the messages with a sequence number above seq, or ErrReplayUnavailable
+	Since(room string, seq uint64) ([]Message, error)
+}
+
+// idleRoomLogTTL is how long MemoryEventLog keeps the log of a room without emits when it has no maximum age
+const idleRoomLogTTL = 24 * time.Hour
+
+// MemoryEventLog keeps the last emits of each room in memory
+type MemoryEventLog struct {
+	maxEntries int
+	maxAge     time.Duration
+
+	mu    sync.Mutex
+	rooms map[string]*roomLog
+	// idle lists the room logs by their last append, so idle rooms are evicted from the front
+	idle *list.List
+}
+
+type roomLog struct {
+	room    string
+	entries []loggedMessage
+	// trimmed is the highest sequence number dropped from the log
+	trimmed    uint64
+	lastAppend time.Time
+	element    *list.Element
+}
+
+type loggedMessage struct {
+	at      time.Time
+	message Message
+}
+
+// NewMemoryEventLog creates a log keeping at most maxEntries emits per room, none older than maxAge,
+// a zero value leaving the corresponding bound out. The log of a room without emits for maxAge, or a
+// day without maximum age, is dropped, the emits it held being reported as unavailable.
+func NewMemoryEventLog(maxEntries int, maxAge time.Duration) *MemoryEventLog {
+	return &MemoryEventLog{
+		maxEntries: maxEntries,
+		maxAge:     maxAge,
+		rooms:      make(map[string]*roomLog),
+		idle:       list.New(),
+	}
+}
+
+func (memory *MemoryEventLog) Append(room string, message Message) error {
+	now := time.Now()
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	entries := memory.rooms[room]
+	if entries == nil {
+		// the emits preceding the first one logged, such as those of an evicted log, are unavailable
+		entries = &roomLog{room: room}
+		if message.Seq > 0 {
+			entries.trimmed = message.Seq - 1
+		}
+		entries.element = memory.idle.PushBack(entries)
+		memory.rooms[room] = entries
+	} else {
+		memory.idle.MoveToBack(entries.element)
+	}
+	entries.lastAppend = now
+	entries.entries = append(entries.entries, loggedMessage{at: now, message: message})
+	memory.trim(entries)
+	memory.evictIdle(now)
+	return nil
+}
+
+// evictIdle drops the logs of the rooms without emits for the idle TTL, the caller holds memory.mu
+func (memory *MemoryEventLog) evictIdle(now time.Time) {
+	ttl := memory.maxAge
+	if ttl <= 0 {
+		ttl = idleRoomLogTTL
+	}
+	for front := memory.idle.Front(); front != nil; front = memory.idle.Front() {
+		entries := front.Value.(*roomLog)
+		if now.Sub(entries.lastAppend) <= ttl {
+			return
+		}
+		memory.idle.Remove(front)
+		delete(memory.rooms, entries.room)
+	}
+}
+
+func (memory *MemoryEventLog) Since(room string, seq uint64) ([]Message, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	entries := memory.rooms[room]
+	if entries == nil {
+		return nil, nil
+	}
+	memory.trim(entries)
+	if seq < entries.trimmed {
+		return nil, ErrReplayUnavailable
+	}
+	var messages []Message
+	for _, entry := range entries.entries {
+		if entry.message.Seq > seq {
+			messages = append(messages, entry.message)
+		}
+	}
+	return messages, nil
+}
+
+// trim drops the entries past the bounds, the caller holds memory.mu
+func (memory *MemoryEventLog) trim(entries *roomLog) {
+	drop := 0
+	if memory.maxEntries > 0 && len(entries.entries) > memory.maxEntries {
+		drop = len(entries.entries) - memory.maxEntries
+	}
+	if memory.maxAge > 0 {
+		cutoff := time.Now().Add(-memory.maxAge)
+		for drop < len(entries.entries) && entries.entries[drop].at.Before(cutoff) {
+			drop++
+		}
+	}
+	if drop == 0 {
+		return
+	}
+	entries.trimmed = entries.entries[drop-1].message.Seq
+	entries.entries = append([]loggedMessage(nil), entries.entries[drop:]...)
+}
+
+// logEmit appends a room emit to the event log, the caller holds room.emitMu so emits are logged in order
+func (room *Room) logEmit(message Message) {
+	if room.server.eventLog == nil {
+		return
+	}
+	if err := room.server.eventLog.Append(room.key, message); err != nil {
+		log.Printf("Event log error: room=%s seq=%d: %v", room.Id, message.Seq, err)
+	}
+}
+
+// Replay returns the emits to the room with a sequence number above fromSeq, see WithEventLog
+func (socket *Server) Replay(roomId string, fromSeq uint64) ([]Message, error) {
+	return socket.replay("", roomId, fromSeq)
+}
+
+// Replay returns the emits to the tenant room with a sequence number above fromSeq
+func (tenant *Tenant) Replay(roomId string, fromSeq uint64) ([]Message, error) {
+	return tenant.server.replay(tenant.Id, roomId, fromSeq)
+}
+
+func (socket *Server) replay(tenant, roomId string, fromSeq uint64) ([]Message, error) {
+	if socket.eventLog == nil {
+		return nil, nil
+	}
+	key := roomKey(tenant, roomId)
+	if room := socket.rooms.get(key); room != nil {
+		// an emit numbered and not logged yet would look like a gap
+		room.emitMu.Lock()
+		defer room.emitMu.Unlock()
+	}
+	messages, err := socket.eventLog.Since(key, fromSeq)
+	if err != nil {
+		return nil, err
+	}
+	// a log that lost the emits following fromSeq, such as an evicted one, must not look like nothing was missed
+	if fromSeq < socket.rooms.lastSeq(key) && (len(messages) == 0 || messages[0].Seq != fromSeq+1) {
+		return nil, ErrReplayUnavailable
+	}
+	return messages, nil
+}
+
+// resume answers a ResumeEvent, replaying the missed emits of a room the client is a member of
+func (socket *Server) resume(message Message, client *Client) {
+	var request ResumeRequest
+	if err := Bind(message.Payload, &request); err != nil {
+		client.emitErrorFor(message, CodeInvalidPayload, err.Error())
+		return
+	}
+	room := socket.lookupRoom(client.Tenant(), request.Room)
+	if room == nil || IndexOf(client.ConnectionId, room.members()) == -1 {
+		client.emitErrorFor(message, CodeUnauthorized, "not a member of "+request.Room)
+		return
+	}
+
+	messages, err := socket.replay(client.Tenant(), request.Room, request.Seq)
+	if errors.Is(err, ErrReplayUnavailable) {
+		client.emitErrorFor(message, CodeReplayUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		client.emitErrorFor(message, CodeInternal, err.Error())
+		return
+	}
+	for _, replayed := range messages {
+		if err := client.emitMessage(replayed, client.writeTimeout()); err != nil {
+			return
+		}
+	}
+}
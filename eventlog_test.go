@@ -0,0 +1,68 @@
+package signal
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryEventLogEvictsIdleRooms(t *testing.T) {
+	memory := NewMemoryEventLog(0, 20*time.Millisecond)
+	memory.Append("idle", Message{Seq: 1})
+	time.Sleep(30 * time.Millisecond)
+	memory.Append("active", Message{Seq: 1})
+
+	if _, exists := memory.rooms["idle"]; exists || memory.idle.Len() != 1 {
+		t.Fatalf("%d room logs kept, the idle one is not evicted", memory.idle.Len())
+	}
+
+	// a log started again does not pretend to hold the emits before its first one
+	memory.Append("idle", Message{Seq: 5})
+	if _, err := memory.Since("idle", 2); !errors.Is(err, ErrReplayUnavailable) {
+		t.Errorf("replay from 2: %v, want %v", err, ErrReplayUnavailable)
+	}
+	if messages, err := memory.Since("idle", 4); err != nil || len(messages) != 1 {
+		t.Errorf("replay from 4: %v %v", messages, err)
+	}
+}
+
+func TestReplayAcrossRoomRecreation(t *testing.T) {
+	socket := IOServer("0", WithEventLog(NewMemoryEventLog(2, 0)))
+	client := connectClients(t, socket, 1)[0]
+
+	room := socket.Room("board")
+	room.Join(client)
+	room.Emit("draw", 1)
+	room.Emit("draw", 2)
+	room.Leave(client.ConnectionId)
+
+	socket.Room("board").Join(client)
+	socket.Room("board").Emit("draw", 3)
+
+	messages, err := socket.Replay("board", 1)
+	if err != nil {
+		t.Fatal("replay after the room was created again:", err)
+	}
+	if len(messages) != 2 || messages[0].Seq != 2 || messages[1].Seq != 3 {
+		t.Fatalf("replayed %+v, want the emits 2 and 3", messages)
+	}
+	if _, err := socket.Replay("board", 0); !errors.Is(err, ErrReplayUnavailable) {
+		t.Errorf("replay of a trimmed emit: %v, want %v", err, ErrReplayUnavailable)
+	}
+}
+
+func TestReplayOfEvictedLog(t *testing.T) {
+	memory := NewMemoryEventLog(0, 20*time.Millisecond)
+	socket := IOServer("0", WithEventLog(memory))
+	socket.Room("board").Emit("draw", 1)
+	socket.Room("board").Emit("draw", 2)
+	time.Sleep(30 * time.Millisecond)
+	socket.Room("other").Emit("draw", 1)
+
+	if _, err := socket.Replay("board", 1); !errors.Is(err, ErrReplayUnavailable) {
+		t.Errorf("replay of an evicted log: %v, want %v", err, ErrReplayUnavailable)
+	}
+	if messages, err := socket.Replay("board", 2); err != nil || len(messages) != 0 {
+		t.Errorf("replay of a client up to date: %v %v", messages, err)
+	}
+}
@@ -0,0 +1,89 @@
+package signal
+
+import "time"
+
+// RoomExpiredEvent is emitted to the members of a room right before it expires
+const RoomExpiredEvent = "room_expired"
+
+// RoomExpired is the payload of RoomExpiredEvent
+type RoomExpired struct {
+	Room string `json:"room"`
+}
+
+// SetTTL closes the room once ttl has elapsed, whatever its activity. A zero ttl removes the limit.
+func (room *Room) SetTTL(ttl time.Duration) *Room {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if room.ttlTimer != nil {
+		room.ttlTimer.Cancel()
+		room.ttlTimer = nil
+	}
+	if ttl > 0 {
+		room.ttlTimer = room.server.scheduler.schedule(time.Now().Add(ttl), room.expire)
+	}
+	return room
+}
+
+// SetMaxIdle closes the room once nothing was emitted to it and nobody joined it for maxIdle.
+// A zero maxIdle removes the limit.
+func (room *Room) SetMaxIdle(maxIdle time.Duration) *Room {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	room.maxIdle = maxIdle
+	room.idle.Store(maxIdle > 0)
+	room.lastActive.Store(time.Now().UnixNano())
+	if room.idleTimer != nil {
+		room.idleTimer.Cancel()
+		room.idleTimer = nil
+	}
+	if maxIdle > 0 {
+		room.idleTimer = room.server.scheduler.schedule(time.Now().Add(maxIdle), room.checkIdle)
+	}
+	return room
+}
+
+// touch records activity on the room, it does not need room.mu
+func (room *Room) touch() {
+	if room.idle.Load() {
+		room.lastActive.Store(time.Now().UnixNano())
+	}
+}
+
+// checkIdle expires the room, or schedules the next check when it was active meanwhile
+func (room *Room) checkIdle() {
+	room.mu.Lock()
+	if room.maxIdle <= 0 {
+		room.mu.Unlock()
+		return
+	}
+	deadline := time.Unix(0, room.lastActive.Load()).Add(room.maxIdle)
+	if time.Now().Before(deadline) {
+		room.idleTimer = room.server.scheduler.schedule(deadline, room.checkIdle)
+		room.mu.Unlock()
+		return
+	}
+	room.mu.Unlock()
+	room.expire()
+}
+
+// expire notifies the members and closes the room
+func (room *Room) expire() {
+	room.server.emitAll(room.Clients(), RoomExpiredEvent, RoomExpired{Room: room.Id})
+	room.Close()
+}
+
+// stopExpiry cancels the pending expiry timers, the caller holds room.mu
+func (room *Room) stopExpiry() {
+	if room.ttlTimer != nil {
+		room.ttlTimer.Cancel()
+		room.ttlTimer = nil
+	}
+	if room.idleTimer != nil {
+		room.idleTimer.Cancel()
+		room.idleTimer = nil
+	}
+	room.maxIdle = 0
+	room.idle.Store(false)
+}
@@ -0,0 +1,424 @@
+// Package filetransfer sends files between a signal.io server and its clients on top of streams, with
+// SHA-256 verification, progress callbacks, resumption and size limits.
+//
+//	files := filetransfer.New(socket, "file", filetransfer.WithMaxSize(50<<20))
+//	files.OnReceive(func(file *filetransfer.File, client *signal.Client) {
+//		io.Copy(storage.Create(file.Header.Name), file) // fails with ErrChecksumMismatch on corruption
+//	})
+//
+//	f, _ := os.Open("report.pdf")
+//	info, _ := f.Stat()
+//	files.Send(client, "report.pdf", f, info.Size())
+//
+// A transfer is a "<event>:start" event carrying the Header, followed by the StreamChunk events of a
+// stream named in Header.Stream, on "<event>". Clients resume an interrupted download by emitting
+// "<event>:resume" with a Resume payload, uploads are resumed by starting them again with an Offset.
+package filetransfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"time"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// maxPendingHeaders bounds the announced uploads of a connection whose stream did not start yet
+const maxPendingHeaders = signal.DefaultMaxOpenStreams
+
+// headerTimeout is how long an announced upload waits for its stream
+const headerTimeout = time.Minute
+
+var (
+	// ErrTooLarge is returned when a file exceeds the size limit
+	ErrTooLarge = errors.New("filetransfer: file too large")
+	// ErrChecksumMismatch is returned when the received content does not match the SHA-256 of the header
+	ErrChecksumMismatch = errors.New("filetransfer: checksum mismatch")
+	// ErrSizeMismatch is returned when the received content is shorter or longer than announced
+	ErrSizeMismatch = errors.New("filetransfer: size mismatch")
+	// ErrUnknownTransfer is returned when a resumed transfer expired or never existed
+	ErrUnknownTransfer = errors.New("filetransfer: unknown transfer")
+	// ErrTooManyUploads is returned to clients announcing more uploads than they stream
+	ErrTooManyUploads = errors.New("filetransfer: too many pending uploads")
+)
+
+// Header describes a file, it is sent before the content
+type Header struct {
+	// Id identifies the transfer across resumptions
+	Id string `json:"id"`
+	// Stream is the id of the stream carrying the content
+	Stream string `json:"stream"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	// SHA256 is the hex encoded checksum of the whole file
+	SHA256 string `json:"sha256"`
+	// Offset is the position the content starts at, non-zero when the transfer is resumed
+	Offset      int64  `json:"offset,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// Resume is the payload clients send to continue a download from Offset
+type Resume struct {
+	Id     string `json:"id"`
+	Offset int64  `json:"offset"`
+}
+
+// Progress reports how much of a transfer has been sent or received, Offset included
+type Progress struct {
+	Id          string
+	Name        string
+	Transferred int64
+	Size        int64
+}
+
+// Option configures Transfers
+type Option func(*Transfers)
+
+// WithMaxSize rejects uploads larger than size bytes, unlimited by default. It also bounds the bytes
+// buffered for an upload its handler did not read yet.
+func WithMaxSize(size int64) Option {
+	return func(transfers *Transfers) {
+		transfers.maxSize = size
+	}
+}
+
+// WithProgress is called as content is sent to or received from clients
+func WithProgress(progress func(client *signal.Client, progress Progress)) Option {
+	return func(transfers *Transfers) {
+		transfers.progress = progress
+	}
+}
+
+// WithResumeWindow changes how long sent files can be resumed, 10 minutes by default
+func WithResumeWindow(window time.Duration) Option {
+	return func(transfers *Transfers) {
+		transfers.resumeWindow = window
+	}
+}
+
+// Transfers sends and receives the files of one event
+type Transfers struct {
+	socket       *signal.Server
+	event        string
+	maxSize      int64
+	progress     func(client *signal.Client, progress Progress)
+	resumeWindow time.Duration
+
+	mu   sync.Mutex
+	sent map[string]*sentFile
+	// starting holds the announced uploads by connection and stream, until their stream starts
+	starting map[string]map[string]pendingHeader
+	receive  func(file *File, client *signal.Client)
+}
+
+type pendingHeader struct {
+	header  Header
+	expires time.Time
+}
+
+// sentFile is kept for the resume window so downloads can continue after a reconnection
+type sentFile struct {
+	header  Header
+	content io.ReaderAt
+	userId  string
+	expires time.Time
+}
+
+// New registers the transfer events of event on socket
+func New(socket *signal.Server, event string, options ...Option) *Transfers {
+	transfers := &Transfers{
+		socket:       socket,
+		event:        event,
+		resumeWindow: 10 * time.Minute,
+		sent:         make(map[string]*sentFile),
+		starting:     make(map[string]map[string]pendingHeader),
+	}
+	for _, option := range options {
+		option(transfers)
+	}
+	var streamOptions []signal.StreamOption
+	if transfers.maxSize > 0 && transfers.maxSize < signal.DefaultMaxStreamBuffer {
+		streamOptions = append(streamOptions, signal.WithMaxStreamBuffer(int(transfers.maxSize)))
+	}
+	socket.On(event+":resume", transfers.onResume)
+	socket.On(event+":start", transfers.onStart)
+	socket.OnStream(event, transfers.onStream, streamOptions...)
+	socket.OnStateChange(func(client *signal.Client, from, to signal.ConnectionState) {
+		if to != signal.StateClosed {
+			return
+		}
+		transfers.mu.Lock()
+		delete(transfers.starting, client.ConnectionId)
+		transfers.mu.Unlock()
+	})
+	return transfers
+}
+
+// Send sends size bytes of content to the client, the returned header identifies the transfer
+func (transfers *Transfers) Send(client *signal.Client, name string, content io.ReaderAt, size int64) (Header, error) {
+	checksum := sha256.New()
+	if _, err := io.Copy(checksum, io.NewSectionReader(content, 0, size)); err != nil {
+		return Header{}, err
+	}
+	header := Header{
+		Id:     signal.CreateConnectionId(),
+		Name:   name,
+		Size:   size,
+		SHA256: hex.EncodeToString(checksum.Sum(nil)),
+	}
+
+	transfers.mu.Lock()
+	transfers.expire()
+	transfers.sent[header.Id] = &sentFile{
+		header:  header,
+		content: content,
+		userId:  client.UserId(),
+		expires: time.Now().Add(transfers.resumeWindow),
+	}
+	transfers.mu.Unlock()
+
+	return transfers.send(client, header, content)
+}
+
+// SendToRoom sends the file to every member of the room, each over its own stream
+func (transfers *Transfers) SendToRoom(room *signal.Room, name string, content io.ReaderAt, size int64) error {
+	var errs []error
+	for _, client := range room.Clients() {
+		if _, err := transfers.Send(client, name, content, size); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", client.ConnectionId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// send streams the content from header.Offset
+func (transfers *Transfers) send(client *signal.Client, header Header, content io.ReaderAt) (Header, error) {
+	stream := client.OpenStream(transfers.event)
+	header.Stream = stream.Id()
+	if err := client.Emit(transfers.event+":start", header); err != nil {
+		return header, err
+	}
+
+	writer := &progressWriter{
+		writer:      stream,
+		transferred: header.Offset,
+		report:      transfers.reporter(client, header),
+	}
+	if _, err := io.Copy(writer, io.NewSectionReader(content, header.Offset, header.Size-header.Offset)); err != nil {
+		stream.CloseWithError(err)
+		return header, err
+	}
+	return header, stream.Close()
+}
+
+func (transfers *Transfers) onResume(payload signal.Payload, client *signal.Client) {
+	var resume Resume
+	if err := signal.Bind(payload, &resume); err != nil {
+		client.EmitError(err)
+		return
+	}
+
+	transfers.mu.Lock()
+	transfers.expire()
+	sent, exists := transfers.sent[resume.Id]
+	transfers.mu.Unlock()
+	// a resumed download goes to a new connection, of the same user when the first one was authenticated
+	if !exists || (sent.userId != "" && sent.userId != client.UserId()) {
+		client.EmitError(signal.NewError(signal.CodeInvalidPayload, ErrUnknownTransfer.Error()))
+		return
+	}
+	if resume.Offset < 0 || resume.Offset > sent.header.Size {
+		client.EmitError(signal.NewError(signal.CodeInvalidPayload, "filetransfer: offset out of range"))
+		return
+	}
+
+	header := sent.header
+	header.Offset = resume.Offset
+	go transfers.send(client, header, sent.content)
+}
+
+// expire forgets the sent files past their resume window, the caller holds transfers.mu
+func (transfers *Transfers) expire() {
+	now := time.Now()
+	for id, sent := range transfers.sent {
+		if now.After(sent.expires) {
+			delete(transfers.sent, id)
+		}
+	}
+}
+
+// OnReceive registers the handler of the files clients upload, it runs in its own goroutine
+func (transfers *Transfers) OnReceive(handler func(file *File, client *signal.Client)) {
+	transfers.mu.Lock()
+	defer transfers.mu.Unlock()
+	transfers.receive = handler
+}
+
+func (transfers *Transfers) onStart(payload signal.Payload, client *signal.Client) {
+	var header Header
+	if err := signal.Bind(payload, &header); err != nil {
+		client.EmitError(err)
+		return
+	}
+	if header.Stream == "" || header.Offset < 0 || header.Offset > header.Size {
+		client.EmitError(signal.NewError(signal.CodeInvalidPayload, "filetransfer: invalid header"))
+		return
+	}
+	if transfers.maxSize > 0 && header.Size > transfers.maxSize {
+		client.EmitError(signal.NewError(signal.CodeInvalidPayload, ErrTooLarge.Error()))
+		return
+	}
+
+	transfers.mu.Lock()
+	defer transfers.mu.Unlock()
+	transfers.expireHeaders()
+	pending := transfers.starting[client.ConnectionId]
+	if pending == nil {
+		pending = make(map[string]pendingHeader)
+		transfers.starting[client.ConnectionId] = pending
+	}
+	if _, exists := pending[header.Stream]; !exists && len(pending) >= maxPendingHeaders {
+		client.EmitError(signal.NewError(signal.CodeRateLimited, ErrTooManyUploads.Error()))
+		return
+	}
+	pending[header.Stream] = pendingHeader{header: header, expires: time.Now().Add(headerTimeout)}
+}
+
+// expireHeaders forgets the announced uploads whose stream never started, the caller holds transfers.mu
+func (transfers *Transfers) expireHeaders() {
+	now := time.Now()
+	for connectionId, pending := range transfers.starting {
+		for stream, header := range pending {
+			if now.After(header.expires) {
+				delete(pending, stream)
+			}
+		}
+		if len(pending) == 0 {
+			delete(transfers.starting, connectionId)
+		}
+	}
+}
+
+func (transfers *Transfers) onStream(reader *signal.StreamReader, client *signal.Client) {
+	transfers.mu.Lock()
+	pending, started := transfers.starting[client.ConnectionId][reader.Id()]
+	delete(transfers.starting[client.ConnectionId], reader.Id())
+	receive := transfers.receive
+	transfers.mu.Unlock()
+
+	if !started {
+		// drain streams nobody announced, their buffer is bounded by the stream limits
+		client.EmitError(signal.NewError(signal.CodeInvalidPayload, "filetransfer: stream without header"))
+		io.Copy(io.Discard, reader)
+		return
+	}
+	header := pending.header
+	file := newFile(header, reader)
+	if receive != nil {
+		file.report = transfers.reporter(client, header)
+		receive(file, client)
+	}
+	// the handler may stop early, the rest of the announced content is dropped and anything past it refused
+	io.Copy(io.Discard, io.LimitReader(reader, header.Size-file.received))
+	reader.Abort(ErrTooLarge)
+}
+
+func (transfers *Transfers) reporter(client *signal.Client, header Header) func(int64) {
+	if transfers.progress == nil {
+		return nil
+	}
+	return func(transferred int64) {
+		transfers.progress(client, Progress{Id: header.Id, Name: header.Name, Transferred: transferred, Size: header.Size})
+	}
+}
+
+// File is an upload being received, reading it returns the content from Header.Offset. The last Read
+// fails with ErrSizeMismatch or ErrChecksumMismatch instead of io.EOF when the content is not the one
+// announced. Resumed uploads are only verified when the bytes received before are passed to Prefix.
+type File struct {
+	Header Header
+
+	reader   io.Reader
+	checksum hash.Hash
+	received int64
+	verify   bool
+	report   func(int64)
+}
+
+// NewFile verifies a file received by a Go client: push the chunks of the Header.Stream stream to reader,
+// and read the content from the returned File. progress may be nil.
+func NewFile(header Header, reader *signal.StreamReader, progress func(Progress)) *File {
+	file := newFile(header, reader)
+	if progress != nil {
+		file.report = func(transferred int64) {
+			progress(Progress{Id: header.Id, Name: header.Name, Transferred: transferred, Size: header.Size})
+		}
+	}
+	return file
+}
+
+func newFile(header Header, reader io.Reader) *File {
+	return &File{
+		Header:   header,
+		reader:   reader,
+		checksum: sha256.New(),
+		received: header.Offset,
+		verify:   header.Offset == 0,
+	}
+}
+
+// Prefix feeds the first Header.Offset bytes of the file, stored by an earlier attempt, to the checksum
+// so a resumed upload is verified too. It must be called before the first Read.
+func (file *File) Prefix(prefix io.Reader) error {
+	n, err := io.Copy(file.checksum, io.LimitReader(prefix, file.Header.Offset))
+	if err != nil {
+		return err
+	}
+	if n != file.Header.Offset {
+		return ErrSizeMismatch
+	}
+	file.verify = true
+	return nil
+}
+
+func (file *File) Read(p []byte) (int, error) {
+	n, err := file.reader.Read(p)
+	file.received += int64(n)
+	file.checksum.Write(p[:n])
+	if file.received > file.Header.Size {
+		return n, ErrSizeMismatch
+	}
+	if n > 0 && file.report != nil {
+		file.report(file.received)
+	}
+	if err == io.EOF {
+		if file.received != file.Header.Size {
+			return n, ErrSizeMismatch
+		}
+		if file.verify && hex.EncodeToString(file.checksum.Sum(nil)) != file.Header.SHA256 {
+			return n, ErrChecksumMismatch
+		}
+	}
+	return n, err
+}
+
+// progressWriter reports the bytes written through it
+type progressWriter struct {
+	writer      io.Writer
+	transferred int64
+	report      func(int64)
+}
+
+func (writer *progressWriter) Write(p []byte) (int, error) {
+	n, err := writer.writer.Write(p)
+	writer.transferred += int64(n)
+	if writer.report != nil {
+		writer.report(writer.transferred)
+	}
+	return n, err
+}
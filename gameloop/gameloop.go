@@ -0,0 +1,238 @@
+// Package gameloop runs authoritative game loops on rooms: a fixed-rate tick per room, the inputs clients
+// sent since the previous tick, and a binary state broadcast after every step.
+//
+//	games := gameloop.New(socket, "game")
+//	loop := games.Start(room, 30, func(tick gameloop.Tick) ([]byte, error) {
+//		for _, input := range tick.Inputs {
+//			world.Apply(input.Client.ConnectionId, input.Data)
+//		}
+//		world.Step(tick.Delta)
+//		return world.MarshalBinary()
+//	})
+//	defer loop.Stop()
+//
+// Clients emit "<event>:input" with an InputMessage and receive "<event>" events with a Frame. State is
+// sent as bytes: a binary codec such as CBOR or Protocol Buffers sends it as is, JSON in base64.
+package gameloop
+
+import (
+	"bytes"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// InputMessage is the payload clients send with their inputs, Seq numbers the inputs of a client so it
+// can reconcile its predictions with Frame.Acks
+type InputMessage struct {
+	Room string `json:"room"`
+	Seq  uint64 `json:"seq"`
+	Data any    `json:"data"`
+}
+
+// Input is an input received between two ticks
+type Input struct {
+	Client   *signal.Client
+	Seq      uint64
+	Data     any
+	Received time.Time
+}
+
+// Tick is a step of the loop, Inputs are in arrival order
+type Tick struct {
+	Number uint64
+	// Delta is the time elapsed since the previous tick
+	Delta  time.Duration
+	Inputs []Input
+}
+
+// StepFunc advances the game by one tick and returns the encoded state to broadcast, nil to send none.
+// A state identical to the previous one is only sent once per second, for the players who just joined.
+type StepFunc func(tick Tick) ([]byte, error)
+
+// Frame is the payload of the state broadcast
+type Frame struct {
+	Tick  uint64 `json:"tick"`
+	State []byte `json:"state"`
+	// Acks holds the last input sequence number processed per connection id
+	Acks map[string]uint64 `json:"acks,omitempty"`
+}
+
+// Option configures Games
+type Option func(*Games)
+
+// WithMaxInputs caps the inputs kept per client between two ticks, the oldest are dropped, 32 by default
+func WithMaxInputs(inputs int) Option {
+	return func(games *Games) {
+		games.maxInputs = inputs
+	}
+}
+
+// Games runs the loops of the rooms playing on one event
+type Games struct {
+	socket    *signal.Server
+	event     string
+	maxInputs int
+
+	mu    sync.Mutex
+	loops map[string]*Loop
+}
+
+// New registers the input event of event on socket
+func New(socket *signal.Server, event string, options ...Option) *Games {
+	games := &Games{
+		socket:    socket,
+		event:     event,
+		maxInputs: 32,
+		loops:     make(map[string]*Loop),
+	}
+	for _, option := range options {
+		option(games)
+	}
+	socket.On(event+":input", games.onInput)
+	return games
+}
+
+// Start runs step on the room rate times per second until the loop is stopped or the server shuts down.
+// A room runs a single loop, starting another one stops the previous.
+func (games *Games) Start(room *signal.Room, rate int, step StepFunc) *Loop {
+	loop := &Loop{
+		games: games,
+		room:  room,
+		step:  step,
+		rate:  uint64(rate),
+		key:   room.Tenant + "/" + room.Id,
+		acks:  make(map[string]uint64),
+		last:  time.Now(),
+	}
+	games.mu.Lock()
+	previous := games.loops[loop.key]
+	games.loops[loop.key] = loop
+	games.mu.Unlock()
+	if previous != nil {
+		previous.ticker.Stop()
+	}
+	loop.ticker = games.socket.Every(time.Second/time.Duration(rate), func(*signal.Server) {
+		loop.tick()
+	})
+	return loop
+}
+
+func (games *Games) onInput(payload signal.Payload, client *signal.Client) {
+	var message InputMessage
+	if err := signal.Bind(payload, &message); err != nil {
+		client.EmitError(err)
+		return
+	}
+	games.mu.Lock()
+	loop, exists := games.loops[client.Tenant()+"/"+message.Room]
+	games.mu.Unlock()
+	if !exists || !slices.Contains(client.Rooms(), message.Room) {
+		client.EmitError(signal.NewError(signal.CodeUnauthorized, "gameloop: not playing in "+message.Room))
+		return
+	}
+	loop.push(Input{Client: client, Seq: message.Seq, Data: message.Data, Received: time.Now()})
+}
+
+// Loop is the game loop of a room
+type Loop struct {
+	games  *Games
+	room   *signal.Room
+	step   StepFunc
+	rate   uint64
+	key    string
+	ticker *signal.Ticker
+
+	mu      sync.Mutex
+	inputs  []Input
+	acks    map[string]uint64
+	number  uint64
+	last    time.Time
+	state   []byte
+	stopped bool
+}
+
+// Stop stops the loop, a tick in progress completes
+func (loop *Loop) Stop() {
+	loop.mu.Lock()
+	loop.stopped = true
+	loop.mu.Unlock()
+	loop.ticker.Stop()
+	loop.games.mu.Lock()
+	if loop.games.loops[loop.key] == loop {
+		delete(loop.games.loops, loop.key)
+	}
+	loop.games.mu.Unlock()
+}
+
+// Tick returns the number of the last tick run
+func (loop *Loop) Tick() uint64 {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+	return loop.number
+}
+
+// push queues an input for the next tick, dropping the oldest input of the client past the limit
+func (loop *Loop) push(input Input) {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+	count := 0
+	for _, queued := range loop.inputs {
+		if queued.Client == input.Client {
+			count++
+		}
+	}
+	if count >= loop.games.maxInputs {
+		oldest := slices.IndexFunc(loop.inputs, func(queued Input) bool { return queued.Client == input.Client })
+		loop.inputs = slices.Delete(loop.inputs, oldest, oldest+1)
+	}
+	loop.inputs = append(loop.inputs, input)
+}
+
+func (loop *Loop) tick() {
+	loop.mu.Lock()
+	if loop.stopped {
+		loop.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	loop.number++
+	tick := Tick{Number: loop.number, Delta: now.Sub(loop.last), Inputs: loop.inputs}
+	loop.inputs = nil
+	loop.last = now
+	for _, input := range tick.Inputs {
+		loop.acks[input.Client.ConnectionId] = max(loop.acks[input.Client.ConnectionId], input.Seq)
+	}
+	loop.mu.Unlock()
+
+	state, err := loop.step(tick)
+	if err != nil {
+		log.Printf("Game loop error: room=%s tick=%d: %v", loop.room.Id, tick.Number, err)
+		return
+	}
+	if state == nil {
+		return
+	}
+
+	loop.mu.Lock()
+	unchanged := bytes.Equal(state, loop.state) && len(tick.Inputs) == 0 && tick.Number%loop.rate != 0
+	loop.state = state
+	acks := make(map[string]uint64, len(loop.acks))
+	members := loop.room.Clients()
+	for connectionId, seq := range loop.acks {
+		if signal.IndexOf(connectionId, members) == -1 {
+			// players who left are forgotten
+			delete(loop.acks, connectionId)
+			continue
+		}
+		acks[connectionId] = seq
+	}
+	loop.mu.Unlock()
+	if unchanged {
+		return
+	}
+	loop.room.Emit(loop.games.event, Frame{Tick: tick.Number, State: state, Acks: acks})
+}
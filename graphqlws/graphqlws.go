@@ -0,0 +1,467 @@
+// Package graphqlws exposes rooms as GraphQL subscriptions over the graphql-transport-ws protocol, so
+// GraphQL clients such as graphql-ws or Apollo consume the events emitted to rooms without a second client.
+//
+//	bridge := graphqlws.New(socket)
+//	bridge.Room("messages", "chat:message")
+//	http.Handle("/graphql", bridge)
+//
+// A client then subscribes with
+//
+//	subscription { messages(room: "lobby") { text user { name } } }
+//
+// and receives, for every "chat:message" emitted to the room, the selected fields of its payload.
+//
+// The bridge does not embed a GraphQL engine nor a schema: it accepts subscription documents with one root
+// field, arguments, variables, aliases and nested selections picking fields of the payloads. Fragments and
+// directives are rejected, and so are queries and mutations which are left to the application GraphQL server.
+package graphqlws
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+	"github.com/gorilla/websocket"
+)
+
+// Subprotocol is the WebSocket subprotocol of the graphql-transport-ws protocol
+const Subprotocol = "graphql-transport-ws"
+
+// close codes of the graphql-transport-ws protocol
+const (
+	CloseBadRequest          = 4400
+	CloseUnauthorized        = 4401
+	CloseForbidden           = 4403
+	CloseSubprotocol         = 4406
+	CloseInitTimeout         = 4408
+	CloseSubscriberExists    = 4409
+	CloseTooManyInitRequests = 4429
+)
+
+// Source is the room feeding a subscription
+type Source struct {
+	Tenant string
+	Room   string
+	// Events restricts the subscription to these events, every event of the room when empty
+	Events []string
+	// Transform maps an emit to the value of the subscription field, returning false skips the emit.
+	// The payload is used when it is nil.
+	Transform func(message signal.Message) (any, bool)
+}
+
+// Request describes a subscription to resolve
+type Request struct {
+	Field string
+	Args  map[string]any
+	// Init is the payload of the connection_init message, it usually carries the credentials
+	Init map[string]any
+	HTTP *http.Request
+}
+
+// Resolver returns the source of a subscription field, an error is sent to the client as is
+type Resolver func(request Request) (Source, error)
+
+// InitFunc accepts or rejects a connection from the payload of its connection_init message
+type InitFunc func(r *http.Request, payload map[string]any) error
+
+// Option configures a Bridge
+type Option func(*Bridge)
+
+// WithInit checks the connection_init message, a connection it rejects is closed with CloseForbidden
+func WithInit(init InitFunc) Option {
+	return func(bridge *Bridge) {
+		bridge.init = init
+	}
+}
+
+// WithTenant returns the tenant of the rooms subscribed through Room fields, the default tenant otherwise
+func WithTenant(tenant func(request Request) string) Option {
+	return func(bridge *Bridge) {
+		bridge.tenant = tenant
+	}
+}
+
+// WithCheckOrigin decides which cross-origin handshakes are accepted, same-origin ones only by default
+func WithCheckOrigin(checkOrigin func(r *http.Request) bool) Option {
+	return func(bridge *Bridge) {
+		bridge.upgrader.CheckOrigin = checkOrigin
+	}
+}
+
+// WithInitTimeout closes the connections not initialised within timeout, 10 seconds by default
+func WithInitTimeout(timeout time.Duration) Option {
+	return func(bridge *Bridge) {
+		bridge.initTimeout = timeout
+	}
+}
+
+// WithBuffer sets how many messages wait for a slow connection before it is closed, 64 by default
+func WithBuffer(messages int) Option {
+	return func(bridge *Bridge) {
+		bridge.buffer = messages
+	}
+}
+
+// Bridge serves the graphql-transport-ws protocol, it is an http.Handler
+type Bridge struct {
+	socket      *signal.Server
+	upgrader    websocket.Upgrader
+	init        InitFunc
+	tenant      func(request Request) string
+	initTimeout time.Duration
+	buffer      int
+
+	mu     sync.RWMutex
+	fields map[string]Resolver
+}
+
+// New creates a bridge reading the rooms of socket, mount it on an HTTP path
+func New(socket *signal.Server, options ...Option) *Bridge {
+	bridge := &Bridge{
+		socket:      socket,
+		upgrader:    websocket.Upgrader{Subprotocols: []string{Subprotocol}},
+		initTimeout: 10 * time.Second,
+		buffer:      64,
+		fields:      make(map[string]Resolver),
+	}
+	for _, option := range options {
+		option(bridge)
+	}
+	return bridge
+}
+
+// Field registers a subscription field, replacing the resolver registered under the same name
+func (bridge *Bridge) Field(name string, resolver Resolver) {
+	bridge.mu.Lock()
+	defer bridge.mu.Unlock()
+	bridge.fields[name] = resolver
+}
+
+// Room registers a subscription field taking a room argument, which receives the payloads of the events
+// emitted to that room. It does not authorize the subscription, check the Init payload with WithInit.
+func (bridge *Bridge) Room(name string, events ...string) {
+	bridge.Field(name, func(request Request) (Source, error) {
+		room, ok := request.Args["room"].(string)
+		if !ok || room == "" {
+			return Source{}, fmt.Errorf("field %q requires a room argument", name)
+		}
+		source := Source{Room: room, Events: events}
+		if bridge.tenant != nil {
+			source.Tenant = bridge.tenant(request)
+		}
+		return source, nil
+	})
+}
+
+func (bridge *Bridge) resolver(name string) Resolver {
+	bridge.mu.RLock()
+	defer bridge.mu.RUnlock()
+	return bridge.fields[name]
+}
+
+// message is a frame of the graphql-transport-ws protocol
+type message struct {
+	Id      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type subscribePayload struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables"`
+	OperationName string         `json:"operationName"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+// connection is a graphql-transport-ws connection, its frames are written by a single goroutine
+type connection struct {
+	bridge  *Bridge
+	conn    *websocket.Conn
+	request *http.Request
+	send    chan []byte
+	done    chan struct{}
+	once    sync.Once
+
+	mu            sync.Mutex
+	initialised   bool
+	acknowledged  bool
+	init          map[string]any
+	subscriptions map[string]*subscription
+	closed        bool
+}
+
+type subscription struct {
+	active  atomic.Bool
+	unwatch func()
+}
+
+// ServeHTTP upgrades the request and serves the connection until it closes
+func (bridge *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := bridge.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader answered the request already
+		return
+	}
+	c := &connection{
+		bridge:        bridge,
+		conn:          conn,
+		request:       r,
+		send:          make(chan []byte, bridge.buffer),
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]*subscription),
+	}
+	if conn.Subprotocol() != Subprotocol {
+		c.close(CloseSubprotocol, "Subprotocol not acceptable")
+		return
+	}
+	go c.writeLoop()
+	timer := time.AfterFunc(bridge.initTimeout, func() {
+		c.mu.Lock()
+		initialised := c.initialised
+		c.mu.Unlock()
+		if !initialised {
+			c.close(CloseInitTimeout, "Connection initialisation timeout")
+		}
+	})
+	defer timer.Stop()
+	c.readLoop()
+}
+
+func (c *connection) readLoop() {
+	// a failed read means the peer is gone, there is no one to send a close frame to
+	defer c.close(websocket.CloseAbnormalClosure, "")
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var frame message
+		if err := json.Unmarshal(data, &frame); err != nil || frame.Type == "" {
+			c.close(CloseBadRequest, "Invalid message received")
+			return
+		}
+		if !c.handle(frame) {
+			return
+		}
+	}
+}
+
+// handle processes a client frame, it returns false once the connection is closed
+func (c *connection) handle(frame message) bool {
+	switch frame.Type {
+	case "connection_init":
+		return c.initialise(frame)
+	case "ping":
+		c.write(message{Type: "pong"})
+	case "pong":
+	case "subscribe":
+		return c.subscribe(frame)
+	case "complete":
+		c.complete(frame.Id)
+	default:
+		c.close(CloseBadRequest, fmt.Sprintf("Invalid message type %q", frame.Type))
+		return false
+	}
+	return true
+}
+
+func (c *connection) initialise(frame message) bool {
+	c.mu.Lock()
+	initialised := c.initialised
+	c.initialised = true
+	c.mu.Unlock()
+	if initialised {
+		c.close(CloseTooManyInitRequests, "Too many initialisation requests")
+		return false
+	}
+
+	var payload map[string]any
+	if len(frame.Payload) > 0 {
+		if err := json.Unmarshal(frame.Payload, &payload); err != nil {
+			c.close(CloseBadRequest, "Invalid connection_init payload")
+			return false
+		}
+	}
+	if init := c.bridge.init; init != nil {
+		if err := init(c.request, payload); err != nil {
+			c.close(CloseForbidden, "Forbidden")
+			return false
+		}
+	}
+
+	c.mu.Lock()
+	c.acknowledged = true
+	c.init = payload
+	c.mu.Unlock()
+	c.write(message{Type: "connection_ack"})
+	return true
+}
+
+func (c *connection) subscribe(frame message) bool {
+	if frame.Id == "" {
+		c.close(CloseBadRequest, "Subscribe message requires an id")
+		return false
+	}
+	c.mu.Lock()
+	acknowledged, init := c.acknowledged, c.init
+	_, exists := c.subscriptions[frame.Id]
+	c.mu.Unlock()
+	if !acknowledged {
+		c.close(CloseUnauthorized, "Unauthorized")
+		return false
+	}
+	if exists {
+		c.close(CloseSubscriberExists, fmt.Sprintf("Subscriber for %s already exists", frame.Id))
+		return false
+	}
+
+	var payload subscribePayload
+	if err := json.Unmarshal(frame.Payload, &payload); err != nil {
+		c.close(CloseBadRequest, "Invalid subscribe payload")
+		return false
+	}
+	root, err := parseSubscription(payload.Query, payload.OperationName, payload.Variables)
+	if err != nil {
+		c.fail(frame.Id, err)
+		return true
+	}
+	resolver := c.bridge.resolver(root.name)
+	if resolver == nil {
+		c.fail(frame.Id, fmt.Errorf("cannot query field %q on type \"Subscription\"", root.name))
+		return true
+	}
+	source, err := resolver(Request{Field: root.name, Args: root.args, Init: init, HTTP: c.request})
+	if err != nil {
+		c.fail(frame.Id, err)
+		return true
+	}
+
+	sub := &subscription{}
+	sub.active.Store(true)
+	sub.unwatch = c.bridge.socket.Tenant(source.Tenant).Watch(source.Room, func(emit signal.Message) {
+		if !sub.active.Load() || len(source.Events) > 0 && !slices.Contains(source.Events, emit.EventName) {
+			return
+		}
+		c.next(frame.Id, root, source, emit)
+	})
+	c.mu.Lock()
+	closed := c.closed
+	if !closed {
+		c.subscriptions[frame.Id] = sub
+	}
+	c.mu.Unlock()
+	if closed {
+		sub.unwatch()
+	}
+	return true
+}
+
+// next sends an emit to a subscription, it runs on the emitting goroutine
+func (c *connection) next(id string, root field, source Source, emit signal.Message) {
+	value := any(emit.Payload)
+	if source.Transform != nil {
+		var ok bool
+		if value, ok = source.Transform(emit); !ok {
+			return
+		}
+	}
+	// the value goes through JSON so selections apply to structs as they apply to maps
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("graphqlws: encoding %s for subscription %s: %v", emit.EventName, id, err)
+		return
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		log.Printf("graphqlws: decoding %s for subscription %s: %v", emit.EventName, id, err)
+		return
+	}
+	result, err := json.Marshal(map[string]any{
+		"data": map[string]any{root.key(): project(decoded, root.selections)},
+	})
+	if err != nil {
+		log.Printf("graphqlws: encoding %s for subscription %s: %v", emit.EventName, id, err)
+		return
+	}
+	c.write(message{Id: id, Type: "next", Payload: result})
+}
+
+// fail answers a subscription that could not start, it is over for the client
+func (c *connection) fail(id string, err error) {
+	payload, _ := json.Marshal([]graphQLError{{Message: err.Error()}})
+	c.write(message{Id: id, Type: "error", Payload: payload})
+}
+
+func (c *connection) complete(id string) {
+	c.mu.Lock()
+	sub := c.subscriptions[id]
+	delete(c.subscriptions, id)
+	c.mu.Unlock()
+	if sub != nil {
+		sub.active.Store(false)
+		sub.unwatch()
+	}
+}
+
+// write queues a frame, a connection too slow to keep up is closed rather than blocking the emitters
+func (c *connection) write(frame message) {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		log.Printf("graphqlws: encoding %s: %v", frame.Type, err)
+		return
+	}
+	select {
+	case c.send <- data:
+	case <-c.done:
+	default:
+		go c.close(websocket.CloseTryAgainLater, "Slow consumer")
+	}
+}
+
+func (c *connection) writeLoop() {
+	for {
+		select {
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-c.bridge.socket.Done():
+			c.close(websocket.CloseGoingAway, "Server shutting down")
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// close stops the subscriptions and closes the connection with code, once
+func (c *connection) close(code int, reason string) {
+	c.once.Do(func() {
+		close(c.done)
+		c.mu.Lock()
+		c.closed = true
+		subscriptions := c.subscriptions
+		c.subscriptions = make(map[string]*subscription)
+		c.mu.Unlock()
+		for _, sub := range subscriptions {
+			sub.active.Store(false)
+			sub.unwatch()
+		}
+		if code != websocket.CloseAbnormalClosure {
+			frame := websocket.FormatCloseMessage(code, reason)
+			c.conn.WriteControl(websocket.CloseMessage, frame, time.Now().Add(time.Second))
+		}
+		c.conn.Close()
+	})
+}
@@ -0,0 +1,370 @@
+package graphqlws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// field is a selected field, selections are empty for a leaf or to select the whole value
+type field struct {
+	alias      string
+	name       string
+	args       map[string]any
+	selections []field
+}
+
+// key is the name of the field in the result
+func (f field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenName
+	tokenPunct
+	tokenString
+	tokenNumber
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+// tokenize splits a GraphQL document, commas, white space and comments being insignificant
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(source) && source[i] != '\n' && source[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(source[i:], "..."):
+			tokens = append(tokens, token{tokenPunct, "..."})
+			i += 3
+		case strings.ContainsRune("{}()[]:=!$@|&", rune(c)):
+			tokens = append(tokens, token{tokenPunct, string(c)})
+			i++
+		case strings.HasPrefix(source[i:], `"""`):
+			end := strings.Index(source[i+3:], `"""`)
+			if end == -1 {
+				return nil, errors.New("unterminated block string")
+			}
+			tokens = append(tokens, token{tokenString, source[i+3 : i+3+end]})
+			i += end + 6
+		case c == '"':
+			end := i + 1
+			for end < len(source) && source[end] != '"' {
+				if source[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(source) {
+				return nil, errors.New("unterminated string")
+			}
+			var value string
+			// GraphQL string escapes are those of JSON
+			if err := json.Unmarshal([]byte(source[i:end+1]), &value); err != nil {
+				return nil, fmt.Errorf("invalid string %s", source[i:end+1])
+			}
+			tokens = append(tokens, token{tokenString, value})
+			i = end + 1
+		case c == '-' || c >= '0' && c <= '9':
+			end := i + 1
+			for end < len(source) && strings.ContainsRune("0123456789.eE+-", rune(source[end])) {
+				end++
+			}
+			tokens = append(tokens, token{tokenNumber, source[i:end]})
+			i = end
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			end := i + 1
+			for end < len(source) && (source[end] == '_' || source[end] >= 'a' && source[end] <= 'z' ||
+				source[end] >= 'A' && source[end] <= 'Z' || source[end] >= '0' && source[end] <= '9') {
+				end++
+			}
+			tokens = append(tokens, token{tokenName, source[i:end]})
+			i = end
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+type parser struct {
+	tokens    []token
+	position  int
+	variables map[string]any
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.position]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.position]
+	if t.kind != tokenEOF {
+		p.position++
+	}
+	return t
+}
+
+func (p *parser) is(kind tokenKind, value string) bool {
+	t := p.peek()
+	return t.kind == kind && t.value == value
+}
+
+func (p *parser) expect(kind tokenKind, value string) error {
+	if t := p.next(); t.kind != kind || value != "" && t.value != value {
+		return fmt.Errorf("syntax error: expected %q, found %q", value, t.value)
+	}
+	return nil
+}
+
+func (p *parser) name() (string, error) {
+	t := p.next()
+	if t.kind != tokenName {
+		return "", fmt.Errorf("syntax error: expected a name, found %q", t.value)
+	}
+	return t.value, nil
+}
+
+// parseSubscription returns the root field of the subscription operation of query, the one named
+// operationName when the document holds several operations
+func parseSubscription(query, operationName string, variables map[string]any) (field, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return field{}, fmt.Errorf("syntax error: %w", err)
+	}
+	p := &parser{tokens: tokens, variables: variables}
+
+	var selected []field
+	found := 0
+	for p.peek().kind != tokenEOF {
+		operation, name := "query", ""
+		if p.peek().kind == tokenName {
+			operation = p.next().value
+			if operation == "fragment" {
+				return field{}, errors.New("fragments are not supported")
+			}
+			if p.peek().kind == tokenName {
+				name = p.next().value
+			}
+			if p.is(tokenPunct, "(") {
+				p.skipBalanced("(", ")")
+			}
+			if p.is(tokenPunct, "@") {
+				return field{}, errors.New("directives are not supported")
+			}
+		}
+		selections, err := p.selectionSet()
+		if err != nil {
+			return field{}, err
+		}
+		if operationName != "" && name != operationName {
+			continue
+		}
+		found++
+		if operation != "subscription" {
+			err = fmt.Errorf("only subscriptions are supported, found a %s", operation)
+		}
+		if err == nil && len(selections) != 1 {
+			err = errors.New("a subscription must select exactly one root field")
+		}
+		selected = selections
+		if err != nil {
+			return field{}, err
+		}
+	}
+	switch {
+	case found == 0 && operationName != "":
+		return field{}, fmt.Errorf("unknown operation %q", operationName)
+	case found == 0:
+		return field{}, errors.New("the document holds no operation")
+	case found > 1:
+		return field{}, errors.New("operationName is required when the document holds several operations")
+	}
+	return selected[0], nil
+}
+
+// skipBalanced skips a group, such as the variable definitions the parser does not need to check
+func (p *parser) skipBalanced(open, close string) {
+	depth := 0
+	for t := p.next(); t.kind != tokenEOF; t = p.next() {
+		if t.kind == tokenPunct && t.value == open {
+			depth++
+		} else if t.kind == tokenPunct && t.value == close {
+			if depth--; depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (p *parser) selectionSet() ([]field, error) {
+	if err := p.expect(tokenPunct, "{"); err != nil {
+		return nil, err
+	}
+	var fields []field
+	for !p.is(tokenPunct, "}") {
+		if p.is(tokenPunct, "...") {
+			return nil, errors.New("fragments are not supported")
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		selected := field{name: name}
+		if p.is(tokenPunct, ":") {
+			p.next()
+			if selected.name, err = p.name(); err != nil {
+				return nil, err
+			}
+			selected.alias = name
+		}
+		if p.is(tokenPunct, "(") {
+			if selected.args, err = p.arguments(); err != nil {
+				return nil, err
+			}
+		}
+		if p.is(tokenPunct, "@") {
+			return nil, errors.New("directives are not supported")
+		}
+		if p.is(tokenPunct, "{") {
+			if selected.selections, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, selected)
+	}
+	p.next()
+	if len(fields) == 0 {
+		return nil, errors.New("syntax error: empty selection set")
+	}
+	return fields, nil
+}
+
+func (p *parser) arguments() (map[string]any, error) {
+	p.next()
+	args := make(map[string]any)
+	for !p.is(tokenPunct, ")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenPunct, ":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.value(); err != nil {
+			return nil, err
+		}
+	}
+	p.next()
+	return args, nil
+}
+
+func (p *parser) value() (any, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return t.value, nil
+	case tokenNumber:
+		if integer, err := strconv.ParseInt(t.value, 10, 64); err == nil {
+			return integer, nil
+		}
+		number, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", t.value)
+		}
+		return number, nil
+	case tokenName:
+		switch t.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		// enum values are passed by name
+		return t.value, nil
+	case tokenPunct:
+		switch t.value {
+		case "$":
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			return p.variables[name], nil
+		case "[":
+			list := []any{}
+			for !p.is(tokenPunct, "]") {
+				if p.peek().kind == tokenEOF {
+					return nil, errors.New("syntax error: unterminated list")
+				}
+				item, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			}
+			p.next()
+			return list, nil
+		case "{":
+			object := make(map[string]any)
+			for !p.is(tokenPunct, "}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(tokenPunct, ":"); err != nil {
+					return nil, err
+				}
+				if object[name], err = p.value(); err != nil {
+					return nil, err
+				}
+			}
+			p.next()
+			return object, nil
+		}
+	}
+	return nil, fmt.Errorf("syntax error: unexpected %q", t.value)
+}
+
+// project keeps the selected fields of value, a JSON-decoded payload, recursing into objects and lists
+func project(value any, selections []field) any {
+	if len(selections) == 0 {
+		return value
+	}
+	switch value := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(selections))
+		for _, selected := range selections {
+			if selected.name == "__typename" {
+				continue
+			}
+			result[selected.key()] = project(value[selected.name], selected.selections)
+		}
+		return result
+	case []any:
+		result := make([]any, len(value))
+		for i, item := range value {
+			result[i] = project(item, selections)
+		}
+		return result
+	}
+	return value
+}
@@ -0,0 +1,255 @@
+package signal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HandoffParam is the handshake query parameter carrying the token of a session handed off by a draining node
+const HandoffParam = "handoff"
+
+// handoffTTL is how long a node keeps a session handed off to it, waiting for its client to reconnect
+const handoffTTL = 5 * time.Minute
+
+// HandoffFunc picks the peer a client migrates to when its node drains, false closes it without handoff
+type HandoffFunc func(client *Client, peers []Member) (Member, bool)
+
+// HandoffByHash spreads the clients over the peers by rendezvous hashing of their session, so the clients
+// of a session land on the same peer and only those of a leaving peer move when the peers change
+func HandoffByHash(client *Client, peers []Member) (Member, bool) {
+	session := client.SessionId()
+	var best Member
+	var bestHash uint64
+	for i, peer := range peers {
+		if hash := ringHash(session + "#" + peer.Address); i == 0 || hash > bestHash {
+			best, bestHash = peer, hash
+		}
+	}
+	return best, len(peers) > 0
+}
+
+// HandoffHint is the payload of the reconnect hint sent to a client whose session was handed off
+type HandoffHint struct {
+	// Node and Address identify the peer holding the session, map them to the URL the client reconnects to
+	Node    string `json:"node"`
+	Address string `json:"address"`
+	// Token resumes the session when sent in the HandoffParam query parameter of the handshake
+	Token string `json:"token"`
+	// Payload is the Payload of the DrainPolicy
+	Payload Payload `json:"payload,omitempty"`
+}
+
+// sessionHandoff is the state of a session moved to another node: its rooms, its metadata and the
+// messages it did not acknowledge. Metadata goes through JSON, numbers come back as float64.
+type sessionHandoff struct {
+	Token    string                     `json:"token"`
+	Tenant   string                     `json:"tenant,omitempty"`
+	UserId   string                     `json:"userId,omitempty"`
+	Rooms    []string                   `json:"rooms,omitempty"`
+	Metadata map[string]json.RawMessage `json:"metadata,omitempty"`
+	Pending  []Message                  `json:"pending,omitempty"`
+
+	expires time.Time
+}
+
+// handoffs holds the sessions handed off to this node until their client reconnects
+type handoffs struct {
+	mu       sync.Mutex
+	sessions map[string]*sessionHandoff
+}
+
+// handOff moves the sessions of clients to the peers picked by the policy and sends every client its
+// reconnect hint, clients which could not be handed off receive the plain hint
+func (socket *Server) handOff(clients []*Client, policy DrainPolicy) {
+	self := socket.cluster.config.Self
+	var peers []Member
+	for _, member := range socket.Members() {
+		if member.Address != self {
+			peers = append(peers, member)
+		}
+	}
+
+	targets := make(map[string]Member)
+	batches := make(map[string][]sessionHandoff)
+	owners := make(map[string][]*Client)
+	var orphans []*Client
+	for _, client := range clients {
+		peer, ok := policy.Handoff(client, peers)
+		if !ok {
+			orphans = append(orphans, client)
+			continue
+		}
+		targets[peer.Address] = peer
+		batches[peer.Address] = append(batches[peer.Address], socket.snapshotSession(client))
+		owners[peer.Address] = append(owners[peer.Address], client)
+	}
+
+	for address, batch := range batches {
+		body, err := json.Marshal(batch)
+		if err == nil {
+			var handled bool
+			if handled, err = socket.cluster.post(address, "/handoff", body); err == nil && !handled {
+				err = fmt.Errorf("%s does not accept handoffs", address)
+			}
+		}
+		if err != nil {
+			log.Printf("Cluster handoff to %s error: %v", address, err)
+			orphans = append(orphans, owners[address]...)
+			continue
+		}
+
+		peer := targets[address]
+		for i, client := range owners[address] {
+			socket.releaseSession(client)
+			client.Emit(policy.Event, HandoffHint{Node: peer.Id, Address: peer.Address, Token: batch[i].Token, Payload: policy.Payload})
+		}
+	}
+	socket.emitAll(orphans, policy.Event, policy.Payload)
+}
+
+// snapshotSession captures the state of the client handed to a peer
+func (socket *Server) snapshotSession(client *Client) sessionHandoff {
+	token := make([]byte, 16)
+	rand.Read(token)
+	handoff := sessionHandoff{
+		Token:  hex.EncodeToString(token),
+		Tenant: client.Tenant(),
+		UserId: client.UserId(),
+		Rooms:  client.Rooms(),
+	}
+
+	client.state.mu.RLock()
+	for key, value := range client.state.metadata {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			log.Printf("Cluster handoff of %s skips metadata %s: %v", client.ConnectionId, key, err)
+			continue
+		}
+		if handoff.Metadata == nil {
+			handoff.Metadata = make(map[string]json.RawMessage)
+		}
+		handoff.Metadata[key] = raw
+	}
+	client.state.mu.RUnlock()
+
+	if delivery := socket.delivery; delivery != nil {
+		delivery.mu.Lock()
+		if box, exists := delivery.sessions[client.SessionId()]; exists {
+			handoff.Pending = append([]Message(nil), box.pending...)
+		}
+		delivery.mu.Unlock()
+	}
+	return handoff
+}
+
+// releaseSession drops the outbox of a client handed off, the peer redelivers it now
+func (socket *Server) releaseSession(client *Client) {
+	if socket.delivery == nil {
+		return
+	}
+	delivery := socket.delivery
+	delivery.mu.Lock()
+	defer delivery.mu.Unlock()
+	if box, exists := delivery.sessions[client.SessionId()]; exists && box.client == client {
+		delete(delivery.sessions, client.SessionId())
+	}
+}
+
+// clusterHandoff receives the sessions of a draining node
+func (socket *Server) clusterHandoff(w http.ResponseWriter, r *http.Request) {
+	var sessions []sessionHandoff
+	if err := json.NewDecoder(r.Body).Decode(&sessions); err != nil {
+		http.Error(w, "invalid handoff", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	socket.handoffs.mu.Lock()
+	for token, session := range socket.handoffs.sessions {
+		if now.After(session.expires) {
+			delete(socket.handoffs.sessions, token)
+		}
+	}
+	if socket.handoffs.sessions == nil {
+		socket.handoffs.sessions = make(map[string]*sessionHandoff)
+	}
+	for _, session := range sessions {
+		if session.Token == "" {
+			continue
+		}
+		session.expires = now.Add(handoffTTL)
+		socket.handoffs.sessions[session.Token] = &session
+	}
+	socket.handoffs.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// claimHandoff restores the metadata and the outbox of the session handed off under the token of the
+// handshake, before the session is attached. The session must belong to the tenant and user of the client.
+func (socket *Server) claimHandoff(client *Client) *sessionHandoff {
+	if client.HTTPRequest == nil {
+		return nil
+	}
+	token := client.HTTPRequest.URL.Query().Get(HandoffParam)
+	if token == "" {
+		return nil
+	}
+	socket.handoffs.mu.Lock()
+	session, exists := socket.handoffs.sessions[token]
+	if exists && (session.Tenant != client.Tenant() || session.UserId != client.UserId()) {
+		// left for its owner, a guessed token must not burn it
+		exists = false
+	}
+	if exists {
+		delete(socket.handoffs.sessions, token)
+	}
+	socket.handoffs.mu.Unlock()
+	if !exists || time.Now().After(session.expires) {
+		return nil
+	}
+
+	for key, raw := range session.Metadata {
+		var value any
+		if err := json.Unmarshal(raw, &value); err == nil {
+			client.Set(key, value)
+		}
+	}
+
+	if delivery := socket.delivery; delivery != nil && len(session.Pending) > 0 {
+		key := sessionKey(client)
+		delivery.mu.Lock()
+		box, exists := delivery.sessions[key]
+		if !exists {
+			box = &outbox{}
+			delivery.sessions[key] = box
+		}
+		box.pending = append(session.Pending, box.pending...)
+		if overflow := len(box.pending) - delivery.policy.OutboxSize; overflow > 0 {
+			box.pending = box.pending[overflow:]
+		}
+		delivery.mu.Unlock()
+		session.Pending = nil
+	}
+	return session
+}
+
+// resumeHandoff joins the client to the rooms of its handed off session once connected, the unacknowledged
+// messages are sent as they are when EmitReliable is not enabled on this node
+func (socket *Server) resumeHandoff(client *Client, session *sessionHandoff) {
+	if session == nil {
+		return
+	}
+	for _, roomId := range session.Rooms {
+		if err := socket.tenantRoom(client.Tenant(), roomId).Join(client); err != nil {
+			log.Printf("Cluster handoff of %s cannot rejoin %s: %v", client.ConnectionId, roomId, err)
+		}
+	}
+	for _, message := range session.Pending {
+		client.emitMessage(message, client.writeTimeout())
+	}
+}
@@ -0,0 +1,63 @@
+package signal
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Pinger is optionally implemented by adapters able to check their connectivity, it is used by ReadyHandler
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthStatus is the body returned by the health and readiness handlers
+type HealthStatus struct {
+	Status      string `json:"status"`
+	Listening   bool   `json:"listening"`
+	Connections int    `json:"connections"`
+	Adapter     string `json:"adapter,omitempty"`
+}
+
+const adapterPingTimeout = 2 * time.Second
+
+// HealthHandler reports the process as alive as long as it can answer, for liveness probes
+func (socket *Server) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, HealthStatus{
+			Status:      "ok",
+			Listening:   socket.listening.Load(),
+			Connections: socket.GetTotalConnections(),
+		})
+	})
+}
+
+// ReadyHandler answers 503 until the server is listening, while it drains, or while its adapter is unreachable, for readiness probes
+func (socket *Server) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := HealthStatus{
+			Status:      "ok",
+			Listening:   socket.listening.Load(),
+			Connections: socket.GetTotalConnections(),
+		}
+		ready := status.Listening && !socket.Draining()
+
+		if pinger, ok := socket.adapter.(Pinger); ok {
+			ctx, cancel := context.WithTimeout(r.Context(), adapterPingTimeout)
+			defer cancel()
+			if err := pinger.Ping(ctx); err != nil {
+				status.Adapter = err.Error()
+				ready = false
+			} else {
+				status.Adapter = "ok"
+			}
+		}
+
+		if !ready {
+			status.Status = "unavailable"
+			writeJSON(w, http.StatusServiceUnavailable, status)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	})
+}
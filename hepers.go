@@ -10,7 +10,7 @@ import (
 )
 
 // IndexOf returns the index of the Client with the given connectionId, or -1 if not found
-func IndexOf(connectionId string, roomClients []Client) int {
+func IndexOf(connectionId string, roomClients []*Client) int {
 	for i, client := range roomClients {
 		if client.ConnectionId == connectionId {
 			return i
@@ -65,3 +65,12 @@ func DecodeQueryData(queryData string) (map[string]string, error) {
 	}
 	return output, nil
 }
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
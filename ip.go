@@ -0,0 +1,94 @@
+package signal
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// parsePrefixes parses CIDRs and plain addresses, invalid entries are logged and skipped
+func parsePrefixes(values []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				log.Printf("Invalid address %q: %v", value, err)
+				continue
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			log.Printf("Invalid CIDR %q: %v", value, err)
+			continue
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+func containsAddr(prefixes []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientIP resolves the address of the peer, X-Forwarded-For and X-Real-IP are only honored
+// when the request comes from a trusted proxy
+func (socket *Server) clientIP(r *http.Request) string {
+	ip := remoteHost(r)
+	if !containsAddr(socket.trustedProxies, ip) {
+		return ip
+	}
+
+	// walk the chain from the closest hop, the first untrusted address is the client
+	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+		hops := strings.Split(forwardedFor, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			ip = hop
+			if !containsAddr(socket.trustedProxies, hop) {
+				return hop
+			}
+		}
+		return ip
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+	return ip
+}
+
+// IP returns the address of the client, as seen through the trusted proxies
+func (client *Client) IP() string {
+	if client.state != nil && client.state.ip != "" {
+		return client.state.ip
+	}
+	if client.HTTPRequest != nil {
+		return remoteHost(client.HTTPRequest)
+	}
+	return ""
+}
@@ -0,0 +1,246 @@
+package signal
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned, wrapped, for every token that fails validation
+var ErrInvalidToken = errors.New("invalid token")
+
+// claimsKey is the metadata key holding the claims of an authenticated client
+const claimsKey = "signal.claims"
+
+// Claims are the claims of a validated JWT
+type Claims map[string]any
+
+// Subject returns the "sub" claim
+func (claims Claims) Subject() string {
+	subject, _ := claims["sub"].(string)
+	return subject
+}
+
+// JWTToken is a parsed token, handed to the Keyfunc before its signature is verified
+type JWTToken struct {
+	Raw    string
+	Header map[string]any
+	Claims Claims
+}
+
+// Algorithm returns the "alg" header of the token
+func (token *JWTToken) Algorithm() string {
+	algorithm, _ := token.Header["alg"].(string)
+	return algorithm
+}
+
+// Keyfunc returns the key verifying a token: a []byte secret for HS*, *rsa.PublicKey for RS* and PS*,
+// *ecdsa.PublicKey for ES* and ed25519.PublicKey for EdDSA
+type Keyfunc func(token *JWTToken) (any, error)
+
+// JWTOptions are the checks applied on top of the signature and the exp/nbf claims
+type JWTOptions struct {
+	// Issuer, when set, must match the "iss" claim
+	Issuer string
+	// Audience, when set, must be listed in the "aud" claim
+	Audience string
+	// Algorithms accepted, every supported algorithm when empty
+	Algorithms []string
+	// Leeway tolerated on exp and nbf to absorb clock skew
+	Leeway time.Duration
+}
+
+// JWTAuth returns a handshake middleware validating the JWT sent in the "Authorization: Bearer" header,
+// or in Client.Auth since browsers cannot set headers on websockets, see WithAuthExtractors.
+// Invalid tokens are rejected with 401, the claims of valid ones are available through Client.Claims.
+func JWTAuth(keyfunc Keyfunc, options JWTOptions) Middleware {
+	return func(client *Client) error {
+		raw := client.Auth
+		if client.HTTPRequest != nil {
+			if bearer, found := strings.CutPrefix(client.HTTPRequest.Header.Get("Authorization"), "Bearer "); found {
+				raw = bearer
+			}
+		}
+		if raw == "" {
+			return fmt.Errorf("%w: missing token", ErrInvalidToken)
+		}
+
+		token, err := ParseJWT(raw, keyfunc, options)
+		if err != nil {
+			return err
+		}
+		client.Set(claimsKey, token.Claims)
+		return nil
+	}
+}
+
+// Claims returns the claims stored by JWTAuth, nil when the client did not authenticate with a JWT
+func (client *Client) Claims() Claims {
+	claims, _ := client.Get(claimsKey)
+	value, _ := claims.(Claims)
+	return value
+}
+
+// ParseJWT parses and validates a compact JWT
+func ParseJWT(raw string, keyfunc Keyfunc, options JWTOptions) (*JWTToken, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	token := &JWTToken{Raw: raw}
+	if err := decodeSegment(parts[0], &token.Header); err != nil {
+		return nil, fmt.Errorf("%w: malformed header: %s", ErrInvalidToken, err)
+	}
+	if err := decodeSegment(parts[1], &token.Claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims: %s", ErrInvalidToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature: %s", ErrInvalidToken, err)
+	}
+
+	algorithm := token.Algorithm()
+	if len(options.Algorithms) > 0 && !contains(options.Algorithms, algorithm) {
+		return nil, fmt.Errorf("%w: algorithm %q not allowed", ErrInvalidToken, algorithm)
+	}
+	key, err := keyfunc(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+	if err := verifySignature(algorithm, parts[0]+"."+parts[1], signature, key); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+	if err := validateClaims(token.Claims, options); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+	return token, nil
+}
+
+func decodeSegment(segment string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func hashFor(algorithm string) (crypto.Hash, func() hash.Hash, error) {
+	switch algorithm[2:] {
+	case "256":
+		return crypto.SHA256, sha256.New, nil
+	case "384":
+		return crypto.SHA384, sha512.New384, nil
+	case "512":
+		return crypto.SHA512, sha512.New, nil
+	}
+	return 0, nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+}
+
+// verifySignature checks the signature, the key type must match the algorithm family
+// so a public key can never be used as an HMAC secret
+func verifySignature(algorithm, signingInput string, signature []byte, key any) error {
+	if algorithm == "EdDSA" {
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("EdDSA requires an ed25519.PublicKey")
+		}
+		if !ed25519.Verify(publicKey, []byte(signingInput), signature) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	}
+	if len(algorithm) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	hashId, newHash, err := hashFor(algorithm)
+	if err != nil {
+		return err
+	}
+	digest := newHash()
+	digest.Write([]byte(signingInput))
+	sum := digest.Sum(nil)
+
+	switch algorithm[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("%s requires a []byte secret", algorithm)
+		}
+		mac := hmac.New(newHash, secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	case "RS", "PS":
+		publicKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an *rsa.PublicKey", algorithm)
+		}
+		if algorithm[0] == 'P' {
+			return rsa.VerifyPSS(publicKey, hashId, sum, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.VerifyPKCS1v15(publicKey, hashId, sum, signature)
+	case "ES":
+		publicKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an *ecdsa.PublicKey", algorithm)
+		}
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("signature mismatch")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(publicKey, sum, r, s) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %q", algorithm)
+}
+
+func validateClaims(claims Claims, options JWTOptions) error {
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(options.Leeway)) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(options.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token not valid yet")
+	}
+	if options.Issuer != "" && claims["iss"] != options.Issuer {
+		return errors.New("unexpected issuer")
+	}
+	if options.Audience != "" && !hasAudience(claims["aud"], options.Audience) {
+		return errors.New("unexpected audience")
+	}
+	return nil
+}
+
+func hasAudience(claim any, audience string) bool {
+	switch aud := claim.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, value := range aud {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
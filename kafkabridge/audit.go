@@ -0,0 +1,20 @@
+package kafkabridge
+
+import (
+	"context"
+	"encoding/json"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// AuditSink writes the audit records of a server to topic as JSON, keyed by connection id
+// so the records of a connection stay ordered within a partition
+func AuditSink(writer Writer, topic string) signal.AuditSink {
+	return signal.AuditSinkFunc(func(record signal.AuditRecord) error {
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return writer.WriteRecords(context.Background(), Record{Topic: topic, Key: []byte(record.ConnectionId), Value: value})
+	})
+}
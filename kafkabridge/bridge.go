@@ -0,0 +1,179 @@
+// Package kafkabridge connects a signal.io server to Kafka: records consumed from mapped topics are emitted
+// to rooms, and selected client events are published to topics.
+//
+// The package does not depend on a Kafka client, readers and writers only need to satisfy Reader and Writer.
+// With github.com/segmentio/kafka-go:
+//
+//	type kafkaReader struct{ *kafka.Reader }
+//
+//	func (r kafkaReader) ReadRecord(ctx context.Context) (kafkabridge.Record, error) {
+//		msg, err := r.ReadMessage(ctx)
+//		return kafkabridge.Record{Topic: msg.Topic, Key: msg.Key, Value: msg.Value}, err
+//	}
+package kafkabridge
+
+import (
+	"context"
+	"log"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// Record is a Kafka message as seen by the bridge
+type Record struct {
+	Topic string
+	Key   []byte
+	Value []byte
+}
+
+// Reader consumes records from the subscribed topics
+type Reader interface {
+	ReadRecord(ctx context.Context) (Record, error)
+}
+
+// Writer publishes records
+type Writer interface {
+	WriteRecords(ctx context.Context, records ...Record) error
+}
+
+// Server is the part of the signal.io server used by the bridge
+type Server interface {
+	Broadcast(eventName string, payload signal.Payload) signal.BroadcastResult
+	EmitTo(roomId, eventName string, payload signal.Payload) signal.BroadcastResult
+	OnAny(callback signal.AnyEvent)
+}
+
+// Route tells where the records of a topic are emitted
+type Route struct {
+	// Room receiving the records, when empty the record key is used as room id,
+	// and records without a key are broadcast to every client
+	Room string
+	// Event name emitted to clients, defaults to the topic name
+	Event string
+}
+
+// Bridge moves events between Kafka and a signal.io server
+type Bridge struct {
+	server Server
+	reader Reader
+	writer Writer
+	codec  signal.Codec
+	routes map[string]Route
+	topics map[string]string
+}
+
+// Option configures the bridge
+type Option func(*Bridge)
+
+// WithReader sets the reader consumed by Run
+func WithReader(reader Reader) Option {
+	return func(bridge *Bridge) {
+		bridge.reader = reader
+	}
+}
+
+// WithWriter sets the writer used to publish client events
+func WithWriter(writer Writer) Option {
+	return func(bridge *Bridge) {
+		bridge.writer = writer
+	}
+}
+
+// WithCodec changes how record values are decoded and payloads encoded, JSON by default
+func WithCodec(codec signal.Codec) Option {
+	return func(bridge *Bridge) {
+		bridge.codec = codec
+	}
+}
+
+// New creates a bridge, client events are only forwarded once Publish has been called for them
+func New(server Server, options ...Option) *Bridge {
+	bridge := &Bridge{
+		server: server,
+		codec:  signal.JSONCodec{},
+		routes: make(map[string]Route),
+		topics: make(map[string]string),
+	}
+	for _, option := range options {
+		option(bridge)
+	}
+	server.OnAny(bridge.onClientEvent)
+	return bridge
+}
+
+// Route maps the records consumed from topic to a room event.
+// Routes must be registered before calling Run.
+func (bridge *Bridge) Route(topic string, route Route) *Bridge {
+	bridge.routes[topic] = route
+	return bridge
+}
+
+// Publish forwards the payloads of eventName sent by clients to topic, keyed by connection id.
+// Topics must be registered before the server starts.
+func (bridge *Bridge) Publish(eventName, topic string) *Bridge {
+	bridge.topics[eventName] = topic
+	return bridge
+}
+
+// Run consumes records until ctx is done or the reader fails
+func (bridge *Bridge) Run(ctx context.Context) error {
+	for {
+		record, err := bridge.reader.ReadRecord(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		bridge.dispatch(record)
+	}
+}
+
+func (bridge *Bridge) dispatch(record Record) {
+	route, exists := bridge.routes[record.Topic]
+	if !exists {
+		return
+	}
+
+	eventName := route.Event
+	if eventName == "" {
+		eventName = record.Topic
+	}
+
+	var payload signal.Payload
+	if err := bridge.codec.Unmarshal(record.Value, &payload); err != nil {
+		// not encoded with the codec, hand the raw value to clients
+		payload = string(record.Value)
+	}
+
+	roomId := route.Room
+	if roomId == "" {
+		roomId = string(record.Key)
+	}
+	if roomId == "" {
+		bridge.server.Broadcast(eventName, payload)
+		return
+	}
+	bridge.server.EmitTo(roomId, eventName, payload)
+}
+
+func (bridge *Bridge) onClientEvent(eventName string, payload signal.Payload, client *signal.Client) {
+	topic, exists := bridge.topics[eventName]
+	if !exists || bridge.writer == nil {
+		return
+	}
+
+	value, err := bridge.codec.Marshal(payload)
+	if err != nil {
+		log.Printf("kafkabridge: marshal error: %v", err)
+		return
+	}
+	record := Record{
+		Topic: topic,
+		Key:   []byte(client.ConnectionId),
+		Value: value,
+	}
+	if err := bridge.writer.WriteRecords(context.Background(), record); err != nil {
+		log.Printf("kafkabridge: write error: %v", err)
+	}
+}
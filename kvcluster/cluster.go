@@ -0,0 +1,160 @@
+// Package kvcluster coordinates a signal.io cluster through a key-value store such as etcd or Consul:
+// nodes advertise themselves for discovery, record the connections they hold so EmitToClient finds them
+// in one hop, and watch configuration keys.
+//
+//	nodes := kvcluster.New(store, kvcluster.WithPrefix("chat/"))
+//	socket := signal.IOServer("8080", signal.WithCluster(signal.ClusterConfig{
+//		Discovery: nodes,
+//		Registry:  nodes,
+//		Self:      self,
+//		Secret:    os.Getenv("CLUSTER_SECRET"),
+//	}))
+//	nodes.Join(ctx, signal.Member{Id: hostname, Address: self})
+//
+// The package does not depend on a store client. Keys written by a node must expire when it dies: with
+// go.etcd.io/etcd/client/v3, attach them to a lease kept alive by the node:
+//
+//	type etcdStore struct {
+//		client *clientv3.Client
+//		lease  clientv3.LeaseID
+//	}
+//
+//	func (s etcdStore) Put(ctx context.Context, key string, value []byte) error {
+//		_, err := s.client.Put(ctx, key, string(value), clientv3.WithLease(s.lease))
+//		return err
+//	}
+//
+//	func (s etcdStore) Watch(ctx context.Context, key string, changed func(value []byte)) error {
+//		for response := range s.client.Watch(ctx, key) {
+//			for _, event := range response.Events {
+//				if event.Type == clientv3.EventTypeDelete {
+//					changed(nil)
+//				} else {
+//					changed(event.Kv.Value)
+//				}
+//			}
+//		}
+//		return ctx.Err()
+//	}
+//
+// With Consul, Put acquires the key with a session created with a TTL and the delete behavior, and
+// Watch runs blocking queries on the key.
+package kvcluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// Store is the part of a key-value store used by the cluster
+type Store interface {
+	// Put stores value under key, the key is removed when the node stops renewing its lease or session
+	Put(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, key string) error
+	// Get returns the value of key, nil when it does not exist
+	Get(ctx context.Context, key string) ([]byte, error)
+	// List returns the values of the keys starting with prefix, by key
+	List(ctx context.Context, prefix string) (map[string][]byte, error)
+	// Watch calls changed at every change of key, with nil once deleted, until ctx is done
+	Watch(ctx context.Context, key string, changed func(value []byte)) error
+}
+
+// Cluster implements signal.Discovery and signal.ConnectionRegistry on top of a Store
+type Cluster struct {
+	store  Store
+	prefix string
+}
+
+// Option configures the cluster
+type Option func(*Cluster)
+
+// WithPrefix changes the prefix of the keys, "signal/" by default. Use one prefix per cluster.
+func WithPrefix(prefix string) Option {
+	return func(cluster *Cluster) {
+		cluster.prefix = prefix
+	}
+}
+
+// New creates a cluster keeping its keys in store
+func New(store Store, options ...Option) *Cluster {
+	cluster := &Cluster{
+		store:  store,
+		prefix: "signal/",
+	}
+	for _, option := range options {
+		option(cluster)
+	}
+	return cluster
+}
+
+func (cluster *Cluster) memberKey(id string) string {
+	return cluster.prefix + "members/" + url.PathEscape(id)
+}
+
+func (cluster *Cluster) connectionKey(connectionId string) string {
+	return cluster.prefix + "connections/" + url.PathEscape(connectionId)
+}
+
+// Join advertises this node to the others, it stays a member until Leave or until its lease expires
+func (cluster *Cluster) Join(ctx context.Context, self signal.Member) error {
+	value, err := json.Marshal(self)
+	if err != nil {
+		return err
+	}
+	return cluster.store.Put(ctx, cluster.memberKey(self.Id), value)
+}
+
+// Leave stops advertising the node, call it before shutting down so others stop routing to it at once
+func (cluster *Cluster) Leave(ctx context.Context, id string) error {
+	return cluster.store.Delete(ctx, cluster.memberKey(id))
+}
+
+// Members lists the nodes which joined the cluster
+func (cluster *Cluster) Members(ctx context.Context) ([]signal.Member, error) {
+	values, err := cluster.store.List(ctx, cluster.prefix+"members/")
+	if err != nil {
+		return nil, err
+	}
+	members := make([]signal.Member, 0, len(values))
+	for key, value := range values {
+		var member signal.Member
+		if err := json.Unmarshal(value, &member); err != nil || member.Address == "" {
+			// written by something else, the key is of no use to route
+			continue
+		}
+		if member.Id == "" {
+			member.Id, _ = url.PathUnescape(strings.TrimPrefix(key, cluster.prefix+"members/"))
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (cluster *Cluster) Register(ctx context.Context, connectionId, address string) error {
+	return cluster.store.Put(ctx, cluster.connectionKey(connectionId), []byte(address))
+}
+
+func (cluster *Cluster) Unregister(ctx context.Context, connectionId string) error {
+	return cluster.store.Delete(ctx, cluster.connectionKey(connectionId))
+}
+
+func (cluster *Cluster) Lookup(ctx context.Context, connectionId string) (string, error) {
+	address, err := cluster.store.Get(ctx, cluster.connectionKey(connectionId))
+	return string(address), err
+}
+
+// Watch calls changed with the value of the configuration key, once with the current value, nil when it
+// is not set, then at every change until ctx is done. Keys live under "<prefix>config/".
+func (cluster *Cluster) Watch(ctx context.Context, key string, changed func(value []byte)) error {
+	key = cluster.prefix + "config/" + key
+	value, err := cluster.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	changed(value)
+	return cluster.store.Watch(ctx, key, changed)
+}
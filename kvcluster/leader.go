@@ -0,0 +1,77 @@
+package kvcluster
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Creator is implemented by stores able to create a key only when it does not exist yet, which Campaign
+// requires: an etcd transaction comparing the key create revision to 0, a Consul acquire with a session
+type Creator interface {
+	Create(ctx context.Context, key string, value []byte) (created bool, err error)
+}
+
+// Campaign implements signal.Elector: the node holding the leader key leads until the key is deleted or its
+// lease expires, the others watch the key to take over
+func (cluster *Cluster) Campaign(ctx context.Context, id string) (<-chan struct{}, error) {
+	creator, ok := cluster.store.(Creator)
+	if !ok {
+		return nil, errors.New("kvcluster: the store cannot create keys exclusively, see Creator")
+	}
+	key := cluster.prefix + "leader"
+	for {
+		created, err := creator.Create(ctx, key, []byte(id))
+		if err != nil {
+			return nil, err
+		}
+		if !created {
+			// a leadership lost to a failed watch leaves the key to this node
+			holder, err := cluster.store.Get(ctx, key)
+			created = err == nil && string(holder) == id
+		}
+		if created {
+			return cluster.hold(ctx, key, id), nil
+		}
+		// wait for the key to go, checking again now and then in case the deletion happened before the watch
+		wait, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err = cluster.store.Watch(wait, key, func(value []byte) {
+			if value == nil {
+				cancel()
+			}
+		})
+		if err != nil && wait.Err() == nil {
+			// the watch failed at once, wait out the rest of the period rather than spin
+			<-wait.Done()
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// hold watches the leader key, the returned channel is closed once it changes hands or ctx is done,
+// the key being deleted then so another node takes over without waiting for the lease to expire
+func (cluster *Cluster) hold(ctx context.Context, key, id string) <-chan struct{} {
+	lost := make(chan struct{})
+	go func() {
+		defer close(lost)
+		watch, cancel := context.WithCancel(ctx)
+		defer cancel()
+		cluster.store.Watch(watch, key, func(value []byte) {
+			if string(value) != id {
+				cancel()
+			}
+		})
+		if ctx.Err() == nil {
+			return
+		}
+		resign, cancelResign := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelResign()
+		if value, err := cluster.store.Get(resign, key); err == nil && string(value) == id {
+			cluster.store.Delete(resign, key)
+		}
+	}()
+	return lost
+}
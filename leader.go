@@ -0,0 +1,81 @@
+package signal
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Elector elects the leader of the cluster, see ClusterConfig.Elector
+type Elector interface {
+	// Campaign blocks until the node identified by id leads the cluster or ctx is done,
+	// it returns a channel closed once the leadership is lost
+	Campaign(ctx context.Context, id string) (lost <-chan struct{}, err error)
+}
+
+// IsLeader reports whether this node leads the cluster. Without an Elector the leader is the member with the
+// lowest id among the last discovered members, so two nodes may both lead until their member lists agree.
+// A server outside a cluster always leads.
+func (socket *Server) IsLeader() bool {
+	if socket.cluster == nil {
+		return true
+	}
+	return socket.cluster.leading.Load()
+}
+
+// IfLeader runs task when this node leads the cluster and reports whether it ran, so periodic jobs run on a single node:
+//
+//	socket.Every(time.Minute, func(s *signal.Server) { s.IfLeader(collectGarbage) })
+func (socket *Server) IfLeader(task func()) bool {
+	if !socket.IsLeader() {
+		return false
+	}
+	task()
+	return true
+}
+
+// setLeading records the leadership of this node, publishing InternalLeaderChanged when it changes
+func (socket *Server) setLeading(leading bool) {
+	if socket.cluster.leading.Swap(leading) != leading {
+		socket.bus.Emit(InternalEvent{Name: InternalLeaderChanged, Data: leading})
+	}
+}
+
+// electByMembers makes the member with the lowest id the leader, used when no Elector is configured
+func (socket *Server) electByMembers(members []Member) {
+	if len(members) == 0 {
+		socket.setLeading(true)
+		return
+	}
+	leader := members[0]
+	for _, member := range members[1:] {
+		if member.Id < leader.Id {
+			leader = member
+		}
+	}
+	socket.setLeading(leader.Address == socket.cluster.config.Self)
+}
+
+// campaign keeps this node running for the leadership until the server shuts down
+func (socket *Server) campaign() {
+	c := socket.cluster
+	for socket.ctx.Err() == nil {
+		lost, err := c.config.Elector.Campaign(socket.ctx, c.config.Self)
+		if err != nil {
+			if socket.ctx.Err() == nil {
+				log.Printf("Cluster election error: %v", err)
+				select {
+				case <-time.After(time.Second):
+				case <-socket.ctx.Done():
+				}
+			}
+			continue
+		}
+		socket.setLeading(true)
+		select {
+		case <-lost:
+		case <-socket.ctx.Done():
+		}
+		socket.setLeading(false)
+	}
+}
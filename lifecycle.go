@@ -0,0 +1,90 @@
+package signal
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Ticker is the handle of a periodic task, see Every
+type Ticker struct {
+	once sync.Once
+	stop chan struct{}
+}
+
+// Stop stops the task, a run in progress is not interrupted. It can be called from the task itself.
+func (ticker *Ticker) Stop() {
+	ticker.once.Do(func() {
+		close(ticker.stop)
+	})
+}
+
+// Every runs task at each interval until the returned ticker is stopped or the server shuts down.
+// Runs never overlap, a tick happening while the task still runs is skipped.
+func (socket *Server) Every(interval time.Duration, task func(s *Server)) *Ticker {
+	ticker := &Ticker{
+		stop: make(chan struct{}),
+	}
+	go func() {
+		clock := time.NewTicker(interval)
+		defer clock.Stop()
+		for {
+			select {
+			case <-clock.C:
+				task(socket)
+			case <-ticker.stop:
+				return
+			case <-socket.ctx.Done():
+				return
+			}
+		}
+	}()
+	return ticker
+}
+
+// Done is closed once the server starts shutting down
+func (socket *Server) Done() <-chan struct{} {
+	return socket.ctx.Done()
+}
+
+// Shutdown stops the periodic and scheduled tasks, runs the plugins OnShutdown hooks, stops accepting
+// connections, closes the connected clients and the adapter. It waits for in-flight handshakes until ctx is done.
+func (socket *Server) Shutdown(ctx context.Context) error {
+	socket.cancel()
+	socket.scheduler.stop()
+
+	socket.mu.Lock()
+	httpServer, debugServer := socket.httpServer, socket.debugServer
+	socket.mu.Unlock()
+
+	if debugServer != nil {
+		debugServer.Close()
+	}
+	err := socket.shutdownPlugins(ctx)
+	if httpServer != nil {
+		if shutdownErr := httpServer.Shutdown(ctx); err == nil {
+			err = shutdownErr
+		}
+	}
+	for _, client := range socket.Clients() {
+		client.closeWith(DisconnectShutdown, websocket.CloseGoingAway, nil)
+	}
+	if socket.auditor != nil {
+		socket.auditor.close()
+	}
+	if socket.adapter != nil {
+		if closeErr := socket.adapter.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (socket *Server) setHTTPServer(httpServer *http.Server) {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	socket.httpServer = httpServer
+}
@@ -0,0 +1,223 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// loadWindow is the period the message rates of Load are averaged over
+const loadWindow = 10 * time.Second
+
+// defaultBufferSize is the size of the read and write buffers of a connection when the upgrader leaves it unset
+const defaultBufferSize = 4096
+
+// Load is a machine-readable report of the load of a node, to drive autoscalers and connection steering
+type Load struct {
+	Node        string `json:"node"`
+	Connections int    `json:"connections"`
+	Rooms       int    `json:"rooms"`
+	// MessagesIn is the rate of the messages received from clients and MessagesOut of the deliveries to
+	// clients, per second over the last 10 seconds
+	MessagesIn  float64 `json:"messagesIn"`
+	MessagesOut float64 `json:"messagesOut"`
+	// BufferBytes estimates the memory held by the read and write buffers of the connections
+	BufferBytes int64 `json:"bufferBytes"`
+	// Queued counts the messages held for clients: unacknowledged reliable messages, offline queues and
+	// emits waiting for the coalescing window
+	Queued         int   `json:"queued"`
+	WritesInFlight int64 `json:"writesInFlight"`
+	Draining       bool  `json:"draining"`
+}
+
+// loadSampler turns the message counters into rates
+type loadSampler struct {
+	received atomic.Uint64
+	sent     atomic.Uint64
+
+	mu      sync.Mutex
+	at      time.Time
+	in, out uint64
+	rateIn  float64
+	rateOut float64
+	sampled bool
+}
+
+// rates returns the message rates since the start of the current window, the previous ones when it
+// started less than a second ago
+func (sampler *loadSampler) rates(now time.Time) (float64, float64) {
+	sampler.mu.Lock()
+	defer sampler.mu.Unlock()
+	in, out := sampler.received.Load(), sampler.sent.Load()
+	if !sampler.sampled {
+		sampler.at, sampler.in, sampler.out, sampler.sampled = now, in, out, true
+		return 0, 0
+	}
+	elapsed := now.Sub(sampler.at)
+	if elapsed < time.Second {
+		return sampler.rateIn, sampler.rateOut
+	}
+	sampler.rateIn = float64(in-sampler.in) / elapsed.Seconds()
+	sampler.rateOut = float64(out-sampler.out) / elapsed.Seconds()
+	if elapsed >= loadWindow {
+		sampler.at, sampler.in, sampler.out = now, in, out
+	}
+	return sampler.rateIn, sampler.rateOut
+}
+
+// Load reports the load of this node
+func (socket *Server) Load() Load {
+	clients := socket.snapshot()
+	load := Load{
+		Node:           socket.nodeId,
+		Connections:    len(clients),
+		Rooms:          len(socket.roomList()),
+		WritesInFlight: socket.writesInFlight.Load(),
+		Draining:       socket.Draining(),
+	}
+	load.MessagesIn, load.MessagesOut = socket.metrics.load.rates(time.Now())
+
+	readSize, writeSize := socket.upgrader.ReadBufferSize, socket.upgrader.WriteBufferSize
+	if readSize == 0 {
+		readSize = defaultBufferSize
+	}
+	if writeSize == 0 {
+		writeSize = defaultBufferSize
+	}
+	load.BufferBytes = int64(len(clients) * readSize)
+	if socket.upgrader.WriteBufferPool != nil {
+		// pooled write buffers are only held while writing
+		load.BufferBytes += load.WritesInFlight * int64(writeSize)
+	} else {
+		load.BufferBytes += int64(len(clients) * writeSize)
+	}
+
+	for _, client := range clients {
+		if client.state != nil {
+			client.state.coalesced.mu.Lock()
+			load.Queued += len(client.state.coalesced.pending)
+			client.state.coalesced.mu.Unlock()
+		}
+	}
+	if delivery := socket.delivery; delivery != nil {
+		delivery.mu.Lock()
+		for _, box := range delivery.sessions {
+			load.Queued += len(box.pending)
+		}
+		delivery.mu.Unlock()
+	}
+	if offline := socket.offline; offline != nil {
+		offline.mu.Lock()
+		for _, queue := range offline.users {
+			load.Queued += len(queue)
+		}
+		offline.mu.Unlock()
+	}
+	return load
+}
+
+// LoadHandler serves the Load of this node as JSON, for autoscalers such as the KEDA metrics-api scaler
+func (socket *Server) LoadHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, socket.Load())
+	})
+}
+
+// MemberLoad is the load of a member of the cluster
+type MemberLoad struct {
+	Member
+	Load
+}
+
+// ClusterLoad returns the load of every member, as reported at the last discovery, except for this node
+// whose load is current. Members which did not report their load yet are left out.
+func (socket *Server) ClusterLoad() []MemberLoad {
+	if socket.cluster == nil {
+		return nil
+	}
+	socket.cluster.mu.RLock()
+	loads := socket.cluster.loads
+	socket.cluster.mu.RUnlock()
+
+	var members []MemberLoad
+	for _, member := range socket.Members() {
+		if member.Address == socket.cluster.config.Self {
+			members = append(members, MemberLoad{Member: member, Load: socket.Load()})
+		} else if load, reported := loads[member.Address]; reported {
+			members = append(members, MemberLoad{Member: member, Load: load})
+		}
+	}
+	return members
+}
+
+// LeastLoaded returns the member holding the fewest connections, draining members excluded.
+// Serve it to clients asking where to connect, or pick handoff targets with it.
+func (socket *Server) LeastLoaded() (Member, bool) {
+	var least MemberLoad
+	found := false
+	for _, member := range socket.ClusterLoad() {
+		if member.Draining {
+			continue
+		}
+		if !found || member.Connections < least.Connections ||
+			member.Connections == least.Connections && member.MessagesOut < least.MessagesOut {
+			least, found = member, true
+		}
+	}
+	return least.Member, found
+}
+
+// refreshLoads collects the load of the other members
+func (socket *Server) refreshLoads(members []Member) {
+	c := socket.cluster
+	loads := make(map[string]Load, len(members))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, member := range members {
+		if member.Address == c.config.Self {
+			continue
+		}
+		wg.Add(1)
+		go func(address string) {
+			defer wg.Done()
+			load, err := c.load(socket.ctx, address)
+			if err != nil {
+				// an older node or an unreachable one, it is left out of the steering
+				return
+			}
+			mu.Lock()
+			loads[address] = load
+			mu.Unlock()
+		}(member.Address)
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	c.loads = loads
+	c.mu.Unlock()
+}
+
+// load asks a node for its Load
+func (c *cluster) load(ctx context.Context, address string) (Load, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, address+"/load", nil)
+	if err != nil {
+		return Load{}, err
+	}
+	request.Header.Set(clusterSecretHeader, c.config.Secret)
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return Load{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return Load{}, fmt.Errorf("%s answered %s", address, response.Status)
+	}
+	var load Load
+	err = json.NewDecoder(response.Body).Decode(&load)
+	return load, err
+}
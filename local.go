@@ -0,0 +1,57 @@
+package signal
+
+// LocalEmitter emits to the clients connected to this node only, nothing is published
+// through the adapter. See Server.Local.
+type LocalEmitter struct {
+	server *Server
+	tenant *Tenant
+}
+
+// Local returns an emitter restricted to this node, for node-local announcements such as maintenance notices
+func (socket *Server) Local() *LocalEmitter {
+	return &LocalEmitter{server: socket}
+}
+
+// Local returns an emitter restricted to the tenant clients connected to this node
+func (tenant *Tenant) Local() *LocalEmitter {
+	return &LocalEmitter{server: tenant.server, tenant: tenant}
+}
+
+// Broadcast sends the event to every client of this node
+func (local *LocalEmitter) Broadcast(eventName string, payload Payload) BroadcastResult {
+	if local.tenant != nil {
+		return local.server.emitAll(local.server.broadcastClients(local.tenant.Id), eventName, payload)
+	}
+	return local.server.emitAll(local.server.snapshot(), eventName, payload)
+}
+
+// EmitTo sends the event to the members of the room connected to this node
+func (local *LocalEmitter) EmitTo(roomId, eventName string, payload Payload) BroadcastResult {
+	room := local.server.lookupRoom(local.tenantId(), roomId)
+	if room == nil {
+		return BroadcastResult{}
+	}
+	return room.Local().Emit(eventName, payload)
+}
+
+// Publish sends the event to the members connected to this node of the rooms matching topic
+func (local *LocalEmitter) Publish(topic, eventName string, payload Payload) BroadcastResult {
+	return local.server.emitAll(local.server.topicClients(local.tenantId(), topic), eventName, payload)
+}
+
+func (local *LocalEmitter) tenantId() string {
+	if local.tenant == nil {
+		return ""
+	}
+	return local.tenant.Id
+}
+
+// Local returns a view of the room whose emits stay on this node
+func (room *Room) Local() *RoomView {
+	return &RoomView{room: room, local: true}
+}
+
+// Local restricts the view emits to this node
+func (view *RoomView) Local() *RoomView {
+	return &RoomView{room: view.room, except: view.except, local: true}
+}
@@ -0,0 +1,295 @@
+// Package matchmaking groups waiting clients into rooms: clients queue up, a Matcher forms groups from
+// the waiting tickets, and every group gets a new room whose members are notified before the application
+// takes over.
+//
+//	lobby := matchmaking.New(socket, matchmaking.WithMatcher(matchmaking.BySkill(4, "rating", 100, 20)))
+//	lobby.OnMatch(func(room *signal.Room, players []*signal.Client) {
+//		games.Start(room, players)
+//	})
+//
+// Clients emit JoinEvent to queue, optionally with a Join payload, and LeaveEvent to leave the queue.
+// They receive QueuedEvent once queued and MatchedEvent with a Match once grouped, already members of
+// the room. Disconnected clients leave the queue.
+package matchmaking
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// Events exchanged with clients
+const (
+	JoinEvent    = "matchmaking:join"
+	LeaveEvent   = "matchmaking:leave"
+	QueuedEvent  = "matchmaking:queued"
+	MatchedEvent = "matchmaking:matched"
+)
+
+// Join is the optional payload of JoinEvent, Party is the number of players the client stands for
+type Join struct {
+	Party int `json:"party,omitempty"`
+	// Attributes are matching criteria chosen by the client, such as a game mode
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// Match is the payload of MatchedEvent
+type Match struct {
+	Room    string   `json:"room"`
+	Players []string `json:"players"`
+}
+
+// Ticket is a client waiting for a match
+type Ticket struct {
+	Client     *signal.Client
+	Party      int
+	Attributes map[string]any
+	Since      time.Time
+}
+
+// Waited returns how long the ticket has been queued
+func (ticket *Ticket) Waited() time.Duration {
+	return time.Since(ticket.Since)
+}
+
+// Matcher forms groups from the waiting tickets of a tenant, in queue order. Tickets left out keep
+// waiting, a ticket must not appear in two groups.
+type Matcher func(waiting []*Ticket) [][]*Ticket
+
+// BySize groups the tickets in queue order into groups of exactly size players, counting parties
+func BySize(size int) Matcher {
+	return func(waiting []*Ticket) [][]*Ticket {
+		var groups [][]*Ticket
+		var group []*Ticket
+		players := 0
+		for _, ticket := range waiting {
+			if players+ticket.Party > size {
+				continue
+			}
+			group = append(group, ticket)
+			players += ticket.Party
+			if players == size {
+				groups = append(groups, group)
+				group, players = nil, 0
+			}
+		}
+		return groups
+	}
+}
+
+// BySkill groups size players whose rating, read from the key client metadata, is within tolerance of
+// the longest waiting ticket of the group. The tolerance widens by widen per second waited, so players
+// with rare ratings are eventually matched.
+func BySkill(size int, key string, tolerance, widen float64) Matcher {
+	rating := func(ticket *Ticket) float64 {
+		value, _ := ticket.Client.Get(key)
+		switch value := value.(type) {
+		case float64:
+			return value
+		case int:
+			return float64(value)
+		}
+		return 0
+	}
+	return func(waiting []*Ticket) [][]*Ticket {
+		var groups [][]*Ticket
+		taken := make(map[*Ticket]bool)
+		for _, anchor := range waiting {
+			if taken[anchor] {
+				continue
+			}
+			window := tolerance + widen*anchor.Waited().Seconds()
+			group := []*Ticket{anchor}
+			players := anchor.Party
+			for _, candidate := range waiting {
+				if players == size {
+					break
+				}
+				if taken[candidate] || candidate == anchor || players+candidate.Party > size {
+					continue
+				}
+				if math.Abs(rating(candidate)-rating(anchor)) <= window {
+					group = append(group, candidate)
+					players += candidate.Party
+				}
+			}
+			if players == size {
+				for _, ticket := range group {
+					taken[ticket] = true
+				}
+				groups = append(groups, group)
+			}
+		}
+		return groups
+	}
+}
+
+// Option configures a Lobby
+type Option func(*Lobby)
+
+// WithMatcher replaces the matcher, BySize(2) by default
+func WithMatcher(matcher Matcher) Option {
+	return func(lobby *Lobby) {
+		lobby.matcher = matcher
+	}
+}
+
+// WithInterval changes how often the queue is matched again, for matchers widening their criteria over
+// time, 1 second by default. The queue is also matched whenever a client joins.
+func WithInterval(interval time.Duration) Option {
+	return func(lobby *Lobby) {
+		lobby.interval = interval
+	}
+}
+
+// WithRoomPrefix changes the prefix of the ids of the rooms created, "match-" by default
+func WithRoomPrefix(prefix string) Option {
+	return func(lobby *Lobby) {
+		lobby.prefix = prefix
+	}
+}
+
+// Lobby queues the clients waiting for a match
+type Lobby struct {
+	socket   *signal.Server
+	matcher  Matcher
+	interval time.Duration
+	prefix   string
+
+	mu      sync.Mutex
+	waiting []*Ticket
+	onMatch func(room *signal.Room, players []*signal.Client)
+	// matching serializes the matching passes
+	matching sync.Mutex
+}
+
+// New registers the matchmaking events on socket
+func New(socket *signal.Server, options ...Option) *Lobby {
+	lobby := &Lobby{
+		socket:   socket,
+		matcher:  BySize(2),
+		interval: time.Second,
+		prefix:   "match-",
+	}
+	for _, option := range options {
+		option(lobby)
+	}
+	socket.On(JoinEvent, lobby.onJoin)
+	socket.On(LeaveEvent, func(payload signal.Payload, client *signal.Client) {
+		lobby.Leave(client)
+	})
+	socket.OnStateChange(func(client *signal.Client, from, to signal.ConnectionState) {
+		if to == signal.StateClosed {
+			lobby.Leave(client)
+		}
+	})
+	socket.Every(lobby.interval, func(*signal.Server) {
+		lobby.Match()
+	})
+	return lobby
+}
+
+// OnMatch registers the handler receiving every room formed, its members already joined and notified
+func (lobby *Lobby) OnMatch(handler func(room *signal.Room, players []*signal.Client)) {
+	lobby.mu.Lock()
+	defer lobby.mu.Unlock()
+	lobby.onMatch = handler
+}
+
+func (lobby *Lobby) onJoin(payload signal.Payload, client *signal.Client) {
+	var join Join
+	if payload != nil {
+		if err := signal.Bind(payload, &join); err != nil {
+			client.EmitError(err)
+			return
+		}
+	}
+	lobby.Queue(client, join)
+}
+
+// Queue adds the client to the queue, a client already queued keeps its place
+func (lobby *Lobby) Queue(client *signal.Client, join Join) {
+	if join.Party <= 0 {
+		join.Party = 1
+	}
+	lobby.mu.Lock()
+	queued := slices.ContainsFunc(lobby.waiting, func(ticket *Ticket) bool { return ticket.Client == client })
+	if !queued {
+		lobby.waiting = append(lobby.waiting, &Ticket{Client: client, Party: join.Party, Attributes: join.Attributes, Since: time.Now()})
+	}
+	lobby.mu.Unlock()
+
+	client.Emit(QueuedEvent, nil)
+	lobby.Match()
+}
+
+// Leave removes the client from the queue
+func (lobby *Lobby) Leave(client *signal.Client) {
+	lobby.mu.Lock()
+	defer lobby.mu.Unlock()
+	lobby.waiting = slices.DeleteFunc(lobby.waiting, func(ticket *Ticket) bool { return ticket.Client == client })
+}
+
+// Waiting returns the number of queued clients
+func (lobby *Lobby) Waiting() int {
+	lobby.mu.Lock()
+	defer lobby.mu.Unlock()
+	return len(lobby.waiting)
+}
+
+// Match runs the matcher on the queue of every tenant and starts the groups formed
+func (lobby *Lobby) Match() {
+	lobby.matching.Lock()
+	defer lobby.matching.Unlock()
+
+	lobby.mu.Lock()
+	tenants := make(map[string][]*Ticket)
+	var order []string
+	for _, ticket := range lobby.waiting {
+		tenant := ticket.Client.Tenant()
+		if _, exists := tenants[tenant]; !exists {
+			order = append(order, tenant)
+		}
+		tenants[tenant] = append(tenants[tenant], ticket)
+	}
+	lobby.mu.Unlock()
+
+	for _, tenant := range order {
+		for _, group := range lobby.matcher(tenants[tenant]) {
+			lobby.start(tenant, group)
+		}
+	}
+}
+
+// start removes the group from the queue and gathers it in a new room
+func (lobby *Lobby) start(tenant string, group []*Ticket) {
+	lobby.mu.Lock()
+	for _, ticket := range group {
+		// a client may have left while the matcher ran
+		if !slices.Contains(lobby.waiting, ticket) {
+			lobby.mu.Unlock()
+			return
+		}
+	}
+	lobby.waiting = slices.DeleteFunc(lobby.waiting, func(ticket *Ticket) bool { return slices.Contains(group, ticket) })
+	onMatch := lobby.onMatch
+	lobby.mu.Unlock()
+
+	room := lobby.socket.Tenant(tenant).Room(lobby.prefix + signal.CreateConnectionId())
+	players := make([]*signal.Client, 0, len(group))
+	ids := make([]string, 0, len(group))
+	for _, ticket := range group {
+		if err := room.Join(ticket.Client); err != nil {
+			continue
+		}
+		players = append(players, ticket.Client)
+		ids = append(ids, ticket.Client.ConnectionId)
+	}
+	room.Emit(MatchedEvent, Match{Room: room.Id, Players: ids})
+	if onMatch != nil {
+		onMatch(room, players)
+	}
+}
@@ -0,0 +1,95 @@
+package signal
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Middleware runs during the handshake, before the connection is upgraded, so Client.Socket is still nil.
+// Returning an error rejects the handshake with 401 Unauthorized, return a *HandshakeError to choose the response.
+type Middleware func(client *Client) error
+
+// HandshakeError rejects a handshake with a chosen HTTP response, so clients and load balancers can tell
+// an auth failure (401, 403) from a capacity issue (429, 503). Middlewares, tenant resolvers and the other
+// handshake hooks return it instead of a plain error.
+type HandshakeError struct {
+	Status int
+	// Body is the response body, the status text when empty
+	Body string
+	// Header is added to the response, such as Retry-After or WWW-Authenticate
+	Header http.Header
+	// Err is the cause, reported to the audit log and the tracing span
+	Err error
+}
+
+// Reject creates a HandshakeError answering status with body
+func Reject(status int, body string) *HandshakeError {
+	return &HandshakeError{Status: status, Body: body}
+}
+
+// WithHeader adds a response header and returns the error, for chaining
+func (err *HandshakeError) WithHeader(key, value string) *HandshakeError {
+	if err.Header == nil {
+		err.Header = http.Header{}
+	}
+	err.Header.Add(key, value)
+	return err
+}
+
+func (err *HandshakeError) Error() string {
+	if err.Err != nil {
+		return err.Err.Error()
+	}
+	if err.Body != "" {
+		return err.Body
+	}
+	return http.StatusText(err.Status)
+}
+
+func (err *HandshakeError) Unwrap() error {
+	return err.Err
+}
+
+// Use registers handshake middlewares, they run in registration order
+func (socket *Server) Use(middlewares ...Middleware) {
+	socket.middlewares = append(socket.middlewares, middlewares...)
+}
+
+func (socket *Server) runMiddlewares(client *Client) error {
+	for _, middleware := range socket.middlewares {
+		if err := middleware(client); err != nil {
+			return err
+		}
+	}
+	if socket.tenantResolver != nil {
+		tenant, err := socket.tenantResolver(client)
+		if err != nil {
+			return err
+		}
+		client.SetTenant(tenant)
+	}
+	return nil
+}
+
+// rejectHandshake answers the handshake request with an HTTP error instead of upgrading it,
+// the response of a *HandshakeError replaces the default status
+func rejectHandshake(w http.ResponseWriter, status int, err error) {
+	var rejection *HandshakeError
+	if !errors.As(err, &rejection) {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	for key, values := range rejection.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	if rejection.Status != 0 {
+		status = rejection.Status
+	}
+	body := rejection.Body
+	if body == "" {
+		body = http.StatusText(status)
+	}
+	http.Error(w, body, status)
+}
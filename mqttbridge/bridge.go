@@ -0,0 +1,120 @@
+// Package mqttbridge relays messages between an MQTT broker and a signal.io server.
+// Broker messages are published to the rooms whose ids match their topic (see signal.MatchTopic),
+// and selected client events are published back to the broker.
+//
+// The package does not depend on an MQTT client, with github.com/eclipse/paho.mqtt.golang:
+//
+//	type pahoClient struct{ mqtt.Client }
+//
+//	func (c pahoClient) Subscribe(filter string, handler func(topic string, payload []byte)) error {
+//		token := c.Client.Subscribe(filter, 1, func(_ mqtt.Client, msg mqtt.Message) { handler(msg.Topic(), msg.Payload()) })
+//		token.Wait()
+//		return token.Error()
+//	}
+//
+//	func (c pahoClient) Publish(topic string, payload []byte) error {
+//		token := c.Client.Publish(topic, 1, false, payload)
+//		token.Wait()
+//		return token.Error()
+//	}
+package mqttbridge
+
+import (
+	"log"
+	"strings"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// Client is the part of an MQTT client used by the bridge
+type Client interface {
+	Subscribe(filter string, handler func(topic string, payload []byte)) error
+	Publish(topic string, payload []byte) error
+}
+
+// Server is the part of the signal.io server used by the bridge
+type Server interface {
+	Publish(topic, eventName string, payload signal.Payload) signal.BroadcastResult
+	OnAny(callback signal.AnyEvent)
+}
+
+// Bridge moves messages between an MQTT broker and a signal.io server
+type Bridge struct {
+	server Server
+	client Client
+	codec  signal.Codec
+	event  string
+	topics map[string]string
+}
+
+// Option configures the bridge
+type Option func(*Bridge)
+
+// WithEvent sets the event name emitted to clients for broker messages, the topic itself by default
+func WithEvent(eventName string) Option {
+	return func(bridge *Bridge) {
+		bridge.event = eventName
+	}
+}
+
+// WithCodec changes how broker payloads are decoded and client payloads encoded, JSON by default
+func WithCodec(codec signal.Codec) Option {
+	return func(bridge *Bridge) {
+		bridge.codec = codec
+	}
+}
+
+// New creates a bridge, nothing is relayed until Subscribe or Forward are called
+func New(server Server, client Client, options ...Option) *Bridge {
+	bridge := &Bridge{
+		server: server,
+		client: client,
+		codec:  signal.JSONCodec{},
+		topics: make(map[string]string),
+	}
+	for _, option := range options {
+		option(bridge)
+	}
+	server.OnAny(bridge.onClientEvent)
+	return bridge
+}
+
+// Subscribe relays the broker messages matching filter to the server
+func (bridge *Bridge) Subscribe(filter string) error {
+	return bridge.client.Subscribe(filter, bridge.onBrokerMessage)
+}
+
+// Forward publishes the payloads of eventName sent by clients to the broker.
+// A "{id}" placeholder in topic is replaced by the sender's connection id.
+func (bridge *Bridge) Forward(eventName, topic string) *Bridge {
+	bridge.topics[eventName] = topic
+	return bridge
+}
+
+func (bridge *Bridge) onBrokerMessage(topic string, data []byte) {
+	var payload signal.Payload
+	if err := bridge.codec.Unmarshal(data, &payload); err != nil {
+		payload = string(data)
+	}
+	eventName := bridge.event
+	if eventName == "" {
+		eventName = topic
+	}
+	bridge.server.Publish(topic, eventName, payload)
+}
+
+func (bridge *Bridge) onClientEvent(eventName string, payload signal.Payload, client *signal.Client) {
+	topic, exists := bridge.topics[eventName]
+	if !exists {
+		return
+	}
+	data, err := bridge.codec.Marshal(payload)
+	if err != nil {
+		log.Printf("mqttbridge: marshal error: %v", err)
+		return
+	}
+	topic = strings.ReplaceAll(topic, "{id}", client.ConnectionId)
+	if err := bridge.client.Publish(topic, data); err != nil {
+		log.Printf("mqttbridge: publish error: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Package natsadapter fans signal.io broadcasts and room emits out to every replica through NATS.
+//
+// The package does not depend on a NATS client, any connection satisfying Conn can be used.
+// With github.com/nats-io/nats.go a few lines are enough:
+//
+//	type natsConn struct{ *nats.Conn }
+//
+//	func (c natsConn) Subscribe(subject string, handler func(data []byte)) (func() error, error) {
+//		sub, err := c.Conn.Subscribe(subject, func(msg *nats.Msg) { handler(msg.Data) })
+//		if err != nil {
+//			return nil, err
+//		}
+//		return sub.Unsubscribe, nil
+//	}
+//
+//	nc, _ := nats.Connect(nats.DefaultURL)
+//	socket := signal.IOServer("8080", signal.WithAdapter(natsadapter.New(natsConn{nc})))
+package natsadapter
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// DefaultSubject is the subject used when none is configured
+const DefaultSubject = "signal.io"
+
+// Conn is the part of a NATS connection used by the adapter
+type Conn interface {
+	Publish(subject string, data []byte) error
+	Subscribe(subject string, handler func(data []byte)) (unsubscribe func() error, err error)
+}
+
+// Adapter implements signal.Adapter on top of a NATS subject
+type Adapter struct {
+	conn    Conn
+	subject string
+
+	mu          sync.Mutex
+	unsubscribe func() error
+}
+
+// Option configures the adapter
+type Option func(*Adapter)
+
+// WithSubject changes the subject shared by the nodes, use one subject per cluster
+func WithSubject(subject string) Option {
+	return func(adapter *Adapter) {
+		adapter.subject = subject
+	}
+}
+
+// New creates an adapter publishing on DefaultSubject unless configured otherwise
+func New(conn Conn, options ...Option) *Adapter {
+	adapter := &Adapter{
+		conn:    conn,
+		subject: DefaultSubject,
+	}
+	for _, option := range options {
+		option(adapter)
+	}
+	return adapter
+}
+
+func (adapter *Adapter) Publish(data []byte) error {
+	return adapter.conn.Publish(adapter.subject, data)
+}
+
+func (adapter *Adapter) Subscribe(handler func(data []byte)) error {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+
+	if adapter.unsubscribe != nil {
+		return errors.New("natsadapter: already subscribed")
+	}
+	unsubscribe, err := adapter.conn.Subscribe(adapter.subject, handler)
+	if err != nil {
+		return err
+	}
+	adapter.unsubscribe = unsubscribe
+	return nil
+}
+
+// Ping flushes the connection when it supports FlushWithContext, as *nats.Conn does, so readiness probes see broker outages
+func (adapter *Adapter) Ping(ctx context.Context) error {
+	if flusher, ok := adapter.conn.(interface{ FlushWithContext(context.Context) error }); ok {
+		return flusher.FlushWithContext(ctx)
+	}
+	return nil
+}
+
+func (adapter *Adapter) Close() error {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+
+	if adapter.unsubscribe == nil {
+		return nil
+	}
+	err := adapter.unsubscribe()
+	adapter.unsubscribe = nil
+	return err
+}
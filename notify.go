@@ -0,0 +1,218 @@
+package signal
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Notification events, see WithNotifications
+const (
+	// NotificationEvent delivers a Notification to every connection of its user
+	NotificationEvent = "notification"
+	// BadgeEvent carries a Badge, sent on connection and whenever the unread count of the user changes
+	BadgeEvent = "notification:badge"
+	// NotificationReadEvent is sent by clients to mark notifications read, with a list of ids, none meaning all
+	NotificationReadEvent = "notification:read"
+	// NotificationListEvent is sent by clients with a NotificationQuery, the answer is the list of notifications
+	NotificationListEvent = "notification:list"
+)
+
+// Notification is a message addressed to a user, kept by the NotificationStore until it is read
+type Notification struct {
+	Id     string         `json:"id"`
+	UserId string         `json:"userId"`
+	Type   string         `json:"type,omitempty"`
+	Title  string         `json:"title,omitempty"`
+	Body   string         `json:"body,omitempty"`
+	Data   map[string]any `json:"data,omitempty"`
+	Time   time.Time      `json:"time"`
+	Read   bool           `json:"read"`
+}
+
+// Badge is the payload of BadgeEvent
+type Badge struct {
+	Unread int `json:"unread"`
+}
+
+// NotificationQuery is the payload of NotificationListEvent
+type NotificationQuery struct {
+	UnreadOnly bool `json:"unreadOnly,omitempty"`
+	Limit      int  `json:"limit,omitempty"`
+}
+
+// NotificationStore keeps the notifications of every user, shared by the nodes of a cluster so the
+// counts are global. Implementations must be safe for concurrent use.
+type NotificationStore interface {
+	Add(notification Notification) error
+	// List returns the notifications of the user, newest first, at most limit unless it is zero
+	List(userId string, unreadOnly bool, limit int) ([]Notification, error)
+	// MarkRead marks the notifications read, every notification of the user when ids is empty
+	MarkRead(userId string, ids ...string) error
+	Unread(userId string) (int, error)
+}
+
+// MemoryNotificationStore keeps the notifications in memory, the oldest dropped past the per user limit
+type MemoryNotificationStore struct {
+	maxPerUser int
+
+	mu    sync.Mutex
+	users map[string][]Notification
+}
+
+// NewMemoryNotificationStore creates a store keeping at most maxPerUser notifications per user, zero
+// keeping them all
+func NewMemoryNotificationStore(maxPerUser int) *MemoryNotificationStore {
+	return &MemoryNotificationStore{maxPerUser: maxPerUser, users: make(map[string][]Notification)}
+}
+
+func (store *MemoryNotificationStore) Add(notification Notification) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	notifications := append(store.users[notification.UserId], notification)
+	if store.maxPerUser > 0 && len(notifications) > store.maxPerUser {
+		notifications = notifications[len(notifications)-store.maxPerUser:]
+	}
+	store.users[notification.UserId] = notifications
+	return nil
+}
+
+func (store *MemoryNotificationStore) List(userId string, unreadOnly bool, limit int) ([]Notification, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var list []Notification
+	notifications := store.users[userId]
+	for i := len(notifications) - 1; i >= 0 && (limit == 0 || len(list) < limit); i-- {
+		if !unreadOnly || !notifications[i].Read {
+			list = append(list, notifications[i])
+		}
+	}
+	return list, nil
+}
+
+func (store *MemoryNotificationStore) MarkRead(userId string, ids ...string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	notifications := store.users[userId]
+	for i := range notifications {
+		if len(ids) == 0 || slices.Contains(ids, notifications[i].Id) {
+			notifications[i].Read = true
+		}
+	}
+	return nil
+}
+
+func (store *MemoryNotificationStore) Unread(userId string) (int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	unread := 0
+	for _, notification := range store.users[userId] {
+		if !notification.Read {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+// Notify stores the notification and delivers it to every connection of the user, along with the new
+// unread count. Users without a connection find it in the store, and with WithOfflineQueue receive it
+// when they connect. Id and Time are set when empty.
+func (socket *Server) Notify(userId string, notification Notification) (Notification, error) {
+	if notification.Id == "" {
+		notification.Id = CreateConnectionId()
+	}
+	if notification.Time.IsZero() {
+		notification.Time = time.Now().UTC()
+	}
+	notification.UserId = userId
+	notification.Read = false
+	if socket.notifications == nil {
+		socket.EmitToUser(userId, NotificationEvent, notification)
+		return notification, nil
+	}
+	if err := socket.notifications.Add(notification); err != nil {
+		return notification, err
+	}
+	socket.EmitToUser(userId, NotificationEvent, notification)
+	socket.updateBadge(userId)
+	return notification, nil
+}
+
+// UnreadCount returns the number of unread notifications of the user
+func (socket *Server) UnreadCount(userId string) (int, error) {
+	if socket.notifications == nil {
+		return 0, nil
+	}
+	return socket.notifications.Unread(userId)
+}
+
+// MarkNotificationsRead marks notifications of the user read, all of them when ids is empty, and sends
+// the new unread count to the user
+func (socket *Server) MarkNotificationsRead(userId string, ids ...string) error {
+	if socket.notifications == nil {
+		return nil
+	}
+	if err := socket.notifications.MarkRead(userId, ids...); err != nil {
+		return err
+	}
+	socket.updateBadge(userId)
+	return nil
+}
+
+// updateBadge sends the unread count to every connection of the user
+func (socket *Server) updateBadge(userId string) {
+	unread, err := socket.notifications.Unread(userId)
+	if err != nil {
+		return
+	}
+	// badges are not queued, clients get a fresh one when they connect
+	socket.emitAll(socket.userClients(userId), BadgeEvent, Badge{Unread: unread})
+	socket.publishPacket(clusterPacket{User: userId, Message: Message{EventName: BadgeEvent, Payload: Badge{Unread: unread}}})
+}
+
+// sendBadge sends the unread count to a client that just connected
+func (socket *Server) sendBadge(client *Client) {
+	if socket.notifications == nil || client.UserId() == "" {
+		return
+	}
+	if unread, err := socket.notifications.Unread(client.UserId()); err == nil {
+		client.Emit(BadgeEvent, Badge{Unread: unread})
+	}
+}
+
+func (socket *Server) onNotificationRead(payload Payload, client *Client) {
+	var ids []string
+	if payload != nil {
+		if err := Bind(payload, &ids); err != nil {
+			client.EmitError(err)
+			return
+		}
+	}
+	if client.UserId() == "" {
+		client.EmitError(NewError(CodeUnauthorized, "notifications require an authenticated user"))
+		return
+	}
+	if err := socket.MarkNotificationsRead(client.UserId(), ids...); err != nil {
+		client.EmitError(err)
+	}
+}
+
+func (socket *Server) onNotificationList(payload Payload, client *Client) {
+	var query NotificationQuery
+	if payload != nil {
+		if err := Bind(payload, &query); err != nil {
+			client.EmitError(err)
+			return
+		}
+	}
+	if client.UserId() == "" {
+		client.EmitError(NewError(CodeUnauthorized, "notifications require an authenticated user"))
+		return
+	}
+	list, err := socket.notifications.List(client.UserId(), query.UnreadOnly, query.Limit)
+	if err != nil {
+		client.EmitError(err)
+		return
+	}
+	client.Emit(NotificationListEvent, list)
+}
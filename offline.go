@@ -0,0 +1,150 @@
+package signal
+
+import (
+	"sync"
+	"time"
+)
+
+// OfflineQueue configures the queueing of EmitToUser emits for users without a connection
+type OfflineQueue struct {
+	// Size bounds the messages queued per user, the oldest are dropped first, 100 by default
+	Size int
+	// TTL drops the queued messages older than this, 1 hour by default
+	TTL time.Duration
+}
+
+func (policy OfflineQueue) withDefaults() OfflineQueue {
+	if policy.Size <= 0 {
+		policy.Size = 100
+	}
+	if policy.TTL <= 0 {
+		policy.TTL = time.Hour
+	}
+	return policy
+}
+
+type queuedMessage struct {
+	at      time.Time
+	message Message
+}
+
+// offlineQueues holds the messages of the users that were not connected when they were emitted
+type offlineQueues struct {
+	policy OfflineQueue
+
+	mu        sync.Mutex
+	users     map[string][]queuedMessage
+	lastSweep time.Time
+}
+
+func newOfflineQueues(policy OfflineQueue) *offlineQueues {
+	return &offlineQueues{
+		policy: policy.withDefaults(),
+		users:  make(map[string][]queuedMessage),
+	}
+}
+
+// push queues the message and returns the messages dropped to make room or because they expired
+func (queues *offlineQueues) push(userId string, message Message) []DeadLetter {
+	queues.mu.Lock()
+	defer queues.mu.Unlock()
+	now := time.Now()
+	var dropped []DeadLetter
+	if now.Sub(queues.lastSweep) > queues.policy.TTL/10 {
+		dropped = queues.sweep(now)
+	}
+	queue := queues.users[userId]
+	if len(queue) >= queues.policy.Size {
+		dropped = append(dropped, DeadLetter{Message: queue[0].message, UserId: userId, Reason: ErrOfflineQueueFull, Time: now})
+		queue = queue[1:]
+	}
+	queues.users[userId] = append(queue, queuedMessage{at: now, message: message})
+	return dropped
+}
+
+// take removes and returns the messages queued for the user that did not expire, along with the expired ones
+func (queues *offlineQueues) take(userId string) ([]Message, []DeadLetter) {
+	queues.mu.Lock()
+	queue := queues.users[userId]
+	delete(queues.users, userId)
+	queues.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-queues.policy.TTL)
+	messages := make([]Message, 0, len(queue))
+	var expired []DeadLetter
+	for _, queued := range queue {
+		if queued.at.After(cutoff) {
+			messages = append(messages, queued.message)
+		} else {
+			expired = append(expired, DeadLetter{Message: queued.message, UserId: userId, Reason: ErrOfflineExpired, Time: now})
+		}
+	}
+	return messages, expired
+}
+
+// sweep drops and returns the expired messages of every user, the caller holds queues.mu
+func (queues *offlineQueues) sweep(now time.Time) []DeadLetter {
+	queues.lastSweep = now
+	cutoff := now.Add(-queues.policy.TTL)
+	var dropped []DeadLetter
+	for userId, queue := range queues.users {
+		expired := 0
+		for expired < len(queue) && !queue[expired].at.After(cutoff) {
+			dropped = append(dropped, DeadLetter{Message: queue[expired].message, UserId: userId, Reason: ErrOfflineExpired, Time: now})
+			expired++
+		}
+		if expired == len(queue) {
+			delete(queues.users, userId)
+		} else if expired > 0 {
+			queues.users[userId] = queue[expired:]
+		}
+	}
+	return dropped
+}
+
+// userClients returns the connections of the user on this node
+func (socket *Server) userClients(userId string) []*Client {
+	return filterClients(socket.snapshot(), func(client *Client) bool {
+		return client.UserId() == userId
+	})
+}
+
+// EmitToUser sends the event to every connection of the user, on every node sharing the adapter.
+// With WithOfflineQueue, emits to a user without a connection on this node are queued and
+// delivered on the next connection of the user to this node.
+func (socket *Server) EmitToUser(userId, eventName string, payload Payload) BroadcastResult {
+	clients := socket.userClients(userId)
+	if len(clients) == 0 && socket.offline != nil {
+		socket.deadLetterAll(socket.offline.push(userId, Message{EventName: eventName, Payload: payload}))
+	}
+	result := socket.emitAll(clients, eventName, payload)
+	socket.publishPacket(clusterPacket{
+		User:    userId,
+		Message: Message{EventName: eventName, Payload: payload},
+	})
+	return result
+}
+
+// flushOffline delivers the messages queued while the user of the client was offline
+func (socket *Server) flushOffline(client *Client) {
+	if socket.offline == nil || client.UserId() == "" {
+		return
+	}
+	messages, expired := socket.offline.take(client.UserId())
+	socket.deadLetterAll(expired)
+	for i, message := range messages {
+		if err := client.emitMessage(message, client.writeTimeout()); err != nil {
+			// the connection is lost and the queue was taken, what is left cannot be delivered anymore
+			for _, undelivered := range messages[i:] {
+				socket.deadLetter(DeadLetter{
+					Message:      undelivered,
+					ConnectionId: client.ConnectionId,
+					UserId:       client.UserId(),
+					Reason:       err,
+				})
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package signal
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures a Server at construction time
+type Option func(*Server)
+
+// WithIDGenerator replaces the built-in CUID-style generator used to assign connection ids.
+// The generator receives the handshake request, so ids can be derived from it (user ids, trace ids...).
+func WithIDGenerator(generator func(r *http.Request) string) Option {
+	return func(socket *Server) {
+		socket.idGenerator = generator
+	}
+}
+
+// WithCodec replaces the encoding/json based codec used to read and write messages
+func WithCodec(codec Codec) Option {
+	return func(socket *Server) {
+		if codec != nil {
+			socket.codec = codec
+		}
+	}
+}
+
+// WithCodecs offers additional codecs clients can pick with the "codec" handshake query parameter,
+// for example ?codec=cbor. Clients not asking for one use the server codec set by WithCodec, and
+// broadcasts are encoded once per codec in use among the recipients.
+func WithCodecs(codecs map[string]Codec) Option {
+	return func(socket *Server) {
+		if socket.codecs == nil {
+			socket.codecs = make(map[string]Codec, len(codecs))
+		}
+		for name, codec := range codecs {
+			socket.codecs[name] = codec
+		}
+	}
+}
+
+// WithSubprotocols lists the WebSocket subprotocols the server accepts, in order of preference.
+// Subprotocols named "signal.v<version>.<codec>", such as "signal.v2.cbor", also select the protocol
+// version and the codec of the connection, the codec being looked up in WithCodecs, "json" standing
+// for JSONCodec. The handshake query parameters take precedence over the subprotocol.
+func WithSubprotocols(subprotocols ...string) Option {
+	return func(socket *Server) {
+		socket.upgrader.Subprotocols = append(socket.upgrader.Subprotocols, subprotocols...)
+	}
+}
+
+// WithAuthExtractors replaces where Client.Auth is read from during the handshake, the "auth" query
+// parameter by default. Extractors are tried in order and the first credentials found win, so tokens
+// can move to cookies or headers and stay out of proxy and access logs:
+//
+//	signal.WithAuthExtractors(signal.FromCookie("session"), signal.FromBearer())
+func WithAuthExtractors(extractors ...CredentialExtractor) Option {
+	return func(socket *Server) {
+		socket.credentialExtractors = append(socket.credentialExtractors, extractors...)
+	}
+}
+
+// UpgradeHeaders sets headers on the response upgrading the connection, such as a session Set-Cookie,
+// rate-limit headers or the server identity. The client has been authenticated by then.
+type UpgradeHeaders func(r *http.Request, client *Client, header http.Header)
+
+// WithUpgradeHeaders registers hooks adding headers to the upgrade response, they run in registration order
+func WithUpgradeHeaders(hooks ...UpgradeHeaders) Option {
+	return func(socket *Server) {
+		socket.upgradeHeaders = append(socket.upgradeHeaders, hooks...)
+	}
+}
+
+// WithAtLeastOnce enables the reliable delivery of EmitReliable: messages stay in a per-session outbox
+// until the client acknowledges them, and are redelivered when the session reconnects. Clients name
+// their session with the "session" handshake query parameter.
+func WithAtLeastOnce(policy AtLeastOnce) Option {
+	return func(socket *Server) {
+		socket.delivery = newDeliveries(policy)
+	}
+}
+
+// WithDeduplication drops the inbound messages whose id was already received from the same client
+// session within window, so retried messages do not run their handler twice. Messages without an id
+// are always processed.
+func WithDeduplication(window time.Duration) Option {
+	return func(socket *Server) {
+		socket.dedup = newDeduplicator(window)
+	}
+}
+
+// WithRoomSequences stamps every room emit with the room id and a sequence number increasing by one per
+// emit, so clients detect gaps and order messages. Sequences are kept by each node for the members
+// connected to it, and emits leaving members out, such as EmitFrom, still consume a number. A room dropped
+// once empty continues its sequence when it is used again, the node keeping a counter per room id.
+func WithRoomSequences() Option {
+	return func(socket *Server) {
+		socket.roomSequences = true
+	}
+}
+
+// WithEventLog records the room emits in log, turning on room sequences, so clients catch up after a
+// reconnection by emitting "resume" with the room and the last sequence number they received.
+func WithEventLog(log EventLog) Option {
+	return func(socket *Server) {
+		socket.eventLog = log
+		socket.roomSequences = true
+	}
+}
+
+// WithOfflineQueue queues the EmitToUser emits addressed to users without a connection on this node,
+// and delivers them on their next connection, so short disconnects do not drop direct notifications
+func WithOfflineQueue(policy OfflineQueue) Option {
+	return func(socket *Server) {
+		socket.offline = newOfflineQueues(policy)
+	}
+}
+
+// WithDeadLetters hands the messages that could not be delivered to sink: failed writes, reliable messages
+// dropped from a full outbox or left unacknowledged by an expired session, and offline messages dropped or expired
+func WithDeadLetters(sink DeadLetterSink) Option {
+	return func(socket *Server) {
+		socket.deadLetters = sink
+	}
+}
+
+// WithRetryPolicy retries the writes failing with a transient error, see RetryPolicy. Retries wait in the
+// emitting goroutine, a broadcast reaching a slow client is delayed by its retries.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(socket *Server) {
+		policy = policy.withDefaults()
+		socket.retry = &policy
+	}
+}
+
+// WithCircuitBreaker stops writing to clients failing consecutive writes and disconnects them, so emits
+// do not wait on dead sockets until the heartbeat notices them. Their emits fail with ErrCircuitOpen.
+func WithCircuitBreaker(breaker CircuitBreaker) Option {
+	return func(socket *Server) {
+		breaker = breaker.withDefaults()
+		socket.breaker = &breaker
+	}
+}
+
+// WithCoalescing packs the emits to a client within window, 10ms for instance, into one BatchEvent frame,
+// cutting the frame overhead of high frequency updates at the cost of that much latency. Emits then
+// return before the write, failed writes are reported to WithDeadLetters.
+func WithCoalescing(window time.Duration) Option {
+	return func(socket *Server) {
+		socket.coalesceWindow = window
+	}
+}
+
+// WithNotifications keeps the notifications sent with Notify in store, tracks their read state and
+// serves the notification events to clients, see NotificationReadEvent and NotificationListEvent
+func WithNotifications(store NotificationStore) Option {
+	return func(socket *Server) {
+		socket.notifications = store
+		socket.On(NotificationReadEvent, socket.onNotificationRead)
+		socket.On(NotificationListEvent, socket.onNotificationList)
+	}
+}
+
+// WithAffinity sets a sticky-session cookie and header naming this node at every handshake, so load
+// balancers return reconnections to it and session resumption works without a shared store
+func WithAffinity(affinity Affinity) Option {
+	return func(socket *Server) {
+		socket.affinity = &affinity
+	}
+}
+
+// WithSigning signs the messages sent to clients and verifies the messages they send with HMAC-SHA256,
+// per client or per tenant keys, rejecting tampered frames with CodeInvalidSignature
+func WithSigning(signing Signing) Option {
+	return func(socket *Server) {
+		socket.signing = &signing
+	}
+}
+
+// WithAdapter connects the server to the other nodes of a cluster,
+// broadcasts and room emits then reach the clients connected to every node
+func WithAdapter(adapter Adapter) Option {
+	return func(socket *Server) {
+		socket.adapter = adapter
+	}
+}
+
+// WithTracer records spans for handshakes, inbound events and emits
+func WithTracer(tracer Tracer) Option {
+	return func(socket *Server) {
+		socket.tracer = tracer
+	}
+}
+
+// WithCheckOrigin decides which cross-origin handshakes are accepted.
+// Without it, only same-origin browser requests are upgraded.
+func WithCheckOrigin(checkOrigin func(r *http.Request) bool) Option {
+	return func(socket *Server) {
+		socket.upgrader.CheckOrigin = checkOrigin
+	}
+}
+
+// WithAllowedOrigins accepts handshakes from the listed origins, see AllowOrigins
+func WithAllowedOrigins(origins ...string) Option {
+	return WithCheckOrigin(AllowOrigins(origins...))
+}
+
+// WithTrustedProxies lists the proxies, as CIDRs or addresses, whose X-Forwarded-For and X-Real-IP headers are trusted by Client.IP
+func WithTrustedProxies(proxies ...string) Option {
+	return func(socket *Server) {
+		socket.trustedProxies = parsePrefixes(proxies)
+	}
+}
+
+// WithRoleResolver replaces ClaimRoles to resolve the roles and permissions checked by Require
+func WithRoleResolver(resolver func(client *Client) []string) Option {
+	return func(socket *Server) {
+		socket.roleResolver = resolver
+	}
+}
+
+// WithCanJoin checks every room join, returning an error keeps the client out of the room
+func WithCanJoin(canJoin func(roomId string, client *Client) error) Option {
+	return func(socket *Server) {
+		socket.canJoin = canJoin
+	}
+}
+
+// WithCanEmit checks every emit into a room made on behalf of a client, see Client.EmitTo
+func WithCanEmit(canEmit func(roomId string, client *Client) error) Option {
+	return func(socket *Server) {
+		socket.canEmit = canEmit
+	}
+}
+
+// WithTenantResolver assigns every client to a tenant during the handshake, after the middlewares ran.
+// An error rejects the handshake like a failing middleware.
+func WithTenantResolver(resolver func(client *Client) (string, error)) Option {
+	return func(socket *Server) {
+		socket.tenantResolver = resolver
+	}
+}
+
+// WithDecodeErrorReplies controls whether clients receive an invalid_payload error for frames that cannot be decoded,
+// enabled by default. The error listener is called either way and the connection stays open.
+func WithDecodeErrorReplies(enabled bool) Option {
+	return func(socket *Server) {
+		socket.replyDecodeErrors = enabled
+	}
+}
+
+// WithWriteTimeout bounds every write to a client, a peer not draining its socket makes emits fail
+// with ErrWriteTimeout instead of blocking forever
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(socket *Server) {
+		socket.writeTimeout = timeout
+	}
+}
+
+// WithHandlerTimeout bounds how long the read loop waits for a listener, see SetHandlerTimeout for per-event values.
+// The listener's client.Context() is canceled when it runs late and the handler_timeout listener is called.
+func WithHandlerTimeout(timeout time.Duration) Option {
+	return func(socket *Server) {
+		socket.defaultHandlerTimeout = timeout
+	}
+}
+
+// WithMaxConnections limits the number of connections the server holds at once
+func WithMaxConnections(maxConnections int) Option {
+	return func(socket *Server) {
+		socket.quota.maxTotal = maxConnections
+	}
+}
+
+// WithMaxConnectionsPerIP limits the number of connections opened from the same Client.IP
+func WithMaxConnectionsPerIP(maxConnections int) Option {
+	return func(socket *Server) {
+		socket.quota.maxPerIP = maxConnections
+	}
+}
+
+// WithMaxConnectionsPerUser limits the number of connections of the same Client.UserId, anonymous clients are not counted
+func WithMaxConnectionsPerUser(maxConnections int) Option {
+	return func(socket *Server) {
+		socket.quota.maxPerUser = maxConnections
+	}
+}
+
+// WithQuotaRejection selects how handshakes over a connection limit are refused, RejectWithHTTP by default
+func WithQuotaRejection(rejection QuotaRejection) Option {
+	return func(socket *Server) {
+		socket.quota.rejection = rejection
+	}
+}
+
+// WithUserResolver identifies the user behind a client once the handshake middlewares ran,
+// the subject of the JWT claims is used by default
+func WithUserResolver(resolver func(client *Client) string) Option {
+	return func(socket *Server) {
+		socket.userResolver = resolver
+	}
+}
+
+// WithBlocklist replaces the in-process blocklist, a shared store propagates bans across replicas
+func WithBlocklist(store BlocklistStore) Option {
+	return func(socket *Server) {
+		if store != nil {
+			socket.blocklist = store
+		}
+	}
+}
+
+// WithDrainPolicy configures the reconnect hint and pacing used by Drain
+func WithDrainPolicy(policy DrainPolicy) Option {
+	return func(socket *Server) {
+		socket.drainPolicy = policy
+	}
+}
+
+// WithCluster lets the node discover its peers and route emits to connections they hold, see EmitToClient
+func WithCluster(config ClusterConfig) Option {
+	return func(socket *Server) {
+		socket.cluster = newCluster(config)
+	}
+}
+
+// WithChaos starts the server with fault injection enabled, see SetChaos
+func WithChaos(chaos Chaos) Option {
+	return func(socket *Server) {
+		socket.chaos.Store(&chaos)
+	}
+}
+
+// WithStatsSummary publishes a Stats snapshot on the internal bus as InternalStats at every interval
+func WithStatsSummary(interval time.Duration) Option {
+	return func(socket *Server) {
+		socket.statsInterval = interval
+	}
+}
+
+// WithDebugEndpoints serves the handler built for the server on addr, e.g. "127.0.0.1:6060", and closes it
+// on Shutdown. debug.Handler serves net/http/pprof and expvar counters. Keep addr internal.
+func WithDebugEndpoints(addr string, handler func(socket *Server) http.Handler) Option {
+	return func(socket *Server) {
+		socket.debugAddr = addr
+		socket.debugHandler = handler
+	}
+}
+
+// WithAuditSink records connections, disconnects with their reason, rejected handshakes and room
+// joins and leaves, with the user, tenant and IP of the client, to sink
+func WithAuditSink(sink AuditSink) Option {
+	return func(socket *Server) {
+		socket.auditor = newAuditor(sink)
+	}
+}
+
+// WithPlugins registers plugins, see Register
+func WithPlugins(plugins ...Plugin) Option {
+	return func(socket *Server) {
+		socket.plugins = append(socket.plugins, plugins...)
+	}
+}
+
+// WithOutbound adds outbound transformers, see UseOutbound
+func WithOutbound(transformers ...OutboundTransformer) Option {
+	return func(socket *Server) {
+		socket.outbound = append(socket.outbound, transformers...)
+	}
+}
+
+// WithProtocolVersion turns on protocol versioning: clients send their version in the "v" handshake
+// query parameter, those not sending one are assumed to speak minimum and those older than minimum are
+// refused with 426 Upgrade Required. Payloads are converted with the translators set by SetTranslator.
+func WithProtocolVersion(current, minimum int) Option {
+	return func(socket *Server) {
+		socket.protocol.current = current
+		socket.protocol.minimum = min(minimum, current)
+	}
+}
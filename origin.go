@@ -0,0 +1,47 @@
+package signal
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AllowOrigins returns a CheckOrigin function accepting the listed origins, for example "https://app.example.com".
+// "https://*.example.com" accepts any subdomain over https on any port, "*.example.com" on any scheme, and "*"
+// accepts every origin. Requests without an Origin header come from non-browser clients and are accepted.
+func AllowOrigins(origins ...string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		parsed, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		for _, allowed := range origins {
+			if matchOrigin(allowed, origin, parsed) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func matchOrigin(allowed, origin string, parsed *url.URL) bool {
+	if allowed == "*" {
+		return true
+	}
+	scheme, pattern, pinned := strings.Cut(allowed, "://")
+	if !pinned {
+		scheme, pattern = "", allowed
+	}
+	if !strings.HasPrefix(pattern, "*.") {
+		return strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin)
+	}
+	if pinned && !strings.EqualFold(scheme, parsed.Scheme) {
+		return false
+	}
+	// the wildcard matches the host name whatever the port, and never the bare domain
+	return strings.HasSuffix(strings.ToLower(parsed.Hostname()), strings.ToLower(strings.TrimSuffix(pattern[1:], "/")))
+}
@@ -0,0 +1,335 @@
+package signal
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ringReplicas is the number of points of every node on the hash ring, enough for rooms to spread evenly
+const ringReplicas = 64
+
+// hashRing maps keys to members by consistent hashing, only the keys of a node joining or leaving move
+type hashRing struct {
+	points []ringPoint
+	// addresses identifies the members the ring was built from
+	addresses string
+}
+
+type ringPoint struct {
+	hash   uint64
+	member Member
+}
+
+func newHashRing(members []Member) *hashRing {
+	ring := &hashRing{points: make([]ringPoint, 0, len(members)*ringReplicas)}
+	addresses := make([]string, 0, len(members))
+	for _, member := range members {
+		addresses = append(addresses, member.Address)
+		for i := range ringReplicas {
+			ring.points = append(ring.points, ringPoint{hash: ringHash(member.Address + "#" + strconv.Itoa(i)), member: member})
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i].hash < ring.points[j].hash })
+	slices.Sort(addresses)
+	ring.addresses = strings.Join(addresses, " ")
+	return ring
+}
+
+// owner returns the member owning key, the first point following its hash
+func (ring *hashRing) owner(key string) (Member, bool) {
+	if len(ring.points) == 0 {
+		return Member{}, false
+	}
+	hash := ringHash(key)
+	i := sort.Search(len(ring.points), func(i int) bool { return ring.points[i].hash >= hash })
+	if i == len(ring.points) {
+		i = 0
+	}
+	return ring.points[i].member, true
+}
+
+func ringHash(key string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return hash.Sum64()
+}
+
+// roomOwnership is the state of RoomOwnership: the ring, the nodes interested in the rooms this node owns,
+// the owners this node declared its interest to, and the ordered queues of requests to the other nodes
+type roomOwnership struct {
+	ring atomic.Pointer[hashRing]
+
+	mu        sync.Mutex
+	interests map[string]map[string]struct{}
+	declared  map[string]string
+	peers     map[string]chan peerRequest
+}
+
+type peerRequest struct {
+	path string
+	body []byte
+	// done, optional, receives the outcome of the request
+	done func(handled bool, err error)
+}
+
+// roomPacket is a room emit exchanged between the owner of the room and the other nodes
+type roomPacket struct {
+	Origin string        `json:"origin"`
+	Packet clusterPacket `json:"packet"`
+}
+
+type roomInterest struct {
+	Tenant  string `json:"tenant,omitempty"`
+	Room    string `json:"room"`
+	Address string `json:"address"`
+}
+
+func (socket *Server) ownsRooms() bool {
+	return socket.cluster != nil && socket.cluster.config.RoomOwnership
+}
+
+// RoomOwner returns the node owning the room, false when RoomOwnership is disabled or no member is known yet.
+// A load balancer routing the clients of a room to its owner spares the relaying between nodes.
+func (socket *Server) RoomOwner(roomId string) (Member, bool) {
+	return socket.roomOwner("", roomId)
+}
+
+// RoomOwner returns the node owning the tenant room, see Server.RoomOwner
+func (tenant *Tenant) RoomOwner(roomId string) (Member, bool) {
+	return tenant.server.roomOwner(tenant.Id, roomId)
+}
+
+func (socket *Server) roomOwner(tenant, roomId string) (Member, bool) {
+	if !socket.ownsRooms() {
+		return Member{}, false
+	}
+	ring := socket.cluster.ownership.ring.Load()
+	if ring == nil {
+		return Member{}, false
+	}
+	return ring.owner(roomKey(tenant, roomId))
+}
+
+// updateRing rebuilds the ring from the discovered members, the rooms whose owner moved are declared again
+func (socket *Server) updateRing(members []Member) {
+	ring := newHashRing(members)
+	previous := socket.cluster.ownership.ring.Swap(ring)
+	if previous != nil && previous.addresses == ring.addresses {
+		return
+	}
+	ownership := &socket.cluster.ownership
+	ownership.mu.Lock()
+	ownership.declared = nil
+	ownership.mu.Unlock()
+
+	for _, room := range socket.roomList() {
+		socket.declareInterest(room.Tenant, room.Id)
+	}
+	socket.watchers.mu.RLock()
+	keys := make([]string, 0, len(socket.watchers.watchers))
+	for key := range socket.watchers.watchers {
+		keys = append(keys, key)
+	}
+	socket.watchers.mu.RUnlock()
+	for _, key := range keys {
+		tenant, roomId, found := strings.Cut(key, "\x00")
+		if !found {
+			tenant, roomId = "", key
+		}
+		socket.declareInterest(tenant, roomId)
+	}
+}
+
+// declareInterest asks the owner of a room to relay its emits to this node, which holds members or watchers of it
+func (socket *Server) declareInterest(tenant, roomId string) {
+	owner, ok := socket.roomOwner(tenant, roomId)
+	if !ok || owner.Address == socket.cluster.config.Self {
+		return
+	}
+	self := socket.cluster.config.Self
+	key := roomKey(tenant, roomId)
+	ownership := &socket.cluster.ownership
+	ownership.mu.Lock()
+	if ownership.declared[key] == owner.Address {
+		ownership.mu.Unlock()
+		return
+	}
+	if ownership.declared == nil {
+		ownership.declared = make(map[string]string)
+	}
+	ownership.declared[key] = owner.Address
+	ownership.mu.Unlock()
+
+	body, _ := json.Marshal(roomInterest{Tenant: tenant, Room: roomId, Address: self})
+	socket.sendToPeer(owner.Address, peerRequest{path: "/room/interest", body: body, done: func(handled bool, err error) {
+		if err != nil {
+			log.Printf("Cluster room interest error: %v", err)
+			// declared again at the next join
+			ownership.mu.Lock()
+			delete(ownership.declared, key)
+			ownership.mu.Unlock()
+		}
+	}})
+}
+
+// publishRoom sends a room emit of this node to the owner of the room, or relays it when this node owns it
+func (socket *Server) publishRoom(packet clusterPacket) {
+	owner, ok := socket.roomOwner(packet.Tenant, packet.Room)
+	if !ok {
+		socket.publishPacket(packet)
+		return
+	}
+	self := socket.cluster.config.Self
+	packet.Node = socket.nodeId
+	if owner.Address == self {
+		socket.relayRoom(packet, self)
+		return
+	}
+	body, err := json.Marshal(roomPacket{Origin: self, Packet: packet})
+	if err != nil {
+		log.Printf("Cluster room marshal error: %v", err)
+		return
+	}
+	socket.sendToPeer(owner.Address, peerRequest{path: "/room/route", body: body, done: func(handled bool, err error) {
+		if err != nil {
+			log.Printf("Cluster room route error: %v", err)
+			// the owner is unreachable, the adapter still reaches every node
+			socket.publishPacket(packet)
+		}
+	}})
+}
+
+// relayRoom sends a room emit to the nodes interested in the room, but the one it comes from
+func (socket *Server) relayRoom(packet clusterPacket, origin string) {
+	key := roomKey(packet.Tenant, packet.Room)
+	ownership := &socket.cluster.ownership
+	ownership.mu.Lock()
+	addresses := make([]string, 0, len(ownership.interests[key]))
+	for address := range ownership.interests[key] {
+		if address != origin {
+			addresses = append(addresses, address)
+		}
+	}
+	ownership.mu.Unlock()
+	if len(addresses) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(roomPacket{Origin: origin, Packet: packet})
+	if err != nil {
+		log.Printf("Cluster room marshal error: %v", err)
+		return
+	}
+	for _, address := range addresses {
+		socket.sendToPeer(address, peerRequest{path: "/room/deliver", body: body, done: func(handled bool, err error) {
+			if err == nil && !handled {
+				// the node has neither members nor watchers of the room anymore
+				ownership.mu.Lock()
+				delete(ownership.interests[key], address)
+				if len(ownership.interests[key]) == 0 {
+					delete(ownership.interests, key)
+				}
+				ownership.mu.Unlock()
+			}
+		}})
+	}
+}
+
+// sendToPeer queues a request to a node, the requests to a node are sent in order by a goroutine of its own
+func (socket *Server) sendToPeer(address string, request peerRequest) {
+	ownership := &socket.cluster.ownership
+	ownership.mu.Lock()
+	queue, exists := ownership.peers[address]
+	if !exists {
+		if ownership.peers == nil {
+			ownership.peers = make(map[string]chan peerRequest)
+		}
+		queue = make(chan peerRequest, 1024)
+		ownership.peers[address] = queue
+		go socket.drainPeer(address, queue)
+	}
+	ownership.mu.Unlock()
+
+	select {
+	case queue <- request:
+	default:
+		log.Printf("Cluster queue to %s full, dropping %s", address, request.path)
+	}
+}
+
+func (socket *Server) drainPeer(address string, queue chan peerRequest) {
+	for {
+		select {
+		case request := <-queue:
+			handled, err := socket.cluster.post(address, request.path, request.body)
+			if request.done != nil {
+				request.done(handled, err)
+			}
+		case <-socket.ctx.Done():
+			return
+		}
+	}
+}
+
+// clusterRoomRoute receives the room emits of nodes not owning the room
+func (socket *Server) clusterRoomRoute(w http.ResponseWriter, r *http.Request) {
+	var request roomPacket
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid room packet", http.StatusBadRequest)
+		return
+	}
+	socket.deliverPacket(request.Packet)
+	socket.relayRoom(request.Packet, request.Origin)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clusterRoomDeliver receives the room emits relayed by the owner, answering 404 when no longer interested
+func (socket *Server) clusterRoomDeliver(w http.ResponseWriter, r *http.Request) {
+	var request roomPacket
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid room packet", http.StatusBadRequest)
+		return
+	}
+	key := roomKey(request.Packet.Tenant, request.Packet.Room)
+	socket.watchers.mu.RLock()
+	watched := len(socket.watchers.watchers[key]) > 0
+	socket.watchers.mu.RUnlock()
+	if !watched && socket.lookupRoom(request.Packet.Tenant, request.Packet.Room) == nil {
+		socket.cluster.ownership.mu.Lock()
+		delete(socket.cluster.ownership.declared, key)
+		socket.cluster.ownership.mu.Unlock()
+		http.Error(w, "room not found", http.StatusNotFound)
+		return
+	}
+	socket.deliverPacket(request.Packet)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clusterRoomInterest records a node holding members of a room this node owns
+func (socket *Server) clusterRoomInterest(w http.ResponseWriter, r *http.Request) {
+	var request roomInterest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Address == "" {
+		http.Error(w, "invalid room interest", http.StatusBadRequest)
+		return
+	}
+	key := roomKey(request.Tenant, request.Room)
+	ownership := &socket.cluster.ownership
+	ownership.mu.Lock()
+	if ownership.interests == nil {
+		ownership.interests = make(map[string]map[string]struct{})
+	}
+	if ownership.interests[key] == nil {
+		ownership.interests[key] = make(map[string]struct{})
+	}
+	ownership.interests[key][request.Address] = struct{}{}
+	ownership.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// Plugin packages a feature hooking into the server lifecycle. Embed BasePlugin to implement only the hooks needed.
+type Plugin interface {
+	Name() string
+	// OnStart runs when the server starts serving, an error aborts the start
+	OnStart(server *Server) error
+	// OnConnect runs once a connection is upgraded, before the connect listener. An error closes it.
+	OnConnect(client *Client) error
+	// OnMessage runs for every decoded client message before authorization and validation,
+	// it may modify the message. An error is answered to the client and the message dropped.
+	OnMessage(client *Client, message *Message) error
+	// OnEmit runs after an event is written to clients of this node, with the number of recipients reached
+	OnEmit(eventName string, payload Payload, delivered int)
+	// OnShutdown runs when the server shuts down, before the connections are closed
+	OnShutdown(ctx context.Context) error
+}
+
+// BasePlugin implements every Plugin hook as a no-op
+type BasePlugin struct{}
+
+func (BasePlugin) OnStart(server *Server) error                            { return nil }
+func (BasePlugin) OnConnect(client *Client) error                          { return nil }
+func (BasePlugin) OnMessage(client *Client, message *Message) error        { return nil }
+func (BasePlugin) OnEmit(eventName string, payload Payload, delivered int) {}
+func (BasePlugin) OnShutdown(ctx context.Context) error                    { return nil }
+
+// Register adds plugins, their hooks run in registration order. Register them before Start.
+func (socket *Server) Register(plugins ...Plugin) {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	socket.plugins = append(socket.plugins, plugins...)
+}
+
+func (socket *Server) pluginList() []Plugin {
+	if socket == nil {
+		return nil
+	}
+	socket.mu.RLock()
+	defer socket.mu.RUnlock()
+	return socket.plugins
+}
+
+func (socket *Server) startPlugins() error {
+	for _, plugin := range socket.pluginList() {
+		if err := plugin.OnStart(socket); err != nil {
+			return fmt.Errorf("plugin %s: %w", plugin.Name(), err)
+		}
+	}
+	return nil
+}
+
+// connectPlugins runs the OnConnect hooks, it closes the connection and returns false when one fails
+func (socket *Server) connectPlugins(client *Client) bool {
+	for _, plugin := range socket.pluginList() {
+		if err := plugin.OnConnect(client); err != nil {
+			client.closeWith(DisconnectKicked, websocket.ClosePolicyViolation, fmt.Errorf("plugin %s: %w", plugin.Name(), err))
+			return false
+		}
+	}
+	return true
+}
+
+// messagePlugins runs the OnMessage hooks, it answers the client and returns false when one fails
+func (socket *Server) messagePlugins(client *Client, message *Message) bool {
+	for _, plugin := range socket.pluginList() {
+		if err := plugin.OnMessage(client, message); err != nil {
+			var envelope *Error
+			if errors.As(err, &envelope) {
+				client.emitErrorFor(*message, envelope.Code, envelope.Message)
+			} else {
+				client.emitErrorFor(*message, CodeInternal, err.Error())
+			}
+			return false
+		}
+	}
+	return true
+}
+
+func (socket *Server) emitPlugins(eventName string, payload Payload, delivered int) {
+	for _, plugin := range socket.pluginList() {
+		plugin.OnEmit(eventName, payload, delivered)
+	}
+}
+
+func (socket *Server) shutdownPlugins(ctx context.Context) error {
+	var errs []error
+	for _, plugin := range socket.pluginList() {
+		if err := plugin.OnShutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s: %w", plugin.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,51 @@
+package signal
+
+import "slices"
+
+// Priority is the outbound lane of an event, see SetPriority
+type Priority int
+
+const (
+	// PriorityLow is for bulk data that can wait behind everything else
+	PriorityLow Priority = iota - 1
+	// PriorityNormal is the lane of events without a priority
+	PriorityNormal
+	// PriorityHigh is for control messages and alerts, they skip the coalescing window
+	PriorityHigh
+)
+
+// SetPriority sets the lane of eventName in the outbound buffer of each client: pending messages are
+// written high priority first, keeping the emit order within a lane, and a high priority emit is
+// written right away along with what is pending instead of waiting for the coalescing window, so
+// critical events are not stuck behind a backlog of updates. The outbound buffer is the coalescing
+// window, priorities require WithCoalescing.
+func (socket *Server) SetPriority(eventName string, priority Priority) {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	if socket.priorities == nil {
+		socket.priorities = make(map[string]Priority)
+	}
+	socket.priorities[eventName] = priority
+}
+
+func (socket *Server) priority(eventName string) Priority {
+	socket.mu.RLock()
+	defer socket.mu.RUnlock()
+	return socket.priorities[eventName]
+}
+
+// byPriority orders the pending messages high priority first, the order of a lane is kept
+func byPriority(pending []Message, lanes []Priority) []Message {
+	if !slices.ContainsFunc(lanes, func(lane Priority) bool { return lane != PriorityNormal }) {
+		return pending
+	}
+	ordered := make([]Message, 0, len(pending))
+	for _, lane := range []Priority{PriorityHigh, PriorityNormal, PriorityLow} {
+		for i, message := range pending {
+			if lanes[i] == lane {
+				ordered = append(ordered, message)
+			}
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,63 @@
+package signal
+
+import (
+	"context"
+	"errors"
+)
+
+// ProcedureFunc answers a remote procedure call, the returned payload is sent back to the caller
+type ProcedureFunc func(ctx context.Context, request Payload) (Payload, error)
+
+// callerKey is the context key of the client calling a procedure
+type callerKey struct{}
+
+// Caller returns the client calling the procedure running with ctx
+func Caller(ctx context.Context) (*Client, bool) {
+	client, ok := ctx.Value(callerKey{}).(*Client)
+	return client, ok
+}
+
+// Procedure registers a remote procedure on top of the event system: the client emits name with an id,
+// and receives either name with the same id and the result as payload, or an ErrorEvent correlated
+// with the id. Errors are sent with their *Error code, CodeTimeout once the handler timeout of name
+// (see SetHandlerTimeout) expired, CodeInternal otherwise.
+func (socket *Server) Procedure(name string, procedure ProcedureFunc) {
+	socket.On(name, func(payload Payload, client *Client) {
+		ctx := client.Context()
+		message, _ := InboundMessage(ctx)
+		result, err := procedure(context.WithValue(ctx, callerKey{}, client), payload)
+		if err == nil && ctx.Err() != nil {
+			// results arriving after the handler timeout are reported as a timeout
+			err = ctx.Err()
+		}
+		if err != nil {
+			var envelope *Error
+			switch {
+			case errors.As(err, &envelope):
+				client.emitErrorFor(message, envelope.Code, envelope.Message)
+			case errors.Is(err, context.DeadlineExceeded):
+				client.emitErrorFor(message, CodeTimeout, err.Error())
+			default:
+				client.emitErrorFor(message, CodeInternal, err.Error())
+			}
+			return
+		}
+		client.ReplyContext(ctx, result)
+	})
+}
+
+// TypedProcedure adapts a procedure taking its request as T, bound with Bind, and returning R:
+//
+//	socket.Procedure("getProfile", signal.TypedProcedure(func(ctx context.Context, req ProfileRequest) (*Profile, error) {
+//		client, _ := signal.Caller(ctx)
+//		return profiles.Get(ctx, client.UserId(), req.Id)
+//	}))
+func TypedProcedure[T, R any](procedure func(ctx context.Context, request T) (R, error)) ProcedureFunc {
+	return func(ctx context.Context, payload Payload) (Payload, error) {
+		var request T
+		if err := Bind(payload, &request); err != nil {
+			return nil, err
+		}
+		return procedure(ctx, request)
+	}
+}
@@ -0,0 +1,179 @@
+// Package pubsubadapter fans signal.io broadcasts and room emits out to every replica through Google Cloud Pub/Sub.
+//
+// Every node publishes to a shared topic and receives from a subscription of its own, a subscription shared
+// by several nodes would split the messages between them instead of fanning them out. Give each node its
+// subscription with WithSubscription, or let the adapter create one when the client implements Subscriptions.
+//
+// The package does not depend on the Pub/Sub client, with cloud.google.com/go/pubsub:
+//
+//	type pubsubClient struct{ *pubsub.Client }
+//
+//	func (c pubsubClient) Publish(ctx context.Context, topic string, data []byte) error {
+//		_, err := c.Topic(topic).Publish(ctx, &pubsub.Message{Data: data}).Get(ctx)
+//		return err
+//	}
+//
+//	func (c pubsubClient) Receive(ctx context.Context, subscription string, handler func(data []byte)) error {
+//		return c.Subscription(subscription).Receive(ctx, func(_ context.Context, msg *pubsub.Message) {
+//			handler(msg.Data)
+//			msg.Ack()
+//		})
+//	}
+//
+//	socket := signal.IOServer("8080", signal.WithAdapter(pubsubadapter.New(pubsubClient{client},
+//		pubsubadapter.WithSubscription("signal-"+os.Getenv("HOSTNAME")))))
+package pubsubadapter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultTopic is the topic used when none is configured
+const DefaultTopic = "signal-io"
+
+// Client is the part of a Pub/Sub client used by the adapter
+type Client interface {
+	Publish(ctx context.Context, topic string, data []byte) error
+	// Receive hands the messages of subscription to handler until ctx is done, acknowledging them
+	Receive(ctx context.Context, subscription string, handler func(data []byte)) error
+}
+
+// Subscriptions is implemented by clients able to create and delete subscriptions, the adapter then
+// creates a subscription for the node when none is configured and deletes it on Close
+type Subscriptions interface {
+	CreateSubscription(ctx context.Context, topic, subscription string) error
+	DeleteSubscription(ctx context.Context, subscription string) error
+}
+
+// Adapter implements signal.Adapter on top of a Pub/Sub topic
+type Adapter struct {
+	client       Client
+	topic        string
+	subscription string
+	timeout      time.Duration
+
+	mu sync.Mutex
+	// created is set when the adapter created the subscription, it deletes it on Close
+	created bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// Option configures the adapter
+type Option func(*Adapter)
+
+// WithTopic changes the topic shared by the nodes, use one topic per cluster
+func WithTopic(topic string) Option {
+	return func(adapter *Adapter) {
+		adapter.topic = topic
+	}
+}
+
+// WithSubscription sets the subscription of this node, attached to the topic and used by no other node
+func WithSubscription(subscription string) Option {
+	return func(adapter *Adapter) {
+		adapter.subscription = subscription
+	}
+}
+
+// WithTimeout bounds every publish and subscription change, 10 seconds by default
+func WithTimeout(timeout time.Duration) Option {
+	return func(adapter *Adapter) {
+		adapter.timeout = timeout
+	}
+}
+
+// New creates an adapter publishing on DefaultTopic unless configured otherwise
+func New(client Client, options ...Option) *Adapter {
+	adapter := &Adapter{
+		client:  client,
+		topic:   DefaultTopic,
+		timeout: 10 * time.Second,
+	}
+	for _, option := range options {
+		option(adapter)
+	}
+	return adapter
+}
+
+func (adapter *Adapter) Publish(data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), adapter.timeout)
+	defer cancel()
+	return adapter.client.Publish(ctx, adapter.topic, data)
+}
+
+func (adapter *Adapter) Subscribe(handler func(data []byte)) error {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+
+	if adapter.cancel != nil {
+		return errors.New("pubsubadapter: already subscribed")
+	}
+	if adapter.subscription == "" {
+		subscriptions, ok := adapter.client.(Subscriptions)
+		if !ok {
+			return errors.New("pubsubadapter: no subscription, see WithSubscription")
+		}
+		subscription := adapter.topic + "-" + randomSuffix()
+		ctx, cancel := context.WithTimeout(context.Background(), adapter.timeout)
+		defer cancel()
+		if err := subscriptions.CreateSubscription(ctx, adapter.topic, subscription); err != nil {
+			return err
+		}
+		adapter.subscription, adapter.created = subscription, true
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	adapter.cancel, adapter.done = cancel, make(chan struct{})
+	go adapter.receive(ctx, handler, adapter.done)
+	return nil
+}
+
+// receive runs the subscription until the adapter is closed, restarting it when it fails
+func (adapter *Adapter) receive(ctx context.Context, handler func(data []byte), done chan struct{}) {
+	defer close(done)
+	for {
+		err := adapter.client.Receive(ctx, adapter.subscription, handler)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("pubsubadapter: receive error, retrying: %v", err)
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (adapter *Adapter) Close() error {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+
+	if adapter.cancel == nil {
+		return nil
+	}
+	adapter.cancel()
+	<-adapter.done
+	adapter.cancel = nil
+	if !adapter.created {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adapter.timeout)
+	defer cancel()
+	subscription := adapter.subscription
+	adapter.subscription, adapter.created = "", false
+	return adapter.client.(Subscriptions).DeleteSubscription(ctx, subscription)
+}
+
+func randomSuffix() string {
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	return hex.EncodeToString(suffix)
+}
@@ -0,0 +1,121 @@
+package signal
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrTooManyConnections is returned when a handshake exceeds one of the connection limits
+var ErrTooManyConnections = errors.New("too many connections")
+
+// QuotaRejection selects how handshakes exceeding a connection limit are refused
+type QuotaRejection int
+
+const (
+	// RejectWithHTTP answers 503 Service Unavailable without upgrading
+	RejectWithHTTP QuotaRejection = iota
+	// RejectWithCloseFrame upgrades then closes with the 1013 "try again later" close code,
+	// which browser clients can observe, unlike HTTP statuses on failed handshakes
+	RejectWithCloseFrame
+)
+
+// quota counts the connections held by the server, per IP and per user
+type quota struct {
+	maxTotal   int
+	maxPerIP   int
+	maxPerUser int
+	rejection  QuotaRejection
+
+	mu      sync.Mutex
+	total   int
+	perIP   map[string]int
+	perUser map[string]int
+}
+
+func (q *quota) enabled() bool {
+	return q.maxTotal > 0 || q.maxPerIP > 0 || q.maxPerUser > 0
+}
+
+// reserve takes a slot for the connection, it must be given back with release
+func (q *quota) reserve(ip, userId string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.maxTotal > 0 && q.total >= q.maxTotal {
+		return ErrTooManyConnections
+	}
+	if q.maxPerIP > 0 && q.perIP[ip] >= q.maxPerIP {
+		return ErrTooManyConnections
+	}
+	if q.maxPerUser > 0 && userId != "" && q.perUser[userId] >= q.maxPerUser {
+		return ErrTooManyConnections
+	}
+
+	if q.perIP == nil {
+		q.perIP = make(map[string]int)
+		q.perUser = make(map[string]int)
+	}
+	q.total++
+	q.perIP[ip]++
+	if userId != "" {
+		q.perUser[userId]++
+	}
+	return nil
+}
+
+func (q *quota) release(ip, userId string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.total--
+	if q.perIP[ip]--; q.perIP[ip] <= 0 {
+		delete(q.perIP, ip)
+	}
+	if userId != "" {
+		if q.perUser[userId]--; q.perUser[userId] <= 0 {
+			delete(q.perUser, userId)
+		}
+	}
+}
+
+// rejectOverQuota refuses the handshake the configured way
+func (socket *Server) rejectOverQuota(w http.ResponseWriter, r *http.Request, err error) {
+	if socket.quota.rejection == RejectWithHTTP {
+		rejectHandshake(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	ws, upgradeErr := socket.upgrader.Upgrade(w, r, nil)
+	if upgradeErr != nil {
+		return
+	}
+	defer ws.Close()
+	ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error()), time.Now().Add(time.Second))
+}
+
+// UserId returns the user the client authenticated as, empty for anonymous clients
+func (client *Client) UserId() string {
+	if client.state == nil {
+		return ""
+	}
+	client.state.mu.RLock()
+	defer client.state.mu.RUnlock()
+	return client.state.userId
+}
+
+// resolveUser identifies the user behind the client once the middlewares ran,
+// by default the subject of its JWT claims
+func (socket *Server) resolveUser(client *Client) {
+	var userId string
+	if socket.userResolver != nil {
+		userId = socket.userResolver(client)
+	} else {
+		userId = client.Claims().Subject()
+	}
+	client.state.mu.Lock()
+	client.state.userId = userId
+	client.state.mu.Unlock()
+}
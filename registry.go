@@ -0,0 +1,83 @@
+package signal
+
+import (
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+)
+
+// roomShards is the number of independently locked parts of the room registry
+const roomShards = 64
+
+// roomRegistry spreads the rooms over shards keyed by room hash, so joins and leaves
+// on different rooms do not wait on a single lock
+type roomRegistry struct {
+	seed   maphash.Seed
+	shards []roomShard
+}
+
+type roomShard struct {
+	mu    sync.RWMutex
+	rooms map[string]*Room
+	// sequences holds the sequence counter of every room key, it outlives the rooms so a room dropped
+	// and created again continues its sequence
+	sequences map[string]*atomic.Uint64
+}
+
+// newRoomRegistry creates a registry of the given number of shards, a single shard being one global lock
+func newRoomRegistry(shards int) *roomRegistry {
+	registry := &roomRegistry{seed: maphash.MakeSeed(), shards: make([]roomShard, shards)}
+	for i := range registry.shards {
+		registry.shards[i].rooms = make(map[string]*Room)
+		registry.shards[i].sequences = make(map[string]*atomic.Uint64)
+	}
+	return registry
+}
+
+// shard returns the shard holding the room registered under key
+func (registry *roomRegistry) shard(key string) *roomShard {
+	return &registry.shards[maphash.String(registry.seed, key)%uint64(len(registry.shards))]
+}
+
+// sequence returns the sequence counter of the room key, the caller holds shard.mu
+func (shard *roomShard) sequence(key string) *atomic.Uint64 {
+	counter := shard.sequences[key]
+	if counter == nil {
+		counter = new(atomic.Uint64)
+		shard.sequences[key] = counter
+	}
+	return counter
+}
+
+// lastSeq returns the last sequence number of the room key, zero when it has none
+func (registry *roomRegistry) lastSeq(key string) uint64 {
+	shard := registry.shard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	if counter := shard.sequences[key]; counter != nil {
+		return counter.Load()
+	}
+	return 0
+}
+
+// get returns the room registered under key, nil when there is none
+func (registry *roomRegistry) get(key string) *Room {
+	shard := registry.shard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	return shard.rooms[key]
+}
+
+// list returns a snapshot of the registered rooms, locking one shard at a time
+func (registry *roomRegistry) list() []*Room {
+	rooms := make([]*Room, 0)
+	for i := range registry.shards {
+		shard := &registry.shards[i]
+		shard.mu.RLock()
+		for _, room := range shard.rooms {
+			rooms = append(rooms, room)
+		}
+		shard.mu.RUnlock()
+	}
+	return rooms
+}
@@ -0,0 +1,37 @@
+package signal
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// BenchmarkRoomJoinLeave joins and leaves rooms from parallel goroutines, with the sharded registry
+// and with a single shard standing for the former global lock
+func BenchmarkRoomJoinLeave(b *testing.B) {
+	for _, bench := range []struct {
+		name   string
+		shards int
+	}{
+		{"sharded", roomShards},
+		{"single-lock", 1},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			socket := IOServer("0")
+			socket.rooms = newRoomRegistry(bench.shards)
+			var workers atomic.Int64
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				worker := workers.Add(1)
+				client := &Client{ConnectionId: "bench-" + strconv.FormatInt(worker, 10), state: newClientState(socket)}
+				for i := 0; pb.Next(); i++ {
+					room := socket.Room("room-" + strconv.Itoa(int(worker)*31+i%64))
+					if err := room.Join(client); err != nil {
+						b.Fatal(err)
+					}
+					room.Leave(client.ConnectionId)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package signal
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoInboundMessage is returned by Reply when the client context does not belong to an inbound message
+var ErrNoInboundMessage = errors.New("no inbound message to reply to")
+
+// inboundKey is the context key of the message being handled
+type inboundKey struct{}
+
+// InboundMessage returns the message being handled in ctx, as received from the client
+func InboundMessage(ctx context.Context) (Message, bool) {
+	message, ok := ctx.Value(inboundKey{}).(Message)
+	return message, ok
+}
+
+// Reply answers the message being handled: the payload is sent with the event name and the id of the
+// inbound message, so clients correlate the response without threading ids through Emit.
+// Handlers replying from another goroutine should capture client.Context() and use ReplyContext.
+func (client *Client) Reply(payload Payload) error {
+	return client.ReplyContext(client.Context(), payload)
+}
+
+// ReplyContext answers the inbound message carried by ctx, see Reply
+func (client *Client) ReplyContext(ctx context.Context, payload Payload) error {
+	message, ok := InboundMessage(ctx)
+	if !ok {
+		return ErrNoInboundMessage
+	}
+	return client.emitMessage(Message{Id: message.Id, EventName: message.EventName, Payload: payload}, client.writeTimeout())
+}
@@ -0,0 +1,40 @@
+package signal
+
+import (
+	"errors"
+	"fmt"
+)
+
+// BroadcastResult summarizes an emit to several clients connected to this node.
+// Clients reached through the cluster adapter are not counted.
+type BroadcastResult struct {
+	// Delivered counts the clients the message was written to. With WithCoalescing it counts the clients
+	// it was queued for, the messages whose write fails later being reported to the dead-letter sink.
+	Delivered int
+	// Errors holds the write error of every client the message could not be delivered to, by connection id
+	Errors map[string]error
+}
+
+// Failed returns the number of clients the message could not be delivered to
+func (result BroadcastResult) Failed() int {
+	return len(result.Errors)
+}
+
+// Err joins the per-client errors, nil when every client received the message
+func (result BroadcastResult) Err() error {
+	if len(result.Errors) == 0 {
+		return nil
+	}
+	errs := make([]error, 0, len(result.Errors))
+	for connectionId, err := range result.Errors {
+		errs = append(errs, fmt.Errorf("%s: %w", connectionId, err))
+	}
+	return errors.Join(errs...)
+}
+
+func (result *BroadcastResult) fail(connectionId string, err error) {
+	if result.Errors == nil {
+		result.Errors = make(map[string]error)
+	}
+	result.Errors[connectionId] = err
+}
@@ -0,0 +1,90 @@
+package signal
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy retries the writes to a client that failed with a transient error
+type RetryPolicy struct {
+	// Attempts is the number of writes tried, the first one included, 3 by default
+	Attempts int
+	// Backoff is the delay before the first retry, doubled after every attempt, 50ms by default
+	Backoff time.Duration
+	// MaxBackoff caps the delay between attempts, 1 second by default
+	MaxBackoff time.Duration
+	// Jitter randomizes up to this fraction of every delay so retries of many clients spread out
+	Jitter float64
+	// Retryable tells the transient errors apart, IsTransient by default
+	Retryable func(err error) bool
+}
+
+func (policy RetryPolicy) withDefaults() RetryPolicy {
+	if policy.Attempts <= 0 {
+		policy.Attempts = 3
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = 50 * time.Millisecond
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = time.Second
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = IsTransient
+	}
+	return policy
+}
+
+// IsTransient reports whether a write failed without breaking the connection, such as a write timing
+// out while another write held the connection. Writes failing on a closed connection are never retried.
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrWriteTimeout)
+}
+
+// delay returns the wait before the retry following attempt, counted from 1
+func (policy RetryPolicy) delay(attempt int) time.Duration {
+	delay := policy.Backoff
+	for i := 1; i < attempt && delay < policy.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, policy.MaxBackoff)
+	if policy.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * policy.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// writeWithTimeout writes the frame unless the circuit breaker of the client tripped, retrying transient
+// failures while the connection stays open when a RetryPolicy is set
+func (client *Client) writeWithTimeout(data []byte, timeout time.Duration) error {
+	if client.circuitOpen() {
+		return ErrCircuitOpen
+	}
+	err := client.writeWithRetry(data, timeout)
+	client.recordWrite(err)
+	return err
+}
+
+func (client *Client) writeWithRetry(data []byte, timeout time.Duration) error {
+	err := client.writeOnce(data, timeout)
+	server := client.server()
+	if err == nil || server == nil || server.retry == nil {
+		return err
+	}
+	policy := server.retry
+	for attempt := 1; attempt < policy.Attempts; attempt++ {
+		if !policy.Retryable(err) || client.State() >= StateClosing {
+			return err
+		}
+		select {
+		case <-time.After(policy.delay(attempt)):
+		case <-client.Context().Done():
+			return err
+		}
+		if err = client.writeOnce(data, timeout); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,342 @@
+package signal
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRoomFull is returned when joining a room that reached its member limit
+var ErrRoomFull = errors.New("room is full")
+
+// Room is a named group of clients that can be addressed together
+type Room struct {
+	Id     string
+	Tenant string
+	server *Server
+	key    string
+
+	mu         sync.RWMutex
+	clients    []*Client
+	metadata   map[string]any
+	ttlTimer   *Timer
+	idleTimer  *Timer
+	maxIdle    time.Duration
+	maxClients int
+	welcome    WelcomeFunc
+	ephemerals map[string]*Ephemeral
+
+	// idle is set while maxIdle is, lastActive holds the Unix nanoseconds of the last activity, both are
+	// read without the lock so concurrent emits do not serialize on it
+	idle       atomic.Bool
+	lastActive atomic.Int64
+
+	// seq is the last sequence number, shared by the instances of the room key with room sequences,
+	// emitMu keeps the frames of concurrent emits in sequence order
+	seq    *atomic.Uint64
+	emitMu sync.Mutex
+}
+
+// RoomView is a room with some of its clients left out, see Room.Except
+type RoomView struct {
+	room   *Room
+	except []string
+	local  bool
+}
+
+func newRoom(server *Server, tenant, roomId string) *Room {
+	return &Room{
+		Id:       roomId,
+		Tenant:   tenant,
+		server:   server,
+		key:      roomKey(tenant, roomId),
+		clients:  make([]*Client, 0),
+		metadata: make(map[string]any),
+		seq:      new(atomic.Uint64),
+	}
+}
+
+// Room returns the room registered under roomId, creating it when it does not exist yet.
+// Tenant rooms are reached through Tenant(tenantId).Room.
+func (socket *Server) Room(roomId string) *Room {
+	return socket.tenantRoom("", roomId)
+}
+
+func (socket *Server) tenantRoom(tenant, roomId string) *Room {
+	key := roomKey(tenant, roomId)
+	if room := socket.rooms.get(key); room != nil {
+		return room
+	}
+
+	shard := socket.rooms.shard(key)
+	shard.mu.Lock()
+	room, exists := shard.rooms[key]
+	if !exists {
+		room = newRoom(socket, tenant, roomId)
+		if socket.roomSequences {
+			room.seq = shard.sequence(key)
+		}
+		shard.rooms[key] = room
+	}
+	shard.mu.Unlock()
+
+	if !exists {
+		socket.bus.Emit(InternalEvent{Name: InternalRoomCreated, Room: room})
+	}
+	return room
+}
+
+// lookupRoom returns the registered room without creating it
+func (socket *Server) lookupRoom(tenant, roomId string) *Room {
+	return socket.rooms.get(roomKey(tenant, roomId))
+}
+
+// Join adds the client to the room, joining twice is a no-op.
+// The error returned by the CanJoin hook, if any, is returned as is and the client is not added.
+func (room *Room) Join(client *Client) error {
+	if client.Tenant() != room.Tenant {
+		return ErrTenantMismatch
+	}
+	if canJoin := room.server.canJoin; canJoin != nil {
+		if err := canJoin(room.Id, client); err != nil {
+			return err
+		}
+	}
+	target, added, err := room.add(client)
+	if added {
+		room.server.audit(AuditJoin, client, target.Id, nil)
+		room.server.declareInterest(target.Tenant, target.Id)
+		target.sendWelcome(client)
+	}
+	return err
+}
+
+// add registers the client on the room registered under the key, which may not be this instance when it
+// was dropped and created again. It returns that room and reports whether the client was not a member yet.
+func (room *Room) add(client *Client) (*Room, bool, error) {
+	// the registry shard stays locked until the client is added, so an emptied room cannot be dropped meanwhile
+	shard := room.server.rooms.shard(room.key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	target := room
+	if registered, exists := shard.rooms[room.key]; exists {
+		target = registered
+	} else {
+		// a closed room is registered again so it can be reused
+		shard.rooms[room.key] = room
+	}
+
+	target.mu.Lock()
+	defer target.mu.Unlock()
+	target.touch()
+	if IndexOf(client.ConnectionId, target.clients) != -1 {
+		return target, false, nil
+	}
+	if target.maxClients > 0 && len(target.clients) >= target.maxClients {
+		return target, false, ErrRoomFull
+	}
+	clients := make([]*Client, len(target.clients), len(target.clients)+1)
+	copy(clients, target.clients)
+	target.clients = append(clients, client)
+	return target, true, nil
+}
+
+// SetMaxClients limits the number of members, joining a full room fails with ErrRoomFull. Zero removes the limit.
+func (room *Room) SetMaxClients(maxClients int) *Room {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	room.maxClients = maxClients
+	return room
+}
+
+// MaxClients returns the member limit, zero when unlimited
+func (room *Room) MaxClients() int {
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+	return room.maxClients
+}
+
+// Leave removes the client from the room, the room is dropped once its last client leaves
+func (room *Room) Leave(connectionId string) {
+	removed, remaining := room.remove(connectionId)
+	if removed != nil {
+		room.server.audit(AuditLeave, removed, room.Id, nil)
+	}
+	if removed != nil && remaining == 0 {
+		shard := room.server.rooms.shard(room.key)
+		shard.mu.Lock()
+		// check again, someone may have joined meanwhile
+		dropped := shard.rooms[room.key] == room && room.Len() == 0
+		if dropped {
+			delete(shard.rooms, room.key)
+			room.mu.Lock()
+			room.stopExpiry()
+			room.mu.Unlock()
+		}
+		shard.mu.Unlock()
+
+		if dropped {
+			room.server.bus.Emit(InternalEvent{Name: InternalRoomClosed, Room: room})
+		}
+	}
+}
+
+// remove drops the client from the room members, it returns the removed client, nil when it was not
+// a member, and how many are left
+func (room *Room) remove(connectionId string) (*Client, int) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	position := IndexOf(connectionId, room.clients)
+	if position == -1 {
+		return nil, len(room.clients)
+	}
+	removed := room.clients[position]
+	// the member list is copied on write, see members
+	clients := make([]*Client, 0, len(room.clients)-1)
+	clients = append(clients, room.clients[:position]...)
+	room.clients = append(clients, room.clients[position+1:]...)
+	return removed, len(room.clients)
+}
+
+// Has reports whether the connection is a member of the room
+func (room *Room) Has(connectionId string) bool {
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+	return IndexOf(connectionId, room.clients) != -1
+}
+
+// Clients returns a snapshot of the room members
+func (room *Room) Clients() []*Client {
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+	clients := make([]*Client, len(room.clients))
+	copy(clients, room.clients)
+	return clients
+}
+
+// members returns the current member list, it is copied on write so it can be
+// read without holding the lock but must never be modified
+func (room *Room) members() []*Client {
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+	return room.clients
+}
+
+// Len returns the number of clients in the room
+func (room *Room) Len() int {
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+	return len(room.clients)
+}
+
+// Emit sends the event to every client in the room
+func (room *Room) Emit(eventName string, payload Payload) BroadcastResult {
+	room.touch()
+	result := room.emitMembers(room.members(), eventName, payload)
+	room.server.publish(room.Tenant, room.Id, nil, eventName, payload)
+	return result
+}
+
+// EmitFrom sends the event on behalf of client to every other member of the room,
+// after checking the CanEmit hook
+func (room *Room) EmitFrom(client *Client, eventName string, payload Payload) (BroadcastResult, error) {
+	if err := room.server.checkCanEmit(room.Id, client); err != nil {
+		return BroadcastResult{}, err
+	}
+	return room.Except(client.ConnectionId).Emit(eventName, payload), nil
+}
+
+func (socket *Server) checkCanEmit(roomId string, client *Client) error {
+	if socket.canEmit == nil {
+		return nil
+	}
+	return socket.canEmit(roomId, client)
+}
+
+// Close removes every client from the room and unregisters it from the server
+func (room *Room) Close() {
+	shard := room.server.rooms.shard(room.key)
+	shard.mu.Lock()
+	registered := shard.rooms[room.key] == room
+	if registered {
+		delete(shard.rooms, room.key)
+	}
+	shard.mu.Unlock()
+
+	room.mu.Lock()
+	room.clients = make([]*Client, 0)
+	room.stopExpiry()
+	room.mu.Unlock()
+
+	if registered {
+		room.server.bus.Emit(InternalEvent{Name: InternalRoomClosed, Room: room})
+	}
+}
+
+// Set stores a value on the room
+func (room *Room) Set(key string, value any) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	room.metadata[key] = value
+}
+
+// Get returns the value stored on the room under key, and whether it was set
+func (room *Room) Get(key string) (any, bool) {
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+	value, ok := room.metadata[key]
+	return value, ok
+}
+
+// Except returns a view of the room that leaves out the given connections
+func (room *Room) Except(connectionIds ...string) *RoomView {
+	return &RoomView{room: room, except: connectionIds}
+}
+
+// Except leaves more connections out of the view
+func (view *RoomView) Except(connectionIds ...string) *RoomView {
+	except := append(append([]string{}, view.except...), connectionIds...)
+	return &RoomView{room: view.room, except: except, local: view.local}
+}
+
+// Clients returns a snapshot of the room members, minus the excluded connections
+func (view *RoomView) Clients() []*Client {
+	if len(view.except) == 0 {
+		return view.room.Clients()
+	}
+	clients := view.room.members()
+	filtered := make([]*Client, 0, len(clients))
+	for _, client := range clients {
+		if !contains(view.except, client.ConnectionId) {
+			filtered = append(filtered, client)
+		}
+	}
+	return filtered
+}
+
+// Len returns the number of clients in the view
+func (view *RoomView) Len() int {
+	return len(view.Clients())
+}
+
+// Emit sends the event to every client in the view
+func (view *RoomView) Emit(eventName string, payload Payload) BroadcastResult {
+	view.room.touch()
+
+	result := view.emitLocal(eventName, payload)
+	if view.local {
+		return result
+	}
+	view.room.server.publish(view.room.Tenant, view.room.Id, view.except, eventName, payload)
+	return result
+}
+
+func (view *RoomView) emitLocal(eventName string, payload Payload) BroadcastResult {
+	if len(view.except) == 0 {
+		return view.room.emitMembers(view.room.members(), eventName, payload)
+	}
+	return view.room.emitMembers(view.Clients(), eventName, payload)
+}
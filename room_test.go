@@ -0,0 +1,132 @@
+package signal
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// connectClients opens n WebSocket connections to socket, their frames being read and dropped
+func connectClients(tb testing.TB, socket *Server, n int) []*Client {
+	tb.Helper()
+	server := httptest.NewServer(socket)
+	tb.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	for i := 0; i < n; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		tb.Cleanup(func() { conn.Close() })
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+	}
+	waitClients(tb, socket, n)
+	return socket.Clients()
+}
+
+// waitClients waits until n clients are connected to socket
+func waitClients(tb testing.TB, socket *Server, n int) {
+	tb.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(socket.Clients()) < n {
+		if time.Now().After(deadline) {
+			tb.Fatalf("%d of %d clients connected", len(socket.Clients()), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// BenchmarkRoomEmit emits to a room from parallel goroutines while another one keeps joining and leaving it.
+// The exclusive-lock case takes the room lock on every emit, as emits did to record the room activity.
+func BenchmarkRoomEmit(b *testing.B) {
+	for _, bench := range []struct {
+		name      string
+		exclusive bool
+	}{
+		{"lock-free", false},
+		{"exclusive-lock", true},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			socket := IOServer("0")
+			clients := connectClients(b, socket, 9)
+			room := socket.Room("bench")
+			for _, client := range clients[1:] {
+				if err := room.Join(client); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			var stop atomic.Bool
+			churned := make(chan struct{})
+			go func() {
+				defer close(churned)
+				for !stop.Load() {
+					room.Join(clients[0])
+					room.Leave(clients[0].ConnectionId)
+				}
+			}()
+			defer func() {
+				stop.Store(true)
+				<-churned
+			}()
+
+			payload := map[string]string{"text": "hello"}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if bench.exclusive {
+						room.mu.Lock()
+						room.touch()
+						room.mu.Unlock()
+					}
+					room.Emit("message", payload)
+				}
+			})
+		})
+	}
+}
+
+// TestJoinDroppedRoom joins through a room instance dropped from the registry, the client is added to the
+// registered room and gets its welcome
+func TestJoinDroppedRoom(t *testing.T) {
+	socket := IOServer("0")
+	clients := connectClients(t, socket, 3)
+
+	dropped := socket.Room("lobby")
+	var stale, current atomic.Int32
+	dropped.SetWelcome(func(*Client) Payload { stale.Add(1); return nil })
+	if err := dropped.Join(clients[0]); err != nil {
+		t.Fatal(err)
+	}
+	dropped.Leave(clients[0].ConnectionId)
+
+	registered := socket.Room("lobby")
+	if registered == dropped {
+		t.Fatal("the emptied room was not dropped")
+	}
+	registered.SetWelcome(func(*Client) Payload { current.Add(1); return nil })
+	if err := registered.Join(clients[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dropped.Join(clients[2]); err != nil {
+		t.Fatal(err)
+	}
+	if !registered.Has(clients[2].ConnectionId) || dropped.Has(clients[2].ConnectionId) {
+		t.Fatal("the client was not added to the registered room")
+	}
+	if stale.Load() != 1 || current.Load() != 2 {
+		t.Fatalf("welcomes: %d from the dropped room, %d from the registered one", stale.Load(), current.Load())
+	}
+}
@@ -0,0 +1,147 @@
+package signal
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Timer is the handle of a scheduled task
+type Timer struct {
+	at        time.Time
+	task      func()
+	index     int
+	scheduler *scheduler
+}
+
+// Cancel prevents the task from running, it reports false when the task already ran or was canceled
+func (timer *Timer) Cancel() bool {
+	return timer.scheduler.cancel(timer)
+}
+
+// timerHeap orders timers by due time
+type timerHeap []*Timer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x any) {
+	timer := x.(*Timer)
+	timer.index = len(*h)
+	*h = append(*h, timer)
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	timer := old[len(old)-1]
+	old[len(old)-1] = nil
+	timer.index = -1
+	*h = old[:len(old)-1]
+	return timer
+}
+
+// scheduler runs every scheduled task of a server from a single goroutine and a single runtime timer,
+// however many tasks are pending
+type scheduler struct {
+	mu      sync.Mutex
+	timers  timerHeap
+	wake    chan struct{}
+	running bool
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (s *scheduler) schedule(at time.Time, task func()) *Timer {
+	timer := &Timer{at: at, task: task, scheduler: s}
+
+	s.mu.Lock()
+	heap.Push(&s.timers, timer)
+	if !s.running {
+		s.running = true
+		go s.loop()
+	}
+	s.mu.Unlock()
+
+	s.notify()
+	return timer
+}
+
+func (s *scheduler) cancel(timer *Timer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if timer.index < 0 {
+		return false
+	}
+	heap.Remove(&s.timers, timer.index)
+	return true
+}
+
+// stop drops every pending task
+func (s *scheduler) stop() {
+	s.mu.Lock()
+	for _, timer := range s.timers {
+		timer.index = -1
+	}
+	s.timers = nil
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) loop() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		now := time.Now()
+		for len(s.timers) > 0 && !s.timers[0].at.After(now) {
+			due := heap.Pop(&s.timers).(*Timer)
+			go due.task()
+		}
+		if len(s.timers) == 0 {
+			// nothing pending, the next schedule starts a new loop
+			s.running = false
+			s.mu.Unlock()
+			return
+		}
+		next := s.timers[0].at
+		s.mu.Unlock()
+
+		timer.Reset(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-s.wake:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+	}
+}
+
+// EmitAfter emits the event to the room once delay has elapsed, unless the returned timer is canceled first
+func (socket *Server) EmitAfter(delay time.Duration, roomId, eventName string, payload Payload) *Timer {
+	return socket.EmitAt(time.Now().Add(delay), roomId, eventName, payload)
+}
+
+// EmitAt emits the event to the room at the given time, unless the returned timer is canceled first
+func (socket *Server) EmitAt(at time.Time, roomId, eventName string, payload Payload) *Timer {
+	return socket.scheduler.schedule(at, func() {
+		socket.EmitTo(roomId, eventName, payload)
+	})
+}
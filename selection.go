@@ -0,0 +1,169 @@
+package signal
+
+// RoomSelection targets the union of rooms, narrowed by intersections and differences, see Server.To.
+// A client belonging to several of the rooms receives each emit once.
+type RoomSelection struct {
+	server *Server
+	tenant string
+	rooms  []string
+	// and holds the rooms every recipient must also belong to
+	and []string
+	// except holds the rooms whose members are left out
+	except      []string
+	connections []string
+	local       bool
+}
+
+// selectionPacket carries a RoomSelection to the other nodes
+type selectionPacket struct {
+	Rooms       []string `json:"rooms"`
+	And         []string `json:"and,omitempty"`
+	Except      []string `json:"except,omitempty"`
+	Connections []string `json:"connections,omitempty"`
+}
+
+// To selects the members of the given rooms, like socket.io's io.to(...):
+//
+//	socket.To("game-7", "game-8").Except("spectators").Emit("round:start", payload)
+func (socket *Server) To(roomIds ...string) *RoomSelection {
+	return &RoomSelection{server: socket, rooms: roomIds}
+}
+
+// To selects the members of the given tenant rooms
+func (tenant *Tenant) To(roomIds ...string) *RoomSelection {
+	return &RoomSelection{server: tenant.server, tenant: tenant.Id, rooms: roomIds}
+}
+
+// To adds the members of more rooms to the selection
+func (selection *RoomSelection) To(roomIds ...string) *RoomSelection {
+	next := selection.clone()
+	next.rooms = append(next.rooms, roomIds...)
+	return next
+}
+
+// And keeps only the clients that are also members of every given room
+func (selection *RoomSelection) And(roomIds ...string) *RoomSelection {
+	next := selection.clone()
+	next.and = append(next.and, roomIds...)
+	return next
+}
+
+// Except leaves out the members of the given rooms
+func (selection *RoomSelection) Except(roomIds ...string) *RoomSelection {
+	next := selection.clone()
+	next.except = append(next.except, roomIds...)
+	return next
+}
+
+// ExceptClients leaves out the given connections, typically the sender
+func (selection *RoomSelection) ExceptClients(connectionIds ...string) *RoomSelection {
+	next := selection.clone()
+	next.connections = append(next.connections, connectionIds...)
+	return next
+}
+
+// Local restricts the selection emits to this node
+func (selection *RoomSelection) Local() *RoomSelection {
+	next := selection.clone()
+	next.local = true
+	return next
+}
+
+func (selection *RoomSelection) clone() *RoomSelection {
+	return &RoomSelection{
+		server:      selection.server,
+		tenant:      selection.tenant,
+		rooms:       append([]string(nil), selection.rooms...),
+		and:         append([]string(nil), selection.and...),
+		except:      append([]string(nil), selection.except...),
+		connections: append([]string(nil), selection.connections...),
+		local:       selection.local,
+	}
+}
+
+// Clients returns the selected clients connected to this node, each once
+func (selection *RoomSelection) Clients() []*Client {
+	excluded := make(map[string]bool, len(selection.connections))
+	for _, connectionId := range selection.connections {
+		excluded[connectionId] = true
+	}
+	for _, roomId := range selection.except {
+		for _, client := range selection.members(roomId) {
+			excluded[client.ConnectionId] = true
+		}
+	}
+
+	required := make([]map[string]bool, 0, len(selection.and))
+	for _, roomId := range selection.and {
+		members := make(map[string]bool)
+		for _, client := range selection.members(roomId) {
+			members[client.ConnectionId] = true
+		}
+		required = append(required, members)
+	}
+
+	var clients []*Client
+	seen := make(map[string]bool)
+	for _, roomId := range selection.rooms {
+	members:
+		for _, client := range selection.members(roomId) {
+			if seen[client.ConnectionId] || excluded[client.ConnectionId] {
+				continue
+			}
+			for _, members := range required {
+				if !members[client.ConnectionId] {
+					continue members
+				}
+			}
+			seen[client.ConnectionId] = true
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+func (selection *RoomSelection) members(roomId string) []*Client {
+	room := selection.server.lookupRoom(selection.tenant, roomId)
+	if room == nil {
+		return nil
+	}
+	return room.members()
+}
+
+// Emit sends the event to every selected client, on every node sharing the adapter unless the selection is Local
+func (selection *RoomSelection) Emit(eventName string, payload Payload) BroadcastResult {
+	for _, roomId := range selection.rooms {
+		if room := selection.server.lookupRoom(selection.tenant, roomId); room != nil {
+			room.touch()
+		}
+	}
+
+	result := selection.server.emitAll(selection.Clients(), eventName, payload)
+	if selection.local {
+		return result
+	}
+	selection.server.publishPacket(clusterPacket{
+		Tenant: selection.tenant,
+		Selection: &selectionPacket{
+			Rooms:       selection.rooms,
+			And:         selection.and,
+			Except:      selection.except,
+			Connections: selection.connections,
+		},
+		Message: Message{EventName: eventName, Payload: payload},
+	})
+	return result
+}
+
+// onSelectionPacket delivers a selection emit published by another node to the local clients
+func (socket *Server) onSelectionPacket(tenant string, packet *selectionPacket, message Message) {
+	selection := &RoomSelection{
+		server:      socket,
+		tenant:      tenant,
+		rooms:       packet.Rooms,
+		and:         packet.And,
+		except:      packet.Except,
+		connections: packet.Connections,
+	}
+	socket.emitAll(selection.Clients(), message.EventName, message.Payload)
+}
@@ -0,0 +1,26 @@
+package signal
+
+// Seq returns the sequence number of the last emit to the room on this node, see WithRoomSequences
+func (room *Room) Seq() uint64 {
+	return room.seq.Load()
+}
+
+// emitMembers emits to members of the room, stamping the message with the next room sequence number
+// when sequences are enabled
+func (room *Room) emitMembers(clients []*Client, eventName string, payload Payload) BroadcastResult {
+	if !room.server.roomSequences {
+		room.server.notifyWatchers(room.key, Message{EventName: eventName, Payload: payload, Room: room.Id})
+		return room.server.emitAll(clients, eventName, payload)
+	}
+	room.emitMu.Lock()
+	defer room.emitMu.Unlock()
+	message := Message{
+		EventName: eventName,
+		Payload:   payload,
+		Room:      room.Id,
+		Seq:       room.seq.Add(1),
+	}
+	room.logEmit(message)
+	room.server.notifyWatchers(room.key, message)
+	return room.server.emitAllMessage(clients, message)
+}
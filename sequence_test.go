@@ -0,0 +1,33 @@
+package signal
+
+import "testing"
+
+func TestRoomSequenceOutlivesRoom(t *testing.T) {
+	socket := IOServer("0", WithRoomSequences())
+	client := connectClients(t, socket, 1)[0]
+
+	room := socket.Room("game")
+	if err := room.Join(client); err != nil {
+		t.Fatal(err)
+	}
+	room.Emit("move", 1)
+	room.Emit("move", 2)
+	room.Leave(client.ConnectionId)
+
+	recreated := socket.Room("game")
+	if recreated == room {
+		t.Fatal("the emptied room was not dropped")
+	}
+	if err := recreated.Join(client); err != nil {
+		t.Fatal(err)
+	}
+	recreated.Emit("move", 3)
+	if seq := recreated.Seq(); seq != 3 {
+		t.Fatalf("sequence %d after the room was created again, want 3", seq)
+	}
+	// an emit through the dropped instance takes the next number of the room key
+	room.Emit("move", 4)
+	if seq := recreated.Seq(); seq != 4 {
+		t.Fatalf("sequence %d, want 4", seq)
+	}
+}
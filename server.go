@@ -13,239 +13,656 @@
 package signal
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// Define an upgrader to upgrade HTTP requests to WebSocket connections
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow all connections by default
-		return true
-	},
-}
-
-func (socket *signalIO) On(eventName string, callback Event) {
+func (socket *Server) On(eventName string, callback Event) {
 	if socket.listeners == nil {
 		socket.listeners = make(map[string]Event)
 	}
 	socket.listeners[eventName] = callback
 }
 
-func (socket *signalIO) init() {
-	socket.connections = make([]Client, 0)
-	socket.rooms = make(map[string][]Client)
+// OnAny registers a listener called for every event sent by clients, before the event's own listener
+func (socket *Server) OnAny(callback AnyEvent) {
+	socket.anyListeners = append(socket.anyListeners, callback)
+}
+
+func (socket *Server) init() {
+	socket.connections = make([]*Client, 0)
+	socket.rooms = newRoomRegistry(roomShards)
+	socket.bus = &Bus{}
+	socket.ctx, socket.cancel = context.WithCancel(context.Background())
 }
 
-func (socket *signalIO) Start() {
-	socket.init()
+func (socket *Server) Start() {
 	log.Println("SignalIO service has been started on port", socket.wsPort)
-	// Define the WebSocket route
-	http.HandleFunc("/", socket.handleConnections)
+	// Define the WebSocket route, plain HTTP requests to other paths are not found
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" && !websocket.IsWebSocketUpgrade(r) {
+			http.NotFound(w, r)
+			return
+		}
+		socket.handleConnections(w, r)
+	})
 
-	err := http.ListenAndServe(":"+socket.wsPort, nil)
+	listener, err := net.Listen("tcp", ":"+socket.wsPort)
 	if err != nil {
 		log.Fatal("ListenAndServe: ", err)
 	}
+	if err := socket.serve(listener, nil); err != nil {
+		log.Fatal("ListenAndServe: ", err)
+	}
 }
-func (socket *signalIO) GetTotalConnections() int {
-	return len(socket.connections)
+
+// Serve accepts websocket connections on listener until Shutdown. Unlike Start it neither binds
+// a port nor registers on http.DefaultServeMux, so several servers can run in the same process.
+func (socket *Server) Serve(listener net.Listener) error {
+	return socket.serve(listener, socket)
 }
-func createClient(ws *websocket.Conn, r *http.Request) (Client, error) {
-	r.URL.Query()
-	client := Client{
-		ConnectionId: CreateConnectionId(),
-		Socket:       ws,
-		HTTPRequest:  r,
+
+// serve connects the adapter and the blocklist store then serves handler, nil meaning http.DefaultServeMux
+func (socket *Server) serve(listener net.Listener, handler http.Handler) error {
+	if socket.adapter != nil {
+		if err := socket.adapter.Subscribe(socket.onClusterPacket); err != nil {
+			return fmt.Errorf("adapter subscribe: %w", err)
+		}
+	}
+	if err := socket.blocklist.Watch(socket.onBlocklistChange); err != nil {
+		return fmt.Errorf("blocklist watch: %w", err)
+	}
+	if err := socket.startPlugins(); err != nil {
+		return err
+	}
+	socket.startCluster()
+	socket.startDebugServer()
+	if socket.statsInterval > 0 {
+		socket.Every(socket.statsInterval, func(s *Server) { s.summarizeStats() })
 	}
-	queryParams := r.URL.Query()
 
-	client.Auth = queryParams.Get("auth")
+	socket.listening.Store(true)
+	defer socket.listening.Store(false)
 
-	queryData := queryParams.Get("queryData")
-	query, err := DecodeQueryData(queryData)
-	if err != nil {
-		return client, err
+	httpServer := &http.Server{Handler: handler}
+	socket.setHTTPServer(httpServer)
+	err := httpServer.Serve(listener)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
-	client.Query = query
+	return nil
+}
+func (socket *Server) GetTotalConnections() int {
+	return len(socket.snapshot())
+}
 
-	return client, nil
+// Clients returns a snapshot of the connected clients
+func (socket *Server) Clients() []*Client {
+	connections := socket.snapshot()
+	clients := make([]*Client, len(connections))
+	copy(clients, connections)
+	return clients
 }
 
-func (socket *signalIO) cleanup(connectionId string) {
-	var wg sync.WaitGroup
-	for roomId, clients := range socket.rooms {
-		wg.Add(1)
-		go func(roomId, connectionId string, clients []Client) {
-			defer wg.Done()
+// snapshot returns the current connection list. The list is copied on write,
+// so it can be read without holding the lock but must never be modified.
+func (socket *Server) snapshot() []*Client {
+	socket.mu.RLock()
+	defer socket.mu.RUnlock()
+	return socket.connections
+}
 
-			position := IndexOf(connectionId, clients)
-			if position == -1 {
-				return
-			}
+// Client returns the connected client with the given connectionId
+func (socket *Server) Client(connectionId string) (*Client, bool) {
+	connections := socket.snapshot()
+	if index := IndexOf(connectionId, connections); index != -1 {
+		return connections[index], true
+	}
+	return nil, false
+}
 
-			// Lock the mutex before modifying the map
-			socket.mu.Lock()
-			defer socket.mu.Unlock()
+// Disconnect closes the connection with the given connectionId, it reports whether the connection was found
+func (socket *Server) Disconnect(connectionId string) bool {
+	client, exists := socket.Client(connectionId)
+	if !exists {
+		return false
+	}
+	client.Disconnect()
+	return true
+}
+func (socket *Server) createConnectionId(r *http.Request) string {
+	if socket.idGenerator != nil {
+		if connectionId := socket.idGenerator(r); connectionId != "" {
+			return connectionId
+		}
+	}
+	return CreateConnectionId()
+}
 
-			// Remove the client from the slice by index
-			clients = append(clients[:position], clients[position+1:]...)
+func (socket *Server) createClient(r *http.Request) (*Client, error) {
+	client := &Client{
+		ConnectionId: socket.createConnectionId(r),
+		HTTPRequest:  r,
+		state:        newClientState(socket),
+	}
+	client.state.ip = socket.clientIP(r)
+	client.Auth = socket.extractCredentials(r)
 
-			// If the room is now empty, delete the room
-			if len(clients) == 0 {
-				delete(socket.rooms, roomId)
-			} else {
-				// Otherwise, update the room's clients
-				socket.rooms[roomId] = clients
-			}
+	queryData := r.URL.Query().Get("queryData")
+	query, err := DecodeQueryData(queryData)
+	if err != nil {
+		return client, err
+	}
+	client.Query = query
 
-		}(roomId, connectionId, clients)
+	return client, nil
+}
+
+func (socket *Server) cleanup(connectionId string) {
+	for _, room := range socket.roomList() {
+		room.Leave(connectionId)
 	}
-	wg.Wait()
 }
 
-func (socket *signalIO) removeConnection(connectionId string) {
-	count := len(socket.connections)
-	for index, client := range socket.connections {
-		if client.ConnectionId == connectionId {
-			// remove connection
-			socket.connections[index] = socket.connections[count-1]
-			socket.connections = socket.connections[:count-1]
-			// disconnect user from rooms
-			socket.cleanup(connectionId)
-			return
-		}
+func (socket *Server) removeConnection(connectionId string) {
+	socket.mu.Lock()
+	index := IndexOf(connectionId, socket.connections)
+	if index != -1 {
+		// remove connection, into a new list since snapshots may still be read
+		connections := make([]*Client, 0, len(socket.connections)-1)
+		connections = append(connections, socket.connections[:index]...)
+		socket.connections = append(connections, socket.connections[index+1:]...)
+	}
+	socket.mu.Unlock()
+
+	if index != -1 {
+		// disconnect user from rooms
+		socket.cleanup(connectionId)
+		socket.registerConnection(connectionId, false)
 	}
 }
 
-func (socket *signalIO) onConnect(client Client) {
-	socket.connections = append(socket.connections, client)
+func (socket *Server) onConnect(client *Client) {
+	socket.mu.Lock()
+	connections := make([]*Client, len(socket.connections), len(socket.connections)+1)
+	copy(connections, socket.connections)
+	socket.connections = append(connections, client)
+	socket.mu.Unlock()
+	client.setState(StateOpen)
+	socket.registerConnection(client.ConnectionId, true)
+	socket.audit(AuditConnect, client, "", nil)
+
 	onConnect := socket.listeners["connect"]
 	if onConnect != nil {
 		onConnect(nil, client)
 	}
 }
 
-func (socket *signalIO) onDisconnect(client Client) {
+func (socket *Server) onDisconnect(client *Client, reason *DisconnectReason) {
 	socket.removeConnection(client.ConnectionId)
+	client.setState(StateClosed)
+	socket.detachSession(client)
+	socket.audit(AuditDisconnect, client, "", reason)
 	onDisconnect := socket.listeners["disconnect"]
 	if onDisconnect != nil {
-		onDisconnect(nil, client)
+		onDisconnect(reason, client)
 	}
 }
 
-func (socket *signalIO) onError(client Client, err error) {
+func (socket *Server) onError(client *Client, err error) {
 	socket.removeConnection(client.ConnectionId)
+	socket.reportError(client, err)
+}
+
+// reportError calls the error listener without ending the connection
+func (socket *Server) reportError(client *Client, err error) {
+	socket.metrics.errors.Add(1)
 	onError := socket.listeners["error"]
 	if onError != nil {
 		onError(err, client)
 	}
 }
 
-func (socket *signalIO) handleConnections(w http.ResponseWriter, r *http.Request) {
-	// Upgrade the HTTP connection to a WebSocket connection
-	ws, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Fatal(err)
+// ServeHTTP upgrades the request to a websocket connection, to serve the server from your own
+// http.Server or an in-memory transport. Start also connects the adapter and the blocklist store.
+func (socket *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	socket.handleConnections(w, r)
+}
+
+func (socket *Server) handleConnections(w http.ResponseWriter, r *http.Request) {
+	ctx := socket.extractTrace(r.Context(), r.Header.Get("traceparent"))
+	ctx, span := socket.startSpan(ctx, "signal.handshake", map[string]any{
+		"net.peer.addr": r.RemoteAddr,
+	})
+
+	if socket.Draining() {
+		span.End(ErrDraining)
+		rejectHandshake(w, http.StatusServiceUnavailable, ErrDraining)
+		return
 	}
-	defer ws.Close()
 
-	client, err := createClient(ws, r)
+	client, err := socket.createClient(r)
 	if err != nil {
+		span.End(err)
+		rejectHandshake(w, http.StatusBadRequest, err)
 		socket.onError(client, err)
 		return
 	}
+	client.setContext(ctx)
+	defer client.setState(StateClosed)
+
+	if err := socket.checkBlocklist(client); err != nil {
+		span.End(err)
+		socket.audit(AuditRejected, client, "", err)
+		rejectBlocked(w)
+		return
+	}
+
+	if err := socket.runMiddlewares(client); err != nil {
+		span.End(err)
+		socket.audit(AuditRejected, client, "", err)
+		rejectHandshake(w, http.StatusUnauthorized, err)
+		return
+	}
+	socket.resolveUser(client)
+
+	if socket.quota.enabled() {
+		ip, userId := client.IP(), client.UserId()
+		if err := socket.quota.reserve(ip, userId); err != nil {
+			span.End(err)
+			socket.audit(AuditRejected, client, "", err)
+			socket.rejectOverQuota(w, r, err)
+			return
+		}
+		defer socket.quota.release(ip, userId)
+	}
+
+	if subprotocol := socket.selectSubprotocol(r); subprotocol != "" {
+		client.state.mu.Lock()
+		client.state.subprotocol = subprotocol
+		client.state.mu.Unlock()
+	}
+	if err := socket.negotiateCodec(client, r); err != nil {
+		span.End(err)
+		socket.audit(AuditRejected, client, "", err)
+		rejectHandshake(w, http.StatusBadRequest, err)
+		return
+	}
+
+	responseHeader := http.Header{}
+	if err := socket.negotiateVersion(client, r, responseHeader); err != nil {
+		span.End(err)
+		socket.audit(AuditRejected, client, "", err)
+		rejectHandshake(w, http.StatusUpgradeRequired, err)
+		return
+	}
+
+	socket.setAffinity(responseHeader)
+	for _, hook := range socket.upgradeHeaders {
+		hook(r, client, responseHeader)
+	}
+
+	// Upgrade the HTTP connection to a WebSocket connection
+	ws, err := socket.upgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		span.End(err)
+		socket.upgradeFailed(r, client, err)
+		return
+	}
+	defer ws.Close()
+	client.Socket = ws
 
+	if !socket.connectPlugins(client) {
+		span.End(nil)
+		return
+	}
+	handoff := socket.claimHandoff(client)
+	socket.attachSession(client)
 	socket.onConnect(client)
+	socket.resumeHandoff(client, handoff)
+	socket.flushOffline(client)
+	socket.sendBadge(client)
+	span.End(nil)
 
 	for {
 		// Read a message from the client
 		_, message, err := ws.ReadMessage()
 		if err != nil {
-			socket.onDisconnect(client)
+			socket.onDisconnect(client, client.disconnectReason(err))
 			break
 		}
 
 		var msg Message
 
-		err = json.Unmarshal(message, &msg)
+		err = client.codec().Unmarshal(message, &msg)
 		if err != nil {
-			socket.onError(client, err)
-			break
+			// a bad frame is the sender's problem, keep the connection and wait for the next one
+			if socket.replyDecodeErrors {
+				client.Emit(ErrorEvent, NewError(CodeInvalidPayload, "malformed message: "+err.Error()))
+			}
+			socket.reportError(client, fmt.Errorf("error decoding message: %w", err))
+			continue
 		}
 
-		socket.processMessage(msg, client)
+		if err := socket.verifyMessage(client, msg, message); err != nil {
+			client.emitErrorFor(msg, CodeInvalidSignature, err.Error())
+			socket.reportError(client, fmt.Errorf("error verifying message: %w", err))
+			continue
+		}
+
+		socket.traceMessage(msg, len(message), client)
 	}
 }
 
-func (socket *signalIO) processMessage(message Message, client Client) {
+// traceMessage processes the message inside a span continuing the trace it carries
+func (socket *Server) traceMessage(message Message, size int, client *Client) {
+	ctx := socket.extractTrace(context.Background(), message.TraceParent)
+	ctx, span := socket.startSpan(ctx, "signal.event "+message.EventName, map[string]any{
+		"signal.event":         message.EventName,
+		"signal.payload_size":  size,
+		"signal.connection_id": client.ConnectionId,
+	})
+	defer span.End(nil)
+
+	socket.countReceived(message.EventName, size, client)
+	client.setContext(context.WithValue(ctx, inboundKey{}, message))
+	socket.processMessage(message, client)
+}
+
+func (socket *Server) processMessage(message Message, client *Client) {
+	if err := socket.translateInbound(client, &message); err != nil {
+		client.emitErrorFor(message, CodeInvalidPayload, err.Error())
+		return
+	}
+	if isPseudoEvent(message.EventName) {
+		// the listeners of pseudo-events are called by the server, clients cannot trigger them
+		client.emitErrorFor(message, CodeInvalidPayload, "reserved event "+message.EventName)
+		return
+	}
+	if message.EventName == AckEvent && socket.delivery != nil {
+		socket.acknowledge(client, message.Payload)
+		return
+	}
+	if socket.duplicateMessage(message, client) {
+		return
+	}
+	if message.EventName == ResumeEvent && socket.eventLog != nil {
+		socket.resume(message, client)
+		return
+	}
+	if !socket.messagePlugins(client, &message) {
+		return
+	}
+	if !socket.authorize(message.EventName, client) {
+		client.emitErrorFor(message, CodeUnauthorized, "not allowed to send "+message.EventName)
+		return
+	}
+	if !socket.validate(message, client) {
+		return
+	}
+	for _, listener := range socket.anyListeners {
+		listener(message.EventName, message.Payload, client)
+	}
 	if event, exists := socket.listeners[message.EventName]; exists {
-		event(message.Payload, client)
+		start := time.Now()
+		socket.callHandler(event, message, client)
+		socket.countHandled(message.EventName, time.Since(start))
 	}
 }
 
 func (client *Client) Emit(eventName string, payload Payload) error {
-	// Create the message struct with the event name and payload
-	msg := Message{
-		EventName: eventName,
-		Payload:   payload,
+	return client.emit(eventName, payload, client.writeTimeout())
+}
+
+// EmitWithTimeout is Emit failing with ErrWriteTimeout when the message cannot be written within timeout.
+// A timed out write leaves the websocket unusable, the connection should be considered lost.
+func (client *Client) EmitWithTimeout(eventName string, payload Payload, timeout time.Duration) error {
+	return client.emit(eventName, payload, timeout)
+}
+
+func (client *Client) emit(eventName string, payload Payload, timeout time.Duration) error {
+	message := Message{EventName: eventName, Payload: payload}
+	err := client.emitMessage(message, timeout)
+	if err != nil {
+		client.server().deadLetter(DeadLetter{
+			Message:      message,
+			ConnectionId: client.ConnectionId,
+			UserId:       client.UserId(),
+			Reason:       err,
+		})
+	}
+	return err
+}
+
+func (client *Client) emitMessage(msg Message, timeout time.Duration) error {
+	eventName, payload := msg.EventName, msg.Payload
+	ctx, span := client.server().startSpan(client.Context(), "signal.emit "+eventName, map[string]any{
+		"signal.event":         eventName,
+		"signal.connection_id": client.ConnectionId,
+	})
+
+	msg.TraceParent = client.server().injectTrace(ctx)
+	msg, err := client.transform(msg)
+	if errors.Is(err, ErrSkipEmit) {
+		span.End(nil)
+		return nil
+	}
+	if err != nil {
+		span.End(err)
+		return err
+	}
+	if client.coalesces() {
+		// counted once written, see flush
+		client.enqueue(msg)
+		span.End(nil)
+		return nil
 	}
 
-	// Marshal the message to JSON
-	messageJSON, err := json.Marshal(msg)
+	// Encode the message into a pooled buffer
+	buf, err := encodeMessage(client.codec(), msg)
 	if err != nil {
 		log.Printf("Marshal error: %v", err)
+		span.End(err)
 		return err
 	}
+	defer putBuffer(buf)
 
 	// Send the message to the client
-	err = client.Socket.WriteMessage(websocket.TextMessage, messageJSON)
+	err = client.writeWithTimeout(buf.Bytes(), timeout)
+	if err == nil {
+		client.server().countSent(eventName, 1, 0)
+		client.server().emitPlugins(eventName, payload, 1)
+	} else {
+		client.server().countSent(eventName, 0, 1)
+	}
+	span.End(err)
+	return err
+}
+
+// Disconnect sends a close frame and closes the connection, the disconnect listener is called once the read loop stops
+// with a DisconnectKicked reason
+func (client *Client) Disconnect() error {
+	return client.closeWith(DisconnectKicked, websocket.CloseNormalClosure, nil)
+}
+
+func (client *Client) write(data []byte) error {
+	return client.writeWithTimeout(data, client.writeTimeout())
+}
+
+// writeOnce serializes writers on the connection, a zero timeout waits forever
+func (client *Client) writeOnce(data []byte, timeout time.Duration) error {
+	if skip, err := client.injectFault(); skip {
+		return err
+	}
+	if server := client.server(); server != nil {
+		server.writesInFlight.Add(1)
+		defer server.writesInFlight.Add(-1)
+	}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if !client.lockWrite(deadline) {
+		log.Printf("WriteMessage error: %v", ErrWriteTimeout)
+		client.bus().Emit(InternalEvent{Name: InternalSlowConsumer, Client: client, Err: ErrWriteTimeout})
+		return ErrWriteTimeout
+	}
+	defer client.unlockWrite()
+
+	client.Socket.SetWriteDeadline(deadline)
+	err := client.Socket.WriteMessage(FrameType(client.codec()), data)
 	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			err = fmt.Errorf("%w: %v", ErrWriteTimeout, err)
+			client.bus().Emit(InternalEvent{Name: InternalSlowConsumer, Client: client, Err: err})
+		}
 		log.Printf("WriteMessage error: %v", err)
+		client.closeWith(DisconnectWriteError, websocket.CloseAbnormalClosure, err)
 		return err
 	}
-
 	return nil
 }
 
-func (socket *signalIO) Broadcast(eventName string, payload Payload) {
-	for _, client := range socket.connections {
-		client.Emit(eventName, payload)
+// emitAll writes the event to every client, encoding it once unless outbound transformers are configured
+func (socket *Server) emitAll(clients []*Client, eventName string, payload Payload) BroadcastResult {
+	return socket.emitAllMessage(clients, Message{EventName: eventName, Payload: payload})
+}
+
+// emitAllMessage is emitAll for a message carrying more than its event name and payload
+func (socket *Server) emitAllMessage(clients []*Client, msg Message) BroadcastResult {
+	eventName, payload := msg.EventName, msg.Payload
+	var result BroadcastResult
+	ctx, span := socket.startSpan(context.Background(), "signal.emit "+eventName, map[string]any{
+		"signal.event":      eventName,
+		"signal.recipients": len(clients),
+	})
+	defer func() { span.End(result.Err()) }()
+
+	msg.TraceParent = socket.injectTrace(ctx)
+	if socket.coalesceWindow > 0 {
+		socket.emitCoalesced(clients, msg, &result)
+	} else if socket.encodesPerClient() {
+		socket.emitEach(clients, msg, &result)
+	} else {
+		socket.emitShared(clients, msg, &result)
+	}
+	if socket.coalesceWindow > 0 {
+		// the coalesced messages are counted once written, see flush
+		socket.countSent(eventName, 0, result.Failed())
+	} else {
+		socket.countSent(eventName, result.Delivered, result.Failed())
+		socket.emitPlugins(eventName, payload, result.Delivered)
 	}
+	socket.deadLetterFailures(clients, msg, result)
+	return result
 }
 
-func (socket *signalIO) JoinRoom(roomId string, client Client) {
-	if socket.rooms[roomId] == nil {
-		socket.rooms[roomId] = []Client{client}
-		return
+// emitShared encodes the message once per codec in use and writes the same frame to every client of that codec
+func (socket *Server) emitShared(clients []*Client, message Message, result *BroadcastResult) {
+	type frame struct {
+		buf *bytes.Buffer
+		err error
+	}
+	frames := make(map[string]frame, 1)
+	defer func() {
+		for _, frame := range frames {
+			if frame.buf != nil {
+				putBuffer(frame.buf)
+			}
+		}
+	}()
+
+	for _, client := range clients {
+		name := client.CodecName()
+		encoded, exists := frames[name]
+		if !exists {
+			encoded.buf, encoded.err = encodeMessage(client.codec(), message)
+			if encoded.err != nil {
+				log.Printf("Marshal error: %v", encoded.err)
+			}
+			frames[name] = encoded
+		}
+		if encoded.err != nil {
+			result.fail(client.ConnectionId, encoded.err)
+			continue
+		}
+		if err := client.write(encoded.buf.Bytes()); err != nil {
+			result.fail(client.ConnectionId, err)
+			continue
+		}
+		result.Delivered++
 	}
+}
 
-	if IndexOf(client.ConnectionId, socket.rooms[roomId]) != -1 {
-		return
+// Broadcast sends the event to every connected client and reports the delivery on this node
+func (socket *Server) Broadcast(eventName string, payload Payload) BroadcastResult {
+	result := socket.emitAll(socket.snapshot(), eventName, payload)
+	socket.publish("", "", nil, eventName, payload)
+	return result
+}
+
+// BroadcastWhere sends the event to the clients for which match returns true, for audiences not worth a room
+// such as clients of a given app version. Predicates cannot travel to other nodes, only the clients of this
+// node are considered.
+func (socket *Server) BroadcastWhere(match func(client *Client) bool, eventName string, payload Payload) BroadcastResult {
+	return socket.emitAll(filterClients(socket.snapshot(), match), eventName, payload)
+}
+
+// filterClients returns the clients matching the predicate, in a new slice
+func filterClients(clients []*Client, match func(client *Client) bool) []*Client {
+	var filtered []*Client
+	for _, client := range clients {
+		if match(client) {
+			filtered = append(filtered, client)
+		}
 	}
+	return filtered
+}
+
+func (socket *Server) JoinRoom(roomId string, client *Client) error {
+	return socket.tenantRoom(client.Tenant(), roomId).Join(client)
+}
 
-	socket.rooms[roomId] = append(socket.rooms[roomId], client)
+func (socket *Server) LeaveRoom(roomId string, client *Client) {
+	if room := socket.lookupRoom(client.Tenant(), roomId); room != nil {
+		room.Leave(client.ConnectionId)
+	}
 }
 
-func (socket *signalIO) EmitTo(roomId, eventName string, payload Payload) {
-	room := socket.rooms[roomId]
+func (socket *Server) EmitTo(roomId, eventName string, payload Payload) BroadcastResult {
+	room := socket.lookupRoom("", roomId)
 
 	if room == nil {
-		return
+		// no local member, the room may still have members on other nodes
+		socket.publish("", roomId, nil, eventName, payload)
+		return BroadcastResult{}
 	}
 
-	for _, client := range room {
-		client.Emit(eventName, payload)
-	}
+	return room.Emit(eventName, payload)
 }
 
-func IOServer(WS_PORT string) *signalIO {
-	server := signalIO{
-		wsPort: WS_PORT,
+func IOServer(WS_PORT string, options ...Option) *Server {
+	server := Server{
+		wsPort:    WS_PORT,
+		codec:     JSONCodec{},
+		nodeId:    CreateConnectionId(),
+		scheduler: newScheduler(),
+		blocklist: NewMemoryBlocklist(),
+
+		replyDecodeErrors: true,
+	}
+	for _, option := range options {
+		option(&server)
 	}
+	server.init()
 	return &server
 }
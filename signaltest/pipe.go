@@ -0,0 +1,67 @@
+package signaltest
+
+import (
+	"net"
+	"sync"
+)
+
+// pipeListener is a net.Listener handing out the server ends of in-memory pipes
+type pipeListener struct {
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// open holds the pipes handed out, hijacked websocket connections are not closed by http.Server
+	mu   sync.Mutex
+	open []net.Conn
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (listener *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-listener.conns:
+		return conn, nil
+	case <-listener.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (listener *pipeListener) Close() error {
+	listener.closeOnce.Do(func() { close(listener.done) })
+
+	listener.mu.Lock()
+	defer listener.mu.Unlock()
+	for _, conn := range listener.open {
+		conn.Close()
+	}
+	listener.open = nil
+	return nil
+}
+
+func (listener *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+// dial returns the client end of a new pipe whose server end is accepted by the listener
+func (listener *pipeListener) dial() (net.Conn, error) {
+	client, server := net.Pipe()
+	select {
+	case listener.conns <- server:
+		listener.mu.Lock()
+		listener.open = append(listener.open, client, server)
+		listener.mu.Unlock()
+		return client, nil
+	case <-listener.done:
+		client.Close()
+		server.Close()
+		return nil, net.ErrClosed
+	}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "signaltest" }
@@ -0,0 +1,307 @@
+// Package signaltest runs a signal.io server over an in-memory transport, so handlers can be
+// unit-tested without binding ports:
+//
+//	socket := signal.IOServer("")
+//	socket.On("join", func(payload signal.Payload, client *signal.Client) {
+//		socket.JoinRoom(payload.(string), client)
+//		client.Emit("joined", payload)
+//	})
+//
+//	server := signaltest.New(socket)
+//	defer server.Close()
+//
+//	client := server.MustConnect(t)
+//	client.Emit("join", "lobby")
+//	client.MustExpect(t, "joined", time.Second)
+//	signaltest.AssertInRoom(t, client, "lobby")
+package signaltest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+	"github.com/gorilla/websocket"
+)
+
+// ErrTimeout is returned when an expected event does not arrive in time
+var ErrTimeout = errors.New("signaltest: timeout waiting for event")
+
+// connectHeader identifies a test connection among the server clients
+const connectHeader = "X-Signaltest-Id"
+
+// Server serves a signal.io server over in-memory connections
+type Server struct {
+	*signal.Server
+
+	listener   *pipeListener
+	httpServer *http.Server
+	nextId     atomic.Int64
+}
+
+// New starts serving socket in memory, Start is not needed
+func New(socket *signal.Server) *Server {
+	server := &Server{
+		Server:     socket,
+		listener:   newPipeListener(),
+		httpServer: &http.Server{Handler: socket},
+	}
+	go server.httpServer.Serve(server.listener)
+	return server
+}
+
+// Close stops the transport, closing every test connection
+func (server *Server) Close() error {
+	return server.httpServer.Close()
+}
+
+// ConnectOption customizes the handshake of a test connection
+type ConnectOption func(*connectConfig)
+
+type connectConfig struct {
+	query        url.Values
+	header       http.Header
+	codec        signal.Codec
+	subprotocols []string
+	timeout      time.Duration
+}
+
+// WithAuth sends token as the auth query parameter
+func WithAuth(token string) ConnectOption {
+	return func(config *connectConfig) {
+		config.query.Set("auth", token)
+	}
+}
+
+// WithQuery sends a query parameter with the handshake
+func WithQuery(key, value string) ConnectOption {
+	return func(config *connectConfig) {
+		config.query.Set(key, value)
+	}
+}
+
+// WithHeader sends a header with the handshake
+func WithHeader(key, value string) ConnectOption {
+	return func(config *connectConfig) {
+		config.header.Set(key, value)
+	}
+}
+
+// WithCodec decodes the messages with the codec configured on the server, JSON by default
+func WithCodec(codec signal.Codec) ConnectOption {
+	return func(config *connectConfig) {
+		config.codec = codec
+	}
+}
+
+// WithSubprotocols requests WebSocket subprotocols in the handshake, in order of preference
+func WithSubprotocols(subprotocols ...string) ConnectOption {
+	return func(config *connectConfig) {
+		config.subprotocols = append(config.subprotocols, subprotocols...)
+	}
+}
+
+// WithConnectTimeout bounds how long Connect waits for the server to register the connection, 5s by default
+func WithConnectTimeout(timeout time.Duration) ConnectOption {
+	return func(config *connectConfig) {
+		config.timeout = timeout
+	}
+}
+
+// Connect opens a test connection and waits until the server registered it
+func (server *Server) Connect(options ...ConnectOption) (*TestClient, error) {
+	config := &connectConfig{
+		query:   url.Values{},
+		header:  http.Header{},
+		codec:   signal.JSONCodec{},
+		timeout: 5 * time.Second,
+	}
+	for _, option := range options {
+		option(config)
+	}
+	id := strconv.FormatInt(server.nextId.Add(1), 10)
+	config.header.Set(connectHeader, id)
+
+	dialer := websocket.Dialer{
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return server.listener.dial()
+		},
+		HandshakeTimeout: config.timeout,
+		Subprotocols:     config.subprotocols,
+	}
+	target := url.URL{Scheme: "ws", Host: "signaltest", Path: "/", RawQuery: config.query.Encode()}
+	conn, response, err := dialer.Dial(target.String(), config.header)
+	if err != nil {
+		if response != nil {
+			return nil, fmt.Errorf("signaltest: handshake refused with %s: %w", response.Status, err)
+		}
+		return nil, err
+	}
+
+	client := &TestClient{conn: conn, codec: config.codec, arrived: make(chan struct{})}
+	go client.read()
+
+	deadline := time.Now().Add(config.timeout)
+	for {
+		for _, registered := range server.Clients() {
+			if registered.HTTPRequest != nil && registered.HTTPRequest.Header.Get(connectHeader) == id {
+				client.Client = registered
+				return client, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			conn.Close()
+			return nil, errors.New("signaltest: connection was not registered by the server")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// MustConnect is Connect failing the test on error
+func (server *Server) MustConnect(t testing.TB, options ...ConnectOption) *TestClient {
+	t.Helper()
+	client, err := server.Connect(options...)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return client
+}
+
+// TestClient is the client end of a test connection
+type TestClient struct {
+	// Client is the server side of the connection
+	Client *signal.Client
+
+	conn  *websocket.Conn
+	codec signal.Codec
+
+	mu       sync.Mutex
+	received []signal.Message
+	arrived  chan struct{}
+	err      error
+}
+
+func (client *TestClient) read() {
+	for {
+		_, data, err := client.conn.ReadMessage()
+		if err != nil {
+			client.mu.Lock()
+			client.err = err
+			close(client.arrived)
+			client.mu.Unlock()
+			return
+		}
+		var message signal.Message
+		if err := client.codec.Unmarshal(data, &message); err != nil {
+			continue
+		}
+		messages, err := signal.Unbatch(message)
+		if err != nil {
+			continue
+		}
+		client.mu.Lock()
+		client.received = append(client.received, messages...)
+		close(client.arrived)
+		client.arrived = make(chan struct{})
+		client.mu.Unlock()
+	}
+}
+
+// Emit sends an event to the server
+func (client *TestClient) Emit(eventName string, payload signal.Payload) error {
+	data, err := client.codec.Marshal(signal.Message{EventName: eventName, Payload: payload})
+	if err != nil {
+		return err
+	}
+	return client.conn.WriteMessage(signal.FrameType(client.codec), data)
+}
+
+// Expect waits for the next eventName event and returns it. Other events received meanwhile are kept for later calls.
+func (client *TestClient) Expect(eventName string, timeout time.Duration) (signal.Message, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		client.mu.Lock()
+		for i, message := range client.received {
+			if message.EventName == eventName {
+				client.received = append(client.received[:i], client.received[i+1:]...)
+				client.mu.Unlock()
+				return message, nil
+			}
+		}
+		arrived, err := client.arrived, client.err
+		client.mu.Unlock()
+		if err != nil {
+			return signal.Message{}, fmt.Errorf("signaltest: connection closed waiting for %s: %w", eventName, err)
+		}
+
+		select {
+		case <-arrived:
+		case <-timer.C:
+			return signal.Message{}, fmt.Errorf("%w %s", ErrTimeout, eventName)
+		}
+	}
+}
+
+// MustExpect is Expect failing the test on error, it returns the payload of the event
+func (client *TestClient) MustExpect(t testing.TB, eventName string, timeout time.Duration) signal.Payload {
+	t.Helper()
+	message, err := client.Expect(eventName, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return message.Payload
+}
+
+// ExpectNone fails when an eventName event arrives within wait
+func (client *TestClient) ExpectNone(eventName string, wait time.Duration) error {
+	if _, err := client.Expect(eventName, wait); err == nil {
+		return fmt.Errorf("signaltest: unexpected %s event", eventName)
+	}
+	return nil
+}
+
+// Close closes the connection, the server calls its disconnect listener
+func (client *TestClient) Close() error {
+	return client.conn.Close()
+}
+
+// AssertInRoom fails the test unless the client is a member of every room
+func AssertInRoom(t testing.TB, client *TestClient, roomIds ...string) {
+	t.Helper()
+	rooms := client.Client.Rooms()
+	for _, roomId := range roomIds {
+		if !contains(rooms, roomId) {
+			t.Errorf("connection %s is not in room %q, its rooms are %v", client.Client.ConnectionId, roomId, rooms)
+		}
+	}
+}
+
+// AssertNotInRoom fails the test when the client is a member of any of the rooms
+func AssertNotInRoom(t testing.TB, client *TestClient, roomIds ...string) {
+	t.Helper()
+	rooms := client.Client.Rooms()
+	for _, roomId := range roomIds {
+		if contains(rooms, roomId) {
+			t.Errorf("connection %s is in room %q", client.Client.ConnectionId, roomId)
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package signaltest_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+	"github.com/Syntax0xError/signal.io-golang/signaltest"
+)
+
+const wait = 2 * time.Second
+
+func TestEmit(t *testing.T) {
+	socket := signal.IOServer("")
+	socket.On("echo", func(payload signal.Payload, client *signal.Client) {
+		client.Emit("echoed", payload)
+	})
+	server := signaltest.New(socket)
+	defer server.Close()
+
+	client := server.MustConnect(t)
+	if err := client.Emit("echo", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if payload := client.MustExpect(t, "echoed", wait); payload != "hello" {
+		t.Fatalf("echoed %v, want hello", payload)
+	}
+}
+
+func TestRooms(t *testing.T) {
+	socket := signal.IOServer("")
+	socket.On("join", func(payload signal.Payload, client *signal.Client) {
+		socket.JoinRoom(payload.(string), client)
+		client.Emit("joined", payload)
+	})
+	socket.On("leave", func(payload signal.Payload, client *signal.Client) {
+		socket.LeaveRoom(payload.(string), client)
+		client.Emit("left", payload)
+	})
+	socket.On("say", func(payload signal.Payload, client *signal.Client) {
+		socket.Room("lobby").Except(client.ConnectionId).Emit("said", payload)
+	})
+	server := signaltest.New(socket)
+	defer server.Close()
+
+	alice, bob, carol := server.MustConnect(t), server.MustConnect(t), server.MustConnect(t)
+	for _, client := range []*signaltest.TestClient{alice, bob} {
+		client.Emit("join", "lobby")
+		client.MustExpect(t, "joined", wait)
+		signaltest.AssertInRoom(t, client, "lobby")
+	}
+	signaltest.AssertNotInRoom(t, carol, "lobby")
+
+	alice.Emit("say", "hi")
+	if payload := bob.MustExpect(t, "said", wait); payload != "hi" {
+		t.Fatalf("bob got %v, want hi", payload)
+	}
+	if err := alice.ExpectNone("said", 100*time.Millisecond); err != nil {
+		t.Error("the sender is left out:", err)
+	}
+	if err := carol.ExpectNone("said", 100*time.Millisecond); err != nil {
+		t.Error("non-members are left out:", err)
+	}
+
+	bob.Emit("leave", "lobby")
+	bob.MustExpect(t, "left", wait)
+	signaltest.AssertNotInRoom(t, bob, "lobby")
+	alice.Emit("say", "bye")
+	if err := bob.ExpectNone("said", 100*time.Millisecond); err != nil {
+		t.Error("members who left are left out:", err)
+	}
+}
+
+func TestRedeliveryUntilAcknowledged(t *testing.T) {
+	const redeliverAfter = 50 * time.Millisecond
+	socket := signal.IOServer("", signal.WithAtLeastOnce(signal.AtLeastOnce{RedeliverAfter: redeliverAfter}))
+	socket.On("subscribe", func(payload signal.Payload, client *signal.Client) {
+		client.EmitReliable("news", "extra")
+	})
+	server := signaltest.New(socket)
+	defer server.Close()
+
+	client := server.MustConnect(t)
+	client.Emit("subscribe", nil)
+	first, err := client.Expect("news", wait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Id == "" {
+		t.Fatal("reliable messages carry an id")
+	}
+	again, err := client.Expect("news", wait)
+	if err != nil {
+		t.Fatal("unacknowledged messages are redelivered:", err)
+	}
+	if again.Id != first.Id {
+		t.Fatalf("redelivered id %s, want %s", again.Id, first.Id)
+	}
+
+	client.Emit(signal.AckEvent, first.Id)
+	// a redelivery may have been on its way before the ack was processed
+	time.Sleep(2 * redeliverAfter)
+	drain(client, "news")
+	if err := client.ExpectNone("news", 4*redeliverAfter); err != nil {
+		t.Error("acknowledged messages are not redelivered:", err)
+	}
+}
+
+// queryUser identifies clients by their user query parameter
+func queryUser(client *signal.Client) string {
+	return client.HTTPRequest.URL.Query().Get("user")
+}
+
+func TestRedeliveryOnReconnect(t *testing.T) {
+	socket := signal.IOServer("", signal.WithAtLeastOnce(signal.AtLeastOnce{}), signal.WithUserResolver(queryUser))
+	socket.On("subscribe", func(payload signal.Payload, client *signal.Client) {
+		client.EmitReliable("news", "extra")
+	})
+	server := signaltest.New(socket)
+	defer server.Close()
+
+	client := server.MustConnect(t, signaltest.WithQuery(signal.SessionParam, "reader"), signaltest.WithQuery("user", "ada"))
+	client.Emit("subscribe", nil)
+	sent, err := client.Expect("news", wait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	waitFor(t, func() bool { return len(socket.Clients()) == 0 })
+
+	other := server.MustConnect(t, signaltest.WithQuery(signal.SessionParam, "reader"), signaltest.WithQuery("user", "bob"))
+	if err := other.ExpectNone("news", 50*time.Millisecond); err != nil {
+		t.Error("the session of another user is redelivered:", err)
+	}
+
+	resumed := server.MustConnect(t, signaltest.WithQuery(signal.SessionParam, "reader"), signaltest.WithQuery("user", "ada"))
+	redelivered, err := resumed.Expect("news", wait)
+	if err != nil {
+		t.Fatal("the session outbox is redelivered on reconnection:", err)
+	}
+	if redelivered.Id != sent.Id {
+		t.Fatalf("redelivered id %s, want %s", redelivered.Id, sent.Id)
+	}
+}
+
+func TestAnonymousSessionsAreNotResumed(t *testing.T) {
+	socket := signal.IOServer("", signal.WithAtLeastOnce(signal.AtLeastOnce{}))
+	socket.On("subscribe", func(payload signal.Payload, client *signal.Client) {
+		client.EmitReliable("news", "extra")
+	})
+	server := signaltest.New(socket)
+	defer server.Close()
+
+	client := server.MustConnect(t, signaltest.WithQuery(signal.SessionParam, "reader"))
+	client.Emit("subscribe", nil)
+	if _, err := client.Expect("news", wait); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	waitFor(t, func() bool { return len(socket.Clients()) == 0 })
+
+	claimed := server.MustConnect(t, signaltest.WithQuery(signal.SessionParam, "reader"))
+	if err := claimed.ExpectNone("news", 50*time.Millisecond); err != nil {
+		t.Error("an anonymous client resumed the outbox of another:", err)
+	}
+}
+
+func TestCoalescing(t *testing.T) {
+	socket := signal.IOServer("", signal.WithCoalescing(20*time.Millisecond))
+	socket.On("burst", func(payload signal.Payload, client *signal.Client) {
+		for _, text := range []string{"one", "two", "three"} {
+			client.Emit("tick", text)
+		}
+	})
+	server := signaltest.New(socket)
+	defer server.Close()
+
+	client := server.MustConnect(t)
+	client.Emit("burst", nil)
+	for _, want := range []string{"one", "two", "three"} {
+		if payload := client.MustExpect(t, "tick", wait); payload != want {
+			t.Fatalf("tick %v, want %s", payload, want)
+		}
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	socket := signal.IOServer("")
+	var mu sync.Mutex
+	var disconnected []string
+	socket.On("disconnect", func(payload signal.Payload, client *signal.Client) {
+		mu.Lock()
+		disconnected = append(disconnected, client.ConnectionId)
+		mu.Unlock()
+	})
+	server := signaltest.New(socket)
+	defer server.Close()
+
+	client := server.MustConnect(t)
+	if err := socket.JoinRoom("lobby", client.Client); err != nil {
+		t.Fatal(err)
+	}
+	connectionId := client.Client.ConnectionId
+	client.Close()
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(disconnected) == 1 && disconnected[0] == connectionId
+	})
+	waitFor(t, func() bool { return socket.Room("lobby").Len() == 0 })
+	if _, exists := socket.Client(connectionId); exists {
+		t.Error("closed connections are unregistered")
+	}
+}
+
+// drain discards the eventName events already received
+func drain(client *signaltest.TestClient, eventName string) {
+	for {
+		if _, err := client.Expect(eventName, 10*time.Millisecond); err != nil {
+			return
+		}
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(wait)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,36 @@
+package signaltest
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// StartTest serves a new server on an ephemeral localhost port and returns it with its websocket URL,
+// e.g. "ws://127.0.0.1:53127/". The server is shut down when the test ends.
+func StartTest(t testing.TB, options ...signal.Option) (*signal.Server, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	socket := signal.IOServer(port, options...)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- socket.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		socket.Shutdown(ctx)
+		if err := <-done; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+	return socket, "ws://" + listener.Addr().String() + "/"
+}
@@ -0,0 +1,161 @@
+package signal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidSignature is reported for inbound messages whose signature or timestamp does not verify, see WithSigning
+var ErrInvalidSignature = errors.New("invalid message signature")
+
+// Signing configures the HMAC-SHA256 signatures of messages, for deployments where an untrusted edge
+// proxies the WebSocket traffic. The signature is the hex HMAC of the event name, the id, the room, the
+// sequence number, the protocol version, the traceparent, the timestamp and the JSON payload, joined by
+// newlines, absent fields being empty and absent numbers 0. Outbound payloads are signed as encoding/json writes them, so
+// browsers verifying them escape <, > and & as \u003c, \u003e and \u0026 after JSON.stringify. Inbound
+// JSON frames are verified against their payload bytes as sent.
+type Signing struct {
+	// Key returns the key of a client, such as the key of its tenant with TenantSigningKeys, it is required.
+	// Messages of clients without a key are neither signed nor verified.
+	Key func(client *Client) []byte
+	// Sign adds a timestamp and signature to the messages sent to clients
+	Sign bool
+	// Verify rejects the messages of clients whose signature does not match
+	Verify bool
+	// MaxSkew rejects inbound messages whose timestamp is further than this from the server clock, 5 minutes
+	// by default. Replays within the window are caught with WithDeduplication.
+	MaxSkew time.Duration
+}
+
+// TenantSigningKeys returns a Signing.Key looking up the key of the client tenant, the default tenant
+// being the empty string
+func TenantSigningKeys(keys map[string][]byte) func(client *Client) []byte {
+	return func(client *Client) []byte {
+		return keys[client.Tenant()]
+	}
+}
+
+// SignMessage stamps the message with the current time, unless it has a timestamp, and signs it with key.
+// Go clients sign their messages with it, the JSON codec then sends the payload as signed.
+func SignMessage(key []byte, message Message) (Message, error) {
+	payload, err := signedPayload(message.Payload)
+	if err != nil {
+		return message, err
+	}
+	if message.Timestamp == 0 {
+		message.Timestamp = time.Now().UnixMilli()
+	}
+	message.Signature = signature(key, message, payload)
+	return message, nil
+}
+
+// VerifyFrame decodes a JSON frame received from the server and checks its signature with key
+func VerifyFrame(key []byte, frame []byte) (Message, error) {
+	var message Message
+	if err := json.Unmarshal(frame, &message); err != nil {
+		return message, err
+	}
+	payload, err := framePayload(frame)
+	if err != nil {
+		return message, err
+	}
+	if !hmac.Equal([]byte(message.Signature), []byte(signature(key, message, payload))) {
+		return message, ErrInvalidSignature
+	}
+	return message, nil
+}
+
+// signature returns the hex HMAC-SHA256 of the signed fields of the message, in a fixed order
+func signature(key []byte, message Message, payload []byte) string {
+	mac := hmac.New(sha256.New, key)
+	for _, field := range []string{
+		message.EventName,
+		message.Id,
+		message.Room,
+		strconv.FormatUint(message.Seq, 10),
+		strconv.Itoa(message.Version),
+		message.TraceParent,
+		strconv.FormatInt(message.Timestamp, 10),
+	} {
+		mac.Write([]byte(field))
+		mac.Write([]byte{'\n'})
+	}
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// framePayload returns the payload of a JSON frame as sent, null when the frame has none
+func framePayload(frame []byte) ([]byte, error) {
+	var raw struct {
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(frame, &raw); err != nil {
+		return nil, err
+	}
+	if raw.Payload == nil {
+		return []byte("null"), nil
+	}
+	return raw.Payload, nil
+}
+
+// signedPayload encodes the payload as the JSON codec writes it, nil payloads being signed as null
+func signedPayload(payload Payload) ([]byte, error) {
+	return json.Marshal(payload)
+}
+
+// sign stamps and signs a message sent to client
+func (client *Client) sign(message Message) (Message, error) {
+	signing := client.server().signing
+	if signing == nil || !signing.Sign {
+		return message, nil
+	}
+	key := signing.Key(client)
+	if key == nil {
+		return message, nil
+	}
+	message.Timestamp = 0
+	return SignMessage(key, message)
+}
+
+// verifyMessage checks the signature and timestamp of a message received from client, frame being the
+// frame it was decoded from
+func (socket *Server) verifyMessage(client *Client, message Message, frame []byte) error {
+	signing := socket.signing
+	if signing == nil || !signing.Verify {
+		return nil
+	}
+	key := signing.Key(client)
+	if key == nil {
+		return nil
+	}
+	if message.Signature == "" {
+		return ErrInvalidSignature
+	}
+	maxSkew := signing.MaxSkew
+	if maxSkew <= 0 {
+		maxSkew = 5 * time.Minute
+	}
+	skew := time.Since(time.UnixMilli(message.Timestamp))
+	if skew > maxSkew || skew < -maxSkew {
+		return ErrInvalidSignature
+	}
+
+	// JSON frames are verified as sent, the payloads of other codecs as encoding/json writes them
+	encode := signedPayload
+	if _, ok := client.codec().(JSONCodec); ok {
+		encode = func(Payload) ([]byte, error) { return framePayload(frame) }
+	}
+	payload, err := encode(message.Payload)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(message.Signature), []byte(signature(key, message, payload))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
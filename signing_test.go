@@ -0,0 +1,91 @@
+package signal
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+var testSigningKey = []byte("secret")
+
+func signedMessage(t *testing.T) Message {
+	t.Helper()
+	message, err := SignMessage(testSigningKey, Message{
+		Id:          "m1",
+		EventName:   "move",
+		Payload:     map[string]any{"x": 3},
+		TraceParent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		Version:     2,
+		Room:        "game",
+		Seq:         42,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func TestVerifyFrameTamper(t *testing.T) {
+	frame, _ := json.Marshal(signedMessage(t))
+	if _, err := VerifyFrame(testSigningKey, frame); err != nil {
+		t.Fatal("a signed frame does not verify:", err)
+	}
+	if _, err := VerifyFrame([]byte("other"), frame); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("another key: %v, want %v", err, ErrInvalidSignature)
+	}
+
+	for field, tamper := range map[string]func(*Message){
+		"eventName":   func(message *Message) { message.EventName = "jump" },
+		"id":          func(message *Message) { message.Id = "m2" },
+		"room":        func(message *Message) { message.Room = "lobby" },
+		"seq":         func(message *Message) { message.Seq = 43 },
+		"v":           func(message *Message) { message.Version = 1 },
+		"traceparent": func(message *Message) { message.TraceParent = "" },
+		"ts":          func(message *Message) { message.Timestamp++ },
+		"payload":     func(message *Message) { message.Payload = map[string]any{"x": 4} },
+	} {
+		message := signedMessage(t)
+		tamper(&message)
+		frame, _ := json.Marshal(message)
+		if _, err := VerifyFrame(testSigningKey, frame); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("tampered %s: %v, want %v", field, err, ErrInvalidSignature)
+		}
+	}
+}
+
+func TestVerifyMessage(t *testing.T) {
+	socket := IOServer("0", WithSigning(Signing{
+		Key:     func(*Client) []byte { return testSigningKey },
+		Verify:  true,
+		MaxSkew: time.Minute,
+	}))
+	client := connectClients(t, socket, 1)[0]
+
+	verify := func(message Message) error {
+		frame, _ := json.Marshal(message)
+		var decoded Message
+		if err := client.codec().Unmarshal(frame, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		return socket.verifyMessage(client, decoded, frame)
+	}
+
+	if err := verify(signedMessage(t)); err != nil {
+		t.Fatal("a signed message does not verify:", err)
+	}
+	if err := verify(Message{EventName: "move"}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("unsigned: %v, want %v", err, ErrInvalidSignature)
+	}
+	stale := signedMessage(t)
+	stale.Timestamp = time.Now().Add(-time.Hour).UnixMilli()
+	stale, _ = SignMessage(testSigningKey, stale)
+	if err := verify(stale); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("stale: %v, want %v", err, ErrInvalidSignature)
+	}
+	tampered := signedMessage(t)
+	tampered.Version = 1
+	if err := verify(tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("tampered version: %v, want %v", err, ErrInvalidSignature)
+	}
+}
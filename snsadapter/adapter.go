@@ -0,0 +1,184 @@
+// Package snsadapter fans signal.io broadcasts and room emits out to every replica through AWS SNS and SQS.
+//
+// Every node publishes to a shared SNS topic and polls an SQS queue of its own subscribed to that topic,
+// a queue shared by several nodes would split the messages between them instead of fanning them out.
+// Raw message delivery may be enabled or not on the subscription, both are understood.
+//
+// The package does not depend on the AWS SDK, with github.com/aws/aws-sdk-go-v2:
+//
+//	type awsClient struct {
+//		sns *sns.Client
+//		sqs *sqs.Client
+//	}
+//
+//	func (c awsClient) Publish(ctx context.Context, topicArn, message string) error {
+//		_, err := c.sns.Publish(ctx, &sns.PublishInput{TopicArn: &topicArn, Message: &message})
+//		return err
+//	}
+//
+//	func (c awsClient) Receive(ctx context.Context, queueURL string) ([]snsadapter.Message, error) {
+//		out, err := c.sqs.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{QueueUrl: &queueURL, MaxNumberOfMessages: 10, WaitTimeSeconds: 20})
+//		if err != nil {
+//			return nil, err
+//		}
+//		messages := make([]snsadapter.Message, len(out.Messages))
+//		for i, msg := range out.Messages {
+//			messages[i] = snsadapter.Message{Body: *msg.Body, ReceiptHandle: *msg.ReceiptHandle}
+//		}
+//		return messages, nil
+//	}
+//
+//	func (c awsClient) Delete(ctx context.Context, queueURL string, receiptHandles []string) error {
+//		entries := make([]types.DeleteMessageBatchRequestEntry, len(receiptHandles))
+//		for i := range receiptHandles {
+//			entries[i] = types.DeleteMessageBatchRequestEntry{Id: aws.String(strconv.Itoa(i)), ReceiptHandle: &receiptHandles[i]}
+//		}
+//		_, err := c.sqs.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{QueueUrl: &queueURL, Entries: entries})
+//		return err
+//	}
+//
+//	socket := signal.IOServer("8080", signal.WithAdapter(snsadapter.New(awsClient{snsClient, sqsClient}, topicArn, queueURL)))
+package snsadapter
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Message is a message received from the queue
+type Message struct {
+	Body          string
+	ReceiptHandle string
+}
+
+// Client is the part of the SNS and SQS clients used by the adapter
+type Client interface {
+	Publish(ctx context.Context, topicArn, message string) error
+	// Receive long polls the queue, returning the messages received, possibly none
+	Receive(ctx context.Context, queueURL string) ([]Message, error)
+	Delete(ctx context.Context, queueURL string, receiptHandles []string) error
+}
+
+// Adapter implements signal.Adapter on top of an SNS topic and a per-node SQS queue
+type Adapter struct {
+	client   Client
+	topicArn string
+	queueURL string
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Option configures the adapter
+type Option func(*Adapter)
+
+// WithTimeout bounds every publish and delete, 10 seconds by default
+func WithTimeout(timeout time.Duration) Option {
+	return func(adapter *Adapter) {
+		adapter.timeout = timeout
+	}
+}
+
+// New creates an adapter publishing to topicArn and receiving from queueURL, the queue of this node
+func New(client Client, topicArn, queueURL string, options ...Option) *Adapter {
+	adapter := &Adapter{
+		client:   client,
+		topicArn: topicArn,
+		queueURL: queueURL,
+		timeout:  10 * time.Second,
+	}
+	for _, option := range options {
+		option(adapter)
+	}
+	return adapter
+}
+
+// Publish sends the packet base64 encoded, SNS only carries text and packets may be binary
+func (adapter *Adapter) Publish(data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), adapter.timeout)
+	defer cancel()
+	return adapter.client.Publish(ctx, adapter.topicArn, base64.StdEncoding.EncodeToString(data))
+}
+
+func (adapter *Adapter) Subscribe(handler func(data []byte)) error {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+
+	if adapter.cancel != nil {
+		return errors.New("snsadapter: already subscribed")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	adapter.cancel, adapter.done = cancel, make(chan struct{})
+	go adapter.poll(ctx, handler, adapter.done)
+	return nil
+}
+
+// poll receives from the queue until the adapter is closed
+func (adapter *Adapter) poll(ctx context.Context, handler func(data []byte), done chan struct{}) {
+	defer close(done)
+	for ctx.Err() == nil {
+		messages, err := adapter.client.Receive(ctx, adapter.queueURL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("snsadapter: receive error, retrying: %v", err)
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		if len(messages) == 0 {
+			continue
+		}
+		receipts := make([]string, 0, len(messages))
+		for _, message := range messages {
+			receipts = append(receipts, message.ReceiptHandle)
+			data, err := decode(message.Body)
+			if err != nil {
+				log.Printf("snsadapter: dropping message: %v", err)
+				continue
+			}
+			handler(data)
+		}
+		deleteCtx, cancel := context.WithTimeout(context.Background(), adapter.timeout)
+		if err := adapter.client.Delete(deleteCtx, adapter.queueURL, receipts); err != nil {
+			// the messages come back after the visibility timeout, nodes drop their own and the others are replayed
+			log.Printf("snsadapter: delete error: %v", err)
+		}
+		cancel()
+	}
+}
+
+// decode returns the packet of an SQS message body, wrapped in the SNS envelope unless raw delivery is enabled
+func decode(body string) ([]byte, error) {
+	var envelope struct {
+		Type    string `json:"Type"`
+		Message string `json:"Message"`
+	}
+	if json.Unmarshal([]byte(body), &envelope) == nil && envelope.Type == "Notification" {
+		body = envelope.Message
+	}
+	return base64.StdEncoding.DecodeString(body)
+}
+
+func (adapter *Adapter) Close() error {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+
+	if adapter.cancel == nil {
+		return nil
+	}
+	adapter.cancel()
+	<-adapter.done
+	adapter.cancel = nil
+	return nil
+}
@@ -0,0 +1,81 @@
+package signal
+
+// ConnectionState is a step of the connection lifecycle, states only move forward
+type ConnectionState int32
+
+const (
+	// StateHandshaking runs the middlewares and checks before the upgrade
+	StateHandshaking ConnectionState = iota
+	// StateOpen is a connected client
+	StateOpen
+	// StateDraining is a client told to reconnect elsewhere by Drain, it is closed later on
+	StateDraining
+	// StateClosing is a connection the server is closing, the read loop has not stopped yet
+	StateClosing
+	// StateClosed is an ended connection, or a refused handshake
+	StateClosed
+)
+
+func (state ConnectionState) String() string {
+	switch state {
+	case StateHandshaking:
+		return "handshaking"
+	case StateOpen:
+		return "open"
+	case StateDraining:
+		return "draining"
+	case StateClosing:
+		return "closing"
+	case StateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// StateListener is called on every state change of a connection
+type StateListener = func(client *Client, from, to ConnectionState)
+
+// OnStateChange registers a listener called whenever a connection changes state, from the goroutine
+// causing the change
+func (socket *Server) OnStateChange(listener StateListener) {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	socket.stateListeners = append(socket.stateListeners, listener)
+}
+
+// State returns the lifecycle state of the connection
+func (client *Client) State() ConnectionState {
+	if client.state == nil {
+		return StateOpen
+	}
+	return ConnectionState(client.state.lifecycle.Load())
+}
+
+// setState moves the connection forward to state, it is a no-op when the connection is already there or past it
+func (client *Client) setState(state ConnectionState) {
+	if client.state == nil {
+		return
+	}
+	for {
+		from := ConnectionState(client.state.lifecycle.Load())
+		if from >= state {
+			return
+		}
+		if client.state.lifecycle.CompareAndSwap(int32(from), int32(state)) {
+			client.server().notifyState(client, from, state)
+			return
+		}
+	}
+}
+
+func (socket *Server) notifyState(client *Client, from, to ConnectionState) {
+	if socket == nil {
+		return
+	}
+	socket.mu.RLock()
+	listeners := socket.stateListeners
+	socket.mu.RUnlock()
+	for _, listener := range listeners {
+		listener(client, from, to)
+	}
+}
@@ -0,0 +1,239 @@
+package statesync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPatch is returned when an operation does not apply to the document
+var ErrInvalidPatch = errors.New("statesync: invalid patch")
+
+// Operation is a JSON Patch (RFC 6902) operation, only add, remove and replace are produced and applied
+type Operation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// MarshalJSON keeps the value of add and replace operations even when it is null
+func (operation Operation) MarshalJSON() ([]byte, error) {
+	if operation.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{operation.Op, operation.Path})
+	}
+	return json.Marshal(struct {
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+		Value any    `json:"value"`
+	}{operation.Op, operation.Path, operation.Value})
+}
+
+// Patch is a list of operations applied in order
+type Patch []Operation
+
+// normalize converts a value to its JSON form: maps, slices, strings, float64, bool and nil
+func normalize(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+// Diff returns the patch turning from into to, both are compared in their JSON form
+func Diff(from, to any) (Patch, error) {
+	from, err := normalize(from)
+	if err != nil {
+		return nil, err
+	}
+	to, err = normalize(to)
+	if err != nil {
+		return nil, err
+	}
+	var patch Patch
+	diff("", from, to, &patch)
+	return patch, nil
+}
+
+func diff(path string, from, to any, patch *Patch) {
+	switch from := from.(type) {
+	case map[string]any:
+		if to, ok := to.(map[string]any); ok {
+			diffObjects(path, from, to, patch)
+			return
+		}
+	case []any:
+		if to, ok := to.([]any); ok {
+			diffArrays(path, from, to, patch)
+			return
+		}
+	}
+	if !reflect.DeepEqual(from, to) {
+		*patch = append(*patch, Operation{Op: "replace", Path: path, Value: to})
+	}
+}
+
+func diffObjects(path string, from, to map[string]any, patch *Patch) {
+	keys := make([]string, 0, len(from))
+	for key := range from {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if value, exists := to[key]; exists {
+			diff(path+"/"+escape(key), from[key], value, patch)
+		} else {
+			*patch = append(*patch, Operation{Op: "remove", Path: path + "/" + escape(key)})
+		}
+	}
+
+	keys = keys[:0]
+	for key := range to {
+		if _, exists := from[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		*patch = append(*patch, Operation{Op: "add", Path: path + "/" + escape(key), Value: to[key]})
+	}
+}
+
+// diffArrays compares the common elements by index, then appends or removes the tail
+func diffArrays(path string, from, to []any, patch *Patch) {
+	common := min(len(from), len(to))
+	for i := 0; i < common; i++ {
+		diff(path+"/"+strconv.Itoa(i), from[i], to[i], patch)
+	}
+	for i := common; i < len(to); i++ {
+		*patch = append(*patch, Operation{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: to[i]})
+	}
+	// the tail is removed from the end so the indexes stay valid
+	for i := len(from) - 1; i >= common; i-- {
+		*patch = append(*patch, Operation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+}
+
+// escape encodes a key as a JSON Pointer reference token
+func escape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+func unescape(token string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+}
+
+// Apply returns the document with the patch applied, doc is left unchanged. Go clients use it to
+// apply the deltas they receive.
+func Apply(doc any, patch Patch) (any, error) {
+	doc, err := normalize(doc)
+	if err != nil {
+		return nil, err
+	}
+	for _, operation := range patch {
+		value, err := normalize(operation.Value)
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = apply(doc, operation.Op, pointer(operation.Path), value); err != nil {
+			return nil, fmt.Errorf("%w: %s %s: %v", ErrInvalidPatch, operation.Op, operation.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+// pointer splits a JSON Pointer into its reference tokens
+func pointer(path string) []string {
+	if path == "" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, token := range tokens {
+		tokens[i] = unescape(token)
+	}
+	return tokens
+}
+
+// apply runs an operation on the node, returning the node to store in its parent
+func apply(node any, op string, tokens []string, value any) (any, error) {
+	if len(tokens) == 0 {
+		switch op {
+		case "add", "replace":
+			return value, nil
+		case "remove":
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unsupported operation")
+	}
+
+	token, last := tokens[0], len(tokens) == 1
+	switch node := node.(type) {
+	case map[string]any:
+		child, exists := node[token]
+		if last {
+			switch op {
+			case "add":
+				node[token] = value
+			case "replace":
+				if !exists {
+					return nil, fmt.Errorf("missing key %q", token)
+				}
+				node[token] = value
+			case "remove":
+				if !exists {
+					return nil, fmt.Errorf("missing key %q", token)
+				}
+				delete(node, token)
+			default:
+				return nil, fmt.Errorf("unsupported operation")
+			}
+			return node, nil
+		}
+		if !exists {
+			return nil, fmt.Errorf("missing key %q", token)
+		}
+		updated, err := apply(child, op, tokens[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = updated
+		return node, nil
+
+	case []any:
+		if last && op == "add" && token == "-" {
+			return append(node, value), nil
+		}
+		index, err := strconv.Atoi(token)
+		if err != nil || index < 0 || index > len(node) || (index == len(node) && !(last && op == "add")) {
+			return nil, fmt.Errorf("index %q out of range", token)
+		}
+		if last {
+			switch op {
+			case "add":
+				return slices.Insert(node, index, value), nil
+			case "replace":
+				node[index] = value
+				return node, nil
+			case "remove":
+				return slices.Delete(node, index, index+1), nil
+			}
+			return nil, fmt.Errorf("unsupported operation")
+		}
+		updated, err := apply(node[index], op, tokens[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = updated
+		return node, nil
+	}
+	return nil, fmt.Errorf("cannot traverse %T", node)
+}
@@ -0,0 +1,181 @@
+// Package statesync keeps a state object per room in sync with its members: the server holds the
+// state, every change is broadcast to the room as a JSON Patch, and joining clients receive a snapshot.
+//
+//	docs := statesync.New(socket, "doc")
+//	state, _ := docs.Room(socket.Room("doc-42"), Document{Title: "Draft"})
+//	state.Update(func(current any) (any, error) {
+//		doc := current.(map[string]any)
+//		doc["title"] = "Final"
+//		return doc, nil
+//	})
+//
+// Joining clients receive the signal.WelcomeEvent of the room with a Snapshot payload, then "<event>"
+// events with a Delta payload. Clients ignore the deltas whose Version is not above their snapshot,
+// and emit "<event>:sync" with the room id to get a new snapshot, as "<event>:snapshot", when they
+// notice a gap in the versions.
+package statesync
+
+import (
+	"sync"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// Snapshot is the whole state of a room at Version
+type Snapshot struct {
+	Room    string `json:"room"`
+	Version uint64 `json:"version"`
+	State   any    `json:"state"`
+}
+
+// Delta turns the state at Version-1 into the state at Version
+type Delta struct {
+	Room    string `json:"room"`
+	Version uint64 `json:"version"`
+	Patch   Patch  `json:"patch"`
+}
+
+// Rooms holds the states of the rooms synchronized on one event
+type Rooms struct {
+	socket *signal.Server
+	event  string
+
+	mu     sync.Mutex
+	states map[string]*State
+}
+
+// New registers the sync events of event on socket
+func New(socket *signal.Server, event string) *Rooms {
+	rooms := &Rooms{
+		socket: socket,
+		event:  event,
+		states: make(map[string]*State),
+	}
+	socket.On(event+":sync", rooms.onSync)
+	return rooms
+}
+
+// Room returns the state of the room, starting it from initial when the room has none yet. The state
+// sends the room welcome, it replaces any other welcome of the room.
+func (rooms *Rooms) Room(room *signal.Room, initial any) (*State, error) {
+	rooms.mu.Lock()
+	defer rooms.mu.Unlock()
+	key := room.Tenant + "/" + room.Id
+	if state, exists := rooms.states[key]; exists {
+		return state, nil
+	}
+	doc, err := normalize(initial)
+	if err != nil {
+		return nil, err
+	}
+	state := &State{rooms: rooms, room: room, doc: doc}
+	room.SetWelcome(func(client *signal.Client) signal.Payload {
+		return state.Snapshot()
+	})
+	rooms.states[key] = state
+	return state, nil
+}
+
+// Remove stops synchronizing the room, its welcome is removed
+func (rooms *Rooms) Remove(room *signal.Room) {
+	rooms.mu.Lock()
+	defer rooms.mu.Unlock()
+	delete(rooms.states, room.Tenant+"/"+room.Id)
+	room.SetWelcome(nil)
+}
+
+func (rooms *Rooms) onSync(payload signal.Payload, client *signal.Client) {
+	roomId, ok := payload.(string)
+	if !ok {
+		client.EmitError(signal.NewError(signal.CodeInvalidPayload, "statesync: expected a room id"))
+		return
+	}
+	rooms.mu.Lock()
+	state, exists := rooms.states[client.Tenant()+"/"+roomId]
+	rooms.mu.Unlock()
+	if !exists || signal.IndexOf(client.ConnectionId, state.room.Clients()) == -1 {
+		client.EmitError(signal.NewError(signal.CodeUnauthorized, "statesync: not a member of "+roomId))
+		return
+	}
+	client.Emit(rooms.event+":snapshot", state.Snapshot())
+}
+
+// State is the synchronized state of a room, held in its JSON form
+type State struct {
+	rooms *Rooms
+	room  *signal.Room
+
+	// updates serializes the changes so deltas are broadcast in version order
+	updates sync.Mutex
+	mu      sync.RWMutex
+	doc     any
+	version uint64
+}
+
+// Snapshot returns a copy of the state and its version
+func (state *State) Snapshot() Snapshot {
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	doc, _ := normalize(state.doc)
+	return Snapshot{Room: state.room.Id, Version: state.version, State: doc}
+}
+
+// Version returns the number of changes applied to the state
+func (state *State) Version() uint64 {
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	return state.version
+}
+
+// Bind decodes the state into out, see signal.Bind
+func (state *State) Bind(out any) error {
+	return signal.Bind(state.Snapshot().State, out)
+}
+
+// Set replaces the state and broadcasts the difference with the previous one
+func (state *State) Set(value any) error {
+	return state.Update(func(any) (any, error) {
+		return value, nil
+	})
+}
+
+// Update changes the state with fn, which receives a copy of the current state in its JSON form
+// (map[string]any, []any, float64...) and returns the new one. Nothing is broadcast when fn fails
+// or leaves the state unchanged.
+func (state *State) Update(fn func(current any) (any, error)) error {
+	state.updates.Lock()
+	defer state.updates.Unlock()
+
+	current := state.Snapshot()
+	next, err := fn(current.State)
+	if err != nil {
+		return err
+	}
+	next, err = normalize(next)
+	if err != nil {
+		return err
+	}
+	state.mu.RLock()
+	patch, err := Diff(state.doc, next)
+	state.mu.RUnlock()
+	if err != nil || len(patch) == 0 {
+		return err
+	}
+
+	state.mu.Lock()
+	state.doc = next
+	state.version++
+	delta := Delta{Room: state.room.Id, Version: state.version, Patch: patch}
+	state.mu.Unlock()
+
+	// the room welcome reads the state while the room emits are held, the state lock is released first
+	state.room.Emit(state.rooms.event, delta)
+	return nil
+}
+
+// ApplyPatch changes the state with a patch, such as one sent by a client, and broadcasts it
+func (state *State) ApplyPatch(patch Patch) error {
+	return state.Update(func(current any) (any, error) {
+		return Apply(current, patch)
+	})
+}
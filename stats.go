@@ -0,0 +1,180 @@
+package signal
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// topTalkers is the number of connections listed in Stats.TopTalkers
+const topTalkers = 10
+
+// EventStats counts the traffic of an event name since the server started
+type EventStats struct {
+	Event string `json:"event"`
+	// Received counts the messages sent by clients, Bytes their encoded size
+	Received uint64 `json:"received"`
+	Bytes    uint64 `json:"bytes"`
+	// Sent counts the deliveries to clients, a broadcast to 100 clients counts 100, Failed the failed ones
+	Sent   uint64 `json:"sent"`
+	Failed uint64 `json:"failed"`
+	// HandlerTime is the average and MaxHandlerTime the longest time spent in the listener
+	HandlerTime    time.Duration `json:"handlerTime"`
+	MaxHandlerTime time.Duration `json:"maxHandlerTime"`
+}
+
+// ConnectionStats counts the messages sent by a connection
+type ConnectionStats struct {
+	ConnectionId string `json:"connectionId"`
+	Received     uint64 `json:"received"`
+	Bytes        uint64 `json:"bytes"`
+	// Rate is the average number of messages per second since the connection opened
+	Rate float64 `json:"rate"`
+}
+
+// Stats is a snapshot of the server traffic
+type Stats struct {
+	Connections int `json:"connections"`
+	Rooms       int `json:"rooms"`
+	// Errors counts the errors reported to the error listener
+	Errors uint64       `json:"errors"`
+	Events []EventStats `json:"events"`
+	// TopTalkers are the connections sending the most messages per second
+	TopTalkers []ConnectionStats `json:"topTalkers"`
+}
+
+type eventCounter struct {
+	received       atomic.Uint64
+	bytes          atomic.Uint64
+	sent           atomic.Uint64
+	failed         atomic.Uint64
+	handled        atomic.Uint64
+	handlerTime    atomic.Int64
+	maxHandlerTime atomic.Int64
+}
+
+// connectionCounter is kept on the client state
+type connectionCounter struct {
+	since    time.Time
+	received atomic.Uint64
+	bytes    atomic.Uint64
+}
+
+type metrics struct {
+	errors atomic.Uint64
+	// load counts every message for the rates of Load
+	load loadSampler
+
+	mu     sync.RWMutex
+	events map[string]*eventCounter
+}
+
+func (m *metrics) event(eventName string) *eventCounter {
+	m.mu.RLock()
+	counter, exists := m.events[eventName]
+	m.mu.RUnlock()
+	if exists {
+		return counter
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.events == nil {
+		m.events = make(map[string]*eventCounter)
+	}
+	if counter, exists = m.events[eventName]; !exists {
+		counter = &eventCounter{}
+		m.events[eventName] = counter
+	}
+	return counter
+}
+
+func (socket *Server) countReceived(eventName string, size int, client *Client) {
+	counter := socket.metrics.event(eventName)
+	counter.received.Add(1)
+	counter.bytes.Add(uint64(size))
+	socket.metrics.load.received.Add(1)
+	if client.state != nil {
+		client.state.counter.received.Add(1)
+		client.state.counter.bytes.Add(uint64(size))
+	}
+}
+
+func (socket *Server) countSent(eventName string, delivered, failed int) {
+	if socket == nil || delivered+failed == 0 {
+		return
+	}
+	counter := socket.metrics.event(eventName)
+	counter.sent.Add(uint64(delivered))
+	socket.metrics.load.sent.Add(uint64(delivered))
+	counter.failed.Add(uint64(failed))
+}
+
+func (socket *Server) countHandled(eventName string, elapsed time.Duration) {
+	counter := socket.metrics.event(eventName)
+	counter.handled.Add(1)
+	counter.handlerTime.Add(int64(elapsed))
+	for {
+		max := counter.maxHandlerTime.Load()
+		if int64(elapsed) <= max || counter.maxHandlerTime.CompareAndSwap(max, int64(elapsed)) {
+			return
+		}
+	}
+}
+
+// Stats returns the traffic per event name and the connections sending the most messages
+func (socket *Server) Stats() Stats {
+	stats := Stats{
+		Connections: socket.GetTotalConnections(),
+		Rooms:       len(socket.roomList()),
+		Errors:      socket.metrics.errors.Load(),
+		Events:      make([]EventStats, 0),
+		TopTalkers:  make([]ConnectionStats, 0),
+	}
+
+	socket.metrics.mu.RLock()
+	for eventName, counter := range socket.metrics.events {
+		event := EventStats{
+			Event:          eventName,
+			Received:       counter.received.Load(),
+			Bytes:          counter.bytes.Load(),
+			Sent:           counter.sent.Load(),
+			Failed:         counter.failed.Load(),
+			MaxHandlerTime: time.Duration(counter.maxHandlerTime.Load()),
+		}
+		if handled := counter.handled.Load(); handled > 0 {
+			event.HandlerTime = time.Duration(counter.handlerTime.Load() / int64(handled))
+		}
+		stats.Events = append(stats.Events, event)
+	}
+	socket.metrics.mu.RUnlock()
+	sort.Slice(stats.Events, func(i, j int) bool { return stats.Events[i].Event < stats.Events[j].Event })
+
+	now := time.Now()
+	for _, client := range socket.snapshot() {
+		if client.state == nil {
+			continue
+		}
+		counter := &client.state.counter
+		connection := ConnectionStats{
+			ConnectionId: client.ConnectionId,
+			Received:     counter.received.Load(),
+			Bytes:        counter.bytes.Load(),
+		}
+		if elapsed := now.Sub(counter.since).Seconds(); elapsed > 0 {
+			connection.Rate = float64(connection.Received) / elapsed
+		}
+		stats.TopTalkers = append(stats.TopTalkers, connection)
+	}
+	sort.Slice(stats.TopTalkers, func(i, j int) bool { return stats.TopTalkers[i].Rate > stats.TopTalkers[j].Rate })
+	if len(stats.TopTalkers) > topTalkers {
+		stats.TopTalkers = stats.TopTalkers[:topTalkers]
+	}
+	return stats
+}
+
+// summarizeStats publishes a Stats snapshot on the internal bus
+func (socket *Server) summarizeStats() {
+	socket.bus.Emit(InternalEvent{Name: InternalStats, Data: socket.Stats()})
+}
@@ -0,0 +1,220 @@
+// Package statsd exports the traffic statistics of a signal.io server to a StatsD or DogStatsD agent.
+//
+//	exporter, err := statsd.New("127.0.0.1:8125", socket, statsd.WithTags("service:chat", "env:prod"))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	go exporter.Run(ctx)
+//
+// Every flush sends the gauges signal.connections and signal.rooms, the counters signal.errors,
+// signal.events.received, signal.events.bytes, signal.events.sent and signal.events.failed, and
+// the timing signal.handler.time, the event counters and timing being tagged with event:<name>.
+package statsd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// maxPacketSize keeps the datagrams under the usual MTU
+const maxPacketSize = 1432
+
+// Source is the part of the server read by the exporter
+type Source interface {
+	Stats() signal.Stats
+}
+
+// Exporter periodically sends the Stats of a server to a StatsD agent
+type Exporter struct {
+	conn       net.Conn
+	source     Source
+	prefix     string
+	tags       []string
+	sampleRate float64
+	interval   time.Duration
+	plain      bool
+
+	last signal.Stats
+}
+
+// Option configures the exporter
+type Option func(*Exporter)
+
+// WithPrefix changes the metric prefix, "signal" by default
+func WithPrefix(prefix string) Option {
+	return func(exporter *Exporter) {
+		exporter.prefix = prefix
+	}
+}
+
+// WithTags adds global tags, such as "env:prod", to every metric
+func WithTags(tags ...string) Option {
+	return func(exporter *Exporter) {
+		exporter.tags = append(exporter.tags, tags...)
+	}
+}
+
+// WithSampleRate sends the counters and timings with the given probability, the agent scales them back
+func WithSampleRate(rate float64) Option {
+	return func(exporter *Exporter) {
+		exporter.sampleRate = rate
+	}
+}
+
+// WithInterval changes the flush interval, 10s by default
+func WithInterval(interval time.Duration) Option {
+	return func(exporter *Exporter) {
+		exporter.interval = interval
+	}
+}
+
+// WithPlainStatsD drops the DogStatsD tags for agents not supporting them, the event
+// name is then appended to the metric name, e.g. signal.events.received.chat_message
+func WithPlainStatsD() Option {
+	return func(exporter *Exporter) {
+		exporter.plain = true
+	}
+}
+
+// New creates an exporter sending UDP datagrams to addr
+func New(addr string, source Source, options ...Option) (*Exporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	exporter := &Exporter{
+		conn:       conn,
+		source:     source,
+		prefix:     "signal",
+		sampleRate: 1,
+		interval:   10 * time.Second,
+	}
+	for _, option := range options {
+		option(exporter)
+	}
+	return exporter, nil
+}
+
+// Run flushes at every interval until ctx is done, then closes the connection
+func (exporter *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(exporter.interval)
+	defer ticker.Stop()
+	defer exporter.conn.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			exporter.Flush()
+		}
+	}
+}
+
+// Flush sends the current gauges and the counters accumulated since the previous flush
+func (exporter *Exporter) Flush() error {
+	stats := exporter.source.Stats()
+	previous := make(map[string]signal.EventStats, len(exporter.last.Events))
+	for _, event := range exporter.last.Events {
+		previous[event.Event] = event
+	}
+
+	lines := make([]string, 0)
+	lines = append(lines, exporter.line("connections", "", int64(stats.Connections), "g", false))
+	lines = append(lines, exporter.line("rooms", "", int64(stats.Rooms), "g", false))
+	if delta := stats.Errors - exporter.last.Errors; delta > 0 {
+		lines = append(lines, exporter.line("errors", "", int64(delta), "c", true))
+	}
+	for _, event := range stats.Events {
+		before := previous[event.Event]
+		counters := []struct {
+			name  string
+			delta uint64
+		}{
+			{"events.received", event.Received - before.Received},
+			{"events.bytes", event.Bytes - before.Bytes},
+			{"events.sent", event.Sent - before.Sent},
+			{"events.failed", event.Failed - before.Failed},
+		}
+		for _, counter := range counters {
+			if counter.delta > 0 {
+				lines = append(lines, exporter.line(counter.name, event.Event, int64(counter.delta), "c", true))
+			}
+		}
+		if event.Received > before.Received && event.HandlerTime > 0 {
+			lines = append(lines, exporter.line("handler.time", event.Event, event.HandlerTime.Milliseconds(), "ms", true))
+		}
+	}
+	exporter.last = stats
+	return exporter.send(lines)
+}
+
+// line formats a metric, sampled metrics are left out, returning "", at the sample rate
+func (exporter *Exporter) line(name, event string, value int64, kind string, sampled bool) string {
+	if sampled && exporter.sampleRate < 1 && rand.Float64() >= exporter.sampleRate {
+		return ""
+	}
+	metric := exporter.prefix + "." + name
+	tags := exporter.tags
+	if event != "" {
+		if exporter.plain {
+			metric += "." + sanitize(event)
+		} else {
+			tags = append(tags[:len(tags):len(tags)], "event:"+event)
+		}
+	}
+
+	line := fmt.Sprintf("%s:%d|%s", metric, value, kind)
+	if sampled && exporter.sampleRate < 1 {
+		line += fmt.Sprintf("|@%g", exporter.sampleRate)
+	}
+	if !exporter.plain && len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	return line
+}
+
+// send packs the lines into datagrams
+func (exporter *Exporter) send(lines []string) error {
+	var packet bytes.Buffer
+	var firstErr error
+	flush := func() {
+		if packet.Len() == 0 {
+			return
+		}
+		if _, err := exporter.conn.Write(packet.Bytes()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		packet.Reset()
+	}
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxPacketSize {
+			flush()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	flush()
+	return firstErr
+}
+
+// sanitize makes an event name usable in a plain StatsD metric name
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,383 @@
+package signal
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// StreamChunkSize is the payload size of the chunks written by a Stream
+const StreamChunkSize = 32 << 10
+
+// maxPendingChunks bounds the chunks a StreamReader keeps while waiting for a missing sequence number
+const maxPendingChunks = 1024
+
+// finishedStreamTTL is how long OnStream drops the late chunks of a finished stream, at most
+// maxFinishedStreams finished streams being remembered per connection
+const (
+	finishedStreamTTL  = time.Minute
+	maxFinishedStreams = 1024
+)
+
+// Default limits of the streams received by OnStream, see WithMaxOpenStreams and WithMaxStreamBuffer
+const (
+	DefaultMaxOpenStreams  = 16
+	DefaultMaxStreamBuffer = 8 << 20
+)
+
+var (
+	// ErrStreamClosed is returned by writes on a closed Stream
+	ErrStreamClosed = errors.New("stream closed")
+	// ErrStreamAborted is returned by reads on a stream whose connection ended before the last chunk
+	ErrStreamAborted = errors.New("stream aborted")
+	// ErrStreamOverflow is returned when too many chunks arrive ahead of a missing one
+	ErrStreamOverflow = errors.New("stream overflow: too many chunks out of order")
+	// ErrStreamBufferFull is returned when the data received and not read yet exceeds the buffer of the reader
+	ErrStreamBufferFull = errors.New("stream overflow: too much data buffered")
+	// ErrTooManyStreams is returned to clients opening more streams than their connection may have open
+	ErrTooManyStreams = errors.New("too many open streams")
+)
+
+// StreamChunk is the payload of every frame of a stream. Chunks are numbered from zero, the last one has End set,
+// and Error when the sender aborted the stream.
+type StreamChunk struct {
+	Stream string `json:"stream"`
+	Seq    int    `json:"seq"`
+	Data   []byte `json:"data,omitempty"`
+	End    bool   `json:"end,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Stream writes a large payload as a sequence of StreamChunk events, it implements io.WriteCloser.
+// Writes are buffered into StreamChunkSize chunks, Close sends the remainder and marks the end.
+type Stream struct {
+	client    *Client
+	eventName string
+	id        string
+
+	mu     sync.Mutex
+	seq    int
+	buf    []byte
+	closed bool
+}
+
+// OpenStream starts a stream of eventName events to the client, see Stream
+func (client *Client) OpenStream(eventName string) *Stream {
+	return &Stream{
+		client:    client,
+		eventName: eventName,
+		id:        CreateConnectionId(),
+		buf:       make([]byte, 0, StreamChunkSize),
+	}
+}
+
+// Id returns the identifier carried by every chunk of the stream
+func (stream *Stream) Id() string {
+	return stream.id
+}
+
+func (stream *Stream) Write(p []byte) (int, error) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if stream.closed {
+		return 0, ErrStreamClosed
+	}
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), StreamChunkSize-len(stream.buf))
+		stream.buf = append(stream.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(stream.buf) == StreamChunkSize {
+			if err := stream.send(StreamChunk{Data: stream.buf}); err != nil {
+				return written, err
+			}
+			stream.buf = make([]byte, 0, StreamChunkSize)
+		}
+	}
+	return written, nil
+}
+
+// Close sends the buffered data along with the end of the stream
+func (stream *Stream) Close() error {
+	return stream.CloseWithError(nil)
+}
+
+// CloseWithError ends the stream, a non-nil err tells the reader the stream is incomplete
+func (stream *Stream) CloseWithError(err error) error {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if stream.closed {
+		return nil
+	}
+	stream.closed = true
+	last := StreamChunk{Data: stream.buf, End: true}
+	if err != nil {
+		last = StreamChunk{End: true, Error: err.Error()}
+	}
+	stream.buf = nil
+	return stream.send(last)
+}
+
+// send emits a chunk, the caller holds stream.mu
+func (stream *Stream) send(chunk StreamChunk) error {
+	chunk.Stream = stream.id
+	chunk.Seq = stream.seq
+	stream.seq++
+	return stream.client.Emit(stream.eventName, chunk)
+}
+
+// StreamReader reassembles the chunks of a stream in sequence order, it implements io.Reader.
+// Clients written in Go push the chunks they receive, OnStream does it for the streams clients send.
+type StreamReader struct {
+	id string
+	// maxBuffered bounds the bytes received and not read yet, zero leaves them unbounded
+	maxBuffered int
+
+	mu      sync.Mutex
+	arrived *sync.Cond
+	next    int
+	pending map[int]StreamChunk
+	data    []byte
+	// buffered counts the bytes of data and of the pending chunks
+	buffered int
+	done     bool
+	err      error
+}
+
+// NewStreamReader creates a reader for the stream with the given id, it buffers whatever it receives
+// until read
+func NewStreamReader(id string) *StreamReader {
+	reader := &StreamReader{id: id, pending: make(map[int]StreamChunk)}
+	reader.arrived = sync.NewCond(&reader.mu)
+	return reader
+}
+
+// Id returns the identifier of the stream
+func (reader *StreamReader) Id() string {
+	return reader.id
+}
+
+// Push adds a received chunk, chunks may arrive out of order and duplicates are ignored
+func (reader *StreamReader) Push(chunk StreamChunk) error {
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	if reader.done || chunk.Seq < reader.next {
+		return nil
+	}
+	if len(reader.pending) >= maxPendingChunks {
+		reader.abort(ErrStreamOverflow)
+		return ErrStreamOverflow
+	}
+	if _, duplicate := reader.pending[chunk.Seq]; duplicate {
+		return nil
+	}
+	if reader.maxBuffered > 0 && reader.buffered+len(chunk.Data) > reader.maxBuffered {
+		reader.abort(ErrStreamBufferFull)
+		return ErrStreamBufferFull
+	}
+	reader.pending[chunk.Seq] = chunk
+	reader.buffered += len(chunk.Data)
+	for {
+		next, exists := reader.pending[reader.next]
+		if !exists {
+			break
+		}
+		delete(reader.pending, reader.next)
+		reader.next++
+		reader.data = append(reader.data, next.Data...)
+		if next.End {
+			reader.done = true
+			if next.Error != "" {
+				reader.err = errors.New(next.Error)
+			}
+			reader.pending = nil
+			break
+		}
+	}
+	reader.arrived.Broadcast()
+	return nil
+}
+
+// Abort ends the stream with err, pending and future reads fail once the received data is consumed
+func (reader *StreamReader) Abort(err error) {
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	reader.abort(err)
+}
+
+func (reader *StreamReader) abort(err error) {
+	if reader.done {
+		return
+	}
+	reader.done = true
+	reader.err = err
+	reader.pending = nil
+	reader.buffered = len(reader.data)
+	reader.arrived.Broadcast()
+}
+
+func (reader *StreamReader) finished() bool {
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	return reader.done
+}
+
+// Read blocks until data is available, it returns io.EOF once the stream ended
+func (reader *StreamReader) Read(p []byte) (int, error) {
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	for len(reader.data) == 0 && !reader.done {
+		reader.arrived.Wait()
+	}
+	if len(reader.data) == 0 {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, reader.data)
+	reader.data = reader.data[n:]
+	reader.buffered -= n
+	return n, nil
+}
+
+// StreamHandler consumes a stream sent by a client, it runs in its own goroutine
+type StreamHandler func(reader *StreamReader, client *Client)
+
+// StreamOption configures the limits of OnStream
+type StreamOption func(*streamLimits)
+
+type streamLimits struct {
+	maxOpen     int
+	maxBuffered int
+}
+
+// WithMaxOpenStreams bounds the streams a connection may have open at once, DefaultMaxOpenStreams by default.
+// The chunks of further streams are answered with ErrTooManyStreams.
+func WithMaxOpenStreams(streams int) StreamOption {
+	return func(limits *streamLimits) {
+		limits.maxOpen = streams
+	}
+}
+
+// WithMaxStreamBuffer bounds the bytes a stream buffers when its handler reads slower than the chunks
+// arrive, DefaultMaxStreamBuffer by default. The stream is aborted with ErrStreamBufferFull past it.
+func WithMaxStreamBuffer(bytes int) StreamOption {
+	return func(limits *streamLimits) {
+		limits.maxBuffered = bytes
+	}
+}
+
+// OnStream registers a handler for the streams clients send as eventName StreamChunk events.
+// The handler is started on the first chunk of every stream, streams still open when their
+// connection closes fail with ErrStreamAborted. Streams exceeding the limits are aborted and the
+// client receives an error event.
+func (socket *Server) OnStream(eventName string, handler StreamHandler, options ...StreamOption) {
+	limits := streamLimits{maxOpen: DefaultMaxOpenStreams, maxBuffered: DefaultMaxStreamBuffer}
+	for _, option := range options {
+		option(&limits)
+	}
+	var mu sync.Mutex
+	connections := make(map[string]*streamConnection)
+
+	socket.On(eventName, func(payload Payload, client *Client) {
+		var chunk StreamChunk
+		if err := Bind(payload, &chunk); err != nil {
+			client.EmitError(err)
+			return
+		}
+		if chunk.Stream == "" {
+			client.EmitError(NewError(CodeInvalidPayload, "stream: missing stream id"))
+			return
+		}
+
+		mu.Lock()
+		connection := connections[client.ConnectionId]
+		if connection == nil {
+			connection = &streamConnection{open: make(map[string]*StreamReader), finished: make(map[string]time.Time)}
+			connections[client.ConnectionId] = connection
+		}
+		reader, exists := connection.open[chunk.Stream]
+		if !exists {
+			if connection.hasFinished(chunk.Stream, time.Now()) {
+				// a late or duplicate chunk of a stream already handled
+				mu.Unlock()
+				return
+			}
+			if len(connection.open) >= limits.maxOpen {
+				mu.Unlock()
+				client.EmitError(NewError(CodeRateLimited, "stream "+chunk.Stream+": "+ErrTooManyStreams.Error()))
+				return
+			}
+			reader = NewStreamReader(chunk.Stream)
+			reader.maxBuffered = limits.maxBuffered
+			connection.open[chunk.Stream] = reader
+			go handler(reader, client)
+		}
+		mu.Unlock()
+
+		if err := reader.Push(chunk); err != nil {
+			client.EmitError(NewError(CodeInvalidPayload, "stream "+chunk.Stream+": "+err.Error()))
+		}
+		if reader.finished() {
+			mu.Lock()
+			if connection.open[chunk.Stream] == reader {
+				delete(connection.open, chunk.Stream)
+				connection.finish(chunk.Stream, time.Now())
+			}
+			mu.Unlock()
+		}
+	})
+
+	socket.OnStateChange(func(client *Client, from, to ConnectionState) {
+		if to != StateClosed {
+			return
+		}
+		mu.Lock()
+		connection := connections[client.ConnectionId]
+		delete(connections, client.ConnectionId)
+		mu.Unlock()
+		if connection == nil {
+			return
+		}
+		for _, reader := range connection.open {
+			reader.Abort(ErrStreamAborted)
+		}
+	})
+}
+
+// streamConnection holds the streams a connection has open and the ones it finished recently
+type streamConnection struct {
+	open     map[string]*StreamReader
+	finished map[string]time.Time
+}
+
+// hasFinished reports whether the stream finished less than finishedStreamTTL ago
+func (connection *streamConnection) hasFinished(stream string, now time.Time) bool {
+	at, exists := connection.finished[stream]
+	if exists && now.Sub(at) >= finishedStreamTTL {
+		delete(connection.finished, stream)
+		return false
+	}
+	return exists
+}
+
+// finish remembers the stream, forgetting the expired ones, and an arbitrary one when too many are remembered
+func (connection *streamConnection) finish(stream string, now time.Time) {
+	if len(connection.finished) >= maxFinishedStreams {
+		for id, at := range connection.finished {
+			if now.Sub(at) >= finishedStreamTTL {
+				delete(connection.finished, id)
+			}
+		}
+	}
+	if len(connection.finished) >= maxFinishedStreams {
+		for id := range connection.finished {
+			delete(connection.finished, id)
+			break
+		}
+	}
+	connection.finished[stream] = now
+}
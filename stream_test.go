@@ -0,0 +1,59 @@
+package signal
+
+import (
+	"io"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestOnStreamDropsLateChunks sends chunks of a stream after its end, they must not start another handler
+func TestOnStreamDropsLateChunks(t *testing.T) {
+	socket := IOServer("0")
+	var started atomic.Int32
+	read := make(chan string, 2)
+	socket.OnStream("upload", func(reader *StreamReader, client *Client) {
+		started.Add(1)
+		data, _ := io.ReadAll(reader)
+		read <- string(data)
+	})
+	client := connectClients(t, socket, 1)[0]
+	listener := socket.listeners["upload"]
+
+	listener(StreamChunk{Stream: "file", Seq: 0, Data: []byte("hello")}, client)
+	listener(StreamChunk{Stream: "file", Seq: 1, End: true}, client)
+	select {
+	case data := <-read:
+		if data != "hello" {
+			t.Fatalf("read %q", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream did not end")
+	}
+
+	listener(StreamChunk{Stream: "file", Seq: 1, End: true}, client)
+	listener(StreamChunk{Stream: "file", Seq: 2, Data: []byte("late")}, client)
+	time.Sleep(50 * time.Millisecond)
+	if started.Load() != 1 {
+		t.Fatalf("the handler started %d times", started.Load())
+	}
+}
+
+func TestStreamConnectionFinished(t *testing.T) {
+	connection := &streamConnection{open: make(map[string]*StreamReader), finished: make(map[string]time.Time)}
+	now := time.Now()
+	connection.finish("file", now)
+	if !connection.hasFinished("file", now.Add(finishedStreamTTL/2)) {
+		t.Fatal("a stream finished recently is not remembered")
+	}
+	if connection.hasFinished("file", now.Add(finishedStreamTTL)) {
+		t.Fatal("an expired stream is still remembered")
+	}
+	for i := 0; i < 2*maxFinishedStreams; i++ {
+		connection.finish(strconv.Itoa(i), now)
+	}
+	if len(connection.finished) > maxFinishedStreams {
+		t.Fatalf("%d finished streams remembered", len(connection.finished))
+	}
+}
@@ -0,0 +1,48 @@
+package signal
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// selectSubprotocol picks the subprotocol the upgrader will accept: the first one of the server list
+// requested by the client, or an empty string
+func (socket *Server) selectSubprotocol(r *http.Request) string {
+	requested := websocket.Subprotocols(r)
+	for _, offered := range socket.upgrader.Subprotocols {
+		if contains(requested, offered) {
+			return offered
+		}
+	}
+	return ""
+}
+
+// parseSubprotocol reads the protocol version and codec name of a "signal.v<version>.<codec>" subprotocol
+func parseSubprotocol(subprotocol string) (version int, codec string, ok bool) {
+	rest, found := strings.CutPrefix(subprotocol, "signal.v")
+	if !found {
+		return 0, "", false
+	}
+	number, codec, found := strings.Cut(rest, ".")
+	if !found || codec == "" {
+		return 0, "", false
+	}
+	version, err := strconv.Atoi(number)
+	if err != nil || version <= 0 {
+		return 0, "", false
+	}
+	return version, codec, true
+}
+
+// Subprotocol returns the WebSocket subprotocol negotiated with the client, empty when none was
+func (client *Client) Subprotocol() string {
+	if client.state == nil {
+		return ""
+	}
+	client.state.mu.RLock()
+	defer client.state.mu.RUnlock()
+	return client.state.subprotocol
+}
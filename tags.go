@@ -0,0 +1,90 @@
+package signal
+
+import "sort"
+
+// Tag labels the client with cross-cutting cohorts such as "beta", "admin" or "ios", see Server.EmitToTag.
+// Unlike rooms, tags carry no membership events, capacity or access control.
+func (client *Client) Tag(tags ...string) {
+	if client.state == nil {
+		client.state = newClientState(nil)
+	}
+	client.state.mu.Lock()
+	defer client.state.mu.Unlock()
+	if client.state.tags == nil {
+		client.state.tags = make(map[string]struct{}, len(tags))
+	}
+	for _, tag := range tags {
+		client.state.tags[tag] = struct{}{}
+	}
+}
+
+// Untag removes tags from the client
+func (client *Client) Untag(tags ...string) {
+	if client.state == nil {
+		return
+	}
+	client.state.mu.Lock()
+	defer client.state.mu.Unlock()
+	for _, tag := range tags {
+		delete(client.state.tags, tag)
+	}
+}
+
+// HasTag reports whether the client carries tag
+func (client *Client) HasTag(tag string) bool {
+	if client.state == nil {
+		return false
+	}
+	client.state.mu.RLock()
+	defer client.state.mu.RUnlock()
+	_, exists := client.state.tags[tag]
+	return exists
+}
+
+// Tags returns the tags of the client, sorted
+func (client *Client) Tags() []string {
+	if client.state == nil {
+		return nil
+	}
+	client.state.mu.RLock()
+	tags := make([]string, 0, len(client.state.tags))
+	for tag := range client.state.tags {
+		tags = append(tags, tag)
+	}
+	client.state.mu.RUnlock()
+	sort.Strings(tags)
+	return tags
+}
+
+// taggedClients returns the clients of this node carrying tag, restricted to tenant unless it is empty
+func (socket *Server) taggedClients(tenant, tag string) []*Client {
+	return filterClients(socket.broadcastClients(tenant), func(client *Client) bool {
+		return client.HasTag(tag)
+	})
+}
+
+// EmitToTag sends the event to every client carrying tag, on every node sharing the adapter
+func (socket *Server) EmitToTag(tag, eventName string, payload Payload) BroadcastResult {
+	result := socket.emitAll(socket.taggedClients("", tag), eventName, payload)
+	socket.publishPacket(clusterPacket{
+		Tag:     tag,
+		Message: Message{EventName: eventName, Payload: payload},
+	})
+	return result
+}
+
+// EmitToTag sends the event to the tenant clients carrying tag, on every node sharing the adapter
+func (tenant *Tenant) EmitToTag(tag, eventName string, payload Payload) BroadcastResult {
+	result := tenant.server.emitAll(tenant.server.taggedClients(tenant.Id, tag), eventName, payload)
+	tenant.server.publishPacket(clusterPacket{
+		Tenant:  tenant.Id,
+		Tag:     tag,
+		Message: Message{EventName: eventName, Payload: payload},
+	})
+	return result
+}
+
+// EmitToTag sends the event to the clients of this node carrying tag
+func (local *LocalEmitter) EmitToTag(tag, eventName string, payload Payload) BroadcastResult {
+	return local.server.emitAll(local.server.taggedClients(local.tenantId(), tag), eventName, payload)
+}
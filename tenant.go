@@ -0,0 +1,119 @@
+package signal
+
+import "errors"
+
+// ErrTenantMismatch is returned when a client tries to join a room of another tenant
+var ErrTenantMismatch = errors.New("room belongs to another tenant")
+
+// Tenant scopes rooms, broadcasts and counts to the clients of one tenant.
+// Rooms of different tenants never share members, even when their ids collide.
+type Tenant struct {
+	Id     string
+	server *Server
+}
+
+// roomKey is the registry key of a room, the separator cannot appear in sane room ids
+func roomKey(tenant, roomId string) string {
+	if tenant == "" {
+		return roomId
+	}
+	return tenant + "\x00" + roomId
+}
+
+// Tenant returns the view of the server restricted to tenantId
+func (socket *Server) Tenant(tenantId string) *Tenant {
+	return &Tenant{Id: tenantId, server: socket}
+}
+
+// Tenant returns the tenant the client belongs to, empty when tenancy is not used
+func (client *Client) Tenant() string {
+	if client.state == nil {
+		return ""
+	}
+	client.state.mu.RLock()
+	defer client.state.mu.RUnlock()
+	return client.state.tenant
+}
+
+// SetTenant assigns the client to a tenant, it is meant to be called by handshake middlewares
+// before the client joins any room
+func (client *Client) SetTenant(tenant string) {
+	if client.state == nil {
+		client.state = newClientState(nil)
+	}
+	client.state.mu.Lock()
+	defer client.state.mu.Unlock()
+	client.state.tenant = tenant
+}
+
+// broadcastClients returns the connected clients of the tenant, every client when tenant is empty.
+// The list may be the connection snapshot and must not be modified.
+func (socket *Server) broadcastClients(tenant string) []*Client {
+	clients := socket.snapshot()
+	if tenant == "" {
+		return clients
+	}
+	filtered := make([]*Client, 0, len(clients))
+	for _, client := range clients {
+		if client.Tenant() == tenant {
+			filtered = append(filtered, client)
+		}
+	}
+	return filtered
+}
+
+// BroadcastWhere sends the event to the tenant clients of this node for which match returns true
+func (tenant *Tenant) BroadcastWhere(match func(client *Client) bool, eventName string, payload Payload) BroadcastResult {
+	return tenant.server.emitAll(filterClients(tenant.server.broadcastClients(tenant.Id), match), eventName, payload)
+}
+
+// Room returns the tenant room registered under roomId, creating it when it does not exist yet
+func (tenant *Tenant) Room(roomId string) *Room {
+	return tenant.server.tenantRoom(tenant.Id, roomId)
+}
+
+// JoinRoom adds the client to the tenant room, clients of other tenants are rejected with ErrTenantMismatch
+func (tenant *Tenant) JoinRoom(roomId string, client *Client) error {
+	return tenant.Room(roomId).Join(client)
+}
+
+// LeaveRoom removes the client from the tenant room
+func (tenant *Tenant) LeaveRoom(roomId string, client *Client) {
+	if room := tenant.server.lookupRoom(tenant.Id, roomId); room != nil {
+		room.Leave(client.ConnectionId)
+	}
+}
+
+// EmitTo sends the event to the members of the tenant room
+func (tenant *Tenant) EmitTo(roomId, eventName string, payload Payload) BroadcastResult {
+	if room := tenant.server.lookupRoom(tenant.Id, roomId); room != nil {
+		return room.Emit(eventName, payload)
+	}
+	tenant.server.publish(tenant.Id, roomId, nil, eventName, payload)
+	return BroadcastResult{}
+}
+
+// Broadcast sends the event to every client of the tenant
+func (tenant *Tenant) Broadcast(eventName string, payload Payload) BroadcastResult {
+	result := tenant.server.emitAll(tenant.server.broadcastClients(tenant.Id), eventName, payload)
+	tenant.server.publish(tenant.Id, "", nil, eventName, payload)
+	return result
+}
+
+// Publish sends the event to the tenant rooms matching topic, see Server.Publish
+func (tenant *Tenant) Publish(topic, eventName string, payload Payload) BroadcastResult {
+	return tenant.server.publishTenantTopic(tenant.Id, topic, eventName, payload)
+}
+
+// Clients returns a snapshot of the connected clients of the tenant
+func (tenant *Tenant) Clients() []*Client {
+	if tenant.Id == "" {
+		return tenant.server.Clients()
+	}
+	return tenant.server.broadcastClients(tenant.Id)
+}
+
+// GetTotalConnections returns the number of connected clients of the tenant
+func (tenant *Tenant) GetTotalConnections() int {
+	return len(tenant.Clients())
+}
@@ -0,0 +1,82 @@
+package signal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+// SetHandlerTimeout overrides the timeout of the eventName listener, zero disables it
+func (socket *Server) SetHandlerTimeout(eventName string, timeout time.Duration) {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	if socket.handlerTimeouts == nil {
+		socket.handlerTimeouts = make(map[string]time.Duration)
+	}
+	socket.handlerTimeouts[eventName] = timeout
+}
+
+func (socket *Server) handlerTimeout(eventName string) time.Duration {
+	socket.mu.RLock()
+	defer socket.mu.RUnlock()
+	if timeout, exists := socket.handlerTimeouts[eventName]; exists {
+		return timeout
+	}
+	return socket.defaultHandlerTimeout
+}
+
+// callHandler runs the listener, when it has a timeout the read loop stops waiting for it once
+// the timeout expires and the context returned by client.Context() is canceled
+func (socket *Server) callHandler(event Event, message Message, client *Client) {
+	timeout := socket.handlerTimeout(message.EventName)
+	if timeout <= 0 {
+		socket.runHandler(event, message, client)
+		return
+	}
+
+	parent := client.Context()
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	client.setContext(ctx)
+	defer client.setContext(parent)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		socket.runHandler(event, message, client)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Handler timeout: event=%s connection=%s timeout=%s", message.EventName, client.ConnectionId, timeout)
+		socket.bus.Emit(InternalEvent{
+			Name:   InternalHandlerTimeout,
+			Client: client,
+			Event:  message.EventName,
+			Data:   timeout,
+		})
+	}
+}
+
+// runHandler calls the listener, a panic is reported on the internal bus and answered with CodeInternal
+// instead of ending the connection
+func (socket *Server) runHandler(event Event, message Message, client *Client) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			stack := debug.Stack()
+			log.Printf("Handler panic: event=%s connection=%s: %v\n%s", message.EventName, client.ConnectionId, recovered, stack)
+			socket.bus.Emit(InternalEvent{
+				Name:   InternalHandlerPanic,
+				Client: client,
+				Event:  message.EventName,
+				Err:    fmt.Errorf("panic: %v", recovered),
+				Data:   string(stack),
+			})
+			client.emitErrorFor(message, CodeInternal, "internal error")
+		}
+	}()
+	event(message.Payload, client)
+}
@@ -0,0 +1,65 @@
+package signal
+
+import "strings"
+
+// MatchTopic reports whether topic matches an MQTT style filter,
+// "+" matches exactly one level and a trailing "#" matches any number of levels
+func MatchTopic(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	for i, level := range filterLevels {
+		if level == "#" {
+			return i == len(filterLevels)-1
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+func isTopicFilter(roomId string) bool {
+	return strings.ContainsAny(roomId, "+#")
+}
+
+// Publish emits the event to every room whose id matches topic, room ids are read as MQTT style
+// filters so clients joining "sensors/+/temperature" receive what is published to "sensors/7/temperature".
+// A client member of several matching rooms receives the event once.
+func (socket *Server) Publish(topic, eventName string, payload Payload) BroadcastResult {
+	return socket.publishTenantTopic("", topic, eventName, payload)
+}
+
+func (socket *Server) publishTenantTopic(tenant, topic, eventName string, payload Payload) BroadcastResult {
+	result := socket.emitAll(socket.topicClients(tenant, topic), eventName, payload)
+	socket.publishTopic(tenant, topic, eventName, payload)
+	return result
+}
+
+// topicClients returns the clients of every room of the tenant matching topic, without duplicates
+func (socket *Server) topicClients(tenant, topic string) []*Client {
+	rooms := make([]*Room, 0)
+	for _, room := range socket.rooms.list() {
+		if room.Tenant != tenant {
+			continue
+		}
+		if room.Id == topic || (isTopicFilter(room.Id) && MatchTopic(room.Id, topic)) {
+			rooms = append(rooms, room)
+		}
+	}
+
+	seen := make(map[string]bool)
+	clients := make([]*Client, 0)
+	for _, room := range rooms {
+		for _, client := range room.Clients() {
+			if !seen[client.ConnectionId] {
+				seen[client.ConnectionId] = true
+				clients = append(clients, client)
+			}
+		}
+	}
+	return clients
+}
@@ -0,0 +1,67 @@
+package signal
+
+import "context"
+
+// Tracer creates the spans recorded around handshakes, inbound events and emits.
+// It is shaped to be backed by an OpenTelemetry tracer and propagator, traces cross the
+// websocket through the W3C traceparent carried in the message envelope.
+type Tracer interface {
+	// StartSpan starts a span as a child of the span carried by ctx
+	StartSpan(ctx context.Context, name string, attributes map[string]any) (context.Context, Span)
+	// Extract returns ctx carrying the remote span described by a traceparent value
+	Extract(ctx context.Context, traceparent string) context.Context
+	// Inject returns the traceparent value describing the span carried by ctx
+	Inject(ctx context.Context) string
+}
+
+// Span is a unit of traced work, err is nil when the work succeeded
+type Span interface {
+	End(err error)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(error) {}
+
+func (socket *Server) startSpan(ctx context.Context, name string, attributes map[string]any) (context.Context, Span) {
+	if socket == nil || socket.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return socket.tracer.StartSpan(ctx, name, attributes)
+}
+
+func (socket *Server) extractTrace(ctx context.Context, traceparent string) context.Context {
+	if socket.tracer == nil || traceparent == "" {
+		return ctx
+	}
+	return socket.tracer.Extract(ctx, traceparent)
+}
+
+func (socket *Server) injectTrace(ctx context.Context) string {
+	if socket == nil || socket.tracer == nil {
+		return ""
+	}
+	return socket.tracer.Inject(ctx)
+}
+
+// Context returns the context of the event being handled, carrying its trace when a Tracer is configured
+func (client *Client) Context() context.Context {
+	if client.state == nil {
+		return context.Background()
+	}
+	client.state.mu.RLock()
+	defer client.state.mu.RUnlock()
+	if client.state.ctx == nil {
+		return context.Background()
+	}
+	return client.state.ctx
+}
+
+func (client *Client) setContext(ctx context.Context) {
+	if client.state == nil {
+		return
+	}
+	client.state.mu.Lock()
+	defer client.state.mu.Unlock()
+	client.state.ctx = ctx
+}
@@ -0,0 +1,82 @@
+package signal
+
+import (
+	"errors"
+	"log"
+)
+
+// ErrSkipEmit is returned by an OutboundTransformer to not send the event to the client,
+// the emit is then neither delivered nor failed
+var ErrSkipEmit = errors.New("emit skipped")
+
+// OutboundTransformer rewrites an outgoing event for one client, e.g. to redact fields by role or
+// localize a notification, and returns the payload to send. Transformers must not modify the payload
+// they receive in place, it is shared by every recipient.
+type OutboundTransformer func(client *Client, eventName string, payload Payload) (Payload, error)
+
+// UseOutbound adds transformers applied, in order, to every event sent to a client.
+// Broadcasts then encode the message once per client instead of once in total.
+func (socket *Server) UseOutbound(transformers ...OutboundTransformer) {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	socket.outbound = append(socket.outbound, transformers...)
+}
+
+func (socket *Server) outboundTransformers() []OutboundTransformer {
+	if socket == nil {
+		return nil
+	}
+	socket.mu.RLock()
+	defer socket.mu.RUnlock()
+	return socket.outbound
+}
+
+// transform runs the outbound transformers on the message for client, translates it to the client protocol version
+// and signs it
+func (client *Client) transform(message Message) (Message, error) {
+	for _, transformer := range client.server().outboundTransformers() {
+		payload, err := transformer(client, message.EventName, message.Payload)
+		if err != nil {
+			return message, err
+		}
+		message.Payload = payload
+	}
+	message, err := client.translateOutbound(message)
+	if err != nil {
+		return message, err
+	}
+	return client.sign(message)
+}
+
+// encodesPerClient reports whether broadcasts must be encoded for each client rather than once
+func (socket *Server) encodesPerClient() bool {
+	return len(socket.outboundTransformers()) > 0 || socket.protocol.enabled() || socket.signing != nil && socket.signing.Sign
+}
+
+// emitEach transforms and encodes the message for every client, it is the broadcast path used
+// when outbound transformers are configured
+func (socket *Server) emitEach(clients []*Client, message Message, result *BroadcastResult) {
+	for _, client := range clients {
+		transformed, err := client.transform(message)
+		if errors.Is(err, ErrSkipEmit) {
+			continue
+		}
+		if err != nil {
+			result.fail(client.ConnectionId, err)
+			continue
+		}
+		buf, err := encodeMessage(client.codec(), transformed)
+		if err != nil {
+			log.Printf("Marshal error: %v", err)
+			result.fail(client.ConnectionId, err)
+			continue
+		}
+		err = client.write(buf.Bytes())
+		putBuffer(buf)
+		if err != nil {
+			result.fail(client.ConnectionId, err)
+			continue
+		}
+		result.Delivered++
+	}
+}
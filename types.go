@@ -1,8 +1,12 @@
 package signal
 
 import (
+	"context"
 	"net/http"
+	"net/netip"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -10,19 +14,97 @@ import (
 type Payload any
 
 type Message struct {
-	EventName string  `json:"eventName"`
-	Payload   Payload `json:"payload"`
+	Id          string  `json:"id,omitempty"`
+	EventName   string  `json:"eventName"`
+	Payload     Payload `json:"payload"`
+	TraceParent string  `json:"traceparent,omitempty"`
+	// Version is the protocol version of the payload, see WithProtocolVersion
+	Version int `json:"v,omitempty"`
+	// Room and Seq stamp room emits with the room sequence number, see WithRoomSequences
+	Room string `json:"room,omitempty"`
+	Seq  uint64 `json:"seq,omitempty"`
+	// Timestamp in Unix milliseconds and Signature authenticate the message, see WithSigning
+	Timestamp int64  `json:"ts,omitempty"`
+	Signature string `json:"sig,omitempty"`
 }
 
-type Event = func(Payload, Client)
+type Event = func(Payload, *Client)
 
-type signalIO struct {
-	wsPort      string
-	listeners   map[string]Event
-	connections []Client
-	rooms       map[string][]Client
+// AnyEvent is a listener receiving every event sent by clients, see OnAny
+type AnyEvent = func(eventName string, payload Payload, client *Client)
 
-	mu sync.Mutex
+type Server struct {
+	wsPort                string
+	listeners             map[string]Event
+	anyListeners          []AnyEvent
+	stateListeners        []StateListener
+	upgradeErrorHandlers  []UpgradeErrorHandler
+	bus                   *Bus
+	middlewares           []Middleware
+	upgradeHeaders        []UpgradeHeaders
+	credentialExtractors  []CredentialExtractor
+	outbound              []OutboundTransformer
+	conflated             map[string]ConflationKey
+	priorities            map[string]Priority
+	plugins               []Plugin
+	authorizer            authorizer
+	roleResolver          func(client *Client) []string
+	canJoin               func(roomId string, client *Client) error
+	canEmit               func(roomId string, client *Client) error
+	tenantResolver        func(client *Client) (string, error)
+	userResolver          func(client *Client) string
+	quota                 quota
+	blocklist             BlocklistStore
+	auditor               *auditor
+	drainPolicy           DrainPolicy
+	affinity              *Affinity
+	signing               *Signing
+	replyDecodeErrors     bool
+	roomSequences         bool
+	eventLog              EventLog
+	writeTimeout          time.Duration
+	retry                 *RetryPolicy
+	breaker               *CircuitBreaker
+	coalesceWindow        time.Duration
+	defaultHandlerTimeout time.Duration
+	handlerTimeouts       map[string]time.Duration
+	validators            map[string]Validator
+	eventDocs             map[string][]EventDoc
+	scheduler             *scheduler
+	delivery              *deliveries
+	dedup                 *deduplicator
+	offline               *offlineQueues
+	notifications         NotificationStore
+	watchers              roomWatchers
+	deadLetters           DeadLetterSink
+	httpServer            *http.Server
+	debugServer           *http.Server
+	debugAddr             string
+	debugHandler          func(socket *Server) http.Handler
+	ctx                   context.Context
+	cancel                context.CancelFunc
+	connections           []*Client
+	rooms                 *roomRegistry
+	idGenerator           func(r *http.Request) string
+	codec                 Codec
+	codecs                map[string]Codec
+	protocol              protocol
+	adapter               Adapter
+	cluster               *cluster
+	nodeId                string
+	tracer                Tracer
+	metrics               metrics
+	statsInterval         time.Duration
+	listening             atomic.Bool
+	draining              atomic.Bool
+	// handoffs holds the sessions handed off by draining peers, see DrainPolicy.Handoff
+	handoffs       handoffs
+	writesInFlight atomic.Int64
+	chaos          atomic.Pointer[Chaos]
+	upgrader       websocket.Upgrader
+	trustedProxies []netip.Prefix
+
+	mu sync.RWMutex
 }
 
 type Client struct {
@@ -31,4 +113,6 @@ type Client struct {
 	Query        map[string]string
 	Socket       *websocket.Conn
 	HTTPRequest  *http.Request
+
+	state *clientState
 }
@@ -0,0 +1,188 @@
+package signal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// typescriptClient is the typed wrapper emitted after the event maps, it speaks the {eventName, payload} JSON envelope
+const typescriptClient = `export class SignalClient {
+  private handlers = new Map<string, Set<(payload: any) => void>>();
+
+  constructor(private socket: WebSocket) {
+    socket.addEventListener("message", (message) => {
+      const { eventName, payload } = JSON.parse(message.data);
+      this.handlers.get(eventName)?.forEach((handler) => handler(payload));
+    });
+  }
+
+  emit<E extends keyof InboundEvents>(eventName: E, payload: InboundEvents[E]): void {
+    this.socket.send(JSON.stringify({ eventName, payload }));
+  }
+
+  on<E extends keyof OutboundEvents>(eventName: E, handler: (payload: OutboundEvents[E]) => void): () => void {
+    if (!this.handlers.has(eventName)) {
+      this.handlers.set(eventName, new Set());
+    }
+    this.handlers.get(eventName)!.add(handler);
+    return () => this.handlers.get(eventName)?.delete(handler);
+  }
+}
+`
+
+// typescriptGenerator names and declares the struct types reachable from the event payloads
+type typescriptGenerator struct {
+	names        map[reflect.Type]string
+	taken        map[string]bool
+	declarations []string
+}
+
+// TypeScript writes TypeScript interfaces for the payloads of the event catalog, the InboundEvents
+// and OutboundEvents maps and a typed SignalClient wrapper around a browser WebSocket, see Events.
+// It is meant to run from a small go:generate program building the server without starting it.
+func (socket *Server) TypeScript(w io.Writer) error {
+	generator := &typescriptGenerator{names: make(map[reflect.Type]string), taken: make(map[string]bool)}
+
+	inbound, outbound := make([]string, 0), make([]string, 0)
+	seen := make(map[string]bool)
+	for _, spec := range socket.Events() {
+		key := spec.Name + "\x00" + string(spec.Direction)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		entry := fmt.Sprintf("  %s: %s;", strconv.Quote(spec.Name), generator.typeOf(spec.PayloadType))
+		if spec.Direction == Outbound {
+			outbound = append(outbound, entry)
+		} else {
+			inbound = append(inbound, entry)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by signal.io TypeScript; DO NOT EDIT.\n\n")
+	for _, declaration := range generator.declarations {
+		out.WriteString(declaration)
+		out.WriteString("\n")
+	}
+	writeEventMap(&out, "InboundEvents", inbound)
+	writeEventMap(&out, "OutboundEvents", outbound)
+	out.WriteString(typescriptClient)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// WriteTypeScript writes the output of TypeScript to a file
+func (socket *Server) WriteTypeScript(path string) error {
+	var out bytes.Buffer
+	if err := socket.TypeScript(&out); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), 0o644)
+}
+
+func writeEventMap(out *bytes.Buffer, name string, entries []string) {
+	fmt.Fprintf(out, "export interface %s {\n", name)
+	for _, entry := range entries {
+		out.WriteString(entry)
+		out.WriteString("\n")
+	}
+	out.WriteString("}\n\n")
+}
+
+// typeOf returns the TypeScript type of the JSON encoding of t, declaring the named structs it meets
+func (generator *typescriptGenerator) typeOf(t reflect.Type) string {
+	if t == nil {
+		return "unknown"
+	}
+	if t.Kind() == reflect.Pointer {
+		return generator.typeOf(t.Elem()) + " | null"
+	}
+	if t == timeType {
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+		element := generator.typeOf(t.Elem())
+		if strings.Contains(element, " ") {
+			element = "(" + element + ")"
+		}
+		return element + "[]"
+	case reflect.Map:
+		return "Record<string, " + generator.typeOf(t.Elem()) + ">"
+	case reflect.Struct:
+		if t.Name() == "" {
+			return generator.fields(t, true)
+		}
+		return generator.declare(t)
+	}
+	return "unknown"
+}
+
+// declare emits the interface of a named struct once and returns its name
+func (generator *typescriptGenerator) declare(t reflect.Type) string {
+	if name, exists := generator.names[t]; exists {
+		return name
+	}
+	name := t.Name()
+	if generator.taken[name] {
+		// same name in another package
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	generator.names[t] = name
+	generator.taken[name] = true
+
+	// reserve the slot so nested types are declared after this one
+	index := len(generator.declarations)
+	generator.declarations = append(generator.declarations, "")
+	generator.declarations[index] = "export interface " + name + " " + generator.fields(t, false) + "\n"
+	return name
+}
+
+// fields returns the object type of the struct fields, on a single line for anonymous structs
+func (generator *typescriptGenerator) fields(t reflect.Type, inline bool) string {
+	separator, last := "\n  ", "\n"
+	if inline {
+		separator, last = " ", " "
+	}
+	var out strings.Builder
+	out.WriteString("{")
+	for _, field := range jsonFields(t) {
+		optional := ""
+		if field.optional {
+			optional = "?"
+		}
+		fmt.Fprintf(&out, "%s%s%s: %s;", separator, typescriptKey(field.name), optional, generator.typeOf(field.typ))
+	}
+	out.WriteString(last + "}")
+	return out.String()
+}
+
+// typescriptKey quotes property names that are not valid identifiers
+func typescriptKey(name string) string {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
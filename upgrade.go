@@ -0,0 +1,31 @@
+package signal
+
+import (
+	"log"
+	"net/http"
+)
+
+// UpgradeErrorHandler is called when a handshake that passed every check fails the WebSocket upgrade,
+// such as a request missing the upgrade headers. The HTTP error response is already written.
+type UpgradeErrorHandler = func(r *http.Request, err error)
+
+// OnUpgradeError registers a handler called on every failed upgrade, to count or inspect bad handshakes
+func (socket *Server) OnUpgradeError(handler UpgradeErrorHandler) {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	socket.upgradeErrorHandlers = append(socket.upgradeErrorHandlers, handler)
+}
+
+// upgradeFailed reports a failed upgrade, the upgrader answered the request with an HTTP error so
+// only this handshake ends
+func (socket *Server) upgradeFailed(r *http.Request, client *Client, err error) {
+	log.Printf("Upgrade error: remote=%s: %v", r.RemoteAddr, err)
+	socket.audit(AuditRejected, client, "", err)
+
+	socket.mu.RLock()
+	handlers := socket.upgradeErrorHandlers
+	socket.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(r, err)
+	}
+}
@@ -0,0 +1,85 @@
+package signal
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Validator checks the payload of an inbound event before it reaches its listeners.
+// JSON Schema libraries can be plugged in through ValidatorFunc.
+type Validator interface {
+	Validate(payload Payload) error
+}
+
+// ValidatorFunc adapts a function to Validator
+type ValidatorFunc func(payload Payload) error
+
+func (validate ValidatorFunc) Validate(payload Payload) error {
+	return validate(payload)
+}
+
+// Validatable is implemented by payload types checking their own fields, see Schema
+type Validatable interface {
+	Validate() error
+}
+
+// schema validates a payload against a Go type
+type schema struct {
+	typ reflect.Type
+}
+
+// Schema returns a Validator checking the payload can be bound to a T, see Bind,
+// then calling its Validate method when T implements Validatable
+func Schema[T any]() Validator {
+	return schema{typ: reflect.TypeFor[T]()}
+}
+
+func (s schema) Validate(payload Payload) error {
+	value := reflect.New(s.typ)
+	if err := Bind(payload, value.Interface()); err != nil {
+		return err
+	}
+	if validatable, ok := value.Interface().(Validatable); ok {
+		return validatable.Validate()
+	}
+	if validatable, ok := value.Elem().Interface().(Validatable); ok {
+		return validatable.Validate()
+	}
+	return nil
+}
+
+// SetValidator registers the validator of eventName. Invalid payloads are answered with
+// an ErrorEvent, CodeInvalidPayload unless the validator returns an *Error, and never reach the listeners.
+func (socket *Server) SetValidator(eventName string, validator Validator) {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	if socket.validators == nil {
+		socket.validators = make(map[string]Validator)
+	}
+	socket.validators[eventName] = validator
+}
+
+func (socket *Server) validator(eventName string) Validator {
+	socket.mu.RLock()
+	defer socket.mu.RUnlock()
+	return socket.validators[eventName]
+}
+
+// validate checks the message payload, replying to the client when it is invalid
+func (socket *Server) validate(message Message, client *Client) bool {
+	validator := socket.validator(message.EventName)
+	if validator == nil {
+		return true
+	}
+	err := validator.Validate(message.Payload)
+	if err == nil {
+		return true
+	}
+	var envelope *Error
+	if errors.As(err, &envelope) {
+		client.emitErrorFor(message, envelope.Code, envelope.Message)
+	} else {
+		client.emitErrorFor(message, CodeInvalidPayload, err.Error())
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package signal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// ErrUnsupportedVersion is returned when a client asks for a protocol version older than the minimum supported,
+// or sends a message whose version is not supported
+var ErrUnsupportedVersion = errors.New("unsupported protocol version")
+
+// VersionParam is the handshake query parameter carrying the protocol version of the client
+const VersionParam = "v"
+
+// VersionHeader is the upgrade response header carrying the negotiated protocol version
+const VersionHeader = "X-Signal-Version"
+
+// Translator converts payloads between an older protocol version and the current one
+type Translator struct {
+	// Inbound converts a payload sent by a client of this version into the current format
+	Inbound func(eventName string, payload Payload) (Payload, error)
+	// Outbound converts a payload in the current format into the format of this version
+	Outbound func(eventName string, payload Payload) (Payload, error)
+}
+
+// protocol holds the versioning configuration, versioning is off while current is zero
+type protocol struct {
+	current     int
+	minimum     int
+	translators map[int]Translator
+}
+
+func (p protocol) enabled() bool {
+	return p.current > 0
+}
+
+// negotiate picks the version of a connection from the handshake, clients not sending one get the minimum,
+// clients newer than the server get the current version
+func (p protocol) negotiate(requested string) (int, error) {
+	if requested == "" {
+		return p.minimum, nil
+	}
+	version, err := strconv.Atoi(requested)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrUnsupportedVersion, requested)
+	}
+	if version < p.minimum {
+		return 0, fmt.Errorf("%w: %d, the oldest supported is %d", ErrUnsupportedVersion, version, p.minimum)
+	}
+	return min(version, p.current), nil
+}
+
+// SetTranslator registers the translator between version and the current protocol version
+func (socket *Server) SetTranslator(version int, translator Translator) {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	if socket.protocol.translators == nil {
+		socket.protocol.translators = make(map[int]Translator)
+	}
+	socket.protocol.translators[version] = translator
+}
+
+func (socket *Server) translator(version int) (Translator, bool) {
+	socket.mu.RLock()
+	defer socket.mu.RUnlock()
+	translator, exists := socket.protocol.translators[version]
+	return translator, exists
+}
+
+// ProtocolVersion returns the protocol version negotiated with the client, zero when versioning is off
+func (client *Client) ProtocolVersion() int {
+	if client.state == nil {
+		return 0
+	}
+	client.state.mu.RLock()
+	defer client.state.mu.RUnlock()
+	return client.state.version
+}
+
+// negotiateVersion stores the protocol version requested in the handshake query or subprotocol, and announces it in the upgrade response header
+func (socket *Server) negotiateVersion(client *Client, r *http.Request, header http.Header) error {
+	if !socket.protocol.enabled() {
+		return nil
+	}
+	requested := r.URL.Query().Get(VersionParam)
+	if version, _, ok := parseSubprotocol(client.Subprotocol()); ok && requested == "" {
+		requested = strconv.Itoa(version)
+	}
+	version, err := socket.protocol.negotiate(requested)
+	if err != nil {
+		return err
+	}
+	client.state.mu.Lock()
+	client.state.version = version
+	client.state.mu.Unlock()
+	header.Set(VersionHeader, strconv.Itoa(version))
+	return nil
+}
+
+// translateInbound converts a message from the client version, or the version of its envelope, to the current one.
+// Envelope versions outside the supported range, or without an inbound translator, are rejected with ErrUnsupportedVersion.
+func (socket *Server) translateInbound(client *Client, message *Message) error {
+	if !socket.protocol.enabled() {
+		return nil
+	}
+	version := message.Version
+	if version == 0 {
+		version = client.ProtocolVersion()
+	} else if version < socket.protocol.minimum || version > socket.protocol.current {
+		return fmt.Errorf("%w: %d, the supported are %d to %d", ErrUnsupportedVersion, version, socket.protocol.minimum, socket.protocol.current)
+	}
+	if version == socket.protocol.current {
+		return nil
+	}
+	translator, exists := socket.translator(version)
+	if !exists || translator.Inbound == nil {
+		if message.Version != 0 {
+			return fmt.Errorf("%w: %d, no translator is registered", ErrUnsupportedVersion, version)
+		}
+		return nil
+	}
+	payload, err := translator.Inbound(message.EventName, message.Payload)
+	if err != nil {
+		return err
+	}
+	message.Payload = payload
+	return nil
+}
+
+// translateOutbound converts a message to the client version and stamps the envelope with it
+func (client *Client) translateOutbound(message Message) (Message, error) {
+	server := client.server()
+	if server == nil || !server.protocol.enabled() {
+		return message, nil
+	}
+	version := client.ProtocolVersion()
+	message.Version = version
+	if version == server.protocol.current {
+		return message, nil
+	}
+	translator, exists := server.translator(version)
+	if !exists || translator.Outbound == nil {
+		return message, nil
+	}
+	payload, err := translator.Outbound(message.EventName, message.Payload)
+	if err != nil {
+		return message, err
+	}
+	message.Payload = payload
+	return message, nil
+}
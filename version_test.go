@@ -0,0 +1,37 @@
+package signal
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTranslateInboundVersions(t *testing.T) {
+	socket := IOServer("0", WithProtocolVersion(3, 1))
+	socket.SetTranslator(2, Translator{Inbound: func(eventName string, payload Payload) (Payload, error) {
+		return "translated", nil
+	}})
+	client := &Client{}
+
+	for _, test := range []struct {
+		version int
+		payload Payload
+		err     error
+	}{
+		{version: 0, payload: "sent"},
+		{version: 3, payload: "sent"},
+		{version: 2, payload: "translated"},
+		{version: 1, err: ErrUnsupportedVersion},
+		{version: 4, err: ErrUnsupportedVersion},
+		{version: -1, err: ErrUnsupportedVersion},
+	} {
+		message := Message{EventName: "message", Payload: "sent", Version: test.version}
+		err := socket.translateInbound(client, &message)
+		if !errors.Is(err, test.err) {
+			t.Errorf("version %d: error %v, want %v", test.version, err, test.err)
+			continue
+		}
+		if err == nil && message.Payload != test.payload {
+			t.Errorf("version %d: payload %v, want %v", test.version, message.Payload, test.payload)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package signal
+
+import "sync"
+
+// RoomWatcher receives the emits reaching a room on this node, it runs on the emitting goroutine and must not block
+type RoomWatcher func(message Message)
+
+// roomWatchers holds the watchers by room key, they outlive the rooms so emits published by other nodes
+// reach them while the room has no local member
+type roomWatchers struct {
+	mu       sync.RWMutex
+	watchers map[string][]*roomWatcher
+}
+
+type roomWatcher struct {
+	fn RoomWatcher
+}
+
+// Watch calls watcher with every emit to roomId seen by this node, including those published by other nodes,
+// without joining the room. It returns a function removing the watcher.
+func (socket *Server) Watch(roomId string, watcher RoomWatcher) (unwatch func()) {
+	return socket.watch("", roomId, watcher)
+}
+
+// Watch calls watcher with every emit to the tenant room seen by this node, see Server.Watch
+func (tenant *Tenant) Watch(roomId string, watcher RoomWatcher) (unwatch func()) {
+	return tenant.server.watch(tenant.Id, roomId, watcher)
+}
+
+func (socket *Server) watch(tenant, roomId string, watcher RoomWatcher) func() {
+	key := roomKey(tenant, roomId)
+	entry := &roomWatcher{fn: watcher}
+
+	watchers := &socket.watchers
+	watchers.mu.Lock()
+	if watchers.watchers == nil {
+		watchers.watchers = make(map[string][]*roomWatcher)
+	}
+	watchers.watchers[key] = append(watchers.watchers[key], entry)
+	watchers.mu.Unlock()
+	socket.declareInterest(tenant, roomId)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			watchers.mu.Lock()
+			defer watchers.mu.Unlock()
+			remaining := make([]*roomWatcher, 0, len(watchers.watchers[key]))
+			for _, other := range watchers.watchers[key] {
+				if other != entry {
+					remaining = append(remaining, other)
+				}
+			}
+			if len(remaining) == 0 {
+				delete(watchers.watchers, key)
+				return
+			}
+			watchers.watchers[key] = remaining
+		})
+	}
+}
+
+// notifyWatchers passes a room emit to the watchers of the room
+func (socket *Server) notifyWatchers(key string, message Message) {
+	socket.watchers.mu.RLock()
+	watchers := socket.watchers.watchers[key]
+	socket.watchers.mu.RUnlock()
+	for _, watcher := range watchers {
+		watcher.fn(message)
+	}
+}
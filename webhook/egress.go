@@ -0,0 +1,322 @@
+// Package webhook connects a signal.io server to HTTP backends: client events and room milestones are
+// POSTed to registered endpoints, signed and retried, and webhooks received from providers such as GitHub
+// or Stripe are verified and emitted to rooms.
+//
+//	hooks := webhook.New(socket)
+//	hooks.Add(webhook.Endpoint{
+//		URL:        "https://billing.internal/hooks/signal",
+//		Secret:     os.Getenv("WEBHOOK_SECRET"),
+//		Events:     []string{"order:placed"},
+//		Milestones: []string{signal.InternalRoomClosed},
+//	})
+//
+// Receivers check the X-Signal-Signature header with Verify. In the other direction:
+//
+//	stripe := webhook.NewReceiver(socket, webhook.Stripe(os.Getenv("STRIPE_WEBHOOK_SECRET"), 5*time.Minute)).
+//		Route("invoice.*", webhook.Route{Room: "billing:{data.object.customer}"})
+//	http.Handle("/webhooks/stripe", stripe)
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// Endpoint is an HTTP endpoint receiving deliveries
+type Endpoint struct {
+	URL string
+	// Secret keys the signature of the deliveries, they are not signed when it is empty
+	Secret string
+	// Events lists the client events delivered, "*" delivering every event
+	Events []string
+	// Milestones lists the internal events delivered, such as signal.InternalRoomCreated and signal.InternalRoomClosed
+	Milestones []string
+	// Header is added to every request, for example to authenticate with the endpoint
+	Header http.Header
+}
+
+// Delivery is the JSON body POSTed to endpoints
+type Delivery struct {
+	Id           string         `json:"id"`
+	Event        string         `json:"event"`
+	Time         time.Time      `json:"time"`
+	Tenant       string         `json:"tenant,omitempty"`
+	Room         string         `json:"room,omitempty"`
+	ConnectionId string         `json:"connectionId,omitempty"`
+	UserId       string         `json:"userId,omitempty"`
+	Payload      signal.Payload `json:"payload,omitempty"`
+	// Error is the error of an internal event
+	Error string `json:"error,omitempty"`
+}
+
+// FailureFunc is called with the deliveries an endpoint did not accept after every attempt, or that were
+// dropped because the queue was full
+type FailureFunc func(endpoint Endpoint, delivery Delivery, err error)
+
+// StatusError is an endpoint answering with a status other than 2xx
+type StatusError struct {
+	StatusCode int
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("webhook: endpoint answered %d", err.StatusCode)
+}
+
+// retryable reports whether the endpoint may accept the delivery later
+func (err *StatusError) retryable() bool {
+	return err.StatusCode >= 500 || err.StatusCode == http.StatusTooManyRequests || err.StatusCode == http.StatusRequestTimeout
+}
+
+// ErrQueueFull is passed to the FailureFunc of deliveries dropped because the queue was full
+var ErrQueueFull = errors.New("webhook: delivery queue is full")
+
+// Option configures a Dispatcher
+type Option func(*Dispatcher)
+
+// WithHTTPClient sets the client POSTing the deliveries, its Timeout bounds every attempt
+func WithHTTPClient(client *http.Client) Option {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.client = client
+	}
+}
+
+// WithRetry sets the attempts made per delivery, the first one included, and the delay before the first
+// retry, doubled after every attempt up to a minute. 5 attempts starting at one second by default.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.attempts = attempts
+		dispatcher.backoff = backoff
+	}
+}
+
+// WithWorkers sets how many deliveries are sent concurrently, 4 by default.
+// Deliveries to an endpoint may arrive out of order with more than one worker.
+func WithWorkers(workers int) Option {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.workers = workers
+	}
+}
+
+// WithQueueSize sets how many deliveries wait for a worker, further ones are dropped, 1000 by default
+func WithQueueSize(size int) Option {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.queueSize = size
+	}
+}
+
+// WithFailure sets the function called with the deliveries that failed for good
+func WithFailure(failure FailureFunc) Option {
+	return func(dispatcher *Dispatcher) {
+		dispatcher.failure = failure
+	}
+}
+
+// Dispatcher POSTs events to the registered endpoints
+type Dispatcher struct {
+	socket    *signal.Server
+	client    *http.Client
+	attempts  int
+	backoff   time.Duration
+	workers   int
+	queueSize int
+	failure   FailureFunc
+	queue     chan job
+	// ctx is cancelled when the server shuts down, aborting the deliveries in flight
+	ctx context.Context
+
+	mu        sync.RWMutex
+	endpoints []Endpoint
+}
+
+type job struct {
+	endpoint Endpoint
+	delivery Delivery
+}
+
+// New creates a dispatcher listening to the events of socket, its workers stop when the server shuts down
+func New(socket *signal.Server, options ...Option) *Dispatcher {
+	dispatcher := &Dispatcher{
+		socket:    socket,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		attempts:  5,
+		backoff:   time.Second,
+		workers:   4,
+		queueSize: 1000,
+	}
+	for _, option := range options {
+		option(dispatcher)
+	}
+	dispatcher.queue = make(chan job, dispatcher.queueSize)
+	ctx, cancel := context.WithCancel(context.Background())
+	dispatcher.ctx = ctx
+	go func() {
+		<-socket.Done()
+		cancel()
+	}()
+	for range dispatcher.workers {
+		go dispatcher.work()
+	}
+	socket.OnAny(dispatcher.onEvent)
+	socket.Internal().On("*", dispatcher.onMilestone)
+	return dispatcher
+}
+
+// Add registers an endpoint
+func (dispatcher *Dispatcher) Add(endpoint Endpoint) {
+	dispatcher.mu.Lock()
+	defer dispatcher.mu.Unlock()
+	dispatcher.endpoints = append(dispatcher.endpoints, endpoint)
+}
+
+// Remove unregisters the endpoints posting to url
+func (dispatcher *Dispatcher) Remove(url string) {
+	dispatcher.mu.Lock()
+	defer dispatcher.mu.Unlock()
+	dispatcher.endpoints = slices.DeleteFunc(slices.Clone(dispatcher.endpoints), func(endpoint Endpoint) bool {
+		return endpoint.URL == url
+	})
+}
+
+func (dispatcher *Dispatcher) onEvent(eventName string, payload signal.Payload, client *signal.Client) {
+	delivery := Delivery{
+		Event:        eventName,
+		Tenant:       client.Tenant(),
+		ConnectionId: client.ConnectionId,
+		UserId:       client.UserId(),
+		Payload:      payload,
+	}
+	dispatcher.dispatch(delivery, func(endpoint Endpoint) bool {
+		return slices.Contains(endpoint.Events, eventName) || slices.Contains(endpoint.Events, "*")
+	})
+}
+
+func (dispatcher *Dispatcher) onMilestone(event signal.InternalEvent) {
+	delivery := Delivery{Event: event.Name, Payload: event.Data}
+	if event.Err != nil {
+		delivery.Error = event.Err.Error()
+	}
+	if event.Room != nil {
+		delivery.Tenant, delivery.Room = event.Room.Tenant, event.Room.Id
+	}
+	if event.Client != nil {
+		delivery.Tenant, delivery.ConnectionId, delivery.UserId = event.Client.Tenant(), event.Client.ConnectionId, event.Client.UserId()
+	}
+	dispatcher.dispatch(delivery, func(endpoint Endpoint) bool {
+		return slices.Contains(endpoint.Milestones, event.Name)
+	})
+}
+
+// dispatch queues the delivery for the matching endpoints, it never blocks the caller
+func (dispatcher *Dispatcher) dispatch(delivery Delivery, match func(endpoint Endpoint) bool) {
+	dispatcher.mu.RLock()
+	endpoints := dispatcher.endpoints
+	dispatcher.mu.RUnlock()
+
+	for _, endpoint := range endpoints {
+		if !match(endpoint) {
+			continue
+		}
+		if delivery.Id == "" {
+			delivery.Id, delivery.Time = newId(), time.Now()
+		}
+		select {
+		case dispatcher.queue <- job{endpoint: endpoint, delivery: delivery}:
+		default:
+			dispatcher.fail(endpoint, delivery, ErrQueueFull)
+		}
+	}
+}
+
+func (dispatcher *Dispatcher) work() {
+	for {
+		select {
+		case job := <-dispatcher.queue:
+			if err := dispatcher.deliver(job.endpoint, job.delivery); err != nil {
+				dispatcher.fail(job.endpoint, job.delivery, err)
+			}
+		case <-dispatcher.ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver POSTs the delivery until the endpoint accepts it, the attempts run out or the server shuts down
+func (dispatcher *Dispatcher) deliver(endpoint Endpoint, delivery Delivery) error {
+	body, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	backoff := dispatcher.backoff
+	for attempt := 1; ; attempt++ {
+		err = dispatcher.post(endpoint, delivery, body)
+		if err == nil {
+			return nil
+		}
+		var status *StatusError
+		if errors.As(err, &status) && !status.retryable() || attempt >= dispatcher.attempts {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-dispatcher.ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff = min(2*backoff, time.Minute)
+	}
+}
+
+func (dispatcher *Dispatcher) post(endpoint Endpoint, delivery Delivery, body []byte) error {
+	request, err := http.NewRequestWithContext(dispatcher.ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range endpoint.Header {
+		request.Header[key] = values
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderId, delivery.Id)
+	request.Header.Set(HeaderEvent, delivery.Event)
+	if endpoint.Secret != "" {
+		now := time.Now()
+		request.Header.Set(HeaderTimestamp, fmt.Sprint(now.Unix()))
+		request.Header.Set(HeaderSignature, Sign(endpoint.Secret, now, body))
+	}
+
+	response, err := dispatcher.client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return &StatusError{StatusCode: response.StatusCode}
+	}
+	return nil
+}
+
+func (dispatcher *Dispatcher) fail(endpoint Endpoint, delivery Delivery, err error) {
+	if dispatcher.failure != nil {
+		dispatcher.failure(endpoint, delivery, err)
+		return
+	}
+	log.Printf("webhook: delivery %s of %s to %s failed: %v", delivery.Id, delivery.Event, endpoint.URL, err)
+}
+
+func newId() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,290 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// Provider authenticates the requests of a webhook sender and tells their event type
+type Provider interface {
+	Verify(r *http.Request, body []byte) error
+	EventType(r *http.Request, body []byte) (string, error)
+}
+
+// Signed accepts the deliveries of a Dispatcher signed with secret, see Verify
+func Signed(secret string, tolerance time.Duration) Provider {
+	return signedProvider{secret: secret, tolerance: tolerance}
+}
+
+type signedProvider struct {
+	secret    string
+	tolerance time.Duration
+}
+
+func (provider signedProvider) Verify(r *http.Request, body []byte) error {
+	return Verify(r, body, provider.secret, provider.tolerance)
+}
+
+func (provider signedProvider) EventType(r *http.Request, body []byte) (string, error) {
+	return r.Header.Get(HeaderEvent), nil
+}
+
+// GitHub accepts GitHub webhooks signed with secret, the event type is the X-GitHub-Event header
+// followed by the action of the payload when it has one, such as "pull_request.opened"
+func GitHub(secret string) Provider {
+	return githubProvider{secret: secret}
+}
+
+type githubProvider struct {
+	secret string
+}
+
+func (provider githubProvider) Verify(r *http.Request, body []byte) error {
+	mac := hmac.New(sha256.New, []byte(provider.secret))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(r.Header.Get("X-Hub-Signature-256")), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (provider githubProvider) EventType(r *http.Request, body []byte) (string, error) {
+	event := r.Header.Get("X-GitHub-Event")
+	if event == "" {
+		return "", errors.New("missing X-GitHub-Event header")
+	}
+	var payload struct {
+		Action string `json:"action"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Action != "" {
+		event += "." + payload.Action
+	}
+	return event, nil
+}
+
+// Stripe accepts Stripe webhooks signed with the endpoint secret, rejecting those signed more than tolerance
+// ago. The event type is the type of the Stripe event, such as "invoice.paid".
+func Stripe(secret string, tolerance time.Duration) Provider {
+	return stripeProvider{secret: secret, tolerance: tolerance}
+}
+
+type stripeProvider struct {
+	secret    string
+	tolerance time.Duration
+}
+
+func (provider stripeProvider) Verify(r *http.Request, body []byte) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(r.Header.Get("Stripe-Signature"), ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if provider.tolerance > 0 && time.Since(time.Unix(unix, 0)).Abs() > provider.tolerance {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(provider.secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	// several signatures are sent while the endpoint secret is rolled
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func (provider stripeProvider) EventType(r *http.Request, body []byte) (string, error) {
+	var event struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil || event.Type == "" {
+		return "", errors.New("missing event type")
+	}
+	return event.Type, nil
+}
+
+// Route tells where the webhooks of an event type are emitted
+type Route struct {
+	Tenant string
+	// Room receiving the event, it may reference fields of the JSON body, "orders:{data.object.customer}".
+	// The event is broadcast to every client of the tenant when it is empty.
+	Room string
+	// Event is the name emitted to clients, defaults to the webhook event type
+	Event string
+}
+
+// ReceiverOption configures a Receiver
+type ReceiverOption func(*Receiver)
+
+// WithMaxBody limits the size of the accepted bodies, 1 MiB by default
+func WithMaxBody(bytes int64) ReceiverOption {
+	return func(receiver *Receiver) {
+		receiver.maxBody = bytes
+	}
+}
+
+// Receiver is an http.Handler emitting the webhooks of a provider to rooms
+type Receiver struct {
+	socket   *signal.Server
+	provider Provider
+	maxBody  int64
+
+	mu     sync.RWMutex
+	routes map[string]Route
+}
+
+// NewReceiver creates a receiver authenticating requests with provider, mount it on an HTTP path
+func NewReceiver(socket *signal.Server, provider Provider, options ...ReceiverOption) *Receiver {
+	receiver := &Receiver{
+		socket:   socket,
+		provider: provider,
+		maxBody:  1 << 20,
+		routes:   make(map[string]Route),
+	}
+	for _, option := range options {
+		option(receiver)
+	}
+	return receiver
+}
+
+// Route maps an event type to a room. A pattern ending with "*" matches the types starting with its prefix,
+// the longest matching pattern wins. Webhooks without a route are acknowledged and dropped.
+func (receiver *Receiver) Route(pattern string, route Route) *Receiver {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	receiver.routes[pattern] = route
+	return receiver
+}
+
+func (receiver *Receiver) route(eventType string) (Route, bool) {
+	receiver.mu.RLock()
+	defer receiver.mu.RUnlock()
+	if route, ok := receiver.routes[eventType]; ok {
+		return route, true
+	}
+	var best string
+	var found Route
+	var ok bool
+	for pattern, route := range receiver.routes {
+		prefix, wildcard := strings.CutSuffix(pattern, "*")
+		if wildcard && strings.HasPrefix(eventType, prefix) && (!ok || len(pattern) > len(best)) {
+			best, found, ok = pattern, route, true
+		}
+	}
+	return found, ok
+}
+
+// ServeHTTP verifies the webhook and emits it to the room of its route
+func (receiver *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, receiver.maxBody))
+	if err != nil {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := receiver.provider.Verify(r, body); err != nil {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	eventType, err := receiver.provider.EventType(r, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	route, ok := receiver.route(eventType)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "body is not JSON", http.StatusBadRequest)
+		return
+	}
+	roomId, err := expand(route.Room, payload)
+	if err != nil {
+		// the sender would retry in vain, the webhook is acknowledged
+		log.Printf("webhook: %s: %v", eventType, err)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	eventName := route.Event
+	if eventName == "" {
+		eventName = eventType
+	}
+	tenant := receiver.socket.Tenant(route.Tenant)
+	if roomId == "" {
+		tenant.Broadcast(eventName, payload)
+	} else {
+		tenant.EmitTo(roomId, eventName, payload)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// expand replaces the {path} references of template with the fields of the JSON body
+func expand(template string, body any) (string, error) {
+	var expanded strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start == -1 {
+			expanded.WriteString(template)
+			return expanded.String(), nil
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end == -1 {
+			return "", fmt.Errorf("unterminated reference in %q", template)
+		}
+		path := template[start+1 : start+end]
+		value := body
+		for _, key := range strings.Split(path, ".") {
+			object, ok := value.(map[string]any)
+			if !ok {
+				value = nil
+				break
+			}
+			value = object[key]
+		}
+		switch value := value.(type) {
+		case string:
+			expanded.WriteString(template[:start])
+			expanded.WriteString(value)
+		case float64:
+			expanded.WriteString(template[:start])
+			expanded.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+		default:
+			return "", fmt.Errorf("field %s is not a string nor a number", path)
+		}
+		template = template[start+end+1:]
+	}
+}
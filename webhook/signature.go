@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers set on every delivery
+const (
+	HeaderId        = "X-Signal-Id"
+	HeaderEvent     = "X-Signal-Event"
+	HeaderTimestamp = "X-Signal-Timestamp"
+	HeaderSignature = "X-Signal-Signature"
+)
+
+// ErrInvalidSignature is returned by Verify when the signature does not match the body or is too old
+var ErrInvalidSignature = errors.New("webhook: invalid signature")
+
+// Sign returns the signature of a delivery: "sha256=" followed by the hex HMAC-SHA256, keyed with secret,
+// of the unix timestamp, a dot and the body. Signing the timestamp keeps a captured request from being replayed later.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature headers of a delivery received by r against its body, rejecting deliveries
+// signed more than tolerance ago, zero leaving the age unchecked
+func Verify(r *http.Request, body []byte, secret string, tolerance time.Duration) error {
+	unix, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	timestamp := time.Unix(unix, 0)
+	if tolerance > 0 && time.Since(timestamp).Abs() > tolerance {
+		return ErrInvalidSignature
+	}
+	signature := r.Header.Get(HeaderSignature)
+	if !strings.HasPrefix(signature, "sha256=") || !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Package webrtc relays WebRTC signaling between the peers of a room: offers, answers and ICE candidates
+// are forwarded to their target with the sender identified by the server, so peers cannot impersonate
+// each other.
+//
+//	webrtc.New(socket)
+//
+// Clients emit JoinEvent with a room id to enter a call, and receive PeersEvent with the connection ids
+// already there, who receive PeerJoinedEvent. Peers then exchange OfferEvent, AnswerEvent and
+// CandidateEvent with a Signal payload naming the target in To, and receive them with From set.
+// PeerLeftEvent is sent when a peer emits LeaveEvent or disconnects.
+package webrtc
+
+import (
+	"slices"
+	"sync"
+
+	signal "github.com/Syntax0xError/signal.io-golang"
+)
+
+// Events exchanged with clients
+const (
+	JoinEvent       = "rtc:join"
+	LeaveEvent      = "rtc:leave"
+	PeersEvent      = "rtc:peers"
+	PeerJoinedEvent = "rtc:peer-joined"
+	PeerLeftEvent   = "rtc:peer-left"
+	OfferEvent      = "rtc:offer"
+	AnswerEvent     = "rtc:answer"
+	CandidateEvent  = "rtc:candidate"
+)
+
+// Signal is the payload of the offers, answers and candidates. Data is the session description or the
+// ICE candidate, relayed as is.
+type Signal struct {
+	Room string `json:"room"`
+	// To is the connection id of the target peer, every other peer of the room when empty
+	To string `json:"to,omitempty"`
+	// From is the connection id of the sender, set by the server
+	From string `json:"from,omitempty"`
+	Data any    `json:"data"`
+}
+
+// Peers is the payload of PeersEvent, PeerJoinedEvent and PeerLeftEvent
+type Peers struct {
+	Room  string   `json:"room"`
+	Peers []string `json:"peers"`
+}
+
+// Relay forwards the signaling of the calls of a server
+type Relay struct {
+	socket *signal.Server
+
+	mu sync.Mutex
+	// calls holds the rooms each connection joined with JoinEvent
+	calls map[string][]string
+}
+
+// New registers the signaling events on socket
+func New(socket *signal.Server) *Relay {
+	relay := &Relay{socket: socket, calls: make(map[string][]string)}
+	socket.On(JoinEvent, relay.onJoin)
+	socket.On(LeaveEvent, relay.onLeave)
+	for _, event := range []string{OfferEvent, AnswerEvent, CandidateEvent} {
+		socket.On(event, relay.forward(event))
+	}
+	socket.OnStateChange(func(client *signal.Client, from, to signal.ConnectionState) {
+		if to != signal.StateClosed {
+			return
+		}
+		relay.mu.Lock()
+		rooms := relay.calls[client.ConnectionId]
+		delete(relay.calls, client.ConnectionId)
+		relay.mu.Unlock()
+		for _, roomId := range rooms {
+			relay.left(client, roomId)
+		}
+	})
+	return relay
+}
+
+func (relay *Relay) onJoin(payload signal.Payload, client *signal.Client) {
+	roomId, ok := payload.(string)
+	if !ok || roomId == "" {
+		client.EmitError(signal.NewError(signal.CodeInvalidPayload, "webrtc: expected a room id"))
+		return
+	}
+	room := relay.socket.Tenant(client.Tenant()).Room(roomId)
+	peers := relay.peers(room, client)
+	if err := room.Join(client); err != nil {
+		client.EmitError(err)
+		return
+	}
+	relay.mu.Lock()
+	if !slices.Contains(relay.calls[client.ConnectionId], roomId) {
+		relay.calls[client.ConnectionId] = append(relay.calls[client.ConnectionId], roomId)
+	}
+	relay.mu.Unlock()
+
+	client.Emit(PeersEvent, Peers{Room: roomId, Peers: peers})
+	room.Except(client.ConnectionId).Emit(PeerJoinedEvent, Peers{Room: roomId, Peers: []string{client.ConnectionId}})
+}
+
+func (relay *Relay) onLeave(payload signal.Payload, client *signal.Client) {
+	roomId, ok := payload.(string)
+	if !ok {
+		client.EmitError(signal.NewError(signal.CodeInvalidPayload, "webrtc: expected a room id"))
+		return
+	}
+	relay.mu.Lock()
+	rooms := relay.calls[client.ConnectionId]
+	joined := slices.Contains(rooms, roomId)
+	relay.calls[client.ConnectionId] = slices.DeleteFunc(rooms, func(room string) bool { return room == roomId })
+	relay.mu.Unlock()
+	if !joined {
+		return
+	}
+	relay.socket.Tenant(client.Tenant()).LeaveRoom(roomId, client)
+	relay.left(client, roomId)
+}
+
+// left tells the remaining peers of the room the client left
+func (relay *Relay) left(client *signal.Client, roomId string) {
+	relay.socket.Tenant(client.Tenant()).EmitTo(roomId, PeerLeftEvent, Peers{Room: roomId, Peers: []string{client.ConnectionId}})
+}
+
+// peers returns the connection ids of the other members of the room on this node
+func (relay *Relay) peers(room *signal.Room, client *signal.Client) []string {
+	peers := []string{}
+	for _, member := range room.Clients() {
+		if member.ConnectionId != client.ConnectionId {
+			peers = append(peers, member.ConnectionId)
+		}
+	}
+	return peers
+}
+
+// forward relays a signal to its target, both peers being members of the room
+func (relay *Relay) forward(event string) signal.Event {
+	return func(payload signal.Payload, client *signal.Client) {
+		var message Signal
+		if err := signal.Bind(payload, &message); err != nil {
+			client.EmitError(err)
+			return
+		}
+		if !slices.Contains(client.Rooms(), message.Room) {
+			client.EmitError(signal.NewError(signal.CodeUnauthorized, "webrtc: not a member of "+message.Room))
+			return
+		}
+		room := relay.socket.Tenant(client.Tenant()).Room(message.Room)
+		members := room.Clients()
+		message.From = client.ConnectionId
+
+		if message.To == "" {
+			room.Except(client.ConnectionId).Emit(event, message)
+			return
+		}
+		// peers connected to this node must be members, the others are reached through the cluster
+		if _, local := relay.socket.Client(message.To); local && signal.IndexOf(message.To, members) == -1 {
+			client.EmitError(signal.NewError(signal.CodeUnauthorized, "webrtc: "+message.To+" is not in "+message.Room))
+			return
+		}
+		if err := relay.socket.EmitToClient(message.To, event, message); err != nil {
+			client.EmitError(signal.NewError(signal.CodeInvalidPayload, "webrtc: "+err.Error()))
+		}
+	}
+}
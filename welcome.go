@@ -0,0 +1,38 @@
+package signal
+
+// WelcomeEvent is the event carrying the payload of the room welcome to a joining client, with Room set
+// to the room id and Seq to the room sequence number the snapshot is current as of
+const WelcomeEvent = "welcome"
+
+// WelcomeFunc returns the initial state sent to a client joining a room, such as the current document or
+// the player list, nil sends nothing
+type WelcomeFunc func(client *Client) Payload
+
+// SetWelcome registers the snapshot emitted to every client joining the room, before the emits that
+// follow its join, so clients apply the deltas on top of it. With room sequences, deltas above the
+// Seq of the welcome are the ones missing from the snapshot.
+func (room *Room) SetWelcome(welcome WelcomeFunc) *Room {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	room.welcome = welcome
+	return room
+}
+
+// sendWelcome emits the welcome of the room the client was just added to
+func (room *Room) sendWelcome(client *Client) {
+	room.mu.RLock()
+	welcome := room.welcome
+	room.mu.RUnlock()
+	if welcome == nil {
+		return
+	}
+
+	// no emit to the room is sequenced meanwhile, the snapshot matches its sequence number
+	room.emitMu.Lock()
+	defer room.emitMu.Unlock()
+	payload := welcome(client)
+	if payload == nil {
+		return
+	}
+	client.emitMessage(Message{EventName: WelcomeEvent, Payload: payload, Room: room.Id, Seq: room.Seq()}, client.writeTimeout())
+}